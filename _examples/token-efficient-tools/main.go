@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+func main() {
+	ctx := context.Background()
+
+	// Build a tool-heavy request: many tools and a prompt that makes the model
+	// call several of them
+	var tools []models.Tool
+	for _, city := range []string{"london", "paris", "tokyo", "new_york", "sydney", "berlin", "toronto", "madrid"} {
+		tools = append(tools,
+			models.NewTool("get_weather_"+city, "Get the current weather in "+city, models.SimpleJSONSchema(
+				map[string]models.Property{
+					"unit":    models.NewEnumProperty("Temperature unit", []string{"celsius", "fahrenheit"}),
+					"details": models.NewProperty("boolean", "Include humidity and wind"),
+				},
+				[]string{"unit"},
+			)),
+			models.NewTool("get_time_"+city, "Get the current local time in "+city, models.SimpleJSONSchema(
+				map[string]models.Property{
+					"format": models.NewEnumProperty("Time format", []string{"12h", "24h"}),
+				},
+				[]string{"format"},
+			)),
+		)
+	}
+
+	req := models.MessageRequest{
+		Model:     models.Claude37Sonnet,
+		MaxTokens: 2048,
+		Tools:     tools,
+		Messages: []models.MessageParam{
+			models.NewUserMessage(models.CreateTextBlock(
+				"Get the weather in celsius with details and the 24h time for London, Paris, Tokyo and Sydney.",
+			)),
+		},
+	}
+
+	// Send the same request with and without the beta and compare the usage
+	standard := anthropic.NewClient()
+	efficient := standard.Clone(anthropic.WithTokenEfficientTools())
+
+	baseline, err := run(ctx, standard, req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	beta, err := run(ctx, efficient, req)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%-24s %12s %12s %10s\n", "", "input tokens", "output tokens", "tool calls")
+	fmt.Printf("%-24s %12d %12d %10d\n", "standard", baseline.usage.InputTokens, baseline.usage.OutputTokens, baseline.calls)
+	fmt.Printf("%-24s %12d %12d %10d\n", "token-efficient-tools", beta.usage.InputTokens, beta.usage.OutputTokens, beta.calls)
+	fmt.Printf("\ninput tokens saved:  %d (%.1f%%)\n", baseline.usage.InputTokens-beta.usage.InputTokens,
+		percent(baseline.usage.InputTokens-beta.usage.InputTokens, baseline.usage.InputTokens))
+	fmt.Printf("output tokens saved: %d (%.1f%%)\n", baseline.usage.OutputTokens-beta.usage.OutputTokens,
+		percent(baseline.usage.OutputTokens-beta.usage.OutputTokens, baseline.usage.OutputTokens))
+}
+
+type result struct {
+	usage models.Usage
+	calls int
+}
+
+func run(ctx context.Context, client *anthropic.Client, req models.MessageRequest) (result, error) {
+	resp, err := client.CreateMessage(ctx, req)
+	if err != nil {
+		return result{}, err
+	}
+
+	calls := 0
+	for _, block := range resp.Content {
+		if block.ToolUseContent != nil {
+			calls++
+		}
+	}
+	return result{usage: resp.Usage, calls: calls}, nil
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
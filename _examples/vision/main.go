@@ -14,7 +14,11 @@ func main() {
 	// Create client
 	client := anthropic.NewClient()
 
-	imagePath := "\\rickastley\\nevergonnagiveyouup.png"
+	// Pass the image to describe as the first argument
+	imagePath := filepath.Join("images", "example.png")
+	if len(os.Args) > 1 {
+		imagePath = os.Args[1]
+	}
 
 	// Encode image
 	imageData, mediaType, err := models.Base64EncodeImage(imagePath)
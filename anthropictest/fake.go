@@ -0,0 +1,171 @@
+// Package anthropictest provides utilities for testing code built on the SDK
+// without calling the API
+package anthropictest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ErrNoResponses is returned when a fake model has run out of scripted responses
+var ErrNoResponses = errors.New("anthropictest: no scripted responses left")
+
+// ErrNoMessage is returned for a scripted response with neither a message nor
+// an error, such as the zero Response returned by a Fallback
+var ErrNoMessage = errors.New("anthropictest: scripted response has no message")
+
+// Response is a scripted response of a fake model
+type Response struct {
+	Message *models.Message
+	Err     error
+
+	// Latency delays the response, overriding the model's latency
+	Latency time.Duration
+}
+
+// Text returns a response ending the turn with the given text
+func Text(text string) Response {
+	return Reply(models.EndTurn, models.CreateTextBlock(text))
+}
+
+// ToolCall returns a response calling a tool with the given input
+func ToolCall(name string, input interface{}) Response {
+	return Reply(models.ToolUse, models.CreateToolUseBlock("", name, input))
+}
+
+// Reply returns a response with the given stop reason and content. Tool use
+// blocks without an ID are given one when the response is served
+func Reply(stopReason models.StopReason, content ...models.ContentBlock) Response {
+	return Response{
+		Message: &models.Message{
+			Type:       "message",
+			Role:       models.AssistantRole,
+			Content:    content,
+			StopReason: stopReason,
+		},
+	}
+}
+
+// Error returns a response failing with the given error
+func Error(err error) Response {
+	return Response{Err: err}
+}
+
+// WithLatency returns the response with a simulated latency
+func (r Response) WithLatency(latency time.Duration) Response {
+	r.Latency = latency
+	return r
+}
+
+// FakeModel is a ChatProvider returning scripted responses in order. It is
+// safe for concurrent use
+type FakeModel struct {
+	// Latency delays every response, simulating network and generation time
+	Latency time.Duration
+
+	// Fallback produces a response when the script is exhausted, if set
+	Fallback func(req models.MessageRequest) Response
+
+	mu        sync.Mutex
+	responses []Response
+	requests  []models.MessageRequest
+	served    int
+}
+
+var _ anthropic.ChatProvider = (*FakeModel)(nil)
+
+// NewFakeModel creates a fake model with the given scripted responses
+func NewFakeModel(responses ...Response) *FakeModel {
+	return &FakeModel{responses: responses}
+}
+
+// Script appends responses to the script
+func (f *FakeModel) Script(responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, responses...)
+}
+
+// CreateMessage implements the anthropic.ChatProvider interface
+func (f *FakeModel) CreateMessage(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*models.Message, error) {
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	var response Response
+	switch {
+	case len(f.responses) > 0:
+		response, f.responses = f.responses[0], f.responses[1:]
+	case f.Fallback != nil:
+		response = f.Fallback(req)
+	default:
+		f.mu.Unlock()
+		return nil, ErrNoResponses
+	}
+	f.served++
+	sequence := f.served
+	f.mu.Unlock()
+
+	latency := f.Latency
+	if response.Latency > 0 {
+		latency = response.Latency
+	}
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if response.Err != nil {
+		return nil, response.Err
+	}
+	if response.Message == nil {
+		return nil, ErrNoMessage
+	}
+	return materialize(response.Message, req, sequence), nil
+}
+
+// Requests returns the requests received so far
+func (f *FakeModel) Requests() []models.MessageRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]models.MessageRequest(nil), f.requests...)
+}
+
+// Remaining returns the number of scripted responses not served yet
+func (f *FakeModel) Remaining() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.responses)
+}
+
+// materialize copies a scripted message, filling in IDs and the model
+func materialize(message *models.Message, req models.MessageRequest, sequence int) *models.Message {
+	msg := *message
+	if msg.ID == "" {
+		msg.ID = fmt.Sprintf("msg_fake_%d", sequence)
+	}
+	if msg.Model == "" {
+		msg.Model = req.Model
+	}
+
+	msg.Content = make([]models.ContentBlock, len(message.Content))
+	for i, block := range message.Content {
+		if block.ToolUseContent != nil && block.ToolUseContent.ID == "" {
+			toolUse := *block.ToolUseContent
+			toolUse.ID = fmt.Sprintf("toolu_fake_%d_%d", sequence, i)
+			block.ToolUseContent = &toolUse
+		}
+		msg.Content[i] = block
+	}
+
+	return &msg
+}
@@ -0,0 +1,299 @@
+package anthropictest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ArgsMatcher checks the input of a tool call. It returns an empty string if
+// the input matches, or a description of the mismatch
+type ArgsMatcher func(input map[string]interface{}) string
+
+// Args matches tool inputs containing at least the given values
+func Args(expected map[string]interface{}) ArgsMatcher {
+	return func(input map[string]interface{}) string {
+		var problems []string
+		for key, want := range expected {
+			got, ok := input[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("missing %q", key))
+				continue
+			}
+			if !jsonEqual(want, got) {
+				problems = append(problems, fmt.Sprintf("%q is %s, want %s", key, toJSON(got), toJSON(want)))
+			}
+		}
+		return strings.Join(problems, ", ")
+	}
+}
+
+// step is a single step of a scenario
+type step struct {
+	description string
+	run         func(r *scenarioRun) *ScenarioError
+}
+
+// Scenario declares an expected agent interaction, such as "user says X, the
+// model calls tool Y with arguments Z, the final answer contains W", and runs
+// it against a fake model or the live API
+type Scenario struct {
+	Name  string
+	steps []step
+}
+
+// NewScenario creates a new scenario
+func NewScenario(name string) *Scenario {
+	return &Scenario{Name: name}
+}
+
+// User adds a user turn
+func (s *Scenario) User(text string) *Scenario {
+	s.steps = append(s.steps, step{
+		description: fmt.Sprintf("user says %q", text),
+		run: func(r *scenarioRun) *ScenarioError {
+			content := append(r.pendingResults, models.CreateTextBlock(text))
+			r.pendingResults = nil
+			r.messages = append(r.messages, models.NewUserMessage(content...))
+			r.needsCall = true
+			return nil
+		},
+	})
+	return s
+}
+
+// ExpectToolCall expects the model to call the named tool with input accepted
+// by all matchers
+func (s *Scenario) ExpectToolCall(name string, matchers ...ArgsMatcher) *Scenario {
+	s.steps = append(s.steps, step{
+		description: fmt.Sprintf("model calls %s", name),
+		run: func(r *scenarioRun) *ScenarioError {
+			if err := r.call(); err != nil {
+				return err
+			}
+
+			var calls []string
+			for _, block := range r.response.Content {
+				toolUse := block.ToolUseContent
+				if toolUse == nil || r.answered[toolUse.ID] {
+					continue
+				}
+
+				var input map[string]interface{}
+				_ = toolUse.DecodeInput(&input)
+				calls = append(calls, fmt.Sprintf("%s %s", toolUse.Name, toJSON(input)))
+				if toolUse.Name != name {
+					continue
+				}
+
+				var problems []string
+				for _, matcher := range matchers {
+					if problem := matcher(input); problem != "" {
+						problems = append(problems, problem)
+					}
+				}
+				if len(problems) > 0 {
+					return &ScenarioError{
+						Expected: fmt.Sprintf("tool call %s with matching arguments", name),
+						Actual:   fmt.Sprintf("tool call %s %s (%s)", name, toJSON(input), strings.Join(problems, ", ")),
+					}
+				}
+
+				r.lastToolUse = toolUse
+				return nil
+			}
+
+			actual := "no tool call, answered: " + r.response.Text()
+			if len(calls) > 0 {
+				actual = "tool calls " + strings.Join(calls, "; ")
+			}
+			return &ScenarioError{Expected: "tool call " + name, Actual: actual}
+		},
+	})
+	return s
+}
+
+// ToolResult answers the tool call matched by the previous ExpectToolCall
+func (s *Scenario) ToolResult(content string) *Scenario {
+	s.steps = append(s.steps, step{
+		description: "tool returns a result",
+		run: func(r *scenarioRun) *ScenarioError {
+			if r.lastToolUse == nil {
+				return &ScenarioError{Expected: "a matched tool call to answer", Actual: "none"}
+			}
+			id := r.lastToolUse.ID
+			r.answered[id] = true
+			r.pendingResults = append(r.pendingResults, models.CreateToolResultBlock(id, content, false))
+			r.lastToolUse = nil
+
+			if r.allAnswered() {
+				r.messages = append(r.messages, models.NewUserMessage(r.pendingResults...))
+				r.pendingResults = nil
+				r.needsCall = true
+			}
+			return nil
+		},
+	})
+	return s
+}
+
+// ExpectAnswerContains expects the model to end its turn with text containing
+// all the given substrings
+func (s *Scenario) ExpectAnswerContains(substrings ...string) *Scenario {
+	s.steps = append(s.steps, step{
+		description: fmt.Sprintf("answer contains %q", substrings),
+		run: func(r *scenarioRun) *ScenarioError {
+			if err := r.call(); err != nil {
+				return err
+			}
+
+			if r.response.StopReason == models.ToolUse {
+				return &ScenarioError{
+					Expected: fmt.Sprintf("final answer containing %q", substrings),
+					Actual:   "stopped to use tools",
+				}
+			}
+
+			text := r.response.Text()
+			var missing []string
+			for _, substring := range substrings {
+				if !strings.Contains(text, substring) {
+					missing = append(missing, substring)
+				}
+			}
+			if len(missing) > 0 {
+				return &ScenarioError{
+					Expected: fmt.Sprintf("final answer containing %q", missing),
+					Actual:   text,
+				}
+			}
+			return nil
+		},
+	})
+	return s
+}
+
+// Run executes the scenario against a provider, using base for the model,
+// system prompt and tools of every request
+func (s *Scenario) Run(ctx context.Context, provider anthropic.ChatProvider, base models.MessageRequest) error {
+	r := &scenarioRun{
+		ctx:      ctx,
+		provider: provider,
+		base:     base,
+		messages: append([]models.MessageParam(nil), base.Messages...),
+		answered: make(map[string]bool),
+	}
+
+	for i, step := range s.steps {
+		if err := step.run(r); err != nil {
+			err.Scenario = s.Name
+			err.Step = i + 1
+			err.Description = step.description
+			return err
+		}
+	}
+	return nil
+}
+
+// Test runs the scenario and fails the test if it does not pass
+func (s *Scenario) Test(t testing.TB, provider anthropic.ChatProvider, base models.MessageRequest) {
+	t.Helper()
+	if err := s.Run(context.Background(), provider, base); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// ScenarioError describes the step at which a scenario failed
+type ScenarioError struct {
+	Scenario    string
+	Step        int
+	Description string
+	Expected    string
+	Actual      string
+	Err         error
+}
+
+// Error implements the error interface, formatting the failure as a diff
+func (e *ScenarioError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "scenario %q failed at step %d (%s)", e.Scenario, e.Step, e.Description)
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+		return b.String()
+	}
+	fmt.Fprintf(&b, ":\n- expected: %s\n+ actual:   %s", e.Expected, e.Actual)
+	return b.String()
+}
+
+// Unwrap returns the underlying error
+func (e *ScenarioError) Unwrap() error {
+	return e.Err
+}
+
+// scenarioRun holds the state of a running scenario
+type scenarioRun struct {
+	ctx            context.Context
+	provider       anthropic.ChatProvider
+	base           models.MessageRequest
+	messages       []models.MessageParam
+	response       *models.Message
+	needsCall      bool
+	pendingResults []models.ContentBlock
+	answered       map[string]bool
+	lastToolUse    *models.ToolUseBlock
+}
+
+// call sends the conversation if it changed since the last response
+func (r *scenarioRun) call() *ScenarioError {
+	if !r.needsCall {
+		if r.response == nil {
+			return &ScenarioError{Expected: "a model response", Actual: "no request was sent yet"}
+		}
+		return nil
+	}
+
+	req := r.base
+	req.Messages = r.messages
+	resp, err := r.provider.CreateMessage(r.ctx, req)
+	if err != nil {
+		return &ScenarioError{Err: err}
+	}
+
+	r.response = resp
+	r.messages = append(r.messages, resp.ToParam())
+	r.needsCall = false
+	return nil
+}
+
+// allAnswered reports whether every tool call of the last response has a result
+func (r *scenarioRun) allAnswered() bool {
+	for _, block := range r.response.Content {
+		if block.ToolUseContent != nil && !r.answered[block.ToolUseContent.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonEqual compares two values by their JSON encoding
+func jsonEqual(a, b interface{}) bool {
+	var na, nb interface{}
+	_ = json.Unmarshal([]byte(toJSON(a)), &na)
+	_ = json.Unmarshal([]byte(toJSON(b)), &nb)
+	return reflect.DeepEqual(na, nb)
+}
+
+// toJSON encodes a value as JSON for messages
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
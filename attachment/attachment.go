@@ -0,0 +1,224 @@
+// Package attachment turns files into content blocks. The file type is
+// detected from its content and name, images become image blocks, PDFs
+// document blocks and text files plain text document blocks, so apps can
+// attach bundled assets or files from any storage behind an fs.FS or
+// io.Reader without handling each type themselves
+package attachment
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultMaxImageBytes is the largest image accepted by default, the
+	// API's limit for a single image
+	DefaultMaxImageBytes = 5 << 20
+
+	// DefaultMaxDocumentBytes is the largest PDF or text file accepted by
+	// default, the API's limit for a request
+	DefaultMaxDocumentBytes = 32 << 20
+
+	// sniffLen is the number of bytes the type is detected from
+	sniffLen = 512
+)
+
+// Kind is the detected type of a file
+type Kind int
+
+const (
+	// Unsupported files cannot be attached
+	Unsupported Kind = iota
+
+	// Image files are JPEG, PNG, GIF or WebP images
+	Image
+
+	// PDF files are PDF documents
+	PDF
+
+	// Text files are UTF-8 text
+	Text
+)
+
+// String returns the name of the kind
+func (k Kind) String() string {
+	switch k {
+	case Image:
+		return "image"
+	case PDF:
+		return "pdf"
+	case Text:
+		return "text"
+	default:
+		return "unsupported"
+	}
+}
+
+// ErrUnsupported is returned for files that are not images, PDFs or text
+var ErrUnsupported = errors.New("unsupported attachment type")
+
+// TooLargeError is returned for files above the size limit of their kind
+type TooLargeError struct {
+	Name  string
+	Kind  Kind
+	Limit int64
+}
+
+// Error implements the error interface
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("attachment %s exceeds the %s limit of %d bytes", e.Name, e.Kind, e.Limit)
+}
+
+// Options configures how files are attached
+type Options struct {
+	// MaxImageBytes and MaxDocumentBytes limit the file size, defaulting to
+	// DefaultMaxImageBytes and DefaultMaxDocumentBytes
+	MaxImageBytes    int64
+	MaxDocumentBytes int64
+
+	// Title is the title of document blocks, defaults to the file name
+	Title string
+
+	// Citations enables citations for document blocks
+	Citations bool
+}
+
+// Load reads a file of fsys and returns its content block. The name is
+// normalized with models.FSPath
+func Load(fsys fs.FS, name string, opts Options) (models.ContentBlock, error) {
+	name = models.FSPath(name)
+	file, err := fsys.Open(name)
+	if err != nil {
+		return models.ContentBlock{}, fmt.Errorf("error opening attachment: %w", err)
+	}
+	defer file.Close()
+
+	return FromReader(file, name, opts)
+}
+
+// FromReader reads a file from r and returns its content block. The name is
+// used to detect the type of content that does not identify itself and as the
+// default title
+func FromReader(r io.Reader, name string, opts Options) (models.ContentBlock, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return models.ContentBlock{}, fmt.Errorf("error reading attachment: %w", err)
+	}
+	head = head[:n]
+
+	kind, mediaType := Detect(name, head)
+	if kind == Unsupported {
+		return models.ContentBlock{}, fmt.Errorf("%w: %s is %s", ErrUnsupported, name, mediaType)
+	}
+
+	limit := opts.limit(kind)
+	rest, err := io.ReadAll(io.LimitReader(r, limit-int64(len(head))+1))
+	if err != nil {
+		return models.ContentBlock{}, fmt.Errorf("error reading attachment: %w", err)
+	}
+	data := append(head, rest...)
+	if int64(len(data)) > limit {
+		return models.ContentBlock{}, &TooLargeError{Name: name, Kind: kind, Limit: limit}
+	}
+
+	block, err := Block(kind, models.MediaType(mediaType), data, opts.title(name))
+	if err != nil {
+		return models.ContentBlock{}, err
+	}
+	if opts.Citations && block.DocumentContent != nil {
+		block.DocumentContent.Citations = &models.CitationsConfig{Enabled: true}
+	}
+	return block, nil
+}
+
+// Block returns the content block of data of a detected kind
+func Block(kind Kind, mediaType models.MediaType, data []byte, title string) (models.ContentBlock, error) {
+	switch kind {
+	case Image:
+		return models.CreateImageBlock(models.NewBase64ImageSource(mediaType, base64.StdEncoding.EncodeToString(data))), nil
+	case PDF:
+		return models.CreateDocumentBlock(models.NewPDFSource(data), title), nil
+	case Text:
+		if !utf8.Valid(data) {
+			return models.ContentBlock{}, fmt.Errorf("%w: %s is not valid UTF-8", ErrUnsupported, title)
+		}
+		return models.CreateDocumentBlock(models.NewTextSource(string(data)), title), nil
+	default:
+		return models.ContentBlock{}, fmt.Errorf("%w: %s", ErrUnsupported, mediaType)
+	}
+}
+
+// textExtensions are extensions of text files that content sniffing may not
+// recognize as text
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".csv": true, ".tsv": true,
+	".json": true, ".jsonl": true, ".xml": true, ".yaml": true, ".yml": true,
+	".toml": true, ".ini": true, ".log": true, ".html": true, ".htm": true,
+	".go": true, ".py": true, ".js": true, ".ts": true, ".java": true,
+	".c": true, ".h": true, ".rs": true, ".rb": true, ".sh": true, ".sql": true,
+}
+
+// Detect returns the kind and media type of a file from its name and first
+// bytes
+func Detect(name string, head []byte) (Kind, string) {
+	mediaType := http.DetectContentType(head)
+	switch mediaType {
+	case string(models.JPEGMediaType), string(models.PNGMediaType), string(models.GIFMediaType), string(models.WebPMediaType):
+		return Image, mediaType
+	case string(models.PDFMediaType):
+		return PDF, mediaType
+	}
+
+	if strings.HasPrefix(mediaType, "text/") || textExtensions[strings.ToLower(path.Ext(name))] {
+		if validUTF8Prefix(head) {
+			return Text, string(models.PlainTextMediaType)
+		}
+	}
+	return Unsupported, mediaType
+}
+
+// limit returns the size limit of a kind
+func (o Options) limit(kind Kind) int64 {
+	if kind == Image {
+		if o.MaxImageBytes > 0 {
+			return o.MaxImageBytes
+		}
+		return DefaultMaxImageBytes
+	}
+	if o.MaxDocumentBytes > 0 {
+		return o.MaxDocumentBytes
+	}
+	return DefaultMaxDocumentBytes
+}
+
+// title returns the title of document blocks
+func (o Options) title(name string) string {
+	if o.Title != "" {
+		return o.Title
+	}
+	return path.Base(strings.ReplaceAll(name, "\\", "/"))
+}
+
+// validUTF8Prefix reports whether head is valid UTF-8, allowing it to end
+// inside a multi-byte character as it is cut from a longer file
+func validUTF8Prefix(head []byte) bool {
+	if len(head) < sniffLen {
+		return utf8.Valid(head)
+	}
+	for cut := 0; cut < utf8.UTFMax && cut < len(head); cut++ {
+		if utf8.Valid(head[:len(head)-cut]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package attachment
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ObjectInfo describes an object being read
+type ObjectInfo struct {
+	// Size is the object size in bytes, or -1 when unknown
+	Size int64
+
+	// ContentType is the stored content type, if any
+	ContentType string
+}
+
+// ObjectStore opens objects in cloud storage. It is implemented by small
+// adapters over the storage client, keeping the SDKs of S3, GCS and others out
+// of this module. An S3 adapter wraps GetObject, returning the body together
+// with ContentLength and ContentType, and a GCS adapter wraps
+// Object(key).NewReader, returning the reader with its Attrs
+type ObjectStore interface {
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error)
+}
+
+// ObjectStoreFunc adapts a function to the ObjectStore interface
+type ObjectStoreFunc func(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error)
+
+// Open implements the ObjectStore interface
+func (f ObjectStoreFunc) Open(ctx context.Context, bucket, key string) (io.ReadCloser, ObjectInfo, error) {
+	return f(ctx, bucket, key)
+}
+
+// FromObject streams an object into its content block. Objects whose reported
+// size exceeds the limit are rejected before their content is read, and the
+// content is still read with a limit when the size is unknown
+func FromObject(ctx context.Context, store ObjectStore, bucket, key string, opts Options) (models.ContentBlock, error) {
+	body, info, err := store.Open(ctx, bucket, key)
+	if err != nil {
+		return models.ContentBlock{}, fmt.Errorf("error opening object %s/%s: %w", bucket, key, err)
+	}
+	defer body.Close()
+
+	name := path.Base(key)
+	if info.Size >= 0 {
+		kind := Text
+		if strings.HasPrefix(info.ContentType, "image/") {
+			kind = Image
+		}
+		if limit := opts.limit(kind); info.Size > limit {
+			return models.ContentBlock{}, &TooLargeError{Name: name, Kind: kind, Limit: limit}
+		}
+	}
+
+	return FromReader(body, name, opts)
+}
+
+// FromURI streams the object of a URI such as s3://bucket/key or
+// gs://bucket/key into its content block, using the store registered for the
+// URI's scheme
+func FromURI(ctx context.Context, stores map[string]ObjectStore, uri string, opts Options) (models.ContentBlock, error) {
+	scheme, bucket, key, err := ParseURI(uri)
+	if err != nil {
+		return models.ContentBlock{}, err
+	}
+	store, ok := stores[scheme]
+	if !ok {
+		return models.ContentBlock{}, fmt.Errorf("no object store for scheme %q", scheme)
+	}
+	return FromObject(ctx, store, bucket, key, opts)
+}
+
+// ParseURI splits an object URI such as s3://bucket/path/to/key into its
+// scheme, bucket and key
+func ParseURI(uri string) (scheme, bucket, key string, err error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", "", "", fmt.Errorf("error parsing object URI: %w", err)
+	}
+	key = strings.TrimPrefix(parsed.Path, "/")
+	if parsed.Scheme == "" || parsed.Host == "" || key == "" {
+		return "", "", "", fmt.Errorf("error parsing object URI: %q is not of the form scheme://bucket/key", uri)
+	}
+	return parsed.Scheme, parsed.Host, key, nil
+}
@@ -0,0 +1,256 @@
+// Package audit records tamper-evident digests of API requests and responses.
+// Each record holds SHA-256 digests instead of the content, and the hash of
+// the previous record, forming a chain: changing, removing or reordering any
+// record breaks the chain, which Verify detects. This lets compliance teams
+// prove what was sent to the model without storing the content itself
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/canonical"
+)
+
+// Record is an entry of the audit log
+type Record struct {
+	Sequence       uint64    `json:"sequence"`
+	Timestamp      time.Time `json:"timestamp"`
+	RequestID      string    `json:"request_id,omitempty"`
+	Method         string    `json:"method"`
+	Path           string    `json:"path"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	Streaming      bool      `json:"streaming,omitempty"`
+	RequestDigest  string    `json:"request_digest"`
+	ResponseDigest string    `json:"response_digest,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	PrevHash       string    `json:"prev_hash"`
+	Hash           string    `json:"hash"`
+}
+
+// computeHash returns the hash of the record's fields and the previous hash
+func (r *Record) computeHash() string {
+	fields := []string{
+		strconv.FormatUint(r.Sequence, 10),
+		r.Timestamp.UTC().Format(time.RFC3339Nano),
+		r.RequestID,
+		r.Method,
+		r.Path,
+		strconv.Itoa(r.StatusCode),
+		strconv.FormatBool(r.Streaming),
+		r.RequestDigest,
+		r.ResponseDigest,
+		r.Error,
+		r.PrevHash,
+	}
+
+	h := sha256.New()
+	for _, field := range fields {
+		// Length prefixes keep field boundaries unambiguous
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Sink stores audit records
+type Sink interface {
+	Write(record Record) error
+}
+
+// SinkFunc adapts a function to the Sink interface
+type SinkFunc func(record Record) error
+
+// Write calls f
+func (f SinkFunc) Write(record Record) error {
+	return f(record)
+}
+
+// JSONLinesSink writes records as JSON lines
+type JSONLinesSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesSink creates a sink writing JSON lines to w
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// Write writes a record as a JSON line
+func (s *JSONLinesSink) Write(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+// Logger appends records to a sink, chaining their hashes
+type Logger struct {
+	// OnError is called when a record cannot be written
+	OnError func(error)
+
+	// Canonical computes the digests of JSON bodies over their canonical
+	// form, so they do not depend on the key order and number formatting of
+	// the client that sent them
+	Canonical bool
+
+	mu       sync.Mutex
+	sink     Sink
+	sequence uint64
+	prevHash string
+	now      func() time.Time
+}
+
+// NewLogger creates a logger starting a new chain
+func NewLogger(sink Sink) *Logger {
+	return &Logger{sink: sink, now: time.Now}
+}
+
+// ResumeLogger creates a logger continuing the chain after the last record of
+// an existing log
+func ResumeLogger(sink Sink, last Record) *Logger {
+	logger := NewLogger(sink)
+	logger.sequence = last.Sequence + 1
+	logger.prevHash = last.Hash
+	return logger
+}
+
+// Observer returns an exchange observer that records every API request, to be
+// passed to anthropic.WithExchangeObserver
+func (l *Logger) Observer() anthropic.ExchangeObserver {
+	return func(exchange anthropic.Exchange) {
+		if err := l.Record(exchange); err != nil && l.OnError != nil {
+			l.OnError(err)
+		}
+	}
+}
+
+// Record appends a record for an exchange
+func (l *Logger) Record(exchange anthropic.Exchange) error {
+	record := Record{
+		RequestID:     exchange.RequestID,
+		Method:        exchange.Method,
+		Path:          exchange.Path,
+		StatusCode:    exchange.StatusCode,
+		Streaming:     exchange.Streaming,
+		RequestDigest: l.digest(exchange.RequestBody),
+	}
+	if exchange.ResponseBody != nil {
+		record.ResponseDigest = l.digest(exchange.ResponseBody)
+	} else if exchange.ResponseDigest != nil {
+		record.ResponseDigest = hex.EncodeToString(exchange.ResponseDigest)
+	}
+	if exchange.Err != nil {
+		record.Error = exchange.Err.Error()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record.Sequence = l.sequence
+	record.Timestamp = l.now().UTC()
+	record.PrevHash = l.prevHash
+	record.Hash = record.computeHash()
+
+	if err := l.sink.Write(record); err != nil {
+		return fmt.Errorf("error writing audit record: %w", err)
+	}
+
+	l.sequence++
+	l.prevHash = record.Hash
+	return nil
+}
+
+// Digest returns the hex encoded SHA-256 digest of data, which can be compared
+// with a record's digests to prove what was sent or received
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// CanonicalDigest returns the digest of the canonical form of JSON data, or
+// of data itself when it is not JSON, such as a stream of events
+func CanonicalDigest(data []byte) string {
+	if normalized, err := canonical.Canonicalize(data); err == nil {
+		return Digest(normalized)
+	}
+	return Digest(data)
+}
+
+// digest returns the digest of a body
+func (l *Logger) digest(data []byte) string {
+	if l.Canonical {
+		return CanonicalDigest(data)
+	}
+	return Digest(data)
+}
+
+// ChainError describes where an audit log's chain is broken
+type ChainError struct {
+	Index  int
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at record %d: %s", e.Index, e.Reason)
+}
+
+// Verify checks the hash chain of consecutive records. The first record may
+// continue an earlier chain
+func Verify(records []Record) error {
+	for i, record := range records {
+		if record.Hash != record.computeHash() {
+			return &ChainError{Index: i, Reason: "hash does not match content"}
+		}
+		if i == 0 {
+			continue
+		}
+
+		previous := records[i-1]
+		if record.PrevHash != previous.Hash {
+			return &ChainError{Index: i, Reason: "previous hash does not match"}
+		}
+		if record.Sequence != previous.Sequence+1 {
+			return &ChainError{Index: i, Reason: fmt.Sprintf("sequence %d follows %d", record.Sequence, previous.Sequence)}
+		}
+	}
+	return nil
+}
+
+// ReadRecords reads records written by a JSONLinesSink
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("error decoding audit record on line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading audit records: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,105 @@
+// Package backoff provides the retry delay strategies used by the SDK so that
+// applications orchestrating their own multi-request workflows can reuse the
+// same timing behavior
+package backoff
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// Strategy computes the delay before a retry
+type Strategy interface {
+	// Next returns the delay before the given retry attempt, starting at 1.
+	// previous is the delay returned for the prior attempt, or zero
+	Next(attempt int, previous time.Duration) time.Duration
+}
+
+// Exponential doubles the delay for each attempt without jitter
+type Exponential struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next implements the Strategy interface
+func (e Exponential) Next(attempt int, previous time.Duration) time.Duration {
+	return exponential(e.Base, e.Max, attempt)
+}
+
+// FullJitter picks a random delay between zero and the exponential delay for
+// the attempt, which spreads out clients retrying at the same time
+type FullJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next implements the Strategy interface
+func (f FullJitter) Next(attempt int, previous time.Duration) time.Duration {
+	return random(0, exponential(f.Base, f.Max, attempt))
+}
+
+// DecorrelatedJitter picks a random delay between the base delay and three
+// times the previous delay, capped at Max
+type DecorrelatedJitter struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// Next implements the Strategy interface
+func (d DecorrelatedJitter) Next(attempt int, previous time.Duration) time.Duration {
+	if previous < d.Base {
+		previous = d.Base
+	}
+
+	delay := random(d.Base, previous*3)
+	if d.Max > 0 && delay > d.Max {
+		delay = d.Max
+	}
+	return delay
+}
+
+// Func adapts a function to the Strategy interface
+type Func func(attempt int, previous time.Duration) time.Duration
+
+// Next implements the Strategy interface
+func (f Func) Next(attempt int, previous time.Duration) time.Duration {
+	return f(attempt, previous)
+}
+
+// Sleep waits for the given duration or until the context is done
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// exponential returns base * 2^(attempt-1), capped at max
+func exponential(base, max time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && (max <= 0 || delay < max); i++ {
+		delay *= 2
+	}
+	if max > 0 && delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// random returns a random duration in [min, max)
+func random(min, max time.Duration) time.Duration {
+	if max <= min {
+		return min
+	}
+	return min + rand.N(max-min)
+}
@@ -0,0 +1,63 @@
+package anthropic
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BetaHeader is the header enabling beta features
+const BetaHeader = "anthropic-beta"
+
+// Beta features
+const (
+	// BetaTokenEfficientTools reduces the tokens used by tool calls on Claude
+	// 3.7 Sonnet
+	BetaTokenEfficientTools = "token-efficient-tools-2025-02-19"
+
+	// BetaOutput128k raises the output limit of Claude 3.7 Sonnet to 128k
+	// tokens. Such long responses must be streamed
+	BetaOutput128k = "output-128k-2025-02-19"
+)
+
+// WithBeta enables beta features for every request
+func WithBeta(features ...string) ClientOption {
+	return func(c *Client) {
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Set(BetaHeader, joinBetas(append(c.Headers.Values(BetaHeader), features...)))
+	}
+}
+
+// WithRequestBeta enables beta features for a request, in addition to those
+// enabled on the client
+func WithRequestBeta(features ...string) RequestOption {
+	return func(cfg *requestConfig) {
+		if cfg.headers == nil {
+			cfg.headers = make(http.Header)
+		}
+		cfg.headers.Set(BetaHeader, joinBetas(append(cfg.headers.Values(BetaHeader), features...)))
+	}
+}
+
+// WithTokenEfficientTools enables the token-efficient tool use beta
+func WithTokenEfficientTools() ClientOption {
+	return WithBeta(BetaTokenEfficientTools)
+}
+
+// joinBetas joins beta feature lists into a single header value without
+// duplicates
+func joinBetas(values []string) string {
+	seen := make(map[string]bool)
+	var features []string
+	for _, value := range values {
+		for _, feature := range strings.Split(value, ",") {
+			feature = strings.TrimSpace(feature)
+			if feature != "" && !seen[feature] {
+				seen[feature] = true
+				features = append(features, feature)
+			}
+		}
+	}
+	return strings.Join(features, ",")
+}
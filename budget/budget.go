@@ -0,0 +1,197 @@
+// Package budget enforces spending limits on API usage, for example a daily
+// token budget per tenant. Spend is tracked in a Store that can be local or
+// shared across replicas through Redis
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ErrExceeded is returned when a budget is used up
+var ErrExceeded = errors.New("budget exceeded")
+
+// Store tracks spend per key. Implementations must be safe for concurrent use
+type Store interface {
+	// Add adds amount to the spend of key and returns the new total. ttl is
+	// how long the key must be kept
+	Add(ctx context.Context, key string, amount float64, ttl time.Duration) (float64, error)
+
+	// Get returns the spend of key
+	Get(ctx context.Context, key string) (float64, error)
+}
+
+// CostFunc converts usage into the unit of the budget
+type CostFunc func(model string, usage models.Usage) float64
+
+// TokenCost counts input and output tokens
+func TokenCost(model string, usage models.Usage) float64 {
+	return float64(usage.InputTokens + usage.OutputTokens)
+}
+
+// Manager enforces a limit per key and period
+type Manager struct {
+	Store Store
+
+	// Limit is the maximum spend per key and period
+	Limit float64
+
+	// Period is the length of a budget period, such as 24 hours. Zero means a
+	// single period that never resets
+	Period time.Duration
+
+	// Cost converts usage into spend, defaults to TokenCost
+	Cost CostFunc
+
+	// Key returns the budget key of a request, defaults to a single key
+	Key func(ctx context.Context) string
+}
+
+// Check returns ErrExceeded if the budget of the request's key is used up
+func (m *Manager) Check(ctx context.Context) error {
+	spent, err := m.Store.Get(ctx, m.periodKey(ctx))
+	if err != nil {
+		return fmt.Errorf("error reading budget: %w", err)
+	}
+	if spent >= m.Limit {
+		return fmt.Errorf("%w: spent %g of %g", ErrExceeded, spent, m.Limit)
+	}
+	return nil
+}
+
+// Record adds the usage of a response to the budget of the request's key
+func (m *Manager) Record(ctx context.Context, model string, usage models.Usage) error {
+	cost := m.Cost
+	if cost == nil {
+		cost = TokenCost
+	}
+
+	if _, err := m.Store.Add(ctx, m.periodKey(ctx), cost(model, usage), m.Period); err != nil {
+		return fmt.Errorf("error recording budget: %w", err)
+	}
+	return nil
+}
+
+// Remaining returns the spend left in the current period
+func (m *Manager) Remaining(ctx context.Context) (float64, error) {
+	spent, err := m.Store.Get(ctx, m.periodKey(ctx))
+	if err != nil {
+		return 0, err
+	}
+	return max(m.Limit-spent, 0), nil
+}
+
+// periodKey returns the store key of the current period
+func (m *Manager) periodKey(ctx context.Context) string {
+	key := "default"
+	if m.Key != nil {
+		key = m.Key(ctx)
+	}
+	if m.Period <= 0 {
+		return key
+	}
+	return fmt.Sprintf("%s:%d", key, time.Now().UnixNano()/int64(m.Period))
+}
+
+// Provider wraps a ChatProvider, rejecting requests once the budget is used up
+// and recording the usage of every response
+type Provider struct {
+	anthropic.ChatProvider
+	Manager *Manager
+}
+
+// Wrap returns a provider enforcing the budget
+func Wrap(provider anthropic.ChatProvider, manager *Manager) *Provider {
+	return &Provider{ChatProvider: provider, Manager: manager}
+}
+
+// CreateMessage creates a message if the budget allows it
+func (p *Provider) CreateMessage(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*models.Message, error) {
+	if err := p.Manager.Check(ctx); err != nil {
+		return nil, err
+	}
+
+	resp, err := p.ChatProvider.CreateMessage(ctx, req, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.Manager.Record(ctx, resp.Model, resp.Usage); err != nil {
+		return resp, err
+	}
+	return resp, nil
+}
+
+// sweepInterval is the minimum time between two sweeps of the expired keys
+// of a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore is an in-process store. Keys of past periods are dropped once
+// they expire, so long-running processes do not accumulate them
+type MemoryStore struct {
+	mu        sync.Mutex
+	spent     map[string]float64
+	expires   map[string]time.Time
+	nextSweep time.Time
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{spent: make(map[string]float64), expires: make(map[string]time.Time)}
+}
+
+// Add adds amount to the spend of key
+func (s *MemoryStore) Add(ctx context.Context, key string, amount float64, ttl time.Duration) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	s.expire(key)
+	s.spent[key] += amount
+	if ttl > 0 {
+		if _, ok := s.expires[key]; !ok {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+	}
+	return s.spent[key], nil
+}
+
+// Get returns the spend of key
+func (s *MemoryStore) Get(ctx context.Context, key string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep()
+	s.expire(key)
+	return s.spent[key], nil
+}
+
+// expire drops key once its ttl passed
+func (s *MemoryStore) expire(key string) {
+	if expires, ok := s.expires[key]; ok && time.Now().After(expires) {
+		delete(s.spent, key)
+		delete(s.expires, key)
+	}
+}
+
+// sweep drops every expired key, at most once per sweepInterval
+func (s *MemoryStore) sweep() {
+	now := time.Now()
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(sweepInterval)
+
+	for key, expires := range s.expires {
+		if now.After(expires) {
+			delete(s.spent, key)
+			delete(s.expires, key)
+		}
+	}
+}
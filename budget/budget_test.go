@@ -0,0 +1,170 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	for _, amount := range []float64{1.5, 2.5} {
+		if _, err := store.Add(ctx, "tenant", amount, time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if spent, _ := store.Get(ctx, "tenant"); spent != 4 {
+		t.Fatalf("Get() = %g, want 4", spent)
+	}
+	if spent, _ := store.Get(ctx, "other"); spent != 0 {
+		t.Fatalf("Get() of an unknown key = %g, want 0", spent)
+	}
+
+	// Adding to a key keeps the expiry set when it was created
+	expires := store.expires["tenant"]
+	store.Add(ctx, "tenant", 1, 2*time.Hour)
+	if !store.expires["tenant"].Equal(expires) {
+		t.Fatal("Add() moved the expiry of an existing key")
+	}
+
+	store.expires["tenant"] = time.Now().Add(-time.Second)
+	if spent, _ := store.Get(ctx, "tenant"); spent != 0 {
+		t.Fatalf("Get() of an expired key = %g, want 0", spent)
+	}
+	if spent, _ := store.Add(ctx, "tenant", 1, time.Hour); spent != 1 {
+		t.Fatalf("Add() to an expired key = %g, want 1", spent)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	store.Add(ctx, "old", 1, time.Hour)
+	store.Add(ctx, "forever", 1, 0)
+	store.expires["old"] = time.Now().Add(-time.Second)
+
+	// Sweeps run at most once per interval
+	store.Get(ctx, "forever")
+	if _, ok := store.spent["old"]; !ok {
+		t.Fatal("expired key swept before the sweep interval passed")
+	}
+
+	store.nextSweep = time.Now().Add(-time.Second)
+	store.Get(ctx, "forever")
+	if _, ok := store.spent["old"]; ok {
+		t.Fatal("expired key not swept")
+	}
+	if spent, _ := store.Get(ctx, "forever"); spent != 1 {
+		t.Fatalf("key without ttl = %g, want 1", spent)
+	}
+}
+
+func TestManager(t *testing.T) {
+	tests := []struct {
+		name          string
+		usage         []models.Usage
+		wantExceeded  bool
+		wantRemaining float64
+	}{
+		{name: "unused", wantRemaining: 100},
+		{name: "within the limit", usage: []models.Usage{{InputTokens: 30, OutputTokens: 20}}, wantRemaining: 50},
+		{name: "at the limit", usage: []models.Usage{{InputTokens: 60}, {OutputTokens: 40}}, wantExceeded: true},
+		{name: "over the limit", usage: []models.Usage{{InputTokens: 150}}, wantExceeded: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			manager := &Manager{Store: NewMemoryStore(), Limit: 100, Period: time.Hour}
+			for _, usage := range tt.usage {
+				if err := manager.Record(ctx, "claude", usage); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := manager.Check(ctx)
+			if errors.Is(err, ErrExceeded) != tt.wantExceeded {
+				t.Fatalf("Check() = %v, want exceeded %t", err, tt.wantExceeded)
+			}
+			if remaining, _ := manager.Remaining(ctx); remaining != tt.wantRemaining {
+				t.Fatalf("Remaining() = %g, want %g", remaining, tt.wantRemaining)
+			}
+		})
+	}
+}
+
+func TestManagerKeys(t *testing.T) {
+	type tenantKey struct{}
+	manager := &Manager{
+		Store: NewMemoryStore(),
+		Limit: 10,
+		Key:   func(ctx context.Context) string { return ctx.Value(tenantKey{}).(string) },
+	}
+	a := context.WithValue(context.Background(), tenantKey{}, "a")
+	b := context.WithValue(context.Background(), tenantKey{}, "b")
+
+	manager.Record(a, "claude", models.Usage{InputTokens: 10})
+	if err := manager.Check(a); !errors.Is(err, ErrExceeded) {
+		t.Fatalf("Check() of the spent key = %v, want ErrExceeded", err)
+	}
+	if err := manager.Check(b); err != nil {
+		t.Fatalf("Check() of another key = %v", err)
+	}
+}
+
+// scripter is a RedisScripter returning a scripted result
+type scripter struct {
+	result interface{}
+	err    error
+	keys   []string
+	args   []interface{}
+}
+
+func (s *scripter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s.keys, s.args = keys, args
+	return s.result, s.err
+}
+
+func TestRedisStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  interface{}
+		err     error
+		want    float64
+		wantErr bool
+	}{
+		{name: "string", result: "12.5", want: 12.5},
+		{name: "bytes", result: []byte("3"), want: 3},
+		{name: "integer", result: int64(7), want: 7},
+		{name: "unexpected type", result: 1.5, wantErr: true},
+		{name: "not a number", result: "abc", wantErr: true},
+		{name: "script error", err: errors.New("connection refused"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &scripter{result: tt.result, err: tt.err}
+			store := NewRedisStore(client, "budget:")
+
+			got, err := store.Add(context.Background(), "tenant", 0.25, 90*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Add() = %g, %v, want error %t", got, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Add() = %g, want %g", got, tt.want)
+			}
+			if len(client.keys) != 1 || client.keys[0] != "budget:tenant" {
+				t.Fatalf("script keys = %v, want the prefixed key", client.keys)
+			}
+			if len(client.args) != 2 || client.args[0] != "0.25" || client.args[1] != int64(90000) {
+				t.Fatalf("script args = %v, want the amount and the ttl in milliseconds", client.args)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/ratelimit"
+)
+
+// addScript increments the spend of a key and sets its expiry on creation
+const addScript = `
+local total = redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl > 0 and redis.call('PTTL', KEYS[1]) < 0 then
+  redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return total
+`
+
+// getScript returns the spend of a key
+const getScript = `return redis.call('GET', KEYS[1]) or '0'`
+
+// RedisStore keeps spend in Redis, so replicas of a service share budgets
+type RedisStore struct {
+	client ratelimit.RedisScripter
+	prefix string
+}
+
+// NewRedisStore creates a store keeping spend under keys with the given prefix
+func NewRedisStore(client ratelimit.RedisScripter, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Add adds amount to the spend of key
+func (s *RedisStore) Add(ctx context.Context, key string, amount float64, ttl time.Duration) (float64, error) {
+	result, err := s.client.Eval(ctx, addScript, []string{s.prefix + key}, strconv.FormatFloat(amount, 'f', -1, 64), ttl.Milliseconds())
+	if err != nil {
+		return 0, err
+	}
+	return parseFloat(result)
+}
+
+// Get returns the spend of key
+func (s *RedisStore) Get(ctx context.Context, key string) (float64, error) {
+	result, err := s.client.Eval(ctx, getScript, []string{s.prefix + key})
+	if err != nil {
+		return 0, err
+	}
+	return parseFloat(result)
+}
+
+// parseFloat parses a float returned by a script, which Redis returns as a
+// string
+func parseFloat(result interface{}) (float64, error) {
+	switch v := result.(type) {
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	case int64:
+		return float64(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected budget script result %T", result)
+	}
+}
@@ -0,0 +1,105 @@
+package anthropic
+
+import (
+	"context"
+	"sync"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultToolCacheMinTokens is the estimated size of the tool definitions
+// above which WithToolCaching adds a breakpoint, matching the smallest
+// cacheable prompt of most models
+const DefaultToolCacheMinTokens = 1024
+
+// WithToolCaching places a prompt caching breakpoint after the tool
+// definitions of every message request whose tools are estimated to be at
+// least minTokens long, zero uses DefaultToolCacheMinTokens. Requests that
+// already mark a tool for caching, or already have the maximum number of
+// breakpoints, are left unchanged
+func WithToolCaching(minTokens int) ClientOption {
+	if minTokens <= 0 {
+		minTokens = DefaultToolCacheMinTokens
+	}
+
+	return WithMiddleware(func(ctx context.Context, req *models.MessageRequest) error {
+		if len(req.Tools) == 0 {
+			return nil
+		}
+		for _, tool := range req.Tools {
+			if tool.CacheControl != nil {
+				return nil
+			}
+		}
+		if models.CacheBreakpoints(*req) >= models.MaxCacheBreakpoints {
+			return nil
+		}
+		if models.EstimateToolTokens(req.Tools) >= minTokens {
+			req.Tools = models.CacheTools(req.Tools)
+		}
+		return nil
+	})
+}
+
+// CacheMetrics accumulates the prompt cache usage of responses. It is safe for
+// concurrent use
+type CacheMetrics struct {
+	mu       sync.Mutex
+	usage    models.Usage
+	requests int
+	hits     int
+}
+
+// CacheStats is a snapshot of CacheMetrics
+type CacheStats struct {
+	// Requests is the number of responses recorded, Hits the number of them
+	// that read from the cache
+	Requests int
+	Hits     int
+
+	// Usage is the combined usage of the responses
+	Usage models.Usage
+}
+
+// HitRate returns the share of input tokens read from the cache
+func (s CacheStats) HitRate() float64 {
+	return s.Usage.CacheHitRate()
+}
+
+// Record adds the usage of a response
+func (m *CacheMetrics) Record(usage models.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage = m.usage.Add(usage)
+	m.requests++
+	if usage.CacheReadInputTokens > 0 {
+		m.hits++
+	}
+}
+
+// Stats returns the recorded metrics
+func (m *CacheMetrics) Stats() CacheStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return CacheStats{Requests: m.requests, Hits: m.hits, Usage: m.usage}
+}
+
+// Reset clears the recorded metrics
+func (m *CacheMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.usage = models.Usage{}
+	m.requests = 0
+	m.hits = 0
+}
+
+// WithCacheMetrics records the usage of every message response, streamed or
+// not, in metrics
+func WithCacheMetrics(metrics *CacheMetrics) ClientOption {
+	return func(c *Client) {
+		c.CacheMetrics = metrics
+	}
+}
@@ -0,0 +1,251 @@
+// Package canary compares two models on a saved suite of requests before a
+// migration. Every request is sent to a baseline and a candidate model, and
+// the report summarizes how response length, latency, tool use and optional
+// judge scores change
+package canary
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Case is a request of the suite
+type Case struct {
+	Name    string                `json:"name"`
+	Request models.MessageRequest `json:"request"`
+}
+
+// Judge scores a response to a case, higher is better
+type Judge func(ctx context.Context, c Case, resp *models.Message) (float64, error)
+
+// Runner runs a suite against two models
+type Runner struct {
+	Client anthropic.ChatProvider
+
+	// Baseline and Candidate are the models being compared
+	Baseline  string
+	Candidate string
+
+	// Judge optionally scores every response
+	Judge Judge
+}
+
+// Run is the outcome of sending a case to one model
+type Run struct {
+	Model        string            `json:"model"`
+	Text         string            `json:"text"`
+	Length       int               `json:"length"`
+	OutputTokens int               `json:"output_tokens"`
+	Latency      time.Duration     `json:"latency"`
+	ToolCalls    int               `json:"tool_calls"`
+	StopReason   models.StopReason `json:"stop_reason,omitempty"`
+	Score        *float64          `json:"score,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// CaseResult holds the runs of both models for a case
+type CaseResult struct {
+	Name      string `json:"name"`
+	Baseline  Run    `json:"baseline"`
+	Candidate Run    `json:"candidate"`
+}
+
+// Summary aggregates the runs of one model
+type Summary struct {
+	Model        string         `json:"model"`
+	Runs         int            `json:"runs"`
+	Errors       int            `json:"errors"`
+	MeanLength   float64        `json:"mean_length"`
+	MeanTokens   float64        `json:"mean_output_tokens"`
+	MeanLatency  time.Duration  `json:"mean_latency"`
+	ToolCallRate float64        `json:"tool_call_rate"`
+	MeanScore    *float64       `json:"mean_score,omitempty"`
+	StopReasons  map[string]int `json:"stop_reasons"`
+}
+
+// Report is the structured comparison of both models
+type Report struct {
+	Baseline  Summary      `json:"baseline"`
+	Candidate Summary      `json:"candidate"`
+	Cases     []CaseResult `json:"cases"`
+}
+
+// LoadSuite reads a suite of cases from JSON lines, one case per line
+func LoadSuite(r io.Reader) ([]Case, error) {
+	var cases []Case
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var c Case
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, fmt.Errorf("error decoding case on line %d: %w", line, err)
+		}
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case-%d", line)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading suite: %w", err)
+	}
+	return cases, nil
+}
+
+// LoadSuiteFile reads a suite of cases from a JSON lines file
+func LoadSuiteFile(path string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening suite: %w", err)
+	}
+	defer f.Close()
+	return LoadSuite(f)
+}
+
+// Run sends every case to both models. Failed requests are recorded in the
+// report instead of aborting the run, only a canceled context stops it
+func (r *Runner) Run(ctx context.Context, cases []Case) (*Report, error) {
+	report := &Report{Cases: make([]CaseResult, 0, len(cases))}
+	for _, c := range cases {
+		result := CaseResult{
+			Name:      c.Name,
+			Baseline:  r.run(ctx, c, r.Baseline),
+			Candidate: r.run(ctx, c, r.Candidate),
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Cases = append(report.Cases, result)
+	}
+
+	report.Baseline = summarize(r.Baseline, report.Cases, func(c CaseResult) Run { return c.Baseline })
+	report.Candidate = summarize(r.Candidate, report.Cases, func(c CaseResult) Run { return c.Candidate })
+	return report, nil
+}
+
+// run sends a case to a model
+func (r *Runner) run(ctx context.Context, c Case, model string) Run {
+	req := c.Request
+	req.Model = model
+	req.Stream = false
+
+	run := Run{Model: model}
+	start := time.Now()
+	resp, err := r.Client.CreateMessage(ctx, req)
+	run.Latency = time.Since(start)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+
+	run.Text = resp.Text()
+	run.Length = len([]rune(run.Text))
+	run.OutputTokens = resp.Usage.OutputTokens
+	run.StopReason = resp.StopReason
+	for _, block := range resp.Content {
+		if block.ToolUseContent != nil {
+			run.ToolCalls++
+		}
+	}
+
+	if r.Judge != nil {
+		score, err := r.Judge(ctx, c, resp)
+		if err != nil {
+			run.Error = fmt.Sprintf("error judging response: %v", err)
+		} else {
+			run.Score = &score
+		}
+	}
+	return run
+}
+
+// summarize aggregates the runs of one model
+func summarize(model string, cases []CaseResult, pick func(CaseResult) Run) Summary {
+	summary := Summary{Model: model, Runs: len(cases), StopReasons: make(map[string]int)}
+
+	var ok, withTools, scored int
+	var length, tokens, score float64
+	var latency time.Duration
+	for _, c := range cases {
+		run := pick(c)
+		if run.Error != "" {
+			summary.Errors++
+		}
+		if run.StopReason == "" {
+			// The request failed, there is no response to aggregate
+			continue
+		}
+
+		ok++
+		length += float64(run.Length)
+		tokens += float64(run.OutputTokens)
+		latency += run.Latency
+		summary.StopReasons[string(run.StopReason)]++
+		if run.ToolCalls > 0 {
+			withTools++
+		}
+		if run.Score != nil {
+			scored++
+			score += *run.Score
+		}
+	}
+
+	if ok > 0 {
+		summary.MeanLength = length / float64(ok)
+		summary.MeanTokens = tokens / float64(ok)
+		summary.MeanLatency = latency / time.Duration(ok)
+		summary.ToolCallRate = float64(withTools) / float64(ok)
+	}
+	if scored > 0 {
+		mean := score / float64(scored)
+		summary.MeanScore = &mean
+	}
+	return summary
+}
+
+// WriteText writes a human readable comparison of both models
+func (r *Report) WriteText(w io.Writer) error {
+	rows := []struct {
+		name                string
+		baseline, candidate string
+	}{
+		{"runs", fmt.Sprint(r.Baseline.Runs), fmt.Sprint(r.Candidate.Runs)},
+		{"errors", fmt.Sprint(r.Baseline.Errors), fmt.Sprint(r.Candidate.Errors)},
+		{"mean length", fmt.Sprintf("%.1f", r.Baseline.MeanLength), fmt.Sprintf("%.1f", r.Candidate.MeanLength)},
+		{"mean output tokens", fmt.Sprintf("%.1f", r.Baseline.MeanTokens), fmt.Sprintf("%.1f", r.Candidate.MeanTokens)},
+		{"mean latency", r.Baseline.MeanLatency.Round(time.Millisecond).String(), r.Candidate.MeanLatency.Round(time.Millisecond).String()},
+		{"tool call rate", fmt.Sprintf("%.2f", r.Baseline.ToolCallRate), fmt.Sprintf("%.2f", r.Candidate.ToolCallRate)},
+		{"mean score", formatScore(r.Baseline.MeanScore), formatScore(r.Candidate.MeanScore)},
+	}
+
+	if _, err := fmt.Fprintf(w, "%-20s %-30s %-30s\n", "", r.Baseline.Model, r.Candidate.Model); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := fmt.Fprintf(w, "%-20s %-30s %-30s\n", row.name, row.baseline, row.candidate); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatScore formats an optional score
+func formatScore(score *float64) string {
+	if score == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", *score)
+}
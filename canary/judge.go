@@ -0,0 +1,63 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// judgePrompt instructs the judge model
+const judgePrompt = `You grade responses of an AI assistant. Given a request and a response, score the response from 1 (unusable) to 10 (excellent) by calling the score tool.`
+
+// ModelJudge returns a judge that asks a model to score responses from 1 to 10
+// against a rubric
+func ModelJudge(client anthropic.ChatProvider, model, rubric string) Judge {
+	return func(ctx context.Context, c Case, resp *models.Message) (float64, error) {
+		request, err := c.Request.MarshalJSON()
+		if err != nil {
+			return 0, err
+		}
+
+		system := judgePrompt
+		if rubric != "" {
+			system += "\n\nRubric:\n" + rubric
+		}
+
+		schema := models.SimpleJSONSchema(map[string]models.Property{
+			"score":  models.NewProperty("integer", "Score from 1 to 10"),
+			"reason": models.NewProperty("string", "Short justification"),
+		}, []string{"score"})
+		choice := models.SpecificToolChoice("score", true)
+
+		judged, err := client.CreateMessage(ctx, models.MessageRequest{
+			Model:     model,
+			System:    system,
+			MaxTokens: 512,
+			Messages: []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(
+				fmt.Sprintf("<request>\n%s\n</request>\n\n<response>\n%s\n</response>", request, resp.Text()),
+			))},
+			Tools:      []models.Tool{models.NewTool("score", "Record the score of the response", schema)},
+			ToolChoice: &choice,
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		for _, block := range judged.Content {
+			if block.ToolUseContent == nil {
+				continue
+			}
+			var input struct {
+				Score float64 `json:"score"`
+			}
+			if err := block.ToolUseContent.DecodeInput(&input); err != nil {
+				return 0, fmt.Errorf("error decoding score: %w", err)
+			}
+			return input.Score, nil
+		}
+		return 0, errors.New("judge returned no score")
+	}
+}
@@ -0,0 +1,169 @@
+// Package canonical renders JSON in a canonical form, with object keys sorted,
+// insignificant whitespace removed and numbers formatted the same way
+// regardless of how they were written, following RFC 8785. Canonical request
+// bodies are byte-stable across Go versions and map iteration orders, so their
+// snapshots, hashes and audit logs can be compared directly
+package canonical
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Marshal returns the canonical JSON encoding of v
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(data)
+}
+
+// Canonicalize rewrites JSON data in canonical form
+func Canonicalize(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error decoding JSON: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("error decoding JSON: unexpected data after the top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// write appends the canonical encoding of a decoded value
+func write(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		number, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(number)
+	case string:
+		writeString(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, element := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := write(buf, element); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeString(buf, key)
+			buf.WriteByte(':')
+			if err := write(buf, v[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unexpected JSON value of type %T", value)
+	}
+	return nil
+}
+
+// writeString appends a string with only the escaping JSON requires
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formatNumber formats a number as ECMAScript does, the form RFC 8785 uses:
+// integers and numbers between 1e-6 and 1e21 in plain notation, others in
+// exponent notation, always with the shortest representation that round-trips.
+// Like JavaScript it keeps 53 bits of precision, so larger integers are rounded
+func formatNumber(number json.Number) (string, error) {
+	f, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil {
+		return "", fmt.Errorf("error formatting number %s: %w", number, err)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("error formatting number %s: out of range", number)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+
+	// Go writes exponents with at least two digits and ECMAScript without
+	// leading zeros
+	s := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(s, "e")
+	sign := exponent[:1]
+	exponent = strings.TrimLeft(exponent[1:], "0")
+	return mantissa + "e" + sign + exponent, nil
+}
+
+// lessUTF16 orders strings by their UTF-16 code units, as RFC 8785 requires
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
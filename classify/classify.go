@@ -0,0 +1,183 @@
+// Package classify classifies text into one of a fixed set of labels. The
+// model is forced to answer through a tool whose schema restricts the label to
+// the allowed values, so the result never needs free-form parsing
+package classify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// toolName is the name of the tool the model answers with
+const toolName = "classify"
+
+// DefaultMaxTokens is the default response limit
+const DefaultMaxTokens = 512
+
+// Confidence is how confident the model is in a label
+type Confidence string
+
+const (
+	High   Confidence = "high"
+	Medium Confidence = "medium"
+	Low    Confidence = "low"
+)
+
+// ErrNoClassification is returned when the response contains no tool call
+var ErrNoClassification = errors.New("response contains no classification")
+
+// InvalidLabelError is returned when the model answers with a label outside
+// the label set, which the schema should prevent
+type InvalidLabelError struct {
+	Label string
+}
+
+// Error implements the error interface
+func (e *InvalidLabelError) Error() string {
+	return fmt.Sprintf("invalid label %q", e.Label)
+}
+
+// Classifier classifies text into one of its labels
+type Classifier struct {
+	Client anthropic.ChatProvider
+	Model  string
+
+	// Labels is the set of allowed labels
+	Labels []string
+
+	// Descriptions optionally explains labels to the model
+	Descriptions map[string]string
+
+	// Instructions are added to the system prompt, for example to describe
+	// the classification task
+	Instructions string
+
+	// MaxRetries is the number of times the request is repeated when the
+	// response has no valid classification
+	MaxRetries int
+
+	// MaxTokens limits the response, defaults to DefaultMaxTokens
+	MaxTokens int
+}
+
+// Result is a classification
+type Result struct {
+	Label      string
+	Confidence Confidence
+	Rationale  string
+
+	// Usage is the combined usage of all attempts
+	Usage models.Usage
+}
+
+// classification is the input of the classify tool
+type classification struct {
+	Label      string     `json:"label"`
+	Confidence Confidence `json:"confidence"`
+	Rationale  string     `json:"rationale"`
+}
+
+// New creates a classifier for the given labels
+func New(client anthropic.ChatProvider, model string, labels ...string) *Classifier {
+	return &Classifier{Client: client, Model: model, Labels: labels}
+}
+
+// Classify returns the label that best fits the text
+func (c *Classifier) Classify(ctx context.Context, text string) (*Result, error) {
+	if len(c.Labels) == 0 {
+		return nil, errors.New("error classifying: no labels")
+	}
+
+	req := c.request(text)
+	var usage models.Usage
+	var err error
+	for attempt := 0; attempt <= c.MaxRetries; attempt++ {
+		var resp *models.Message
+		resp, err = c.Client.CreateMessage(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		usage = usage.Add(resp.Usage)
+
+		var result *Result
+		result, err = c.parse(resp)
+		if err == nil {
+			result.Usage = usage
+			return result, nil
+		}
+	}
+
+	return nil, fmt.Errorf("error classifying: %w", err)
+}
+
+// request builds the classification request
+func (c *Classifier) request(text string) models.MessageRequest {
+	maxTokens := c.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	system := "Classify the text provided by the user by calling the classify tool."
+	if len(c.Descriptions) > 0 {
+		var b strings.Builder
+		b.WriteString(system)
+		b.WriteString("\n\nLabels:")
+		for _, label := range c.Labels {
+			fmt.Fprintf(&b, "\n- %s", label)
+			if description := c.Descriptions[label]; description != "" {
+				fmt.Fprintf(&b, ": %s", description)
+			}
+		}
+		system = b.String()
+	}
+	if c.Instructions != "" {
+		system += "\n\n" + c.Instructions
+	}
+
+	schema := models.SimpleJSONSchema(map[string]models.Property{
+		"label":      models.NewEnumProperty("The label that best fits the text", c.Labels),
+		"confidence": models.NewEnumProperty("How confident you are in the label", []string{string(High), string(Medium), string(Low)}),
+		"rationale":  models.NewProperty("string", "A short explanation of why the label fits"),
+	}, []string{"label", "confidence", "rationale"})
+
+	choice := models.SpecificToolChoice(toolName, true)
+	return models.MessageRequest{
+		Model:      c.Model,
+		System:     system,
+		MaxTokens:  maxTokens,
+		Messages:   []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(text))},
+		Tools:      []models.Tool{models.NewTool(toolName, "Record the classification of the text", schema)},
+		ToolChoice: &choice,
+	}
+}
+
+// parse extracts the classification from a response
+func (c *Classifier) parse(resp *models.Message) (*Result, error) {
+	for _, block := range resp.Content {
+		if block.ToolUseContent == nil || block.ToolUseContent.Name != toolName {
+			continue
+		}
+
+		var input classification
+		if err := block.ToolUseContent.DecodeInput(&input); err != nil {
+			return nil, fmt.Errorf("error decoding classification: %w", err)
+		}
+		if !slices.Contains(c.Labels, input.Label) {
+			return nil, &InvalidLabelError{Label: input.Label}
+		}
+
+		return &Result{
+			Label:      input.Label,
+			Confidence: input.Confidence,
+			Rationale:  input.Rationale,
+		}, nil
+	}
+
+	return nil, ErrNoClassification
+}
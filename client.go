@@ -1,15 +1,16 @@
 package anthropic
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/canonical"
 )
 
 const (
@@ -18,12 +19,114 @@ const (
 	DefaultTimeout = 120 * time.Second
 )
 
-// Client provides a client to the Anthropic API
+// Client provides a client to the Anthropic API.
+//
+// A Client is safe for concurrent use. Its fields must not be modified once the
+// client is in use; use Clone to derive a client with different settings
 type Client struct {
 	BaseURL    string
 	APIKey     string
 	Version    string
 	HTTPClient *http.Client
+
+	// AuthToken is sent as a bearer token in the Authorization header, for
+	// gateways that authenticate with tokens instead of API keys
+	AuthToken string
+
+	// Headers are added to every request made by the client
+	Headers http.Header
+
+	// DefaultModel is used for requests that do not specify a model
+	DefaultModel string
+
+	// Middleware is applied to every message request before it is sent
+	Middleware []Middleware
+
+	// Concurrency caps the requests in flight when set. It is shared with the
+	// clients cloned from this one
+	Concurrency *ConcurrencyLimiter
+
+	// RetryPolicy controls how failed requests are retried, nil disables retries
+	RetryPolicy RetryPolicy
+
+	// OnRetry is called before waiting for each retry
+	OnRetry func(RetryEvent)
+
+	// MaxRetryElapsedTime bounds the total time spent on a request including
+	// retry delays, zero means no limit
+	MaxRetryElapsedTime time.Duration
+
+	// Guardrails validates the final text of responses
+	Guardrails *Guardrails
+
+	// PropagatePanics lets panics of callbacks propagate instead of returning
+	// them as a *PanicError
+	PropagatePanics bool
+
+	// StrictDecoding makes decoding fail on unknown content block and stream
+	// event types instead of preserving them
+	StrictDecoding bool
+
+	// ProfilerLabels tags the goroutines making requests with pprof labels
+	ProfilerLabels bool
+
+	// RuntimeTrace runs every request in a runtime/trace task
+	RuntimeTrace bool
+
+	// CanonicalJSON marshals request bodies in canonical form, so they are
+	// byte-stable across Go versions and map iteration orders
+	CanonicalJSON bool
+
+	// MaxResponseSize limits the size of response bodies, zero means no limit
+	MaxResponseSize int
+
+	// MaxStreamMessageSize limits the content accumulated from a stream, zero
+	// means no limit
+	MaxStreamMessageSize int
+
+	// MaxStreamEventSize limits the size of a single streamed event, zero uses
+	// streaming.DefaultMaxEventSize
+	MaxStreamEventSize int
+
+	// Endpoints fails over between several base URLs when set, taking
+	// precedence over BaseURL
+	Endpoints *EndpointPool
+
+	// Signer signs every request after it is built when set
+	Signer RequestSigner
+
+	// Observers are called after every API request
+	Observers []ExchangeObserver
+
+	// RateLimitObservers are called with the rate limits reported by every
+	// response
+	RateLimitObservers []RateLimitObserver
+
+	// OnDiagnostics receives connection and timing details of every request
+	// attempt when set
+	OnDiagnostics func(Diagnostics)
+
+	// CacheMetrics records the prompt cache usage of every message response
+	// when set
+	CacheMetrics *CacheMetrics
+
+	// ModelMaxTokens sets max_tokens of requests that leave it unset to the
+	// model's limit
+	ModelMaxTokens bool
+
+	// ValidateMaxTokens rejects requests exceeding the model's output limit
+	ValidateMaxTokens bool
+
+	// ExtendedOutput enables the model's extended output beta when a request
+	// needs it
+	ExtendedOutput bool
+
+	// lifecycle tracks the work in flight for Shutdown, shared with clones
+	lifecycle *lifecycle
+
+	// configErr is the error loading the profile selected by
+	// ANTHROPIC_PROFILE, returned by every request
+	configErr error
 }
 
 // ClientOption is a function that modifies a Client
@@ -43,6 +146,13 @@ func WithAPIKey(apiKey string) ClientOption {
 	}
 }
 
+// WithAuthToken sets the bearer token sent in the Authorization header
+func WithAuthToken(token string) ClientOption {
+	return func(c *Client) {
+		c.AuthToken = token
+	}
+}
+
 // WithVersion sets the API version for the client
 func WithVersion(version string) ClientOption {
 	return func(c *Client) {
@@ -57,88 +167,370 @@ func WithHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
-// NewClient creates a new Anthropic API client
+// WithHeader adds a header that is sent with every request
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		if c.Headers == nil {
+			c.Headers = make(http.Header)
+		}
+		c.Headers.Add(key, value)
+	}
+}
+
+// WithDefaultModel sets the model used for requests that do not specify one
+func WithDefaultModel(model string) ClientOption {
+	return func(c *Client) {
+		c.DefaultModel = model
+	}
+}
+
+// WithRetryPolicy sets the retry policy for the client, a
+// *StandardRetryPolicy or a custom strategy
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithGuardrails sets the guardrails used to validate responses
+func WithGuardrails(guardrails *Guardrails) ClientOption {
+	return func(c *Client) {
+		c.Guardrails = guardrails
+	}
+}
+
+// WithStrictDecoding makes response and stream decoding fail on unknown content
+// block and event types, which is useful for canaries detecting API changes
+func WithStrictDecoding() ClientOption {
+	return func(c *Client) {
+		c.StrictDecoding = true
+	}
+}
+
+// WithCanonicalJSON marshals request bodies with sorted keys and stable number
+// formatting, so request snapshots, hashes and audit logs are byte-stable
+func WithCanonicalJSON() ClientOption {
+	return func(c *Client) {
+		c.CanonicalJSON = true
+	}
+}
+
+// WithMaxStreamEventSize sets the maximum size in bytes of a single streamed
+// event, protecting relays from huge or hostile frames
+func WithMaxStreamEventSize(size int) ClientOption {
+	return func(c *Client) {
+		c.MaxStreamEventSize = size
+	}
+}
+
+// WithDiagnostics sets a callback that receives the diagnostics of every
+// request attempt
+func WithDiagnostics(fn func(Diagnostics)) ClientOption {
+	return func(c *Client) {
+		c.OnDiagnostics = fn
+	}
+}
+
+// NewClient creates a new Anthropic API client. Settings are resolved from, in
+// increasing order of precedence, the defaults, the profile selected by
+// ANTHROPIC_PROFILE in the config file, the ANTHROPIC_API_KEY,
+// ANTHROPIC_AUTH_TOKEN, ANTHROPIC_BASE_URL and ANTHROPIC_API_VERSION
+// environment variables, and the options. The default config file is optional
+// and ignored when it cannot be read or parsed. If ANTHROPIC_PROFILE is set
+// but the profile cannot be loaded, every request of the client fails with the
+// error, use NewClientFromConfig to get it right away
 func NewClient(options ...ClientOption) *Client {
+	client, err := newClient(options)
+	if os.Getenv(EnvProfile) != "" {
+		client.configErr = err
+	}
+	return client
+}
+
+// NewClientFromConfig creates a client like NewClient, returning an error if
+// the config file exists but cannot be read or parsed, or the profile selected
+// by ANTHROPIC_PROFILE does not exist
+func NewClientFromConfig(options ...ClientOption) (*Client, error) {
+	client, err := newClient(options)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// newClient creates a client and returns it with the error loading its
+// profile from the config file
+func newClient(options []ClientOption) (*Client, error) {
 	client := &Client{
 		BaseURL:    DefaultBaseURL,
 		Version:    DefaultVersion,
 		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		lifecycle:  &lifecycle{},
 	}
 
+	profile, err := defaultProfile()
+	WithProfile(profile)(client)
+	WithProfile(environmentProfile())(client)
+
 	for _, option := range options {
 		option(client)
 	}
 
-	if client.APIKey == "" {
-		client.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+	return client, err
+}
+
+// Clone returns a copy of the client with the given options applied. The
+// original client is not modified, so derived clients can safely be created
+// while the original is in use
+func (c *Client) Clone(options ...ClientOption) *Client {
+	clone := *c
+	clone.Headers = c.Headers.Clone()
+	clone.Middleware = append([]Middleware(nil), c.Middleware...)
+	clone.Observers = append([]ExchangeObserver(nil), c.Observers...)
+	clone.RateLimitObservers = append([]RateLimitObserver(nil), c.RateLimitObservers...)
+
+	for _, option := range options {
+		option(&clone)
 	}
 
-	return client
+	return &clone
+}
+
+// marshal encodes a request body, in canonical form when CanonicalJSON is set
+func (c *Client) marshal(v interface{}) ([]byte, error) {
+	if c.CanonicalJSON {
+		return canonical.Marshal(v)
+	}
+	return json.Marshal(v)
 }
 
 // request makes an HTTP request to the Anthropic API
-func (c *Client) request(ctx context.Context, method, path string, reqBody interface{}, respBody interface{}) error {
-	url := fmt.Sprintf("%s/%s", c.BaseURL, path)
+func (c *Client) request(ctx context.Context, method, path string, reqBody interface{}, respBody interface{}, options ...RequestOption) error {
+	return c.instrument(ctx, path, requestModel(reqBody), func(ctx context.Context) error {
+		return c.send(ctx, method, path, reqBody, respBody, options)
+	})
+}
 
-	var body io.Reader
+// send makes an HTTP request and decodes its response
+func (c *Client) send(ctx context.Context, method, path string, reqBody interface{}, respBody interface{}, options []RequestOption) error {
+	cfg := newRequestConfig(ctx, options)
+
+	var body []byte
 	if reqBody != nil {
-		jsonBody, err := json.Marshal(reqBody)
+		jsonBody, err := c.marshal(reqBody)
 		if err != nil {
 			return fmt.Errorf("error marshaling request body: %w", err)
 		}
-		body = bytes.NewBuffer(jsonBody)
+		body = jsonBody
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	release, err := c.acquire(ctx, cfg)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return err
 	}
+	defer release()
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Api-Key", c.APIKey)
-	req.Header.Set("anthropic-version", c.Version)
-
-	resp, err := c.HTTPClient.Do(req)
+	exchange := newExchange(method, path, body)
+	span := cfg.timeline.Begin(method+" /"+path, "http", nil)
+	defer endRequestSpan(span, &exchange)
+	endRegion := c.region(ctx, "send")
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return c.newRequest(ctx, method, path, body, cfg)
+	})
+	endRegion()
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		exchange.Err = err
+		c.observe(exchange)
+		return err
 	}
 	defer resp.Body.Close()
 
-	respData, err := io.ReadAll(resp.Body)
+	exchange.setResponse(resp)
+	defer c.region(ctx, "read")()
+	respData, err := c.readBody(resp.Body)
+	if err != nil {
+		exchange.Err = fmt.Errorf("error reading response body: %w", err)
+		c.observe(exchange)
+		return exchange.Err
+	}
+	exchange.ResponseBody = respData
+	if err := c.observe(exchange); err != nil {
+		return err
+	}
+
+	if respBody != nil {
+		if err := json.Unmarshal(respData, respBody); err != nil {
+			return fmt.Errorf("error unmarshaling response: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// newRequest creates an HTTP request with the client's headers set
+func (c *Client) newRequest(ctx context.Context, method, path string, body []byte, cfg *requestConfig) (*http.Request, error) {
+	if c.configErr != nil {
+		return nil, c.configErr
+	}
+
+	baseURL := c.BaseURL
+	if c.Endpoints != nil {
+		baseURL = c.Endpoints.currentURL(c.guard())
+	}
+	url := fmt.Sprintf("%s/%s", baseURL, path)
+
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
-		return fmt.Errorf("error reading response body: %w", err)
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	for key, values := range c.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	for key, values := range cfg.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if betas := req.Header.Values(BetaHeader); len(betas) > 1 {
+		req.Header.Set(BetaHeader, joinBetas(betas))
+	}
+
+	version := c.Version
+	if cfg.version != "" {
+		version = cfg.version
 	}
 
-	if resp.StatusCode >= 400 {
-		apiErr := ParseAPIError(resp.StatusCode, respData)
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" || c.AuthToken == "" {
+		req.Header.Set("X-Api-Key", c.APIKey)
+	}
+	if c.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.AuthToken)
+	}
+	req.Header.Set("anthropic-version", version)
+
+	if body != nil {
+		setBody(req, body)
+	}
 
-		if requestID := resp.Header.Get("x-request-id"); requestID != "" {
-			apiErr.RequestID = requestID
+	if c.Signer != nil {
+		err := c.guard().Call("signer", func() error {
+			return c.Signer(req, body)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error signing request: %w", err)
 		}
+	}
+
+	return req, nil
+}
+
+// do sends the request created by newRequest, retrying according to the
+// client's retry policy. Responses with an error status are returned as an
+// *APIError once retries are exhausted
+func (c *Client) do(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	policy := c.RetryPolicy
+	start := time.Now()
+	var delay time.Duration
 
-		if apiErr.IsRateLimitError() {
-			apiErr.RateLimitInfo = &RateLimitInfo{}
-			if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
-				if seconds, err := strconv.Atoi(retryAfter); err == nil {
-					apiErr.RateLimitInfo.ResetAfter = seconds
-				}
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		req, report := c.traceRequest(req, attempt)
+		resp, err := c.HTTPClient.Do(req)
+		if reportErr := report(resp, err); reportErr != nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, reportErr
+		}
+		if c.Endpoints != nil && ctx.Err() == nil {
+			c.Endpoints.observe(req.URL.String(), resp, err, c.guard())
+		}
+		if err != nil {
+			err = fmt.Errorf("error making request: %w", err)
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			next, retry := shouldRetry(policy, attempt, delay, nil, err)
+			if !retry {
+				return nil, err
+			}
+			delay = next
+			if waitErr := c.waitRetry(ctx, policy, start, RetryEvent{Attempt: attempt, Delay: delay, Err: err}); waitErr != nil {
+				return nil, retryError(err, waitErr)
 			}
-			apiErr.RateLimitInfo.LimitType = resp.Header.Get("x-ratelimit-limit-type")
+			continue
 		}
 
-		return apiErr
+		if err := c.observeRateLimits(resp); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if resp.StatusCode < 400 {
+			return resp, nil
+		}
+
+		respData, err := c.readBody(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading error response: %w (status code: %d)", err, resp.StatusCode)
+		}
+
+		apiErr := newAPIError(resp, respData)
+		next, retry := shouldRetry(policy, attempt, delay, resp, apiErr)
+		if !retry {
+			return nil, apiErr
+		}
+		delay = next
+		event := RetryEvent{Attempt: attempt, StatusCode: resp.StatusCode, Delay: delay, Err: apiErr}
+		if waitErr := c.waitRetry(ctx, policy, start, event); waitErr != nil {
+			return nil, retryError(apiErr, waitErr)
+		}
 	}
+}
 
-	if respBody != nil {
-		if err := json.Unmarshal(respData, respBody); err != nil {
-			return fmt.Errorf("error unmarshaling response: %w", err)
+// newAPIError builds an APIError from an error response
+func newAPIError(resp *http.Response, data []byte) *APIError {
+	apiErr := ParseAPIError(resp.StatusCode, data)
+
+	if requestID := resp.Header.Get("x-request-id"); requestID != "" {
+		apiErr.RequestID = requestID
+	}
+
+	if apiErr.IsRateLimitError() {
+		apiErr.RateLimitInfo = &RateLimitInfo{}
+		if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil {
+				apiErr.RateLimitInfo.ResetAfter = seconds
+			}
 		}
+		apiErr.RateLimitInfo.LimitType = resp.Header.Get("x-ratelimit-limit-type")
+		apiErr.RateLimitInfo.Limits = ParseRateLimits(resp.Header)
 	}
 
-	return nil
+	return apiErr
 }
 
 // post makes a POST request to the Anthropic API
-func (c *Client) post(ctx context.Context, path string, reqBody, respBody interface{}) error {
-	return c.request(ctx, http.MethodPost, path, reqBody, respBody)
+func (c *Client) post(ctx context.Context, path string, reqBody, respBody interface{}, options ...RequestOption) error {
+	return c.request(ctx, http.MethodPost, path, reqBody, respBody, options...)
+}
+
+// DoRequest makes a request to any API endpoint, including new or
+// undocumented ones, with the client's authentication, headers, retries,
+// concurrency limits and observers. The body is marshaled to JSON unless nil,
+// pass a json.RawMessage to send pre-encoded JSON. The response is decoded into
+// into unless nil, pass a *json.RawMessage to keep it as is. Error responses
+// are returned as an *APIError
+func (c *Client) DoRequest(ctx context.Context, method, path string, body, into interface{}, options ...RequestOption) error {
+	return c.request(ctx, method, strings.TrimPrefix(path, "/"), body, into, options...)
 }
@@ -0,0 +1,66 @@
+// Command canary runs a suite of saved requests against two models and prints
+// a comparison report
+//
+//	canary -suite suite.jsonl -baseline claude-3-5-sonnet-latest -candidate claude-3-7-sonnet-latest
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/canary"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+func main() {
+	suite := flag.String("suite", "", "path of the JSON lines suite, one {\"name\", \"request\"} object per line")
+	baseline := flag.String("baseline", models.Claude35SonnetLatest, "baseline model")
+	candidate := flag.String("candidate", models.Claude37SonnetLatest, "candidate model")
+	judge := flag.String("judge", "", "model scoring the responses, disabled when empty")
+	rubric := flag.String("rubric", "", "rubric given to the judge")
+	jsonOutput := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	if *suite == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*suite, *baseline, *candidate, *judge, *rubric, *jsonOutput); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(suite, baseline, candidate, judge, rubric string, jsonOutput bool) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cases, err := canary.LoadSuiteFile(suite)
+	if err != nil {
+		return err
+	}
+
+	client := anthropic.NewClient(anthropic.WithRetryPolicy(anthropic.DefaultRetryPolicy()))
+	runner := &canary.Runner{Client: client, Baseline: baseline, Candidate: candidate}
+	if judge != "" {
+		runner.Judge = canary.ModelJudge(client, judge, rubric)
+	}
+
+	report, err := runner.Run(ctx, cases)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
@@ -0,0 +1,57 @@
+// Command openapi-tools generates Go source exposing the operations of an
+// OpenAPI 3 document in JSON as tools. It is meant to be run by go generate:
+//
+//	//go:generate go run github.com/joakimcarlsson/anthropic-sdk/cmd/openapi-tools -spec petstore.json -package petstore -func Tools -o tools_gen.go
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools/openapi"
+)
+
+func main() {
+	spec := flag.String("spec", "", "path of the OpenAPI 3 document in JSON")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package name of the generated file, defaults to $GOPACKAGE")
+	funcName := flag.String("func", "Tools", "name of the generated function")
+	operations := flag.String("operations", "", "comma separated operations to include, all when empty")
+	output := flag.String("o", "", "output file, standard output when empty")
+	flag.Parse()
+
+	if *spec == "" || *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(*spec, *pkg, *funcName, *operations, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, pkg, funcName, operations, output string) error {
+	spec, err := openapi.Load(specPath)
+	if err != nil {
+		return err
+	}
+
+	opts := openapi.GenerateOptions{Package: pkg, Func: funcName}
+	if operations != "" {
+		opts.Operations = strings.Split(operations, ",")
+	}
+
+	var buf bytes.Buffer
+	if err := openapi.Generate(&buf, spec, opts); err != nil {
+		return err
+	}
+
+	if output == "" {
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return os.WriteFile(output, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,95 @@
+// Package codegen generates code with the model. Fenced code blocks are
+// extracted from the response and can be verified by a callback, for example
+// by compiling them, with failures sent back to the model to fix
+package codegen
+
+import (
+	"strings"
+)
+
+// Block is a fenced code block
+type Block struct {
+	// Language is the first word of the info string, such as "go"
+	Language string
+
+	// Info is the full info string following the opening fence
+	Info string
+
+	Code string
+}
+
+// Blocks returns the fenced code blocks of markdown text in order. Both
+// backtick and tilde fences are recognized, and an unclosed block runs to the
+// end of the text
+func Blocks(text string) []Block {
+	var blocks []Block
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		fence, info, ok := openingFence(lines[i])
+		if !ok {
+			continue
+		}
+
+		var code []string
+		for i++; i < len(lines); i++ {
+			if closingFence(lines[i], fence) {
+				break
+			}
+			code = append(code, lines[i])
+		}
+
+		language, _, _ := strings.Cut(info, " ")
+		blocks = append(blocks, Block{
+			Language: strings.ToLower(language),
+			Info:     info,
+			Code:     strings.Join(code, "\n"),
+		})
+	}
+	return blocks
+}
+
+// BlocksFor returns the fenced code blocks with the given language. Blocks
+// without a language tag are included too, as models sometimes omit it
+func BlocksFor(text, language string) []Block {
+	var blocks []Block
+	for _, block := range Blocks(text) {
+		if block.Language == "" || strings.EqualFold(block.Language, language) {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// openingFence parses a line opening a code block, returning the fence and the
+// info string
+func openingFence(line string) (string, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return "", "", false
+	}
+	char := trimmed[0]
+	if char != '`' && char != '~' {
+		return "", "", false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == char {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+	info := strings.TrimSpace(trimmed[n:])
+	if char == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return trimmed[:n], info, true
+}
+
+// closingFence reports whether the line closes a block opened with fence
+func closingFence(line, fence string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(trimmed) < len(fence) {
+		return false
+	}
+	return strings.Trim(trimmed, fence[:1]) == ""
+}
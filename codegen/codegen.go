@@ -0,0 +1,178 @@
+package codegen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultMaxTokens is the default response limit
+	DefaultMaxTokens = 4096
+
+	// DefaultMaxFixes is the default number of times the model is asked to fix
+	// code failing verification
+	DefaultMaxFixes = 2
+)
+
+// ErrNoCode is reported when a response contains no code block in the
+// requested language
+var ErrNoCode = errors.New("response contains no code block")
+
+// VerifyError is returned when the code still fails verification after the
+// maximum number of fixes
+type VerifyError struct {
+	Attempts int
+	Err      error
+}
+
+// Error implements the error interface
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("code failed verification after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// Unwrap returns the last verification error
+func (e *VerifyError) Unwrap() error {
+	return e.Err
+}
+
+// Generator generates code and asks the model to fix it until it passes
+// verification
+type Generator struct {
+	Client anthropic.ChatProvider
+	Model  string
+
+	// System is sent with every request
+	System string
+
+	// Language restricts the extracted blocks to the language when set, such
+	// as "go", and is mentioned in the prompt
+	Language string
+
+	// MaxTokens limits every response, defaults to DefaultMaxTokens
+	MaxTokens int
+
+	// MaxFixes is the number of times the model is asked to fix code failing
+	// verification, defaults to DefaultMaxFixes
+	MaxFixes int
+
+	// Verify checks the extracted blocks when set, for example by compiling
+	// them. The error text is sent to the model as feedback, so compiler
+	// output makes a good error
+	Verify func(ctx context.Context, blocks []Block) error
+}
+
+// Result is the outcome of a generation
+type Result struct {
+	// Blocks are the code blocks of the final response
+	Blocks []Block
+
+	// Message is the final response
+	Message *models.Message
+
+	// Attempts is the number of requests made
+	Attempts int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// Code returns the code of all blocks joined by blank lines
+func (r *Result) Code() string {
+	code := make([]string, len(r.Blocks))
+	for i, block := range r.Blocks {
+		code[i] = block.Code
+	}
+	return strings.Join(code, "\n\n")
+}
+
+// New creates a generator for the language
+func New(client anthropic.ChatProvider, model, language string) *Generator {
+	return &Generator{Client: client, Model: model, Language: language}
+}
+
+// Generate asks for code for the prompt and extracts its code blocks. A
+// response without code or failing Verify is answered with the problem and the
+// model asked to fix it. When the fixes are used up the last result is
+// returned together with a *VerifyError
+func (g *Generator) Generate(ctx context.Context, prompt string) (*Result, error) {
+	instruction := prompt
+	if g.Language != "" {
+		instruction += fmt.Sprintf("\n\nAnswer with the complete code in fenced %s code blocks.", g.Language)
+	}
+	messages := []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(instruction))}
+
+	result := &Result{}
+	for {
+		resp, err := g.Client.CreateMessage(ctx, models.MessageRequest{
+			Model:     g.Model,
+			System:    g.System,
+			MaxTokens: g.maxTokens(),
+			Messages:  messages,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error generating code: %w", err)
+		}
+		result.Attempts++
+		result.Usage = result.Usage.Add(resp.Usage)
+		result.Message = resp
+		result.Blocks = g.blocks(resp.Text())
+
+		verifyErr := g.verify(ctx, result.Blocks)
+		if verifyErr == nil {
+			return result, nil
+		}
+		if result.Attempts > g.maxFixes() {
+			return result, &VerifyError{Attempts: result.Attempts, Err: verifyErr}
+		}
+
+		messages = append(messages, resp.ToParam(), models.NewUserMessage(models.CreateTextBlock(fixPrompt(verifyErr))))
+	}
+}
+
+// blocks extracts the code blocks in the generator's language
+func (g *Generator) blocks(text string) []Block {
+	if g.Language == "" {
+		return Blocks(text)
+	}
+	return BlocksFor(text, g.Language)
+}
+
+// verify checks that there is code and runs Verify on it
+func (g *Generator) verify(ctx context.Context, blocks []Block) error {
+	if len(blocks) == 0 {
+		return ErrNoCode
+	}
+	if g.Verify == nil {
+		return nil
+	}
+	return g.Verify(ctx, blocks)
+}
+
+// fixPrompt asks the model to fix the code given the verification error
+func fixPrompt(err error) string {
+	if errors.Is(err, ErrNoCode) {
+		return "Your answer contains no fenced code block. Answer with the complete code in a fenced code block."
+	}
+	return fmt.Sprintf("The code fails verification:\n\n<error>\n%s\n</error>\n\nFix the code and answer with the complete corrected code in fenced code blocks.", err)
+}
+
+// maxTokens returns the response limit
+func (g *Generator) maxTokens() int {
+	if g.MaxTokens > 0 {
+		return g.MaxTokens
+	}
+	return DefaultMaxTokens
+}
+
+// maxFixes returns the number of fix requests allowed
+func (g *Generator) maxFixes() int {
+	if g.MaxFixes > 0 {
+		return g.MaxFixes
+	}
+	return DefaultMaxFixes
+}
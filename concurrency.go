@@ -0,0 +1,125 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ConcurrencyLimiter caps the number of requests in flight at once, queueing
+// the rest in arrival order. A streaming request holds its slot until the
+// stream is read to the end or closed
+type ConcurrencyLimiter struct {
+	slots chan struct{}
+}
+
+// NewConcurrencyLimiter creates a limiter allowing max requests in flight, max
+// below one is treated as one
+func NewConcurrencyLimiter(max int) *ConcurrencyLimiter {
+	if max < 1 {
+		max = 1
+	}
+	return &ConcurrencyLimiter{slots: make(chan struct{}, max)}
+}
+
+// Acquire waits for a free slot, returning a function that releases it. The
+// release function may be called more than once
+func (l *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("error waiting for a request slot: %w", ctx.Err())
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { <-l.slots })
+	}, nil
+}
+
+// InFlight returns the number of requests currently holding a slot
+func (l *ConcurrencyLimiter) InFlight() int {
+	return len(l.slots)
+}
+
+// Max returns the number of requests allowed in flight at once
+func (l *ConcurrencyLimiter) Max() int {
+	return cap(l.slots)
+}
+
+// WithMaxConcurrentRequests caps the requests and streams in flight across the
+// client and the clients cloned from it, queueing the rest
+func WithMaxConcurrentRequests(max int) ClientOption {
+	return func(c *Client) {
+		c.Concurrency = NewConcurrencyLimiter(max)
+	}
+}
+
+// WithConcurrencyLimiter limits a request with a limiter in addition to the
+// client's, so groups of calls such as those of a tenant can be capped on
+// their own
+func WithConcurrencyLimiter(limiter *ConcurrencyLimiter) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.limiters = append(cfg.limiters, limiter)
+	}
+}
+
+// acquire registers a request with the client's lifecycle and takes a slot of
+// the client's limiter and the request's limiters, returning a function
+// releasing all of them
+func (c *Client) acquire(ctx context.Context, cfg *requestConfig) (func(), error) {
+	limiters := cfg.limiters
+	if c.Concurrency != nil {
+		limiters = append([]*ConcurrencyLimiter{c.Concurrency}, limiters...)
+	}
+
+	releases := make([]func(), 0, len(limiters)+1)
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	if c.lifecycle != nil {
+		done, err := c.lifecycle.begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, done)
+	}
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+		r, err := limiter.Acquire(ctx)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+
+	return release, nil
+}
+
+// releasingBody releases a request slot once the response body is read to the
+// end, fails or is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+// Read implements io.Reader
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		b.release()
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,185 @@
+package anthropic
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Environment variables read by NewClient
+const (
+	EnvAPIKey     = "ANTHROPIC_API_KEY"
+	EnvAuthToken  = "ANTHROPIC_AUTH_TOKEN"
+	EnvBaseURL    = "ANTHROPIC_BASE_URL"
+	EnvAPIVersion = "ANTHROPIC_API_VERSION"
+	EnvProfile    = "ANTHROPIC_PROFILE"
+	EnvConfigFile = "ANTHROPIC_CONFIG_FILE"
+)
+
+// DefaultProfile is the profile used when ANTHROPIC_PROFILE is not set
+const DefaultProfile = "default"
+
+// Profile is a named set of client settings from the config file
+type Profile struct {
+	APIKey    string
+	AuthToken string
+	BaseURL   string
+	Version   string
+}
+
+// Config holds the profiles of a config file
+type Config struct {
+	Profiles map[string]Profile
+}
+
+// DefaultConfigPath returns the path of the config file, ANTHROPIC_CONFIG_FILE
+// if set and ~/.anthropic/config otherwise
+func DefaultConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfigFile); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating config file: %w", err)
+	}
+	return filepath.Join(home, ".anthropic", "config"), nil
+}
+
+// LoadConfig reads a config file. The file contains one section per profile,
+// with the keys api_key, auth_token, base_url and api_version:
+//
+//	[default]
+//	api_key = sk-ant-...
+//
+//	[profile staging]
+//	base_url = https://gateway.example.com
+//	auth_token = ...
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening config file: %w", err)
+	}
+	defer f.Close()
+
+	config := &Config{Profiles: make(map[string]Profile)}
+	var name string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+			name = strings.TrimSpace(strings.TrimPrefix(strings.Trim(text, "[]"), "profile "))
+			if _, ok := config.Profiles[name]; !ok {
+				config.Profiles[name] = Profile{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("error parsing config file: line %d: expected a key = value pair in a profile section", line)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.Trim(strings.TrimSpace(value), `"'`)
+
+		profile := config.Profiles[name]
+		switch key {
+		case "api_key":
+			profile.APIKey = value
+		case "auth_token":
+			profile.AuthToken = value
+		case "base_url":
+			profile.BaseURL = value
+		case "api_version":
+			profile.Version = value
+		default:
+			return nil, fmt.Errorf("error parsing config file: line %d: unknown key %q", line, key)
+		}
+		config.Profiles[name] = profile
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	return config, nil
+}
+
+// Profile returns the profile with the given name
+func (c *Config) Profile(name string) (Profile, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found in config file", name)
+	}
+	return profile, nil
+}
+
+// WithProfile applies the non-empty settings of a profile
+func WithProfile(profile Profile) ClientOption {
+	return func(c *Client) {
+		if profile.APIKey != "" {
+			c.APIKey = profile.APIKey
+		}
+		if profile.AuthToken != "" {
+			c.AuthToken = profile.AuthToken
+		}
+		if profile.BaseURL != "" {
+			c.BaseURL = profile.BaseURL
+		}
+		if profile.Version != "" {
+			c.Version = profile.Version
+		}
+	}
+}
+
+// defaultProfile returns the profile selected by ANTHROPIC_PROFILE from the
+// default config file. The file is optional, so a missing file yields an empty
+// profile, unless ANTHROPIC_PROFILE selects a profile that must exist
+func defaultProfile() (Profile, error) {
+	name := os.Getenv(EnvProfile)
+	if name == "" {
+		path, err := DefaultConfigPath()
+		if err != nil {
+			return Profile{}, nil
+		}
+		config, err := LoadConfig(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return Profile{}, nil
+		}
+		if err != nil {
+			return Profile{}, err
+		}
+		return config.Profiles[DefaultProfile], nil
+	}
+
+	path, err := DefaultConfigPath()
+	if err != nil {
+		return Profile{}, fmt.Errorf("error loading profile %q selected by %s: %w", name, EnvProfile, err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return Profile{}, fmt.Errorf("error loading profile %q selected by %s: %w", name, EnvProfile, err)
+	}
+	profile, err := config.Profile(name)
+	if err != nil {
+		return Profile{}, fmt.Errorf("error loading profile selected by %s: %w", EnvProfile, err)
+	}
+	return profile, nil
+}
+
+// environmentProfile returns the settings from environment variables
+func environmentProfile() Profile {
+	return Profile{
+		APIKey:    os.Getenv(EnvAPIKey),
+		AuthToken: os.Getenv(EnvAuthToken),
+		BaseURL:   os.Getenv(EnvBaseURL),
+		Version:   os.Getenv(EnvAPIVersion),
+	}
+}
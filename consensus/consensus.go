@@ -0,0 +1,310 @@
+// Package consensus implements self-consistency sampling: several responses
+// are sampled concurrently at a non-zero temperature and aggregated into one
+// answer, by majority vote or by a judge model, which improves accuracy on
+// reasoning tasks with a single correct answer
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultSamples is the number of responses sampled when Samples is not set
+const DefaultSamples = 5
+
+// DefaultTemperature is the sampling temperature of requests that do not set
+// one
+const DefaultTemperature = 1.0
+
+// DefaultJudgeTemplate is the prompt sent to the judge model. It is executed
+// with a Prompt field holding the text of the last user message and a Samples
+// field holding the candidate answers
+const DefaultJudgeTemplate = `Several candidate answers were written independently for the task below.
+
+<task>
+{{.Prompt}}
+</task>
+{{range $i, $sample := .Samples}}
+<candidate index="{{$i}}">
+{{$sample}}
+</candidate>
+{{end}}
+Pick the candidate that is most likely correct, preferring the conclusion most candidates agree on when their reasoning is sound. Record your choice with the choose tool.`
+
+// ErrNoSamples is returned when every sample failed
+var ErrNoSamples = errors.New("consensus: every sample failed")
+
+// judgeTool is the name of the tool the judge records its choice with
+const judgeTool = "choose"
+
+// Sample is one of the sampled responses
+type Sample struct {
+	// Message is the response, nil when the request failed
+	Message *models.Message
+
+	// Answer is the normalized answer the sample votes for
+	Answer string
+
+	// Err is the error of a failed request
+	Err error
+
+	// Latency is the duration of the request
+	Latency time.Duration
+}
+
+// Judge picks the consensus answer with a model instead of a majority vote
+type Judge struct {
+	// Model judges the samples
+	Model string
+
+	// MaxTokens limits the judge's response, defaults to 1024
+	MaxTokens int
+
+	// Template builds the judge prompt, defaults to DefaultJudgeTemplate
+	Template *template.Template
+}
+
+// Sampler samples several responses to a request and aggregates them
+type Sampler struct {
+	Client anthropic.ChatProvider
+
+	// Samples is the number of responses sampled, defaults to DefaultSamples
+	Samples int
+
+	// Concurrency limits the requests in flight, defaults to all samples at
+	// once
+	Concurrency int
+
+	// Normalize maps a response text to the answer it votes for, defaults to
+	// Normalize
+	Normalize func(text string) string
+
+	// Judge picks the answer with a model when set, instead of a majority vote
+	Judge *Judge
+}
+
+// Result is the aggregated outcome of the samples
+type Result struct {
+	// Answer is the consensus answer, normalized for majority votes
+	Answer string
+
+	// Message is the first sample voting for the answer, or the sample picked
+	// by the judge
+	Message *models.Message
+
+	// Votes counts the successful samples per normalized answer
+	Votes map[string]int
+
+	// Agreement is the share of successful samples voting for the answer
+	Agreement float64
+
+	// Samples lists every sample in the order they were started
+	Samples []Sample
+
+	// Reason is the judge's explanation of its choice
+	Reason string
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// defaultJudgeTemplate is the parsed DefaultJudgeTemplate
+var defaultJudgeTemplate = template.Must(template.New("judge").Parse(DefaultJudgeTemplate))
+
+// New creates a sampler taking the given number of samples
+func New(client anthropic.ChatProvider, samples int) *Sampler {
+	return &Sampler{Client: client, Samples: samples}
+}
+
+// Normalize lowercases text, collapses whitespace and trims trailing
+// punctuation, so answers differing only in formatting vote together
+func Normalize(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimRight(text, ".!")
+}
+
+// FinalLine normalizes the last non-empty line of text, for prompts asking
+// for the answer on the last line after the reasoning
+func FinalLine(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	return Normalize(lines[len(lines)-1])
+}
+
+// Run samples responses to the request concurrently and returns the consensus
+// answer. Failed samples are recorded and left out of the vote, the run fails
+// only when every sample failed
+func (s *Sampler) Run(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*Result, error) {
+	if req.Temperature == nil && req.Thinking == nil {
+		temperature := DefaultTemperature
+		req.Temperature = &temperature
+	}
+
+	samples := s.sample(ctx, req, options)
+	result := &Result{Samples: samples, Votes: make(map[string]int)}
+
+	var successful []int
+	for i, sample := range samples {
+		if sample.Err != nil {
+			continue
+		}
+		successful = append(successful, i)
+		result.Usage = result.Usage.Add(sample.Message.Usage)
+		result.Votes[sample.Answer]++
+	}
+	if len(successful) == 0 {
+		return nil, fmt.Errorf("%w: %w", ErrNoSamples, samples[0].Err)
+	}
+
+	chosen := successful[0]
+	if s.Judge != nil {
+		index, err := s.judge(ctx, req, samples, successful, result)
+		if err != nil {
+			return nil, err
+		}
+		chosen = index
+	} else {
+		for _, i := range successful {
+			if result.Votes[samples[i].Answer] > result.Votes[samples[chosen].Answer] {
+				chosen = i
+			}
+		}
+	}
+
+	result.Answer = samples[chosen].Answer
+	result.Message = samples[chosen].Message
+	result.Agreement = float64(result.Votes[result.Answer]) / float64(len(successful))
+	return result, nil
+}
+
+// sample sends the request Samples times, at most Concurrency at once
+func (s *Sampler) sample(ctx context.Context, req models.MessageRequest, options []anthropic.RequestOption) []Sample {
+	count := s.Samples
+	if count <= 0 {
+		count = DefaultSamples
+	}
+	concurrency := s.Concurrency
+	if concurrency <= 0 || concurrency > count {
+		concurrency = count
+	}
+	normalize := s.Normalize
+	if normalize == nil {
+		normalize = Normalize
+	}
+
+	samples := make([]Sample, count)
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func(sample *Sample) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			start := time.Now()
+			sample.Message, sample.Err = s.Client.CreateMessage(ctx, req, options...)
+			sample.Latency = time.Since(start)
+			if sample.Err == nil {
+				sample.Answer = normalize(sample.Message.Text())
+			}
+		}(&samples[i])
+	}
+	wg.Wait()
+
+	return samples
+}
+
+// judge asks the judge model to pick one of the successful samples
+func (s *Sampler) judge(ctx context.Context, req models.MessageRequest, samples []Sample, successful []int, result *Result) (int, error) {
+	candidates := make([]string, len(successful))
+	for i, index := range successful {
+		candidates[i] = samples[index].Message.Text()
+	}
+
+	tmpl := s.Judge.Template
+	if tmpl == nil {
+		tmpl = defaultJudgeTemplate
+	}
+	var prompt bytes.Buffer
+	data := struct {
+		Prompt  string
+		Samples []string
+	}{Prompt: lastUserText(req.Messages), Samples: candidates}
+	if err := tmpl.Execute(&prompt, data); err != nil {
+		return 0, fmt.Errorf("error executing judge template: %w", err)
+	}
+
+	type choice struct {
+		Index  int    `json:"index" description:"The index of the chosen candidate"`
+		Reason string `json:"reason" description:"Why the candidate was chosen"`
+	}
+	schema, err := models.SchemaFor(reflect.TypeFor[choice]())
+	if err != nil {
+		return 0, fmt.Errorf("error creating judge schema: %w", err)
+	}
+	toolChoice := models.SpecificToolChoice(judgeTool, true)
+
+	maxTokens := s.Judge.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 1024
+	}
+	resp, err := s.Client.CreateMessage(ctx, models.MessageRequest{
+		Model:      s.Judge.Model,
+		MaxTokens:  maxTokens,
+		Messages:   []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(prompt.String()))},
+		Tools:      []models.Tool{models.NewTool(judgeTool, "Record the chosen candidate", schema)},
+		ToolChoice: &toolChoice,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error judging samples: %w", err)
+	}
+	result.Usage = result.Usage.Add(resp.Usage)
+
+	for _, block := range resp.Content {
+		if block.ToolUseContent == nil || block.ToolUseContent.Name != judgeTool {
+			continue
+		}
+		data, err := json.Marshal(block.ToolUseContent.Input)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding judge choice: %w", err)
+		}
+		var picked choice
+		if err := json.Unmarshal(data, &picked); err != nil {
+			return 0, fmt.Errorf("error decoding judge choice: %w", err)
+		}
+		if picked.Index < 0 || picked.Index >= len(successful) {
+			return 0, fmt.Errorf("error judging samples: candidate %d out of range [0, %d)", picked.Index, len(successful))
+		}
+		result.Reason = picked.Reason
+		return successful[picked.Index], nil
+	}
+	return 0, fmt.Errorf("error judging samples: the judge made no choice")
+}
+
+// lastUserText returns the text of the last user message
+func lastUserText(messages []models.MessageParam) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != models.UserRole {
+			continue
+		}
+		var parts []string
+		for _, block := range messages[i].Content {
+			if block.TextContent != nil {
+				parts = append(parts, block.TextContent.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
@@ -0,0 +1,196 @@
+package conversation
+
+import (
+	"encoding/json"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultCacheBreakpoints is the number of breakpoints placed in the history
+// by default, leaving one of the four allowed per request for the system
+// prompt and tools
+const DefaultCacheBreakpoints = 3
+
+// DefaultCacheMinTokens is the estimated size the history must grow by before
+// an earlier breakpoint is kept, matching the smallest cacheable prompt of
+// most models
+const DefaultCacheMinTokens = 1024
+
+// Cache prices relative to uncached input tokens, used to measure savings
+const (
+	cacheReadPrice  = 0.1
+	cacheWritePrice = 1.25
+)
+
+// CacheStrategy keeps the full history and marks its stable prefix for prompt
+// caching. The newest breakpoint follows the end of the history, so every
+// turn is written to the cache, and earlier breakpoints are kept at least
+// MinTokens apart so long histories are still read from the cache after the
+// newest breakpoint moves on. The oldest breakpoint is dropped once there are
+// more than Breakpoints
+type CacheStrategy struct {
+	// Breakpoints is the number of breakpoints placed in the history, defaults
+	// to DefaultCacheBreakpoints. A request allows four including those of
+	// the system prompt and tools, so the oldest breakpoints of the history
+	// are left out of requests whose system prompt or tools hold some
+	Breakpoints int
+
+	// MinTokens is the estimated size of the history between kept
+	// breakpoints, defaults to DefaultCacheMinTokens
+	MinTokens int
+
+	// CacheSystem places a breakpoint after the system prompt, or after the
+	// tools when there is no system prompt
+	CacheSystem bool
+}
+
+// CacheSavings measures the effect of prompt caching on a conversation
+type CacheSavings struct {
+	// Requests is the number of responses measured
+	Requests int
+
+	// Usage is the combined usage of the responses
+	Usage models.Usage
+
+	// SavedTokens is the number of input tokens saved, in uncached input
+	// token prices. Cache writes cost more than uncached input, so it is
+	// negative until the cache is read often enough
+	SavedTokens float64
+}
+
+// SavedRatio returns the share of the uncached input cost that was saved
+func (s CacheSavings) SavedRatio() float64 {
+	total := s.Usage.TotalInputTokens()
+	if total == 0 {
+		return 0
+	}
+	return s.SavedTokens / float64(total)
+}
+
+// WithPromptCaching enables rotating prompt caching breakpoints
+func WithPromptCaching(strategy CacheStrategy) Option {
+	return func(c *Conversation) {
+		c.CacheStrategy = &strategy
+	}
+}
+
+// CacheSavings returns the measured cache savings of the responses received
+// while a cache strategy was set
+func (c *Conversation) CacheSavings() CacheSavings {
+	return c.cacheSavings
+}
+
+// recordCacheUsage measures the cache savings of a response
+func (c *Conversation) recordCacheUsage(usage models.Usage) {
+	if c.CacheStrategy == nil {
+		return
+	}
+
+	c.cacheSavings.Requests++
+	c.cacheSavings.Usage = c.cacheSavings.Usage.Add(usage)
+	c.cacheSavings.SavedTokens += float64(usage.CacheReadInputTokens)*(1-cacheReadPrice) -
+		float64(usage.CacheCreationInputTokens)*(cacheWritePrice-1)
+}
+
+// rotateCacheBreakpoints moves the newest breakpoint to the end of the
+// history. The previous newest breakpoint is kept when the history grew by at
+// least MinTokens since the breakpoint before it
+func (c *Conversation) rotateCacheBreakpoints() {
+	if c.CacheStrategy == nil || len(c.messages) == 0 {
+		return
+	}
+
+	var points []int
+	for _, point := range c.cachePoints {
+		if point < len(c.messages) {
+			points = append(points, point)
+		}
+	}
+
+	last := len(c.messages) - 1
+	if n := len(points); n > 0 && points[n-1] != last {
+		anchors, moving := points[:n-1], points[n-1]
+		start := 0
+		if len(anchors) > 0 {
+			start = anchors[len(anchors)-1] + 1
+		}
+		if len(anchors) == 0 || estimateMessageTokens(c.messages[start:moving+1]) >= c.CacheStrategy.minTokens() {
+			anchors = append(anchors, moving)
+		}
+		points = anchors
+	} else if n > 0 {
+		points = points[:n-1]
+	}
+	points = append(points, last)
+
+	if max := c.CacheStrategy.breakpoints(); len(points) > max {
+		points = points[len(points)-max:]
+	}
+	c.cachePoints = points
+}
+
+// cacheMessages applies the breakpoints to the request messages.
+// Breakpoints already on the request, such as those of the system prompt and
+// tools, count towards the limit of a request, and the oldest breakpoints of
+// the history are dropped to stay within it
+func (c *Conversation) cacheMessages(req *models.MessageRequest) {
+	if c.CacheStrategy == nil || len(c.cachePoints) == 0 {
+		return
+	}
+
+	points := c.cachePoints
+	available := max(models.MaxCacheBreakpoints-models.CacheBreakpoints(*req), 0)
+	if len(points) > available {
+		points = points[len(points)-available:]
+	}
+
+	for _, point := range points {
+		if point < len(req.Messages) {
+			req.Messages[point], _ = models.CacheMessage(req.Messages[point])
+		}
+	}
+}
+
+// cacheSystem places a breakpoint after the system prompt, or after the tools
+// preceding it in the cached prefix when there is no system prompt
+func (c *Conversation) cacheSystem(req *models.MessageRequest) {
+	if c.CacheStrategy == nil || !c.CacheStrategy.CacheSystem {
+		return
+	}
+
+	if len(req.SystemBlocks) == 0 && req.System != "" {
+		req.SystemBlocks = []models.TextBlock{{Type: models.TextContentType, Text: req.System}}
+	}
+	if n := len(req.SystemBlocks); n > 0 {
+		req.SystemBlocks = append([]models.TextBlock(nil), req.SystemBlocks...)
+		req.SystemBlocks[n-1].CacheControl = models.EphemeralCache()
+		return
+	}
+	req.Tools = models.CacheTools(req.Tools)
+}
+
+// breakpoints returns the number of breakpoints kept in the history, within
+// the limit of a request
+func (s *CacheStrategy) breakpoints() int {
+	if s.Breakpoints > 0 {
+		return min(s.Breakpoints, models.MaxCacheBreakpoints)
+	}
+	return DefaultCacheBreakpoints
+}
+
+// minTokens returns the size of the history between kept breakpoints
+func (s *CacheStrategy) minTokens() int {
+	if s.MinTokens > 0 {
+		return s.MinTokens
+	}
+	return DefaultCacheMinTokens
+}
+
+// estimateMessageTokens returns a rough estimate of the size of messages
+func estimateMessageTokens(messages []models.MessageParam) int {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return 0
+	}
+	return models.EstimateTokens(string(data))
+}
@@ -0,0 +1,254 @@
+// Package conversation manages multi-turn conversations with Claude, keeping
+// the message history and the request settings in one place
+package conversation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultMaxTokens is the max tokens used when none is configured
+const DefaultMaxTokens = 1024
+
+// Conversation holds the history of a conversation and the settings used to
+// continue it. A Conversation is not safe for concurrent use
+type Conversation struct {
+	Client    anthropic.ChatProvider
+	Model     string
+	System    string
+	MaxTokens int
+	Tools     []models.Tool
+
+	// SummaryStrategy compresses older turns into a rolling summary when set
+	SummaryStrategy *SummaryStrategy
+
+	// CacheStrategy places rotating prompt caching breakpoints in the history
+	// when set
+	CacheStrategy *CacheStrategy
+
+	// TitleModel generates titles, defaults to DefaultTitleModel
+	TitleModel string
+
+	messages []models.MessageParam
+	summary  string
+
+	// cachePoints are the indexes of the messages holding a breakpoint
+	cachePoints  []int
+	cacheSavings CacheSavings
+}
+
+// Option is a function that modifies a Conversation
+type Option func(*Conversation)
+
+// WithSystem sets the system prompt
+func WithSystem(system string) Option {
+	return func(c *Conversation) {
+		c.System = system
+	}
+}
+
+// WithMaxTokens sets the max tokens of each response
+func WithMaxTokens(maxTokens int) Option {
+	return func(c *Conversation) {
+		c.MaxTokens = maxTokens
+	}
+}
+
+// WithTools sets the tools available in the conversation
+func WithTools(tools ...models.Tool) Option {
+	return func(c *Conversation) {
+		c.Tools = tools
+	}
+}
+
+// WithMessages sets the initial history
+func WithMessages(messages ...models.MessageParam) Option {
+	return func(c *Conversation) {
+		c.messages = append([]models.MessageParam(nil), messages...)
+	}
+}
+
+// New creates a new conversation
+func New(client anthropic.ChatProvider, model string, options ...Option) *Conversation {
+	conv := &Conversation{
+		Client:    client,
+		Model:     model,
+		MaxTokens: DefaultMaxTokens,
+	}
+
+	for _, option := range options {
+		option(conv)
+	}
+
+	return conv
+}
+
+// Messages returns a copy of the history
+func (c *Conversation) Messages() []models.MessageParam {
+	return append([]models.MessageParam(nil), c.messages...)
+}
+
+// Len returns the number of messages in the history
+func (c *Conversation) Len() int {
+	return len(c.messages)
+}
+
+// Append adds messages to the history
+func (c *Conversation) Append(messages ...models.MessageParam) {
+	c.messages = append(c.messages, messages...)
+}
+
+// Request returns the request that continues the conversation
+func (c *Conversation) Request() models.MessageRequest {
+	return c.request(c.messages)
+}
+
+// request builds a request with the conversation settings and the messages
+func (c *Conversation) request(messages []models.MessageParam) models.MessageRequest {
+	req := models.MessageRequest{
+		Model:        c.Model,
+		System:       c.System,
+		SystemBlocks: c.systemBlocks(),
+		MaxTokens:    c.MaxTokens,
+		Tools:        c.Tools,
+		Messages:     append([]models.MessageParam(nil), messages...),
+	}
+	c.cacheSystem(&req)
+	c.cacheMessages(&req)
+	return req
+}
+
+// Send adds a user turn with the given content, sends the conversation and
+// adds the response to the history
+func (c *Conversation) Send(ctx context.Context, content ...models.ContentBlock) (*models.Message, error) {
+	c.messages = append(c.messages, models.NewUserMessage(content...))
+	return c.Continue(ctx)
+}
+
+// SendText adds a user turn with the given text and sends the conversation
+func (c *Conversation) SendText(ctx context.Context, text string) (*models.Message, error) {
+	return c.Send(ctx, models.CreateTextBlock(text))
+}
+
+// Continue sends the conversation as it is and adds the response to the
+// history. It is used after appending tool results. Older turns are first
+// summarized if a summary strategy is set and its thresholds are exceeded,
+// and the caching breakpoints are rotated if a cache strategy is set
+func (c *Conversation) Continue(ctx context.Context) (*models.Message, error) {
+	if err := c.summarizeIfNeeded(ctx); err != nil {
+		return nil, err
+	}
+	c.rotateCacheBreakpoints()
+
+	resp, err := c.Client.CreateMessage(ctx, c.Request())
+	if err != nil {
+		return nil, err
+	}
+	c.recordCacheUsage(resp.Usage)
+
+	c.messages = append(c.messages, resp.ToParam())
+	return resp, nil
+}
+
+// clone returns a copy of the conversation settings with the given history
+func (c *Conversation) clone(messages []models.MessageParam) *Conversation {
+	branch := *c
+	branch.Tools = append([]models.Tool(nil), c.Tools...)
+	branch.messages = messages
+	branch.cachePoints = append([]int(nil), c.cachePoints...)
+	return &branch
+}
+
+// Fork returns a new conversation branching off before the message at
+// atIndex. A trailing assistant turn whose tool calls would be left without
+// results is dropped, so the branch can be continued directly
+func (c *Conversation) Fork(atIndex int) (*Conversation, error) {
+	if atIndex < 0 || atIndex > len(c.messages) {
+		return nil, fmt.Errorf("fork index %d out of range [0, %d]", atIndex, len(c.messages))
+	}
+
+	messages := append([]models.MessageParam(nil), c.messages[:atIndex]...)
+	return c.clone(trimUnpairedToolUse(messages)), nil
+}
+
+// EditUserTurn returns a new conversation in which the user turn at index is
+// replaced by the new content and everything after it is dropped, ready to be
+// continued for the "edit and regenerate" flow. The original conversation is
+// not modified
+func (c *Conversation) EditUserTurn(index int, content ...models.ContentBlock) (*Conversation, error) {
+	if index < 0 || index >= len(c.messages) {
+		return nil, fmt.Errorf("message index %d out of range [0, %d)", index, len(c.messages))
+	}
+
+	original := c.messages[index]
+	if original.Role != models.UserRole {
+		return nil, fmt.Errorf("message %d is a %s turn, not a user turn", index, original.Role)
+	}
+	if hasToolResults(original) && !hasToolResultsFor(content, toolUseIDs(c.messages[:index])) {
+		return nil, fmt.Errorf("message %d answers tool calls and its replacement must contain their results", index)
+	}
+
+	messages := append([]models.MessageParam(nil), c.messages[:index]...)
+	messages = append(messages, models.NewUserMessage(content...))
+	return c.clone(messages), nil
+}
+
+// trimUnpairedToolUse drops a trailing assistant turn containing tool calls
+// that have no results
+func trimUnpairedToolUse(messages []models.MessageParam) []models.MessageParam {
+	if len(messages) == 0 {
+		return messages
+	}
+
+	last := messages[len(messages)-1]
+	if last.Role == models.AssistantRole && len(toolUseIDs(messages[len(messages)-1:])) > 0 {
+		return messages[:len(messages)-1]
+	}
+	return messages
+}
+
+// toolUseIDs returns the IDs of the tool calls in the last assistant turn
+func toolUseIDs(messages []models.MessageParam) map[string]bool {
+	ids := make(map[string]bool)
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != models.AssistantRole {
+			continue
+		}
+		for _, block := range messages[i].Content {
+			if block.ToolUseContent != nil {
+				ids[block.ToolUseContent.ID] = true
+			}
+		}
+		break
+	}
+	return ids
+}
+
+// hasToolResults reports whether a message contains tool results
+func hasToolResults(message models.MessageParam) bool {
+	for _, block := range message.Content {
+		if block.ToolResultContent != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// hasToolResultsFor reports whether the content answers every tool call
+func hasToolResultsFor(content []models.ContentBlock, ids map[string]bool) bool {
+	answered := make(map[string]bool)
+	for _, block := range content {
+		if block.ToolResultContent != nil {
+			answered[block.ToolResultContent.ToolUseID] = true
+		}
+	}
+	for id := range ids {
+		if !answered[id] {
+			return false
+		}
+	}
+	return true
+}
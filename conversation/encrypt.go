@@ -0,0 +1,170 @@
+package conversation
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// encryptionVersion is the version of the encrypted envelope format
+const encryptionVersion = 1
+
+// KeyProvider issues data keys for envelope encryption, typically backed by a
+// key management service. GenerateDataKey returns a new 256-bit key in plain
+// and encrypted form, and DecryptDataKey recovers the plain key. Only the
+// encrypted key is stored next to the data
+type KeyProvider interface {
+	GenerateDataKey(ctx context.Context) (plaintext, encrypted []byte, keyID string, err error)
+	DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error)
+}
+
+// envelope is the stored form of encrypted data
+type envelope struct {
+	Version      int    `json:"v"`
+	KeyID        string `json:"key_id"`
+	EncryptedKey []byte `json:"encrypted_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// EncryptedStore encrypts transcripts with AES-256-GCM before passing them to
+// the underlying store. Every transcript is encrypted with its own data key,
+// and the ID is authenticated so a transcript cannot be moved to another ID
+type EncryptedStore struct {
+	store Store
+	keys  KeyProvider
+}
+
+// NewEncryptedStore wraps a store with encryption
+func NewEncryptedStore(store Store, keys KeyProvider) *EncryptedStore {
+	return &EncryptedStore{store: store, keys: keys}
+}
+
+// Put encrypts data and stores it under id
+func (s *EncryptedStore) Put(ctx context.Context, id string, data []byte) error {
+	key, encryptedKey, keyID, err := s.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return fmt.Errorf("error generating data key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("error generating nonce: %w", err)
+	}
+
+	sealed, err := json.Marshal(envelope{
+		Version:      encryptionVersion,
+		KeyID:        keyID,
+		EncryptedKey: encryptedKey,
+		Nonce:        nonce,
+		Ciphertext:   gcm.Seal(nil, nonce, data, []byte(id)),
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding envelope: %w", err)
+	}
+
+	return s.store.Put(ctx, id, sealed)
+}
+
+// Get returns the decrypted data stored under id
+func (s *EncryptedStore) Get(ctx context.Context, id string) ([]byte, error) {
+	sealed, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		return nil, fmt.Errorf("error decoding envelope: %w", err)
+	}
+	if env.Version != encryptionVersion {
+		return nil, fmt.Errorf("unsupported envelope version %d", env.Version)
+	}
+
+	key, err := s.keys.DecryptDataKey(ctx, env.KeyID, env.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %w", err)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != gcm.NonceSize() {
+		return nil, errors.New("error decrypting transcript: invalid nonce")
+	}
+
+	data, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting transcript: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the data stored under id
+func (s *EncryptedStore) Delete(ctx context.Context, id string) error {
+	return s.store.Delete(ctx, id)
+}
+
+// StaticKeyProvider wraps data keys with a single master key held in memory,
+// for deployments without a key management service
+type StaticKeyProvider struct {
+	keyID string
+	gcm   cipher.AEAD
+}
+
+// NewStaticKeyProvider creates a key provider from a 16, 24 or 32 byte master
+// key. The key ID is stored with every transcript to support key rotation
+func NewStaticKeyProvider(keyID string, masterKey []byte) (*StaticKeyProvider, error) {
+	gcm, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &StaticKeyProvider{keyID: keyID, gcm: gcm}, nil
+}
+
+// GenerateDataKey returns a random data key and the key wrapped with the
+// master key
+func (p *StaticKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, "", err
+	}
+
+	nonce := make([]byte, p.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, "", err
+	}
+	return key, p.gcm.Seal(nonce, nonce, key, []byte(p.keyID)), p.keyID, nil
+}
+
+// DecryptDataKey unwraps a data key
+func (p *StaticKeyProvider) DecryptDataKey(ctx context.Context, keyID string, encrypted []byte) ([]byte, error) {
+	if keyID != p.keyID {
+		return nil, fmt.Errorf("unknown key ID %q", keyID)
+	}
+
+	nonceSize := p.gcm.NonceSize()
+	if len(encrypted) < nonceSize {
+		return nil, errors.New("encrypted data key too short")
+	}
+	return p.gcm.Open(nil, encrypted[:nonceSize], encrypted[nonceSize:], []byte(keyID))
+}
+
+// newGCM creates an AES-GCM cipher from a key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,98 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// validID restricts transcript IDs used as file names
+var validID = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// FileStore keeps each transcript in a file of a directory
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store keeping transcripts in dir, encrypted with
+// data keys from keys. Use NewPlaintextFileStore to store them unencrypted
+func NewFileStore(dir string, keys KeyProvider) (*EncryptedStore, error) {
+	if keys == nil {
+		return nil, errors.New("file store requires a key provider, use NewPlaintextFileStore to store transcripts unencrypted")
+	}
+
+	store, err := NewPlaintextFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncryptedStore(store, keys), nil
+}
+
+// NewPlaintextFileStore creates a store keeping unencrypted transcripts in dir
+func NewPlaintextFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating store directory: %w", err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Put writes data to the file of id, replacing it atomically
+func (s *FileStore) Put(ctx context.Context, id string, data []byte) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating transcript file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing transcript file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing transcript file: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get reads the file of id
+func (s *FileStore) Get(ctx context.Context, id string) ([]byte, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete removes the file of id
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path returns the file path of id
+func (s *FileStore) path(id string) (string, error) {
+	if !validID.MatchString(id) || id == "." || id == ".." {
+		return "", fmt.Errorf("invalid transcript ID %q", id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
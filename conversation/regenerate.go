@@ -0,0 +1,83 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ErrNoUserTurn is returned when regenerating a conversation without user turns
+var ErrNoUserTurn = errors.New("conversation has no user turn to regenerate from")
+
+// RegenerateOptions overrides request settings when regenerating a response
+type RegenerateOptions struct {
+	Model       string
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+}
+
+// Alternative is a regenerated response that has not been added to the
+// conversation yet
+type Alternative struct {
+	Message *models.Message
+
+	conv      *Conversation
+	userIndex int
+	history   int
+}
+
+// Regenerate re-sends the conversation up to and including the last user turn
+// with the given overrides and returns the alternative response. The history
+// is not modified until the alternative is accepted
+func (c *Conversation) Regenerate(ctx context.Context, options RegenerateOptions) (*Alternative, error) {
+	userIndex := lastUserTurn(c.messages)
+	if userIndex < 0 {
+		return nil, ErrNoUserTurn
+	}
+
+	req := c.request(c.messages[:userIndex+1])
+	if options.Model != "" {
+		req.Model = options.Model
+	}
+	if options.MaxTokens > 0 {
+		req.MaxTokens = options.MaxTokens
+	}
+	req.Temperature = options.Temperature
+	req.TopP = options.TopP
+
+	resp, err := c.Client.CreateMessage(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Alternative{
+		Message:   resp,
+		conv:      c,
+		userIndex: userIndex,
+		history:   len(c.messages),
+	}, nil
+}
+
+// Accept replaces everything after the regenerated user turn with the
+// alternative response
+func (a *Alternative) Accept() error {
+	if len(a.conv.messages) != a.history {
+		return errors.New("conversation changed since the alternative was generated")
+	}
+
+	a.conv.messages = append(a.conv.messages[:a.userIndex+1:a.userIndex+1], a.Message.ToParam())
+	return nil
+}
+
+// lastUserTurn returns the index of the last user turn that is not a tool
+// result, or -1
+func lastUserTurn(messages []models.MessageParam) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == models.UserRole && !hasToolResults(messages[i]) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,440 @@
+package conversation
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Dialect adapts the SQL store to a database
+type Dialect struct {
+	// Placeholder returns the bind parameter for the n-th argument, starting
+	// at 1
+	Placeholder func(n int) string
+
+	// BlobType is the column type of binary data
+	BlobType string
+}
+
+var (
+	// SQLite is the dialect of SQLite
+	SQLite = Dialect{Placeholder: func(int) string { return "?" }, BlobType: "BLOB"}
+
+	// MySQL is the dialect of MySQL and MariaDB
+	MySQL = Dialect{Placeholder: func(int) string { return "?" }, BlobType: "LONGBLOB"}
+
+	// Postgres is the dialect of PostgreSQL
+	Postgres = Dialect{Placeholder: func(n int) string { return "$" + strconv.Itoa(n) }, BlobType: "BYTEA"}
+)
+
+// sqlMigrations creates the schema, one entry per version. %[1]s is replaced
+// with the dialect's blob type
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE conversations (
+			id VARCHAR(255) PRIMARY KEY,
+			model VARCHAR(255) NOT NULL,
+			settings %[1]s NOT NULL,
+			key_id VARCHAR(255),
+			encrypted_key %[1]s,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE messages (
+			conversation_id VARCHAR(255) NOT NULL,
+			seq INTEGER NOT NULL,
+			role VARCHAR(32) NOT NULL,
+			content %[1]s NOT NULL,
+			PRIMARY KEY (conversation_id, seq)
+		)`,
+		`CREATE TABLE tool_calls (
+			conversation_id VARCHAR(255) NOT NULL,
+			tool_use_id VARCHAR(255) NOT NULL,
+			message_seq INTEGER NOT NULL,
+			name VARCHAR(255) NOT NULL,
+			answered INTEGER NOT NULL,
+			is_error INTEGER NOT NULL,
+			PRIMARY KEY (conversation_id, tool_use_id)
+		)`,
+		`CREATE TABLE message_usage (
+			conversation_id VARCHAR(255) NOT NULL,
+			model VARCHAR(255) NOT NULL,
+			input_tokens BIGINT NOT NULL,
+			output_tokens BIGINT NOT NULL,
+			recorded_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX message_usage_conversation ON message_usage (conversation_id)`,
+	},
+}
+
+// SQLStore persists conversations in a SQL database, with one row per
+// conversation, message and tool call, and usage records. Message content and
+// conversation settings are encrypted with a data key per conversation unless
+// the store was created with NewPlaintextSQLStore. Tool call names and IDs and
+// usage are stored unencrypted so they can be queried
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+	keys    KeyProvider
+}
+
+// sqlSettings is the stored form of the conversation settings
+type sqlSettings struct {
+	System    string        `json:"system,omitempty"`
+	MaxTokens int           `json:"max_tokens"`
+	Tools     []models.Tool `json:"tools,omitempty"`
+	Summary   string        `json:"summary,omitempty"`
+}
+
+// NewSQLStore creates a store in db encrypting content with data keys from
+// keys. Call Migrate to create the schema
+func NewSQLStore(db *sql.DB, dialect Dialect, keys KeyProvider) (*SQLStore, error) {
+	if keys == nil {
+		return nil, errors.New("SQL store requires a key provider, use NewPlaintextSQLStore to store transcripts unencrypted")
+	}
+	return &SQLStore{db: db, dialect: dialect, keys: keys}, nil
+}
+
+// NewPlaintextSQLStore creates a store in db keeping content unencrypted
+func NewPlaintextSQLStore(db *sql.DB, dialect Dialect) *SQLStore {
+	return &SQLStore{db: db, dialect: dialect}
+}
+
+// Migrate creates or upgrades the schema
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("error creating migrations table: %w", err)
+	}
+
+	var version int
+	row := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)
+	if err := row.Scan(&version); err != nil {
+		return fmt.Errorf("error reading schema version: %w", err)
+	}
+
+	for v := version; v < len(sqlMigrations); v++ {
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, statement := range sqlMigrations[v] {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf(statement, s.dialect.BlobType)); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, s.query(`INSERT INTO schema_migrations (version) VALUES (?)`), v+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("error migrating schema to version %d: %w", v+1, err)
+		}
+	}
+	return nil
+}
+
+// Put stores an encoded transcript under id, replacing its messages and tool
+// calls
+func (s *SQLStore) Put(ctx context.Context, id string, data []byte) error {
+	var transcript Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return fmt.Errorf("error decoding transcript: %w", err)
+	}
+
+	sealer, keyID, encryptedKey, err := s.newSealer(ctx)
+	if err != nil {
+		return err
+	}
+
+	settings, err := json.Marshal(sqlSettings{
+		System:    transcript.System,
+		MaxTokens: transcript.MaxTokens,
+		Tools:     transcript.Tools,
+		Summary:   transcript.Summary,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding settings: %w", err)
+	}
+	if settings, err = sealer.seal(settings, id); err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		createdAt := now
+		row := tx.QueryRowContext(ctx, s.query(`SELECT created_at FROM conversations WHERE id = ?`), id)
+		if err := row.Scan(&createdAt); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		if err := s.deleteRows(ctx, tx, id, "conversations", "messages", "tool_calls"); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, s.query(`INSERT INTO conversations (id, model, settings, key_id, encrypted_key, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+			id, transcript.Model, settings, keyID, encryptedKey, createdAt, now)
+		if err != nil {
+			return err
+		}
+
+		results := toolResults(transcript.Messages)
+		for seq, message := range transcript.Messages {
+			content, err := json.Marshal(message.Content)
+			if err != nil {
+				return fmt.Errorf("error encoding message %d: %w", seq, err)
+			}
+			if content, err = sealer.seal(content, id+"/"+strconv.Itoa(seq)); err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, s.query(`INSERT INTO messages (conversation_id, seq, role, content) VALUES (?, ?, ?, ?)`),
+				id, seq, string(message.Role), content)
+			if err != nil {
+				return err
+			}
+
+			for _, block := range message.Content {
+				if block.ToolUseContent == nil {
+					continue
+				}
+				result, answered := results[block.ToolUseContent.ID]
+				_, err = tx.ExecContext(ctx, s.query(`INSERT INTO tool_calls (conversation_id, tool_use_id, message_seq, name, answered, is_error) VALUES (?, ?, ?, ?, ?, ?)`),
+					id, block.ToolUseContent.ID, seq, block.ToolUseContent.Name, boolInt(answered), boolInt(answered && result.IsError))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Get returns the encoded transcript stored under id
+func (s *SQLStore) Get(ctx context.Context, id string) ([]byte, error) {
+	var transcript Transcript
+	var settings, encryptedKey []byte
+	var keyID sql.NullString
+	var updatedAt int64
+
+	row := s.db.QueryRowContext(ctx, s.query(`SELECT model, settings, key_id, encrypted_key, updated_at FROM conversations WHERE id = ?`), id)
+	if err := row.Scan(&transcript.Model, &settings, &keyID, &encryptedKey, &updatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	transcript.SavedAt = time.UnixMilli(updatedAt).UTC()
+
+	sealer, err := s.openSealer(ctx, keyID.String, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if settings, err = sealer.open(settings, id); err != nil {
+		return nil, err
+	}
+	var decoded sqlSettings
+	if err := json.Unmarshal(settings, &decoded); err != nil {
+		return nil, fmt.Errorf("error decoding settings: %w", err)
+	}
+	transcript.System = decoded.System
+	transcript.MaxTokens = decoded.MaxTokens
+	transcript.Tools = decoded.Tools
+	transcript.Summary = decoded.Summary
+
+	rows, err := s.db.QueryContext(ctx, s.query(`SELECT seq, role, content FROM messages WHERE conversation_id = ? ORDER BY seq`), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var seq int
+		var role string
+		var content []byte
+		if err := rows.Scan(&seq, &role, &content); err != nil {
+			return nil, err
+		}
+		if content, err = sealer.open(content, id+"/"+strconv.Itoa(seq)); err != nil {
+			return nil, err
+		}
+
+		message := models.MessageParam{Role: models.Role(role)}
+		if err := json.Unmarshal(content, &message.Content); err != nil {
+			return nil, fmt.Errorf("error decoding message %d: %w", seq, err)
+		}
+		transcript.Messages = append(transcript.Messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(transcript)
+}
+
+// Delete removes a conversation with its messages, tool calls and usage
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		return s.deleteRows(ctx, tx, id, "conversations", "messages", "tool_calls", "message_usage")
+	})
+}
+
+// RecordUsage records the usage of a response in a conversation
+func (s *SQLStore) RecordUsage(ctx context.Context, id, model string, usage models.Usage) error {
+	_, err := s.db.ExecContext(ctx, s.query(`INSERT INTO message_usage (conversation_id, model, input_tokens, output_tokens, recorded_at) VALUES (?, ?, ?, ?, ?)`),
+		id, model, usage.InputTokens, usage.OutputTokens, time.Now().UnixMilli())
+	return err
+}
+
+// Usage returns the total recorded usage of a conversation
+func (s *SQLStore) Usage(ctx context.Context, id string) (models.Usage, error) {
+	var usage models.Usage
+	row := s.db.QueryRowContext(ctx, s.query(`SELECT COALESCE(SUM(input_tokens), 0), COALESCE(SUM(output_tokens), 0) FROM message_usage WHERE conversation_id = ?`), id)
+	err := row.Scan(&usage.InputTokens, &usage.OutputTokens)
+	return usage, err
+}
+
+// deleteRows deletes the rows of a conversation from tables
+func (s *SQLStore) deleteRows(ctx context.Context, tx *sql.Tx, id string, tables ...string) error {
+	for _, table := range tables {
+		column := "conversation_id"
+		if table == "conversations" {
+			column = "id"
+		}
+		if _, err := tx.ExecContext(ctx, s.query(fmt.Sprintf(`DELETE FROM %s WHERE %s = ?`, table, column)), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction
+func (s *SQLStore) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// query replaces the ? placeholders of a query with the dialect's
+func (s *SQLStore) query(query string) string {
+	if s.dialect.Placeholder == nil {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(s.dialect.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// newSealer creates a sealer with a new data key, or a plaintext sealer
+func (s *SQLStore) newSealer(ctx context.Context) (*sealer, sql.NullString, []byte, error) {
+	if s.keys == nil {
+		return &sealer{}, sql.NullString{}, nil, nil
+	}
+
+	key, encryptedKey, keyID, err := s.keys.GenerateDataKey(ctx)
+	if err != nil {
+		return nil, sql.NullString{}, nil, fmt.Errorf("error generating data key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, sql.NullString{}, nil, err
+	}
+	return &sealer{gcm: gcm}, sql.NullString{String: keyID, Valid: true}, encryptedKey, nil
+}
+
+// openSealer creates the sealer of a stored conversation
+func (s *SQLStore) openSealer(ctx context.Context, keyID string, encryptedKey []byte) (*sealer, error) {
+	if encryptedKey == nil {
+		return &sealer{}, nil
+	}
+	if s.keys == nil {
+		return nil, errors.New("conversation is encrypted but the store has no key provider")
+	}
+
+	key, err := s.keys.DecryptDataKey(ctx, keyID, encryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data key: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{gcm: gcm}, nil
+}
+
+// toolResults returns the tool results of the messages by tool use ID
+func toolResults(messages []models.MessageParam) map[string]*models.ToolResultBlock {
+	results := make(map[string]*models.ToolResultBlock)
+	for _, message := range messages {
+		for _, block := range message.Content {
+			if block.ToolResultContent != nil {
+				results[block.ToolResultContent.ToolUseID] = block.ToolResultContent
+			}
+		}
+	}
+	return results
+}
+
+// boolInt converts a bool to an integer column value
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// sealer encrypts values with a conversation's data key. A sealer without a
+// cipher passes values through unencrypted
+type sealer struct {
+	gcm cipher.AEAD
+}
+
+// seal encrypts data bound to the associated data, prefixing the nonce
+func (s *sealer) seal(data []byte, associated string) ([]byte, error) {
+	if s.gcm == nil {
+		return data, nil
+	}
+
+	nonce := make([]byte, s.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return s.gcm.Seal(nonce, nonce, data, []byte(associated)), nil
+}
+
+// open decrypts data sealed with seal
+func (s *sealer) open(data []byte, associated string) ([]byte, error) {
+	if s.gcm == nil {
+		return data, nil
+	}
+
+	nonceSize := s.gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("error decrypting value: too short")
+	}
+	plaintext, err := s.gcm.Open(nil, data[:nonceSize], data[nonceSize:], []byte(associated))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting value: %w", err)
+	}
+	return plaintext, nil
+}
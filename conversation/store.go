@@ -0,0 +1,129 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ErrNotFound is returned by stores when no transcript has the given ID
+var ErrNotFound = errors.New("transcript not found")
+
+// Store persists encoded transcripts by ID. Implementations must be safe for
+// concurrent use
+type Store interface {
+	Put(ctx context.Context, id string, data []byte) error
+	Get(ctx context.Context, id string) ([]byte, error)
+	Delete(ctx context.Context, id string) error
+}
+
+// Transcript is the persisted state of a conversation
+type Transcript struct {
+	Model     string                `json:"model"`
+	System    string                `json:"system,omitempty"`
+	MaxTokens int                   `json:"max_tokens"`
+	Tools     []models.Tool         `json:"tools,omitempty"`
+	Messages  []models.MessageParam `json:"messages"`
+	Summary   string                `json:"summary,omitempty"`
+	SavedAt   time.Time             `json:"saved_at"`
+}
+
+// Transcript returns the persistable state of the conversation
+func (c *Conversation) Transcript() Transcript {
+	return Transcript{
+		Model:     c.Model,
+		System:    c.System,
+		MaxTokens: c.MaxTokens,
+		Tools:     append([]models.Tool(nil), c.Tools...),
+		Messages:  c.Messages(),
+		Summary:   c.summary,
+	}
+}
+
+// Save stores the conversation's transcript under id
+func (c *Conversation) Save(ctx context.Context, store Store, id string) error {
+	transcript := c.Transcript()
+	transcript.SavedAt = time.Now().UTC()
+
+	data, err := json.Marshal(transcript)
+	if err != nil {
+		return fmt.Errorf("error encoding transcript: %w", err)
+	}
+	if err := store.Put(ctx, id, data); err != nil {
+		return fmt.Errorf("error saving transcript: %w", err)
+	}
+	return nil
+}
+
+// Load restores a conversation saved under id. Options are applied after the
+// saved settings, for example to set a summary strategy
+func Load(ctx context.Context, store Store, id string, client anthropic.ChatProvider, options ...Option) (*Conversation, error) {
+	data, err := store.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error loading transcript: %w", err)
+	}
+
+	var transcript Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		return nil, fmt.Errorf("error decoding transcript: %w", err)
+	}
+
+	conv := New(client, transcript.Model,
+		WithSystem(transcript.System),
+		WithMaxTokens(transcript.MaxTokens),
+		WithTools(transcript.Tools...),
+		WithMessages(transcript.Messages...),
+	)
+	conv.summary = transcript.Summary
+
+	for _, option := range options {
+		option(conv)
+	}
+	return conv, nil
+}
+
+// MemoryStore keeps transcripts in memory, for tests and short-lived
+// processes
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore creates an empty memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: make(map[string][]byte)}
+}
+
+// Put stores data under id
+func (s *MemoryStore) Put(ctx context.Context, id string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[id] = append([]byte(nil), data...)
+	return nil
+}
+
+// Get returns the data stored under id
+func (s *MemoryStore) Get(ctx context.Context, id string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// Delete removes the data stored under id
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, id)
+	return nil
+}
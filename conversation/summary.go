@@ -0,0 +1,176 @@
+package conversation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultSummaryPrompt instructs the model compressing older turns
+const DefaultSummaryPrompt = `Summarize the conversation transcript below so the conversation can be continued without it. Keep facts, decisions, open questions, user preferences, and the names, inputs and outcomes of tool calls. If an earlier summary is included, merge it into the new summary. Respond with the summary only.`
+
+// summaryMaxTokens limits the length of the rolling summary
+const summaryMaxTokens = 1024
+
+// SummaryStrategy compresses older turns into a rolling summary that is sent
+// as a system block, keeping long conversations within the context window
+type SummaryStrategy struct {
+	// Client summarizes the turns, defaults to the conversation's client
+	Client anthropic.ChatProvider
+
+	// Model summarizes the turns, typically a cheap model
+	Model string
+
+	// MaxMessages triggers a summary when the history has more messages
+	MaxMessages int
+
+	// MaxTokens triggers a summary when the estimated size of the history
+	// exceeds it
+	MaxTokens int
+
+	// KeepRecent is the number of recent messages kept verbatim
+	KeepRecent int
+
+	// Prompt is the summarization instruction, defaults to
+	// DefaultSummaryPrompt
+	Prompt string
+}
+
+// WithRollingSummary enables rolling summaries of older turns
+func WithRollingSummary(strategy SummaryStrategy) Option {
+	return func(c *Conversation) {
+		c.SummaryStrategy = &strategy
+	}
+}
+
+// Summary returns the rolling summary of the turns dropped from the history
+func (c *Conversation) Summary() string {
+	return c.summary
+}
+
+// Summarize compresses all but the most recent turns into the rolling
+// summary, regardless of the strategy's thresholds
+func (c *Conversation) Summarize(ctx context.Context) error {
+	if c.SummaryStrategy == nil {
+		return fmt.Errorf("conversation has no summary strategy")
+	}
+
+	cut := summaryCut(c.messages, c.SummaryStrategy.KeepRecent)
+	if cut <= 0 {
+		return nil
+	}
+
+	client := c.SummaryStrategy.Client
+	if client == nil {
+		client = c.Client
+	}
+	prompt := c.SummaryStrategy.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+
+	resp, err := client.CreateMessage(ctx, models.MessageRequest{
+		Model:     c.SummaryStrategy.Model,
+		System:    prompt,
+		MaxTokens: summaryMaxTokens,
+		Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(transcript(c.summary, c.messages[:cut])))},
+	})
+	if err != nil {
+		return fmt.Errorf("error summarizing conversation: %w", err)
+	}
+
+	c.summary = strings.TrimSpace(resp.Text())
+	c.messages = append([]models.MessageParam(nil), c.messages[cut:]...)
+	c.cachePoints = nil
+	return nil
+}
+
+// summarizeIfNeeded summarizes when the history exceeds the strategy's
+// thresholds
+func (c *Conversation) summarizeIfNeeded(ctx context.Context) error {
+	strategy := c.SummaryStrategy
+	if strategy == nil {
+		return nil
+	}
+
+	exceeded := strategy.MaxMessages > 0 && len(c.messages) > strategy.MaxMessages
+	if !exceeded && strategy.MaxTokens > 0 {
+		exceeded = models.EstimateTokens(transcript(c.summary, c.messages)) > strategy.MaxTokens
+	}
+	if !exceeded {
+		return nil
+	}
+	return c.Summarize(ctx)
+}
+
+// systemBlocks returns the system prompt followed by the rolling summary
+func (c *Conversation) systemBlocks() []models.TextBlock {
+	if c.summary == "" {
+		return nil
+	}
+
+	var blocks []models.TextBlock
+	if c.System != "" {
+		blocks = append(blocks, models.TextBlock{Type: models.TextContentType, Text: c.System})
+	}
+	return append(blocks, models.TextBlock{
+		Type: models.TextContentType,
+		Text: "Summary of the earlier conversation:\n" + c.summary,
+	})
+}
+
+// summaryCut returns the index of the first message kept verbatim. The kept
+// history starts with a user turn that is not a tool result, so tool calls
+// and their results are never separated
+func summaryCut(messages []models.MessageParam, keepRecent int) int {
+	cut := len(messages) - keepRecent
+	for cut > 0 && cut < len(messages) {
+		if messages[cut].Role == models.UserRole && !hasToolResults(messages[cut]) {
+			return cut
+		}
+		cut--
+	}
+	if cut >= len(messages) {
+		// Keep the last user turn when nothing has to be kept
+		return max(lastUserTurn(messages), 0)
+	}
+	return 0
+}
+
+// transcript renders the previous summary and messages as plain text,
+// including tool calls and results
+func transcript(summary string, messages []models.MessageParam) string {
+	var b strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&b, "Earlier summary:\n%s\n\n", summary)
+	}
+
+	toolNames := make(map[string]string)
+	for _, message := range messages {
+		for _, block := range message.Content {
+			switch {
+			case block.TextContent != nil:
+				fmt.Fprintf(&b, "%s: %s\n", message.Role, block.TextContent.Text)
+			case block.ToolUseContent != nil:
+				toolNames[block.ToolUseContent.ID] = block.ToolUseContent.Name
+				input, _ := json.Marshal(block.ToolUseContent.Input)
+				fmt.Fprintf(&b, "%s called tool %s with %s\n", message.Role, block.ToolUseContent.Name, input)
+			case block.ToolResultContent != nil:
+				status := "result"
+				if block.ToolResultContent.IsError {
+					status = "error"
+				}
+				fmt.Fprintf(&b, "tool %s %s: %s\n", toolNames[block.ToolResultContent.ToolUseID], status, block.ToolResultContent.Content)
+			case block.ImageContent != nil:
+				fmt.Fprintf(&b, "%s: [image]\n", message.Role)
+			case block.DocumentContent != nil:
+				fmt.Fprintf(&b, "%s: [document %s]\n", message.Role, block.DocumentContent.Title)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package conversation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultTitleModel is the model generating titles when none is configured
+const DefaultTitleModel = models.Claude35HaikuLatest
+
+// titlePrompt instructs the model generating titles
+const titlePrompt = `Write a short title of at most six words for the conversation below, in the language of the conversation. Respond with the title only, without quotes or punctuation at the end.`
+
+// titleMessages is the number of leading messages used to generate a title
+const titleMessages = 4
+
+// WithTitleModel sets the model used by GenerateTitle
+func WithTitleModel(model string) Option {
+	return func(c *Conversation) {
+		c.TitleModel = model
+	}
+}
+
+// GenerateTitle returns a short title for the conversation, generated from its
+// first exchanges with a cheap model
+func (c *Conversation) GenerateTitle(ctx context.Context) (string, error) {
+	if len(c.messages) == 0 {
+		return "", errors.New("conversation has no messages to generate a title from")
+	}
+
+	model := c.TitleModel
+	if model == "" {
+		model = DefaultTitleModel
+	}
+
+	messages := c.messages[:min(len(c.messages), titleMessages)]
+	resp, err := c.Client.CreateMessage(ctx, models.MessageRequest{
+		Model:     model,
+		System:    titlePrompt,
+		MaxTokens: 32,
+		Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(transcript("", messages)))},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error generating title: %w", err)
+	}
+
+	return cleanTitle(resp.Text()), nil
+}
+
+// cleanTitle removes quotes, trailing punctuation and extra lines from a
+// generated title
+func cleanTitle(title string) string {
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	title = strings.Trim(title, "\"'`*# ")
+	return strings.TrimRight(title, ".!?:; ")
+}
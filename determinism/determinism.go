@@ -0,0 +1,132 @@
+// Package determinism checks how reproducible model output is. The API has no
+// seed parameter, so even at temperature 0 outputs can differ between calls;
+// this package measures how often and where they do, for example to validate
+// reproducibility assumptions before a model upgrade
+package determinism
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// contextTokens is the number of tokens shown around a divergence
+const contextTokens = 5
+
+// tokenPattern splits text into words, punctuation and whitespace runs
+var tokenPattern = regexp.MustCompile(`\w+|\s+|[^\w\s]`)
+
+// Divergence describes where a run's output differs from the first run's
+type Divergence struct {
+	// Run is the index of the diverging run
+	Run int
+
+	// Token is the index of the first differing token
+	Token int
+
+	// Expected and Actual show the tokens of the first run and of the
+	// diverging run around the divergence
+	Expected string
+	Actual   string
+}
+
+// String returns a diff-style rendering of the divergence
+func (d Divergence) String() string {
+	return fmt.Sprintf("run %d diverges at token %d:\n- %q\n+ %q", d.Run, d.Token, d.Expected, d.Actual)
+}
+
+// Report is the outcome of a determinism check
+type Report struct {
+	// Outputs holds the text of each run
+	Outputs []string
+
+	// Distinct is the number of distinct outputs
+	Distinct int
+
+	// Divergences lists the runs whose output differs from the first run
+	Divergences []Divergence
+
+	// Usage is the combined usage of all runs
+	Usage models.Usage
+}
+
+// Deterministic reports whether every run produced the same output
+func (r *Report) Deterministic() bool {
+	return r.Distinct <= 1
+}
+
+// String returns a summary of the report with the divergences
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d runs, %d distinct outputs", len(r.Outputs), r.Distinct)
+	for _, divergence := range r.Divergences {
+		fmt.Fprintf(&b, "\n%s", divergence)
+	}
+	return b.String()
+}
+
+// Check sends the request n times at temperature 0 and reports how the
+// outputs diverge
+func Check(ctx context.Context, client anthropic.ChatProvider, req models.MessageRequest, n int) (*Report, error) {
+	if n < 2 {
+		return nil, errors.New("determinism check needs at least two runs")
+	}
+
+	req.Temperature = models.Float64(0)
+	req.TopP = nil
+	req.TopK = nil
+
+	report := &Report{}
+	distinct := make(map[string]bool)
+	for run := 0; run < n; run++ {
+		resp, err := client.CreateMessage(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("error in run %d: %w", run, err)
+		}
+		report.Usage = report.Usage.Add(resp.Usage)
+
+		output := resp.Text()
+		report.Outputs = append(report.Outputs, output)
+		distinct[output] = true
+
+		if run > 0 && output != report.Outputs[0] {
+			report.Divergences = append(report.Divergences, diverge(run, report.Outputs[0], output))
+		}
+	}
+
+	report.Distinct = len(distinct)
+	return report, nil
+}
+
+// diverge finds the first differing token of two outputs
+func diverge(run int, expected, actual string) Divergence {
+	expectedTokens := tokenPattern.FindAllString(expected, -1)
+	actualTokens := tokenPattern.FindAllString(actual, -1)
+
+	i := 0
+	for i < len(expectedTokens) && i < len(actualTokens) && expectedTokens[i] == actualTokens[i] {
+		i++
+	}
+
+	return Divergence{
+		Run:      run,
+		Token:    i,
+		Expected: window(expectedTokens, i),
+		Actual:   window(actualTokens, i),
+	}
+}
+
+// window joins the tokens around index i
+func window(tokens []string, i int) string {
+	start := max(i-contextTokens, 0)
+	end := min(i+contextTokens, len(tokens))
+	if start >= end {
+		return ""
+	}
+	return strings.Join(tokens[start:end], "")
+}
@@ -0,0 +1,95 @@
+package anthropic
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// Diagnostics contains connection and timing details of a single attempt of a
+// request, useful to debug latency regressions
+type Diagnostics struct {
+	Method  string
+	URL     string
+	Attempt int
+
+	// StatusCode is the status of the response, zero if the attempt failed
+	// without a response
+	StatusCode int
+	Err        error
+
+	// DNS, Connect and TLS are the durations of the connection setup phases,
+	// zero when a connection was reused
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+
+	// TimeToFirstByte is measured from the start of the attempt to the first
+	// byte of the response
+	TimeToFirstByte time.Duration
+
+	// Total is measured from the start of the attempt until the response
+	// headers were received
+	Total time.Duration
+
+	// Reused reports whether the connection was reused from the pool, and
+	// IdleTime how long it was idle before
+	Reused     bool
+	WasIdle    bool
+	IdleTime   time.Duration
+	RemoteAddr string
+}
+
+// traceRequest attaches an httptrace to the request when diagnostics are
+// enabled. The returned function reports the diagnostics once the attempt
+// completes, returning the panic of the callback
+func (c *Client) traceRequest(req *http.Request, attempt int) (*http.Request, func(*http.Response, error) error) {
+	if c.OnDiagnostics == nil {
+		return req, func(*http.Response, error) error { return nil }
+	}
+
+	d := Diagnostics{Method: req.Method, URL: req.URL.String(), Attempt: attempt}
+	start := time.Now()
+	var dnsStart, connectStart, tlsStart time.Time
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			if !dnsStart.IsZero() {
+				d.DNS = time.Since(dnsStart)
+			}
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			if !connectStart.IsZero() {
+				d.Connect = time.Since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			if !tlsStart.IsZero() {
+				d.TLS = time.Since(tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			d.Reused = info.Reused
+			d.WasIdle = info.WasIdle
+			d.IdleTime = info.IdleTime
+			if info.Conn != nil {
+				d.RemoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() { d.TimeToFirstByte = time.Since(start) },
+	}
+
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	return req, func(resp *http.Response, err error) error {
+		d.Total = time.Since(start)
+		d.Err = err
+		if resp != nil {
+			d.StatusCode = resp.StatusCode
+		}
+		return c.guard().Notify("diagnostics callback", func() { c.OnDiagnostics(d) })
+	}
+}
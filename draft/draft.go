@@ -0,0 +1,124 @@
+// Package draft implements the draft and verify pattern: a cheap model writes
+// a draft, and a stronger model verifies and improves it. For many pipelines
+// this costs less than asking the strong model directly, since it mostly
+// corrects and expands the draft instead of writing from scratch
+package draft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultVerifyTemplate is the prompt sent to the verify model. It is executed
+// with a Prompt and a Draft field
+const DefaultVerifyTemplate = `Below is a task and a draft answer written by another assistant.
+
+<task>
+{{.Prompt}}
+</task>
+
+<draft>
+{{.Draft}}
+</draft>
+
+Check the draft for mistakes and omissions. Respond with the final, corrected and complete answer only, without commenting on the draft.`
+
+// DefaultMaxTokens is used for both calls when MaxTokens is not set
+const DefaultMaxTokens = 1024
+
+// Pipeline drafts with one model and verifies with another
+type Pipeline struct {
+	Client anthropic.ChatProvider
+
+	// DraftModel writes the draft, defaults to Claude 3.5 Haiku
+	DraftModel string
+
+	// VerifyModel verifies and expands the draft, defaults to Claude 3.7 Sonnet
+	VerifyModel string
+
+	// System is sent with both requests
+	System string
+
+	// MaxTokens limits both responses, defaults to DefaultMaxTokens
+	MaxTokens int
+
+	// VerifyTemplate builds the verification prompt, defaults to
+	// DefaultVerifyTemplate
+	VerifyTemplate *template.Template
+}
+
+// Result holds the responses of both calls
+type Result struct {
+	Draft *models.Message
+	Final *models.Message
+
+	// Usage is the combined usage of both calls
+	Usage models.Usage
+}
+
+// Text returns the text of the final response
+func (r *Result) Text() string {
+	return r.Final.Text()
+}
+
+// defaultTemplate is the parsed DefaultVerifyTemplate
+var defaultTemplate = template.Must(template.New("verify").Parse(DefaultVerifyTemplate))
+
+// New creates a pipeline with the default models
+func New(client anthropic.ChatProvider) *Pipeline {
+	return &Pipeline{
+		Client:      client,
+		DraftModel:  models.Claude35HaikuLatest,
+		VerifyModel: models.Claude37SonnetLatest,
+	}
+}
+
+// Run drafts a response to the prompt and verifies it
+func (p *Pipeline) Run(ctx context.Context, prompt string) (*Result, error) {
+	draft, err := p.Client.CreateMessage(ctx, p.request(p.DraftModel, prompt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating draft: %w", err)
+	}
+
+	tmpl := p.VerifyTemplate
+	if tmpl == nil {
+		tmpl = defaultTemplate
+	}
+
+	var verifyPrompt bytes.Buffer
+	data := struct{ Prompt, Draft string }{Prompt: prompt, Draft: draft.Text()}
+	if err := tmpl.Execute(&verifyPrompt, data); err != nil {
+		return nil, fmt.Errorf("error executing verify template: %w", err)
+	}
+
+	final, err := p.Client.CreateMessage(ctx, p.request(p.VerifyModel, verifyPrompt.String()))
+	if err != nil {
+		return nil, fmt.Errorf("error verifying draft: %w", err)
+	}
+
+	return &Result{
+		Draft: draft,
+		Final: final,
+		Usage: draft.Usage.Add(final.Usage),
+	}, nil
+}
+
+// request builds a single turn request for a model
+func (p *Pipeline) request(model, prompt string) models.MessageRequest {
+	maxTokens := p.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	return models.MessageRequest{
+		Model:     model,
+		System:    p.System,
+		MaxTokens: maxTokens,
+		Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(prompt))},
+	}
+}
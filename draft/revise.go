@@ -0,0 +1,288 @@
+package draft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"text/template"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultCritiqueTemplate is the prompt sent to the verifier. It is executed
+// with a Prompt, a Draft and a Rubric field
+const DefaultCritiqueTemplate = `Below is a task and a draft answer written by another assistant.
+
+<task>
+{{.Prompt}}
+</task>
+
+<draft>
+{{.Draft}}
+</draft>
+{{if .Rubric}}
+Grade the draft against this rubric:
+
+<rubric>
+{{.Rubric}}
+</rubric>
+{{end}}
+Decide whether the draft is correct and complete. If it is not, list the issues and explain how to fix them. Record your verdict with the verdict tool.`
+
+// DefaultReviseTemplate is the prompt asking for a revised draft. It is
+// executed with a Prompt, a Draft, a Rubric and a Verdict field
+const DefaultReviseTemplate = `Below is a task, your previous answer and a review of it.
+
+<task>
+{{.Prompt}}
+</task>
+
+<answer>
+{{.Draft}}
+</answer>
+
+<review>
+{{.Verdict.Feedback}}
+{{range .Verdict.Issues}}- {{.}}
+{{end}}</review>
+
+Rewrite the answer so that it addresses every issue of the review. Respond with the revised answer only.`
+
+// DefaultMaxRounds is the number of verification rounds when MaxRounds is not
+// set
+const DefaultMaxRounds = 3
+
+// verdictTool is the name of the tool the verifier records its verdict with
+const verdictTool = "verdict"
+
+// Verdict is the verifier's judgement of a draft
+type Verdict struct {
+	Pass     bool     `json:"pass" description:"Whether the draft is correct and complete"`
+	Feedback string   `json:"feedback" description:"Overall assessment of the draft"`
+	Issues   []string `json:"issues,omitempty" description:"The problems that must be fixed, empty when the draft passes"`
+}
+
+// Round is one round of verification
+type Round struct {
+	// Draft is the answer that was verified
+	Draft string
+
+	// Verdict is the verifier's judgement of the draft
+	Verdict Verdict
+
+	// Usage is the combined usage of the verification and of the revision
+	// that produced the draft
+	Usage models.Usage
+}
+
+// Reviser verifies answers against a rubric and revises them until the
+// verifier passes them
+type Reviser struct {
+	Client anthropic.ChatProvider
+
+	// Model writes and revises the answer, defaults to Claude 3.7 Sonnet
+	Model string
+
+	// VerifyModel verifies the answer, defaults to Model
+	VerifyModel string
+
+	// System is sent with the requests writing and revising the answer
+	System string
+
+	// Rubric lists the criteria the answer must meet
+	Rubric string
+
+	// MaxRounds limits the number of verifications, defaults to
+	// DefaultMaxRounds
+	MaxRounds int
+
+	// MaxTokens limits every response, defaults to DefaultMaxTokens
+	MaxTokens int
+
+	// CritiqueTemplate and ReviseTemplate build the prompts, defaulting to
+	// DefaultCritiqueTemplate and DefaultReviseTemplate
+	CritiqueTemplate *template.Template
+	ReviseTemplate   *template.Template
+}
+
+// RevisionResult is the outcome of VerifyAndRevise
+type RevisionResult struct {
+	// Answer is the final answer, the last draft that was verified
+	Answer string
+
+	// Passed reports whether the verifier passed the answer, false when the
+	// rounds ran out first
+	Passed bool
+
+	// Trail lists the rounds of verification in order
+	Trail []Round
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// Parsed default templates
+var (
+	defaultCritiqueTemplate = template.Must(template.New("critique").Parse(DefaultCritiqueTemplate))
+	defaultReviseTemplate   = template.Must(template.New("revise").Parse(DefaultReviseTemplate))
+)
+
+// NewReviser creates a reviser checking answers against the rubric
+func NewReviser(client anthropic.ChatProvider, rubric string) *Reviser {
+	return &Reviser{Client: client, Model: models.Claude37SonnetLatest, Rubric: rubric}
+}
+
+// VerifyAndRevise verifies the draft answer to the prompt and revises it with
+// the verifier's feedback until the verifier passes it or MaxRounds
+// verifications were made. An empty draft is written first
+func (r *Reviser) VerifyAndRevise(ctx context.Context, prompt, draft string) (*RevisionResult, error) {
+	result := &RevisionResult{}
+
+	var usage models.Usage
+	if draft == "" {
+		resp, err := r.Client.CreateMessage(ctx, r.request(r.Model, r.System, prompt))
+		if err != nil {
+			return nil, fmt.Errorf("error creating draft: %w", err)
+		}
+		draft = resp.Text()
+		usage = resp.Usage
+	}
+
+	for round := 1; ; round++ {
+		verdict, verifyUsage, err := r.verify(ctx, prompt, draft)
+		if err != nil {
+			return nil, err
+		}
+		usage = usage.Add(verifyUsage)
+
+		result.Trail = append(result.Trail, Round{Draft: draft, Verdict: verdict, Usage: usage})
+		result.Usage = result.Usage.Add(usage)
+		result.Answer = draft
+		if verdict.Pass {
+			result.Passed = true
+			return result, nil
+		}
+		if round >= r.maxRounds() {
+			return result, nil
+		}
+
+		revised, err := r.revise(ctx, prompt, draft, verdict)
+		if err != nil {
+			return nil, err
+		}
+		draft = revised.Text()
+		usage = revised.Usage
+	}
+}
+
+// VerifyAndRevise verifies and revises a draft answer against a rubric with
+// the default settings
+func VerifyAndRevise(ctx context.Context, client anthropic.ChatProvider, prompt, draft, rubric string, maxRounds int) (*RevisionResult, error) {
+	reviser := NewReviser(client, rubric)
+	reviser.MaxRounds = maxRounds
+	return reviser.VerifyAndRevise(ctx, prompt, draft)
+}
+
+// verify asks the verifier for its verdict on the draft
+func (r *Reviser) verify(ctx context.Context, prompt, draft string) (Verdict, models.Usage, error) {
+	var critique bytes.Buffer
+	data := struct{ Prompt, Draft, Rubric string }{Prompt: prompt, Draft: draft, Rubric: r.Rubric}
+	if err := r.critiqueTemplate().Execute(&critique, data); err != nil {
+		return Verdict{}, models.Usage{}, fmt.Errorf("error executing critique template: %w", err)
+	}
+
+	schema, err := models.SchemaFor(reflect.TypeFor[Verdict]())
+	if err != nil {
+		return Verdict{}, models.Usage{}, fmt.Errorf("error creating verdict schema: %w", err)
+	}
+	choice := models.SpecificToolChoice(verdictTool, true)
+
+	model := r.VerifyModel
+	if model == "" {
+		model = r.Model
+	}
+	req := r.request(model, "", critique.String())
+	req.Tools = []models.Tool{models.NewTool(verdictTool, "Record the verdict on the draft", schema)}
+	req.ToolChoice = &choice
+
+	resp, err := r.Client.CreateMessage(ctx, req)
+	if err != nil {
+		return Verdict{}, models.Usage{}, fmt.Errorf("error verifying draft: %w", err)
+	}
+
+	for _, block := range resp.Content {
+		if block.ToolUseContent == nil || block.ToolUseContent.Name != verdictTool {
+			continue
+		}
+		data, err := json.Marshal(block.ToolUseContent.Input)
+		if err != nil {
+			return Verdict{}, resp.Usage, fmt.Errorf("error encoding verdict: %w", err)
+		}
+		var verdict Verdict
+		if err := json.Unmarshal(data, &verdict); err != nil {
+			return Verdict{}, resp.Usage, fmt.Errorf("error decoding verdict: %w", err)
+		}
+		return verdict, resp.Usage, nil
+	}
+	return Verdict{}, resp.Usage, fmt.Errorf("error verifying draft: the verifier returned no verdict")
+}
+
+// revise asks for a draft addressing the verdict
+func (r *Reviser) revise(ctx context.Context, prompt, draft string, verdict Verdict) (*models.Message, error) {
+	var revise bytes.Buffer
+	data := struct {
+		Prompt, Draft, Rubric string
+		Verdict               Verdict
+	}{Prompt: prompt, Draft: draft, Rubric: r.Rubric, Verdict: verdict}
+	if err := r.reviseTemplate().Execute(&revise, data); err != nil {
+		return nil, fmt.Errorf("error executing revise template: %w", err)
+	}
+
+	resp, err := r.Client.CreateMessage(ctx, r.request(r.Model, r.System, revise.String()))
+	if err != nil {
+		return nil, fmt.Errorf("error revising draft: %w", err)
+	}
+	return resp, nil
+}
+
+// request builds a single turn request
+func (r *Reviser) request(model, system, prompt string) models.MessageRequest {
+	maxTokens := r.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	return models.MessageRequest{
+		Model:     model,
+		System:    system,
+		MaxTokens: maxTokens,
+		Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(prompt))},
+	}
+}
+
+// critiqueTemplate returns the template of the verification prompt
+func (r *Reviser) critiqueTemplate() *template.Template {
+	if r.CritiqueTemplate != nil {
+		return r.CritiqueTemplate
+	}
+	return defaultCritiqueTemplate
+}
+
+// reviseTemplate returns the template of the revision prompt
+func (r *Reviser) reviseTemplate() *template.Template {
+	if r.ReviseTemplate != nil {
+		return r.ReviseTemplate
+	}
+	return defaultReviseTemplate
+}
+
+// maxRounds returns the maximum number of verifications
+func (r *Reviser) maxRounds() int {
+	if r.MaxRounds > 0 {
+		return r.MaxRounds
+	}
+	return DefaultMaxRounds
+}
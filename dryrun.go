@@ -0,0 +1,125 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// redactedHeaders hold credentials and are redacted from dry runs
+var redactedHeaders = []string{"X-Api-Key", "Authorization"}
+
+// DryRunResult describes the request CreateMessage would send
+type DryRunResult struct {
+	// Request is the message request after the default model, middleware and
+	// output limits were applied
+	Request models.MessageRequest
+
+	// Method, URL and Header describe the HTTP request, with credentials
+	// redacted
+	Method string
+	URL    string
+	Header http.Header
+
+	// Body is the exact payload that would be sent
+	Body []byte
+
+	// Problems lists the reasons the API would likely reject the request
+	Problems []error
+
+	// InputTokens is a rough estimate of the input tokens of the request
+	InputTokens int
+
+	// Pricing is the list price of the model, nil for unknown models
+	Pricing *models.Pricing
+
+	// InputCost is the estimated price in US dollars of the input, and MaxCost
+	// adds the price of generating max_tokens. Both are zero for unknown
+	// models
+	InputCost float64
+	MaxCost   float64
+}
+
+// Valid reports whether no problems were found
+func (r *DryRunResult) Valid() bool {
+	return len(r.Problems) == 0
+}
+
+// DryRun prepares a message request like CreateMessage, without sending it. It
+// marshals and validates the request and estimates its tokens and cost, which
+// is useful for debugging and for linting prompts in CI. Middleware and the
+// request signer run as for a real request, so they must not assume the
+// request is sent. An error is returned when preparing the request fails, as
+// CreateMessage would before sending it
+func (c *Client) DryRun(ctx context.Context, req models.MessageRequest, options ...RequestOption) (*DryRunResult, error) {
+	if err := c.prepareRequest(ctx, &req); err != nil {
+		return nil, err
+	}
+	options, err := c.applyOutputLimit(ctx, &req, options)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request body: %w", err)
+	}
+	httpReq, err := c.newRequest(ctx, http.MethodPost, messagesPath, body, newRequestConfig(ctx, options))
+	if err != nil {
+		return nil, err
+	}
+	header := httpReq.Header.Clone()
+	for _, key := range redactedHeaders {
+		if header.Get(key) != "" {
+			header.Set(key, "REDACTED")
+		}
+	}
+
+	result := &DryRunResult{
+		Request:     req,
+		Method:      httpReq.Method,
+		URL:         httpReq.URL.String(),
+		Header:      header,
+		Body:        body,
+		Problems:    c.validateRequest(ctx, &req, options),
+		InputTokens: models.EstimateRequestTokens(&req),
+	}
+	if pricing, ok := models.PricingFor(req.Model); ok {
+		result.Pricing = &pricing
+		result.InputCost = pricing.Cost(models.Usage{InputTokens: result.InputTokens})
+		result.MaxCost = pricing.Cost(models.Usage{InputTokens: result.InputTokens, OutputTokens: req.MaxTokens})
+	}
+	return result, nil
+}
+
+// validateRequest returns the reasons the API would likely reject a request
+func (c *Client) validateRequest(ctx context.Context, req *models.MessageRequest, options []RequestOption) []error {
+	var problems []error
+	if req.Model == "" {
+		problems = append(problems, errors.New("model is not set"))
+	}
+	if req.MaxTokens <= 0 {
+		problems = append(problems, fmt.Errorf("max_tokens %d must be positive", req.MaxTokens))
+	}
+	if len(req.Messages) == 0 {
+		problems = append(problems, errors.New("messages are empty"))
+	}
+
+	extended := false
+	if limit, ok := models.OutputLimitFor(req.Model); ok && limit.ExtendedBeta != "" {
+		extended = c.betaEnabled(ctx, limit.ExtendedBeta, options)
+	}
+	for _, err := range []error{
+		models.ValidateMaxTokens(req, extended),
+		models.ValidateSampling(req),
+		models.ValidateStopSequences(req.StopSequences),
+	} {
+		if err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,241 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/internal/safe"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures after
+	// which an endpoint is considered unhealthy
+	DefaultFailureThreshold = 2
+
+	// DefaultHealthCheckInterval is the minimum time between two health checks
+	// of an unhealthy endpoint
+	DefaultHealthCheckInterval = 30 * time.Second
+)
+
+// HealthCheck reports whether the endpoint at baseURL is reachable
+type HealthCheck func(ctx context.Context, baseURL string) error
+
+// EndpointPool fails over between several base URLs, such as regional proxies
+// in front of the API. Requests stick to the current endpoint until it fails
+// FailureThreshold times in a row, then move to the next healthy endpoint.
+// Unhealthy endpoints are health checked in the background and become
+// eligible again once a check passes
+type EndpointPool struct {
+	// FailureThreshold is the number of consecutive failures after which an
+	// endpoint is marked unhealthy, defaults to DefaultFailureThreshold
+	FailureThreshold int
+
+	// HealthCheckInterval is the minimum time between two health checks of an
+	// unhealthy endpoint, defaults to DefaultHealthCheckInterval
+	HealthCheckInterval time.Duration
+
+	// HealthCheck probes an unhealthy endpoint, defaults to an HTTP HEAD
+	// request that succeeds on any response below 500. A panicking health
+	// check counts as failed
+	HealthCheck HealthCheck
+
+	// OnFailover is called in the background when requests move to another
+	// endpoint. Its panics are recovered unless the client propagates panics
+	OnFailover func(from, to string)
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	current   int
+}
+
+// endpoint is the health state of a base URL
+type endpoint struct {
+	url       string
+	healthy   bool
+	failures  int
+	checking  bool
+	lastCheck time.Time
+}
+
+// NewEndpointPool creates a pool for the base URLs, in order of preference
+func NewEndpointPool(baseURLs ...string) *EndpointPool {
+	pool := &EndpointPool{}
+	for _, url := range baseURLs {
+		pool.endpoints = append(pool.endpoints, &endpoint{url: strings.TrimSuffix(url, "/"), healthy: true})
+	}
+	return pool
+}
+
+// WithBaseURLs makes the client fail over between several base URLs
+func WithBaseURLs(baseURLs ...string) ClientOption {
+	return WithEndpointPool(NewEndpointPool(baseURLs...))
+}
+
+// WithEndpointPool makes the client fail over between the endpoints of a pool
+func WithEndpointPool(pool *EndpointPool) ClientOption {
+	return func(c *Client) {
+		c.Endpoints = pool
+	}
+}
+
+// Current returns the base URL requests are currently sent to
+func (p *EndpointPool) Current() string {
+	return p.currentURL(safe.Guard{})
+}
+
+// currentURL returns the base URL requests are currently sent to, running the
+// callbacks of the health checks it starts with the guard
+func (p *EndpointPool) currentURL(guard safe.Guard) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.endpoints) == 0 {
+		return ""
+	}
+	p.checkUnhealthy(guard)
+	return p.endpoints[p.current].url
+}
+
+// Healthy returns the base URLs currently considered healthy
+func (p *EndpointPool) Healthy() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var urls []string
+	for _, e := range p.endpoints {
+		if e.healthy {
+			urls = append(urls, e.url)
+		}
+	}
+	return urls
+}
+
+// observe records the outcome of a request sent to url
+func (p *EndpointPool) observe(url string, resp *http.Response, err error, guard safe.Guard) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	i := p.find(url)
+	if i < 0 {
+		return
+	}
+	e := p.endpoints[i]
+
+	if err == nil && resp.StatusCode < 500 {
+		e.failures = 0
+		e.healthy = true
+		return
+	}
+
+	e.failures++
+	threshold := p.FailureThreshold
+	if threshold <= 0 {
+		threshold = DefaultFailureThreshold
+	}
+	if e.failures < threshold || !e.healthy {
+		return
+	}
+
+	e.healthy = false
+	e.lastCheck = time.Now()
+	if i == p.current {
+		p.failover(guard)
+	}
+}
+
+// failover moves to the next healthy endpoint after the current one. The
+// current endpoint is kept if no endpoint is healthy
+func (p *EndpointPool) failover(guard safe.Guard) {
+	from := p.endpoints[p.current].url
+	for offset := 1; offset < len(p.endpoints); offset++ {
+		next := (p.current + offset) % len(p.endpoints)
+		if p.endpoints[next].healthy {
+			p.current = next
+			if p.OnFailover != nil {
+				to := p.endpoints[next].url
+				go guard.Notify("failover callback", func() { p.OnFailover(from, to) })
+			}
+			return
+		}
+	}
+}
+
+// checkUnhealthy starts background health checks of unhealthy endpoints whose
+// last check is older than the interval
+func (p *EndpointPool) checkUnhealthy(guard safe.Guard) {
+	interval := p.HealthCheckInterval
+	if interval <= 0 {
+		interval = DefaultHealthCheckInterval
+	}
+
+	for _, e := range p.endpoints {
+		if e.healthy || e.checking || time.Since(e.lastCheck) < interval {
+			continue
+		}
+		e.checking = true
+		go p.check(e, guard)
+	}
+}
+
+// check runs the health check of an endpoint
+func (p *EndpointPool) check(e *endpoint, guard safe.Guard) {
+	healthCheck := p.HealthCheck
+	if healthCheck == nil {
+		healthCheck = defaultHealthCheck
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	err := guard.Call("health check", func() error {
+		return healthCheck(ctx, e.url)
+	})
+	cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.checking = false
+	e.lastCheck = time.Now()
+	if err != nil {
+		return
+	}
+
+	e.healthy = true
+	e.failures = 0
+	if current := p.endpoints[p.current]; !current.healthy {
+		// The current endpoint is down and nothing else was healthy
+		p.failover(guard)
+	}
+}
+
+// find returns the index of the endpoint a request URL was sent to, or -1
+func (p *EndpointPool) find(url string) int {
+	for i, e := range p.endpoints {
+		if strings.HasPrefix(url, e.url+"/") {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultHealthCheck sends a HEAD request to the base URL
+func defaultHealthCheck(ctx context.Context, baseURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("health check failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
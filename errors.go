@@ -3,6 +3,8 @@ package anthropic
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -23,6 +25,9 @@ type APIError struct {
 type RateLimitInfo struct {
 	ResetAfter int    `json:"-"`
 	LimitType  string `json:"-"`
+
+	// Limits holds the rate limit headers of the response
+	Limits RateLimits `json:"-"`
 }
 
 // Error implements the error interface
@@ -77,6 +82,76 @@ func (e *APIError) Error() string {
 	return strings.Join(parts, ". ")
 }
 
+// apiErrorDetail is the error object of a marshaled API error
+type apiErrorDetail struct {
+	Type     string            `json:"type"`
+	Message  string            `json:"message"`
+	Code     string            `json:"code,omitempty"`
+	Param    string            `json:"param,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// apiErrorEnvelope is the stable JSON representation of an API error
+type apiErrorEnvelope struct {
+	Type       string         `json:"type"`
+	Error      apiErrorDetail `json:"error"`
+	StatusCode int            `json:"status_code,omitempty"`
+	RequestID  string         `json:"request_id,omitempty"`
+	RetryAfter int            `json:"retry_after,omitempty"`
+	LimitType  string         `json:"limit_type,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The error is encoded in
+// the same envelope the Anthropic API uses, extended with the HTTP status,
+// request ID and rate limit information, so it can be forwarded to clients
+func (e *APIError) MarshalJSON() ([]byte, error) {
+	envelope := apiErrorEnvelope{
+		Type: "error",
+		Error: apiErrorDetail{
+			Type:     e.Type,
+			Message:  e.Message,
+			Code:     e.Code,
+			Param:    e.Param,
+			Metadata: e.Metadata,
+		},
+		StatusCode: e.StatusCode,
+		RequestID:  e.RequestID,
+	}
+
+	if e.RateLimitInfo != nil {
+		envelope.RetryAfter = e.RateLimitInfo.ResetAfter
+		envelope.LimitType = e.RateLimitInfo.LimitType
+	}
+
+	return json.Marshal(envelope)
+}
+
+// WriteHTTPResponse writes the error as a JSON HTTP response using the original
+// status code, request ID and retry-after headers
+func (e *APIError) WriteHTTPResponse(w http.ResponseWriter) error {
+	data, err := e.MarshalJSON()
+	if err != nil {
+		return fmt.Errorf("error marshaling api error: %w", err)
+	}
+
+	statusCode := e.StatusCode
+	if statusCode == 0 {
+		statusCode = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if e.RequestID != "" {
+		w.Header().Set("x-request-id", e.RequestID)
+	}
+	if e.RateLimitInfo != nil && e.RateLimitInfo.ResetAfter > 0 {
+		w.Header().Set("retry-after", strconv.Itoa(e.RateLimitInfo.ResetAfter))
+	}
+	w.WriteHeader(statusCode)
+
+	_, err = w.Write(data)
+	return err
+}
+
 // ParseAPIError attempts to parse an API error from a JSON response
 func ParseAPIError(statusCode int, data []byte) *APIError {
 	var apiErr APIError
@@ -139,3 +214,13 @@ func (e *APIError) IsPermissionError() bool {
 func (e *APIError) IsModelNotAvailableError() bool {
 	return e.Code == "model_not_available" || strings.Contains(e.Message, "model not available")
 }
+
+// IsOverloadedError returns true if the error indicates the API is overloaded
+func (e *APIError) IsOverloadedError() bool {
+	return e.Type == "overloaded_error"
+}
+
+// IsNotFoundError returns true if the error is a not found error
+func (e *APIError) IsNotFoundError() bool {
+	return e.Type == "not_found_error"
+}
@@ -0,0 +1,147 @@
+package anthropic
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Exchange describes a completed API request, for auditing and logging. For
+// streaming requests ResponseBody is empty, since the response is consumed by
+// the caller. Their exchange is observed once the stream ends or is closed,
+// with the SHA-256 digest of the streamed body in ResponseDigest
+type Exchange struct {
+	Method         string
+	Path           string
+	RequestBody    []byte
+	StatusCode     int
+	ResponseBody   []byte
+	ResponseDigest []byte
+	RequestID      string
+	Streaming      bool
+	Start          time.Time
+	End            time.Time
+	Err            error
+}
+
+// errStreamClosed is the error of streamed exchanges closed before the end of
+// the response
+var errStreamClosed = errors.New("stream closed before the end of the response")
+
+// ExchangeObserver is called after every API request. It must not modify the
+// exchange's byte slices
+type ExchangeObserver func(Exchange)
+
+// WithExchangeObserver adds an observer called after every API request
+func WithExchangeObserver(observer ExchangeObserver) ClientOption {
+	return func(c *Client) {
+		c.Observers = append(c.Observers, observer)
+	}
+}
+
+// observe passes an exchange to the client's observers, returning the panic
+// of an observer
+func (c *Client) observe(exchange Exchange) error {
+	if len(c.Observers) == 0 {
+		return nil
+	}
+
+	exchange.End = time.Now()
+	if exchange.RequestID == "" {
+		var apiErr *APIError
+		if errors.As(exchange.Err, &apiErr) {
+			exchange.RequestID = apiErr.RequestID
+			exchange.StatusCode = apiErr.StatusCode
+		}
+	}
+	for _, observer := range c.Observers {
+		if err := c.guard().Notify("exchange observer", func() { observer(exchange) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newExchange starts an exchange for a request
+func newExchange(method, path string, body []byte) Exchange {
+	return Exchange{Method: method, Path: path, RequestBody: body, Start: time.Now()}
+}
+
+// setResponse records the status and request ID of a response
+func (e *Exchange) setResponse(resp *http.Response) {
+	e.StatusCode = resp.StatusCode
+	e.RequestID = resp.Header.Get("request-id")
+	if e.RequestID == "" {
+		e.RequestID = resp.Header.Get("x-request-id")
+	}
+}
+
+// observingBody computes the digest of a streamed response body while it is
+// read, and passes the exchange to the client's observers once the body ends
+// or is closed
+type observingBody struct {
+	io.ReadCloser
+	client   *Client
+	exchange Exchange
+
+	mu   sync.Mutex
+	hash hash.Hash
+
+	once       sync.Once
+	observeErr error
+}
+
+// observeStream wraps a streamed response body to observe its exchange once
+// the body ends
+func (c *Client) observeStream(body io.ReadCloser, exchange Exchange) io.ReadCloser {
+	if len(c.Observers) == 0 {
+		return body
+	}
+	return &observingBody{ReadCloser: body, client: c, exchange: exchange, hash: sha256.New()}
+}
+
+// Read implements io.Reader. The panic of an observer is returned as the error
+// of the read ending the body
+func (b *observingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	b.hash.Write(p[:n])
+	b.mu.Unlock()
+
+	if err != nil {
+		readErr := err
+		if err == io.EOF {
+			readErr = nil
+		}
+		if observeErr := b.finish(readErr); observeErr != nil {
+			return n, observeErr
+		}
+	}
+	return n, err
+}
+
+// Close implements io.Closer
+func (b *observingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if observeErr := b.finish(errStreamClosed); observeErr != nil && err == nil {
+		err = observeErr
+	}
+	return err
+}
+
+// finish observes the exchange the first time the body ends, with the error
+// that ended it
+func (b *observingBody) finish(err error) error {
+	b.once.Do(func() {
+		b.mu.Lock()
+		b.exchange.ResponseDigest = b.hash.Sum(nil)
+		b.mu.Unlock()
+		b.exchange.Err = err
+		b.observeErr = b.client.observe(b.exchange)
+	})
+	return b.observeErr
+}
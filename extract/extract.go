@@ -0,0 +1,202 @@
+// Package extract extracts structured data from text into Go structs. The
+// JSON schema of the struct is derived by reflection and the model is forced
+// to answer through a tool with that schema
+package extract
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/jsonrepair"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultToolName is the name of the tool the model answers with
+	DefaultToolName = "extract"
+
+	// DefaultMaxTokens is the default response limit
+	DefaultMaxTokens = 2048
+)
+
+// ErrNoExtraction is returned when the response contains no tool call
+var ErrNoExtraction = errors.New("response contains no extraction")
+
+// MissingFieldsError is returned when the extracted data lacks required
+// fields. Fields holds their paths, such as "address.city" or "items[2].name"
+type MissingFieldsError struct {
+	Fields []string
+}
+
+// Error implements the error interface
+func (e *MissingFieldsError) Error() string {
+	return fmt.Sprintf("missing required fields: %s", strings.Join(e.Fields, ", "))
+}
+
+// Options configures an extraction
+type Options struct {
+	Model string
+
+	// Instructions are added to the system prompt, for example to describe
+	// what to extract
+	Instructions string
+
+	// ToolName is the name of the extraction tool, defaults to DefaultToolName
+	ToolName string
+
+	// MaxTokens limits the response, defaults to DefaultMaxTokens
+	MaxTokens int
+
+	// RepairMetrics counts how often the extraction needed JSON repair when
+	// set
+	RepairMetrics *jsonrepair.Metrics
+}
+
+// Extract extracts a T from text. T must be a struct, fields are named after
+// their json tags and described by their description tags. Fields without
+// omitempty that are not pointers are required, and a *MissingFieldsError is
+// returned together with the partially populated T when they are absent.
+// Input that does not decode, such as nested objects sent as JSON strings, and
+// responses answering with JSON text instead of the tool are repaired with
+// jsonrepair before giving up
+func Extract[T any](ctx context.Context, client anthropic.ChatProvider, text string, options Options) (T, error) {
+	var result T
+
+	schema, err := models.SchemaFor(reflect.TypeOf(result))
+	if err != nil {
+		return result, err
+	}
+
+	toolName := options.ToolName
+	if toolName == "" {
+		toolName = DefaultToolName
+	}
+	maxTokens := options.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	system := fmt.Sprintf("Extract the requested information from the text provided by the user by calling the %s tool. Only use information present in the text.", toolName)
+	if options.Instructions != "" {
+		system += "\n\n" + options.Instructions
+	}
+
+	choice := models.SpecificToolChoice(toolName, true)
+	resp, err := client.CreateMessage(ctx, models.MessageRequest{
+		Model:      options.Model,
+		System:     system,
+		MaxTokens:  maxTokens,
+		Messages:   []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(text))},
+		Tools:      []models.Tool{models.NewTool(toolName, "Record the extracted information", schema)},
+		ToolChoice: &choice,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	input, found := toolInput(resp, toolName)
+	repaired := false
+	if !found {
+		if input, found = textInput(resp); !found {
+			options.RepairMetrics.Record(true, true)
+			return result, ErrNoExtraction
+		}
+		repaired = true
+	}
+
+	input, decodeRepaired, err := decode(input, schema, &result)
+	options.RepairMetrics.Record(repaired || decodeRepaired, err != nil)
+	if err != nil {
+		return result, fmt.Errorf("error decoding extraction: %w", err)
+	}
+	if missing := schema.MissingFields(input); len(missing) > 0 {
+		return result, &MissingFieldsError{Fields: missing}
+	}
+	return result, nil
+}
+
+// toolInput returns the input of the extraction tool call
+func toolInput(resp *models.Message, toolName string) (interface{}, bool) {
+	for _, block := range resp.Content {
+		if block.ToolUseContent != nil && block.ToolUseContent.Name == toolName {
+			return block.ToolUseContent.Input, true
+		}
+	}
+	return nil, false
+}
+
+// textInput returns the JSON object of a response that answered in text
+// instead of calling the tool, repairing it when needed
+func textInput(resp *models.Message) (interface{}, bool) {
+	text := resp.Text()
+	if !strings.Contains(text, "{") {
+		return nil, false
+	}
+	var input map[string]interface{}
+	if err := jsonrepair.Unmarshal([]byte(text), &input); err != nil {
+		return nil, false
+	}
+	return input, true
+}
+
+// decode decodes the tool input into v. When it does not decode, string values
+// of object and array properties are repaired and parsed, as models sometimes
+// send nested values as JSON strings. It returns the decoded input and whether
+// repair was needed
+func decode(input interface{}, schema models.InputSchema, v interface{}) (interface{}, bool, error) {
+	if err := remarshal(input, v); err == nil {
+		return input, false, nil
+	}
+
+	input = repairStrings(input, models.Property{Type: schema.Type, Properties: schema.Properties})
+	if err := remarshal(input, v); err != nil {
+		return input, true, err
+	}
+	return input, true, nil
+}
+
+// repairStrings replaces strings where the schema expects objects or arrays
+// with the values they hold
+func repairStrings(value interface{}, property models.Property) interface{} {
+	switch v := value.(type) {
+	case string:
+		if property.Type != "object" && property.Type != "array" {
+			return v
+		}
+		var parsed interface{}
+		if err := jsonrepair.Unmarshal([]byte(v), &parsed); err != nil {
+			return v
+		}
+		return repairStrings(parsed, property)
+	case map[string]interface{}:
+		repaired := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			repaired[key] = repairStrings(field, property.Properties[key])
+		}
+		return repaired
+	case []interface{}:
+		if property.Items == nil {
+			return v
+		}
+		repaired := make([]interface{}, len(v))
+		for i, item := range v {
+			repaired[i] = repairStrings(item, *property.Items)
+		}
+		return repaired
+	}
+	return value
+}
+
+// remarshal converts from into to through JSON
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
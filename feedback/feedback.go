@@ -0,0 +1,143 @@
+// Package feedback provides hooks for recording human feedback on generated
+// messages, keyed by the message ID returned by the API
+package feedback
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Rating is a coarse human judgement of a message
+type Rating string
+
+const (
+	ThumbsUp   Rating = "thumbs_up"
+	ThumbsDown Rating = "thumbs_down"
+)
+
+// ErrMissingMessageID is returned when feedback is recorded without a message ID
+var ErrMissingMessageID = errors.New("feedback: missing message id")
+
+// Feedback is a piece of human feedback on a message
+type Feedback struct {
+	MessageID  string            `json:"message_id"`
+	Rating     Rating            `json:"rating,omitempty"`
+	Correction string            `json:"correction,omitempty"`
+	Comment    string            `json:"comment,omitempty"`
+	UserID     string            `json:"user_id,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// Store records and retrieves feedback. Implementations must be safe for
+// concurrent use
+type Store interface {
+	// Record stores a piece of feedback
+	Record(ctx context.Context, feedback Feedback) error
+
+	// ForMessage returns the feedback recorded for a message, oldest first
+	ForMessage(ctx context.Context, messageID string) ([]Feedback, error)
+}
+
+// Recorder validates feedback and writes it to a store
+type Recorder struct {
+	Store Store
+
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+}
+
+// NewRecorder creates a new recorder writing to the given store
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{Store: store}
+}
+
+// Record stores feedback, setting its creation time if it is not set
+func (r *Recorder) Record(ctx context.Context, feedback Feedback) error {
+	if feedback.MessageID == "" {
+		return ErrMissingMessageID
+	}
+	if feedback.CreatedAt.IsZero() {
+		now := time.Now
+		if r.Now != nil {
+			now = r.Now
+		}
+		feedback.CreatedAt = now()
+	}
+	return r.Store.Record(ctx, feedback)
+}
+
+// ThumbsUp records positive feedback on a message
+func (r *Recorder) ThumbsUp(ctx context.Context, messageID string) error {
+	return r.Record(ctx, Feedback{MessageID: messageID, Rating: ThumbsUp})
+}
+
+// ThumbsDown records negative feedback on a message with an optional
+// correction of what the response should have been
+func (r *Recorder) ThumbsDown(ctx context.Context, messageID, correction string) error {
+	return r.Record(ctx, Feedback{MessageID: messageID, Rating: ThumbsDown, Correction: correction})
+}
+
+// MemoryStore is an in-memory Store, useful for tests and prototypes
+type MemoryStore struct {
+	mu       sync.RWMutex
+	feedback map[string][]Feedback
+}
+
+// NewMemoryStore creates a new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{feedback: make(map[string][]Feedback)}
+}
+
+// Record implements the Store interface
+func (s *MemoryStore) Record(ctx context.Context, feedback Feedback) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.feedback[feedback.MessageID] = append(s.feedback[feedback.MessageID], feedback)
+	return nil
+}
+
+// ForMessage implements the Store interface
+func (s *MemoryStore) ForMessage(ctx context.Context, messageID string) ([]Feedback, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Feedback(nil), s.feedback[messageID]...), nil
+}
+
+// StoreFunc adapts a function to a write-only Store, for forwarding feedback
+// to an external system such as a queue or analytics pipeline
+type StoreFunc func(ctx context.Context, feedback Feedback) error
+
+// Record implements the Store interface
+func (f StoreFunc) Record(ctx context.Context, feedback Feedback) error {
+	return f(ctx, feedback)
+}
+
+// ForMessage implements the Store interface, write-only stores return nothing
+func (f StoreFunc) ForMessage(ctx context.Context, messageID string) ([]Feedback, error) {
+	return nil, nil
+}
+
+// MultiStore records feedback to several stores and reads from the first
+type MultiStore []Store
+
+// Record implements the Store interface
+func (m MultiStore) Record(ctx context.Context, feedback Feedback) error {
+	var errs []error
+	for _, store := range m {
+		if err := store.Record(ctx, feedback); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ForMessage implements the Store interface
+func (m MultiStore) ForMessage(ctx context.Context, messageID string) ([]Feedback, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	return m[0].ForMessage(ctx, messageID)
+}
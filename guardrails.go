@@ -0,0 +1,166 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ViolationsPlaceholder is replaced with the list of violations in the
+// correction prompt
+const ViolationsPlaceholder = "{violations}"
+
+// DefaultCorrectionPrompt is sent after a response that violates the guardrails
+// when retrying
+const DefaultCorrectionPrompt = "Your previous response did not meet the following requirements:\n" + ViolationsPlaceholder + "\nPlease respond again, following all requirements."
+
+// Violation describes a guardrail rule that a response broke
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// Rule is a custom guardrail rule. It returns a description of the problem, or
+// an empty string if the text is acceptable
+type Rule func(text string) string
+
+// Guardrails validates the final text of responses against a set of rules and
+// optionally retries with a corrective instruction. Responses that stop to use
+// tools are not validated
+type Guardrails struct {
+	// MustMatch lists patterns the text must match
+	MustMatch []*regexp.Regexp
+
+	// Banned lists substrings the text must not contain, matched case
+	// insensitively
+	Banned []string
+
+	// MaxLength is the maximum text length in characters, zero means no limit
+	MaxLength int
+
+	// Rules lists custom rules
+	Rules []Rule
+
+	// MaxRetries is the number of corrective retries for unary requests
+	MaxRetries int
+
+	// CorrectionPrompt is the instruction sent when retrying, defaults to
+	// DefaultCorrectionPrompt. ViolationsPlaceholder is replaced with the list
+	// of violations, which is appended when the prompt has no placeholder
+	CorrectionPrompt string
+}
+
+// GuardrailError is returned when a response violates the guardrails
+type GuardrailError struct {
+	Violations []Violation
+	Message    *models.Message
+}
+
+// Error implements the error interface
+func (e *GuardrailError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, violation := range e.Violations {
+		messages = append(messages, violation.Message)
+	}
+	return "guardrail violation: " + strings.Join(messages, "; ")
+}
+
+// Validate checks text against the guardrails and returns the violations
+func (g *Guardrails) Validate(text string) []Violation {
+	var violations []Violation
+
+	for _, pattern := range g.MustMatch {
+		if !pattern.MatchString(text) {
+			violations = append(violations, Violation{
+				Rule:    "must_match",
+				Message: fmt.Sprintf("the response must match the pattern %s", pattern),
+			})
+		}
+	}
+
+	lower := strings.ToLower(text)
+	for _, banned := range g.Banned {
+		if banned != "" && strings.Contains(lower, strings.ToLower(banned)) {
+			violations = append(violations, Violation{
+				Rule:    "banned",
+				Message: fmt.Sprintf("the response must not contain %q", banned),
+			})
+		}
+	}
+
+	if g.MaxLength > 0 && len([]rune(text)) > g.MaxLength {
+		violations = append(violations, Violation{
+			Rule:    "max_length",
+			Message: fmt.Sprintf("the response must be at most %d characters long", g.MaxLength),
+		})
+	}
+
+	for _, rule := range g.Rules {
+		if problem := rule(text); problem != "" {
+			violations = append(violations, Violation{Rule: "custom", Message: problem})
+		}
+	}
+
+	return violations
+}
+
+// validateMessage returns a GuardrailError if the message violates the
+// guardrails
+func (g *Guardrails) validateMessage(message *models.Message) error {
+	if message.StopReason == models.ToolUse {
+		return nil
+	}
+	if violations := g.Validate(message.Text()); len(violations) > 0 {
+		return &GuardrailError{Violations: violations, Message: message}
+	}
+	return nil
+}
+
+// enforce validates a response and retries with a corrective instruction until
+// it passes or the retries are exhausted
+func (g *Guardrails) enforce(ctx context.Context, req models.MessageRequest, resp *models.Message, send func(context.Context, models.MessageRequest) (*models.Message, error)) (*models.Message, error) {
+	for attempt := 0; ; attempt++ {
+		err := g.validateMessage(resp)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= g.MaxRetries {
+			return resp, err
+		}
+
+		messages := append([]models.MessageParam(nil), req.Messages...)
+		messages = append(messages,
+			resp.ToParam(),
+			models.NewUserMessage(models.CreateTextBlock(g.correctionPrompt(err.(*GuardrailError).Violations))),
+		)
+		retry := req
+		retry.Messages = messages
+
+		resp, err = send(ctx, retry)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// correctionPrompt returns the instruction listing the violations
+func (g *Guardrails) correctionPrompt(violations []Violation) string {
+	prompt := g.CorrectionPrompt
+	if prompt == "" {
+		prompt = DefaultCorrectionPrompt
+	}
+
+	lines := make([]string, len(violations))
+	for i, violation := range violations {
+		lines[i] = "- " + violation.Message
+	}
+	list := strings.Join(lines, "\n")
+
+	if !strings.Contains(prompt, ViolationsPlaceholder) {
+		return prompt + "\n\n" + list
+	}
+	return strings.ReplaceAll(prompt, ViolationsPlaceholder, list)
+}
@@ -0,0 +1,401 @@
+// Package handoff runs teams of agents that transfer a conversation to each
+// other through a built-in handoff tool, the pattern of a triage agent routing
+// requests to specialists
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+// ToolName is the name of the handoff tool offered to agents
+const ToolName = "handoff"
+
+// DefaultMaxTokens is the max tokens of agents that do not set one
+const DefaultMaxTokens = 1024
+
+// DefaultMaxHandoffs is the default number of handoffs a run may make
+const DefaultMaxHandoffs = 5
+
+// ErrMaxHandoffs is returned when the agents keep handing the conversation
+// off after the maximum number of handoffs
+var ErrMaxHandoffs = errors.New("handoff: exceeded the maximum number of handoffs")
+
+// ContextFilter selects the history carried over to the agent taking over.
+// The messages end with the turn that led to the handoff, the handoff call
+// itself is removed
+type ContextFilter func(messages []models.MessageParam, handoff Handoff) []models.MessageParam
+
+// FullHistory carries over the whole history. Tool calls and results are
+// rewritten as text, since the agent taking over has other tools, and
+// thinking blocks are dropped
+func FullHistory(messages []models.MessageParam, handoff Handoff) []models.MessageParam {
+	var carried []models.MessageParam
+	for _, message := range messages {
+		var content []models.ContentBlock
+		for _, block := range message.Content {
+			switch {
+			case block.ToolUseContent != nil:
+				input, _ := json.Marshal(block.ToolUseContent.Input)
+				content = append(content, models.CreateTextBlock(fmt.Sprintf("[Called tool %s with input %s]", block.ToolUseContent.Name, input)))
+			case block.ToolResultContent != nil:
+				content = append(content, models.CreateTextBlock(fmt.Sprintf("[Tool result: %s]", toolResultText(block.ToolResultContent))))
+			case block.ThinkingContent != nil, block.RedactedThinkingContent != nil:
+			default:
+				content = append(content, block)
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		// Dropped messages may leave turns of the same role next to each other
+		if last := len(carried) - 1; last >= 0 && carried[last].Role == message.Role {
+			carried[last].Content = append(carried[last].Content, content...)
+			continue
+		}
+		carried = append(carried, models.MessageParam{Role: message.Role, Content: content})
+	}
+	return carried
+}
+
+// LastUserTurn carries over only the most recent user message that is not a
+// tool result, leaving the notes of the handoff as the rest of the context
+func LastUserTurn(messages []models.MessageParam, handoff Handoff) []models.MessageParam {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != models.UserRole || hasToolResults(messages[i]) {
+			continue
+		}
+		return []models.MessageParam{messages[i]}
+	}
+	return nil
+}
+
+// Agent is a model configuration with its own instructions and tools that can
+// take over a conversation
+type Agent struct {
+	// Name identifies the agent to the other agents of a team
+	Name string
+
+	// Description tells the other agents when to hand off to this agent
+	Description string
+
+	Model     string
+	System    string
+	MaxTokens int
+	Tools     []tools.Tool
+
+	// Handoffs names the agents this agent may hand off to, all other agents
+	// of the team when empty
+	Handoffs []string
+
+	// Context selects the history carried over when this agent takes over,
+	// defaults to FullHistory
+	Context ContextFilter
+}
+
+// Handoff is a transfer of the conversation between agents
+type Handoff struct {
+	From string
+	To   string
+
+	// Reason is why the conversation was handed off
+	Reason string
+
+	// Notes is the context the handing off agent passes on
+	Notes string
+}
+
+// Team holds agents that hand conversations off to each other
+type Team struct {
+	Client anthropic.ChatProvider
+
+	// MaxHandoffs limits the handoffs of a run, defaults to
+	// DefaultMaxHandoffs
+	MaxHandoffs int
+
+	// MaxIterations limits the requests of an agent between handoffs,
+	// defaults to tools.DefaultMaxIterations
+	MaxIterations int
+
+	// OnHandoff is called before an agent takes over when set
+	OnHandoff func(handoff Handoff)
+
+	agents map[string]*Agent
+	order  []string
+}
+
+// Result is the outcome of a run
+type Result struct {
+	// Agent is the name of the agent that produced the final response
+	Agent string
+
+	// Message is the final response
+	Message *models.Message
+
+	// Messages is the history of the final agent, ending with its response
+	Messages []models.MessageParam
+
+	// Handoffs lists the handoffs of the run in order
+	Handoffs []Handoff
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// NewTeam creates a team of agents
+func NewTeam(client anthropic.ChatProvider, agents ...*Agent) *Team {
+	team := &Team{Client: client, agents: make(map[string]*Agent)}
+	for _, agent := range agents {
+		team.Register(agent)
+	}
+	return team
+}
+
+// Register adds an agent, replacing an agent with the same name
+func (t *Team) Register(agent *Agent) {
+	if _, ok := t.agents[agent.Name]; !ok {
+		t.order = append(t.order, agent.Name)
+	}
+	t.agents[agent.Name] = agent
+}
+
+// Agent returns the agent with the given name
+func (t *Team) Agent(name string) (*Agent, bool) {
+	agent, ok := t.agents[name]
+	return agent, ok
+}
+
+// Run continues the conversation with the named agent, executing its tools and
+// following handoffs until an agent responds without calling tools
+func (t *Team) Run(ctx context.Context, agentName string, messages []models.MessageParam) (*Result, error) {
+	agent, ok := t.agents[agentName]
+	if !ok {
+		return nil, fmt.Errorf("handoff: unknown agent %q", agentName)
+	}
+
+	result := &Result{}
+	messages = append([]models.MessageParam(nil), messages...)
+	for {
+		resp, next, err := t.runAgent(ctx, agent, &messages, result)
+		if err != nil {
+			return nil, err
+		}
+		if next == nil {
+			result.Agent = agent.Name
+			result.Message = resp
+			result.Messages = messages
+			return result, nil
+		}
+
+		if len(result.Handoffs) >= t.maxHandoffs() {
+			return nil, ErrMaxHandoffs
+		}
+		result.Handoffs = append(result.Handoffs, *next)
+		if t.OnHandoff != nil {
+			t.OnHandoff(*next)
+		}
+
+		target := t.agents[next.To]
+		filter := target.Context
+		if filter == nil {
+			filter = FullHistory
+		}
+		messages = withNotes(filter(messages, *next), *next)
+		agent = target
+	}
+}
+
+// runAgent runs the tool loop of an agent until it responds without calling
+// tools or hands off. The history is updated in place, with the turn calling
+// the handoff tool removed
+func (t *Team) runAgent(ctx context.Context, agent *Agent, messages *[]models.MessageParam, result *Result) (*models.Message, *Handoff, error) {
+	registry := tools.NewRegistry(agent.Tools...)
+	targets := t.targets(agent)
+	definitions := registry.Definitions()
+	if len(targets) > 0 {
+		definitions = append(definitions, t.handoffTool(targets))
+	}
+
+	maxTokens := agent.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	for i := 0; i < t.maxIterations(); i++ {
+		resp, err := t.Client.CreateMessage(ctx, models.MessageRequest{
+			Model:     agent.Model,
+			System:    agent.System,
+			MaxTokens: maxTokens,
+			Tools:     definitions,
+			Messages:  *messages,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error running agent %s: %w", agent.Name, err)
+		}
+		result.Usage = result.Usage.Add(resp.Usage)
+
+		var calls []tools.Call
+		for _, block := range resp.Content {
+			if block.ToolUseContent == nil {
+				continue
+			}
+			call, err := tools.CallFor(block.ToolUseContent)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error running agent %s: %w", agent.Name, err)
+			}
+			calls = append(calls, call)
+		}
+
+		if resp.StopReason != models.ToolUse || len(calls) == 0 {
+			*messages = append(*messages, resp.ToParam())
+			return resp, nil, nil
+		}
+
+		for _, call := range calls {
+			if call.Name != ToolName {
+				continue
+			}
+			handoff, err := parseHandoff(agent.Name, call.Input, targets)
+			if err == nil {
+				return resp, handoff, nil
+			}
+		}
+
+		results := make([]models.ContentBlock, len(calls))
+		for i, call := range calls {
+			if call.Name == ToolName {
+				_, err := parseHandoff(agent.Name, call.Input, targets)
+				results[i] = models.CreateToolResultBlock(call.ID, err.Error(), true)
+				continue
+			}
+			results[i] = registry.Execute(ctx, call)
+		}
+		*messages = append(*messages, resp.ToParam(), models.NewUserMessage(results...))
+	}
+
+	return nil, nil, fmt.Errorf("error running agent %s: %w", agent.Name, tools.ErrMaxTurnsExceeded)
+}
+
+// targets returns the agents an agent may hand off to, in registration order
+func (t *Team) targets(agent *Agent) []*Agent {
+	allowed := make(map[string]bool, len(agent.Handoffs))
+	for _, name := range agent.Handoffs {
+		allowed[name] = true
+	}
+
+	var targets []*Agent
+	for _, name := range t.order {
+		if name == agent.Name || (len(allowed) > 0 && !allowed[name]) {
+			continue
+		}
+		targets = append(targets, t.agents[name])
+	}
+	return targets
+}
+
+// handoffTool returns the definition of the handoff tool listing the targets
+func (t *Team) handoffTool(targets []*Agent) models.Tool {
+	var description strings.Builder
+	description.WriteString("Transfer the conversation to another agent that is better suited to handle it. The other agent sees the conversation and your notes, and answers the user in your place. Available agents:\n")
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
+		fmt.Fprintf(&description, "- %s: %s\n", target.Name, target.Description)
+	}
+
+	return models.NewTool(ToolName, strings.TrimSpace(description.String()), models.InputSchema{
+		Type: "object",
+		Properties: map[string]models.Property{
+			"agent":  {Type: "string", Description: "The agent to transfer to", Enum: names},
+			"reason": {Type: "string", Description: "Why the conversation is transferred"},
+			"notes":  {Type: "string", Description: "Context the other agent needs, such as what was already found out or tried"},
+		},
+		Required: []string{"agent", "reason"},
+	})
+}
+
+// parseHandoff decodes the input of a handoff call
+func parseHandoff(from string, input json.RawMessage, targets []*Agent) (*Handoff, error) {
+	var args struct {
+		Agent  string `json:"agent"`
+		Reason string `json:"reason"`
+		Notes  string `json:"notes"`
+	}
+	if err := json.Unmarshal(input, &args); err != nil {
+		return nil, fmt.Errorf("invalid input: %v", err)
+	}
+
+	for _, target := range targets {
+		if target.Name == args.Agent {
+			return &Handoff{From: from, To: args.Agent, Reason: args.Reason, Notes: args.Notes}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown agent %q", args.Agent)
+}
+
+// withNotes adds the notes of a handoff to the last user message, or as a new
+// user message when the history does not end with one
+func withNotes(messages []models.MessageParam, handoff Handoff) []models.MessageParam {
+	note := fmt.Sprintf("[The conversation was transferred to you by the %s agent. Reason: %s", handoff.From, handoff.Reason)
+	if handoff.Notes != "" {
+		note += ". Notes: " + handoff.Notes
+	}
+	note += "]"
+
+	messages = append([]models.MessageParam(nil), messages...)
+	last := len(messages) - 1
+	if last >= 0 && messages[last].Role == models.UserRole {
+		content := append([]models.ContentBlock(nil), messages[last].Content...)
+		messages[last] = models.NewUserMessage(append(content, models.CreateTextBlock(note))...)
+		return messages
+	}
+	return append(messages, models.NewUserMessage(models.CreateTextBlock(note)))
+}
+
+// toolResultText returns the text of a tool result
+func toolResultText(result *models.ToolResultBlock) string {
+	if len(result.Blocks) == 0 {
+		return result.Content
+	}
+	var parts []string
+	for _, block := range result.Blocks {
+		if block.TextContent != nil {
+			parts = append(parts, block.TextContent.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// hasToolResults reports whether a message answers tool calls
+func hasToolResults(message models.MessageParam) bool {
+	for _, block := range message.Content {
+		if block.ToolResultContent != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// maxHandoffs returns the maximum number of handoffs of a run
+func (t *Team) maxHandoffs() int {
+	if t.MaxHandoffs > 0 {
+		return t.MaxHandoffs
+	}
+	return DefaultMaxHandoffs
+}
+
+// maxIterations returns the maximum number of requests of an agent
+func (t *Team) maxIterations() int {
+	if t.MaxIterations > 0 {
+		return t.MaxIterations
+	}
+	return tools.DefaultMaxIterations
+}
@@ -0,0 +1,146 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/internal/safe"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultHedgeCooldown is how long hedging is paused after a rate limit error
+// when the error does not say when the limit resets
+const DefaultHedgeCooldown = 30 * time.Second
+
+// Hedger reduces tail latency by sending a second identical request when the
+// first one has not completed after a delay, returning whichever completes
+// first and canceling the other. Hedging is paused after rate limit errors so
+// it does not make throttling worse
+type Hedger struct {
+	// Provider sends the requests
+	Provider ChatProvider
+
+	// Delay is how long to wait for the first request before hedging
+	Delay time.Duration
+
+	// Cooldown is how long hedging is paused after a rate limit error that
+	// does not include a reset time, defaults to DefaultHedgeCooldown
+	Cooldown time.Duration
+
+	// OnHedge is called when a hedged request is sent. A panic in it cancels
+	// the requests and is returned as a *PanicError
+	OnHedge func()
+
+	// PropagatePanics lets panics of OnHedge propagate. They also propagate
+	// when the provider is a client created WithPropagatePanics
+	PropagatePanics bool
+
+	mu            sync.Mutex
+	cooldownUntil time.Time
+}
+
+// hedgeResult is the outcome of one of the hedged requests
+type hedgeResult struct {
+	message *models.Message
+	err     error
+}
+
+// NewHedger creates a hedger that sends a second request after delay
+func NewHedger(provider ChatProvider, delay time.Duration) *Hedger {
+	return &Hedger{Provider: provider, Delay: delay}
+}
+
+var _ ChatProvider = (*Hedger)(nil)
+
+// CreateMessage creates a message, hedging the request if it is slow
+func (h *Hedger) CreateMessage(ctx context.Context, req models.MessageRequest, options ...RequestOption) (*models.Message, error) {
+	if !h.canHedge() {
+		return h.send(ctx, req, options)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	launch := func() {
+		go func() {
+			message, err := h.send(ctx, req, options)
+			results <- hedgeResult{message: message, err: err}
+		}()
+	}
+
+	launch()
+	pending := 1
+
+	timer := time.NewTimer(h.Delay)
+	defer timer.Stop()
+
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if pending == 1 && firstErr == nil && h.canHedge() {
+				if h.OnHedge != nil {
+					if err := h.guard().Notify("hedge callback", h.OnHedge); err != nil {
+						return nil, err
+					}
+				}
+				launch()
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				return result.message, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// send sends a single request and starts the cooldown on rate limit errors
+func (h *Hedger) send(ctx context.Context, req models.MessageRequest, options []RequestOption) (*models.Message, error) {
+	message, err := h.Provider.CreateMessage(ctx, req, options...)
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.IsRateLimitError() {
+		cooldown := h.Cooldown
+		if cooldown <= 0 {
+			cooldown = DefaultHedgeCooldown
+		}
+		if apiErr.RateLimitInfo != nil && apiErr.RateLimitInfo.ResetAfter > 0 {
+			cooldown = time.Duration(apiErr.RateLimitInfo.ResetAfter) * time.Second
+		}
+
+		h.mu.Lock()
+		if until := time.Now().Add(cooldown); until.After(h.cooldownUntil) {
+			h.cooldownUntil = until
+		}
+		h.mu.Unlock()
+	}
+
+	return message, err
+}
+
+// guard returns the guard running the hedger's callbacks
+func (h *Hedger) guard() safe.Guard {
+	if client, ok := h.Provider.(*Client); ok && client.PropagatePanics {
+		return client.guard()
+	}
+	return safe.Guard{Propagate: h.PropagatePanics}
+}
+
+// canHedge reports whether hedging is allowed, which it is not while cooling
+// down after a rate limit error
+func (h *Hedger) canHedge() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Now().After(h.cooldownUntil)
+}
@@ -0,0 +1,59 @@
+// Package safe runs user callbacks, recovering their panics into errors so
+// that a misbehaving callback cannot take down the process
+package safe
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is a panic recovered from a callback
+type PanicError struct {
+	// Callback names the kind of callback that panicked, such as "middleware"
+	Callback string
+
+	// Value is the value passed to panic
+	Value interface{}
+
+	// Stack is the stack trace of the panicking goroutine
+	Stack []byte
+}
+
+// Error implements the error interface
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Guard runs callbacks, recovering their panics unless Propagate is set
+type Guard struct {
+	Propagate bool
+}
+
+// Call runs fn, returning a panic as a *PanicError
+func (g Guard) Call(callback string, fn func() error) (err error) {
+	if g.Propagate {
+		return fn()
+	}
+
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Callback: callback, Value: value, Stack: debug.Stack()}
+		}
+	}()
+	return fn()
+}
+
+// Notify runs a callback without a result, returning a panic as a
+// *PanicError
+func (g Guard) Notify(callback string, fn func()) error {
+	return g.Call(callback, func() error {
+		fn()
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+// Package textchunk splits long text into chunks that fit a token budget,
+// preferring paragraph, then sentence, then word boundaries
+package textchunk
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Split splits text into chunks of at most maxTokens tokens as measured by
+// estimate. Paragraphs are kept together when they fit, longer paragraphs are
+// split at sentence and then word boundaries
+func Split(text string, maxTokens int, estimate func(string) int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if maxTokens <= 0 || estimate(text) <= maxTokens {
+		return []string{text}
+	}
+
+	var pieces []string
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			pieces = append(pieces, splitPiece(paragraph, maxTokens, estimate)...)
+		}
+	}
+
+	return pack(pieces, "\n\n", maxTokens, estimate)
+}
+
+// splitPiece splits a paragraph that does not fit into sentences, sentences
+// that do not fit into words, and words that do not fit into runes
+func splitPiece(text string, maxTokens int, estimate func(string) int) []string {
+	if estimate(text) <= maxTokens {
+		return []string{text}
+	}
+
+	if sentences := sentences(text); len(sentences) > 1 {
+		var pieces []string
+		for _, sentence := range sentences {
+			pieces = append(pieces, splitPiece(sentence, maxTokens, estimate)...)
+		}
+		return pack(pieces, " ", maxTokens, estimate)
+	}
+
+	if words := strings.Fields(text); len(words) > 1 {
+		var pieces []string
+		for _, word := range words {
+			pieces = append(pieces, splitPiece(word, maxTokens, estimate)...)
+		}
+		return pack(pieces, " ", maxTokens, estimate)
+	}
+
+	return splitRunes(text, maxTokens, estimate)
+}
+
+// pack greedily joins consecutive pieces while they fit
+func pack(pieces []string, sep string, maxTokens int, estimate func(string) int) []string {
+	var chunks []string
+	var current string
+	for _, piece := range pieces {
+		if current == "" {
+			current = piece
+			continue
+		}
+		if joined := current + sep + piece; estimate(joined) <= maxTokens {
+			current = joined
+			continue
+		}
+		chunks = append(chunks, current)
+		current = piece
+	}
+	if current != "" {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// sentences splits text after sentence ending punctuation followed by space
+func sentences(text string) []string {
+	var result []string
+	start := 0
+	for i, r := range text {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		next, _ := utf8.DecodeRuneInString(text[i+1:])
+		if i+1 < len(text) && unicode.IsSpace(next) {
+			if sentence := strings.TrimSpace(text[start : i+1]); sentence != "" {
+				result = append(result, sentence)
+			}
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(text[start:]); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
+
+// splitRunes cuts text without spaces into pieces that fit
+func splitRunes(text string, maxTokens int, estimate func(string) int) []string {
+	var chunks []string
+	for text != "" {
+		end := len(text)
+		for end > 0 && estimate(text[:end]) > maxTokens {
+			end = len(text[:end]) * maxTokens / estimate(text[:end])
+			for end > 0 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+		chunks = append(chunks, text[:end])
+		text = text[end:]
+	}
+	return chunks
+}
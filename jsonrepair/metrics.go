@@ -0,0 +1,67 @@
+package jsonrepair
+
+import "sync"
+
+// Metrics counts how often decoded JSON needed repair. It is safe for
+// concurrent use
+type Metrics struct {
+	mu       sync.Mutex
+	decodes  int
+	repaired int
+	failed   int
+}
+
+// Stats is a snapshot of Metrics
+type Stats struct {
+	// Decodes is the number of values decoded, Repaired the number of them
+	// that only decoded after repair and Failed the number that could not be
+	// decoded even after repair
+	Decodes  int
+	Repaired int
+	Failed   int
+}
+
+// RepairRate returns the share of decodes that needed repair, successful or
+// not
+func (s Stats) RepairRate() float64 {
+	if s.Decodes == 0 {
+		return 0
+	}
+	return float64(s.Repaired+s.Failed) / float64(s.Decodes)
+}
+
+// Record adds a decode, noting whether it needed repair and whether it
+// failed. A nil Metrics ignores records
+func (m *Metrics) Record(repaired, failed bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.decodes++
+	switch {
+	case failed:
+		m.failed++
+	case repaired:
+		m.repaired++
+	}
+}
+
+// Stats returns the recorded metrics
+func (m *Metrics) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Stats{Decodes: m.decodes, Repaired: m.repaired, Failed: m.failed}
+}
+
+// Reset clears the recorded metrics
+func (m *Metrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.decodes = 0
+	m.repaired = 0
+	m.failed = 0
+}
@@ -0,0 +1,378 @@
+// Package jsonrepair repairs near-valid JSON written by models, such as JSON
+// with trailing commas, unquoted keys, single quoted strings, comments or
+// surrounding prose, and JSON cut off before its end
+package jsonrepair
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrUnrepairable is returned when no JSON value can be recovered
+var ErrUnrepairable = errors.New("jsonrepair: input cannot be repaired")
+
+// maxDepth limits the nesting of repaired values
+const maxDepth = 512
+
+// Repair returns data as valid JSON. Valid JSON is returned unchanged.
+// Otherwise text before the first object or array, such as prose or a
+// markdown fence, and text after it are dropped, and the value is rewritten:
+//
+//   - trailing and repeated commas are removed and missing ones added
+//   - unquoted keys and single quoted strings are double quoted
+//   - comments are removed
+//   - Python's True, False and None are converted
+//   - unescaped control characters in strings are escaped
+//   - truncated strings, numbers, arrays and objects are closed, dropping
+//     object keys without a value
+func Repair(data []byte) ([]byte, error) {
+	if json.Valid(data) {
+		return data, nil
+	}
+
+	start := bytes.IndexAny(data, "{[")
+	if start < 0 {
+		start = 0
+	}
+
+	r := &repairer{in: data, pos: start}
+	if !r.value(0) {
+		return nil, ErrUnrepairable
+	}
+	out := r.out.Bytes()
+	if !json.Valid(out) {
+		return nil, ErrUnrepairable
+	}
+	return out, nil
+}
+
+// Unmarshal repairs data and decodes it into v
+func Unmarshal(data []byte, v interface{}) error {
+	repaired, err := Repair(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(repaired, v)
+}
+
+// repairer rewrites its input as valid JSON
+type repairer struct {
+	in  []byte
+	pos int
+	out bytes.Buffer
+}
+
+// value writes the value at the current position, reporting whether there
+// was one
+func (r *repairer) value(depth int) bool {
+	r.skip()
+	if r.eof() || depth > maxDepth {
+		return false
+	}
+
+	switch c := r.in[r.pos]; {
+	case c == '{':
+		r.object(depth)
+	case c == '[':
+		r.array(depth)
+	case c == '"' || c == '\'':
+		r.str(c, false)
+	case c == '-' || c == '+' || c == '.' || (c >= '0' && c <= '9'):
+		return r.number()
+	case isWordChar(c):
+		r.word()
+	default:
+		return false
+	}
+	return true
+}
+
+// object writes an object, tolerating missing and extra commas
+func (r *repairer) object(depth int) {
+	r.pos++
+	r.out.WriteByte('{')
+
+	count := 0
+	for {
+		r.skip()
+		if r.eof() {
+			break
+		}
+		c := r.in[r.pos]
+		if c == '}' {
+			r.pos++
+			break
+		}
+		if c == ',' || c == ']' {
+			r.pos++
+			continue
+		}
+
+		// Keys without a complete value are removed again
+		mark := r.out.Len()
+		if count > 0 {
+			r.out.WriteByte(',')
+		}
+		switch {
+		case c == '"' || c == '\'':
+			r.str(c, true)
+		case isWordChar(c) || c == '-':
+			r.key()
+		default:
+			r.out.Truncate(mark)
+			r.pos++
+			continue
+		}
+
+		r.skip()
+		if !r.eof() && (r.in[r.pos] == ':' || r.in[r.pos] == '=') {
+			r.pos++
+		}
+		r.out.WriteByte(':')
+		if !r.value(depth + 1) {
+			r.out.Truncate(mark)
+			if r.eof() {
+				break
+			}
+			r.pos++
+			continue
+		}
+		count++
+	}
+
+	r.out.WriteByte('}')
+}
+
+// array writes an array, tolerating missing and extra commas
+func (r *repairer) array(depth int) {
+	r.pos++
+	r.out.WriteByte('[')
+
+	count := 0
+	for {
+		r.skip()
+		if r.eof() {
+			break
+		}
+		c := r.in[r.pos]
+		if c == ']' {
+			r.pos++
+			break
+		}
+		if c == ',' || c == '}' || c == ':' {
+			r.pos++
+			continue
+		}
+
+		mark := r.out.Len()
+		if count > 0 {
+			r.out.WriteByte(',')
+		}
+		if !r.value(depth + 1) {
+			r.out.Truncate(mark)
+			if r.eof() {
+				break
+			}
+			r.pos++
+			continue
+		}
+		count++
+	}
+
+	r.out.WriteByte(']')
+}
+
+// str writes a string quoted with quote. A double quote inside a double quoted
+// string only ends it when followed by a delimiter, since models forget to
+// escape quotes in prose. Unterminated strings are closed
+func (r *repairer) str(quote byte, key bool) {
+	r.pos++
+	var b strings.Builder
+	for !r.eof() {
+		c := r.in[r.pos]
+		switch {
+		case c == '\\':
+			if r.pos+1 >= len(r.in) {
+				r.pos++
+				continue
+			}
+			next := r.in[r.pos+1]
+			r.pos += 2
+			switch next {
+			case '\'':
+				b.WriteByte('\'')
+			case 'u':
+				if r.pos+4 <= len(r.in) && isHex(r.in[r.pos:r.pos+4]) {
+					b.WriteString(`\u`)
+					b.Write(r.in[r.pos : r.pos+4])
+					r.pos += 4
+				}
+			case '"', '\\', '/', 'b', 'f', 'n', 'r', 't':
+				b.WriteByte('\\')
+				b.WriteByte(next)
+			default:
+				b.WriteByte(next)
+			}
+			continue
+		case c == quote && r.closes(key):
+			r.pos++
+			r.out.WriteString(quoteJSON(b.String()))
+			return
+		case c == '"':
+			b.WriteString(`\"`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c == '\t':
+			b.WriteString(`\t`)
+		case c < 0x20:
+			b.WriteString(`\u00`)
+			b.WriteString(strconv.FormatInt(int64(c)>>4, 16))
+			b.WriteString(strconv.FormatInt(int64(c)&0xf, 16))
+		default:
+			b.WriteByte(c)
+		}
+		r.pos++
+	}
+	r.out.WriteString(quoteJSON(b.String()))
+}
+
+// closes reports whether the quote at the current position ends the string,
+// which it does when followed by a delimiter, the start of another string or
+// the end of input
+func (r *repairer) closes(key bool) bool {
+	for i := r.pos + 1; i < len(r.in); i++ {
+		switch r.in[i] {
+		case ' ', '\t', '\n', '\r':
+			continue
+		case ':':
+			return key
+		case ',', '}', ']', '"':
+			return true
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// key writes an unquoted key as a string
+func (r *repairer) key() {
+	start := r.pos
+	for !r.eof() && (isWordChar(r.in[r.pos]) || r.in[r.pos] == '-') {
+		r.pos++
+	}
+	r.out.WriteString(quoteJSON(string(r.in[start:r.pos])))
+}
+
+// number writes a number, dropping a truncated exponent or fraction
+func (r *repairer) number() bool {
+	start := r.pos
+	for !r.eof() && strings.IndexByte("+-.0123456789eE", r.in[r.pos]) >= 0 {
+		r.pos++
+	}
+	text := strings.TrimRight(string(r.in[start:r.pos]), "+-.eE")
+	text = strings.TrimPrefix(text, "+")
+	if text == "" {
+		return false
+	}
+	if json.Valid([]byte(text)) {
+		r.out.WriteString(text)
+		return true
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return false
+	}
+	r.out.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	return true
+}
+
+// word writes a literal, converting Python literals and quoting other bare
+// words as strings. Truncated literals are completed
+func (r *repairer) word() {
+	start := r.pos
+	for !r.eof() && isWordChar(r.in[r.pos]) {
+		r.pos++
+	}
+	word := string(r.in[start:r.pos])
+
+	switch word {
+	case "true", "True":
+		r.out.WriteString("true")
+		return
+	case "false", "False":
+		r.out.WriteString("false")
+		return
+	case "null", "None", "undefined", "NaN":
+		r.out.WriteString("null")
+		return
+	}
+	if r.eof() {
+		for _, literal := range []string{"true", "false", "null"} {
+			if strings.HasPrefix(literal, word) {
+				r.out.WriteString(literal)
+				return
+			}
+		}
+	}
+
+	// Bare words run to the next delimiter, so unquoted text with spaces is
+	// kept together
+	for !r.eof() && strings.IndexByte(",:}]\n", r.in[r.pos]) < 0 {
+		r.pos++
+	}
+	r.out.WriteString(quoteJSON(strings.TrimSpace(string(r.in[start:r.pos]))))
+}
+
+// skip skips whitespace and comments
+func (r *repairer) skip() {
+	for !r.eof() {
+		switch c := r.in[r.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			r.pos++
+		case c == '/' && r.pos+1 < len(r.in) && r.in[r.pos+1] == '/':
+			for !r.eof() && r.in[r.pos] != '\n' {
+				r.pos++
+			}
+		case c == '/' && r.pos+1 < len(r.in) && r.in[r.pos+1] == '*':
+			end := bytes.Index(r.in[r.pos+2:], []byte("*/"))
+			if end < 0 {
+				r.pos = len(r.in)
+			} else {
+				r.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// eof reports whether the input is consumed
+func (r *repairer) eof() bool {
+	return r.pos >= len(r.in)
+}
+
+// quoteJSON quotes a string whose escapes are already valid JSON
+func quoteJSON(s string) string {
+	return `"` + s + `"`
+}
+
+// isWordChar reports whether c can be part of a bare word
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// isHex reports whether b is hexadecimal
+func isHex(b []byte) bool {
+	for _, c := range b {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(c)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package anthropic
+
+import (
+	"fmt"
+	"io"
+)
+
+// ResponseTooLargeError is returned when a response body exceeds the client's
+// maximum response size
+type ResponseTooLargeError struct {
+	Limit int
+}
+
+// Error implements the error interface
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response exceeds maximum size of %d bytes", e.Limit)
+}
+
+// WithMaxResponseSize sets the maximum size in bytes of a response body,
+// protecting the memory of servers from pathological responses. Larger
+// responses fail with a *ResponseTooLargeError, streamed responses are limited
+// by WithMaxStreamMessageSize instead
+func WithMaxResponseSize(size int) ClientOption {
+	return func(c *Client) {
+		c.MaxResponseSize = size
+	}
+}
+
+// WithMaxStreamMessageSize sets the maximum size in bytes of the text,
+// thinking and tool input accumulated from a stream. Larger messages fail the
+// stream with a *streaming.MessageTooLargeError
+func WithMaxStreamMessageSize(size int) ClientOption {
+	return func(c *Client) {
+		c.MaxStreamMessageSize = size
+	}
+}
+
+// readBody reads a response body up to the maximum response size
+func (c *Client) readBody(body io.Reader) ([]byte, error) {
+	if c.MaxResponseSize <= 0 {
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, int64(c.MaxResponseSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > c.MaxResponseSize {
+		return nil, &ResponseTooLargeError{Limit: c.MaxResponseSize}
+	}
+	return data, nil
+}
@@ -0,0 +1,248 @@
+// Package lint inspects message requests for common prompt and parameter
+// mistakes before they are sent. Findings carry stable rule IDs and JSON tags
+// so they can be collected by tooling
+package lint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Rule IDs of the findings
+const (
+	RuleSystemInUserTurn        = "system-in-user-turn"
+	RuleThinkingHeadroom        = "thinking-headroom"
+	RuleThinkingBudget          = "thinking-budget"
+	RuleTemperatureWithThinking = "temperature-with-thinking"
+	RuleTopKWithThinking        = "top-k-with-thinking"
+	RuleShortToolDescription    = "short-tool-description"
+)
+
+const (
+	// DefaultMinThinkingHeadroom is the default number of tokens max_tokens
+	// should leave for the answer after the thinking budget
+	DefaultMinThinkingHeadroom = 1024
+
+	// DefaultMinToolDescriptionWords is the default word count below which a
+	// tool description is reported as too short
+	DefaultMinToolDescriptionWords = 10
+
+	// minThinkingBudget is the smallest thinking budget the API accepts
+	minThinkingBudget = 1024
+)
+
+// Severity is the severity of a finding
+type Severity int
+
+const (
+	// Warning marks requests that work but likely perform worse than intended
+	Warning Severity = iota
+
+	// Error marks requests the API rejects
+	Error
+)
+
+// String returns the name of the severity
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// Finding is a problem found in a request
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+
+	// Path locates the problem in the request, such as "messages[0]" or
+	// "tools[2].description"
+	Path string `json:"path"`
+
+	Message string `json:"message"`
+}
+
+// String formats the finding for logs
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s] %s", f.Path, f.Severity, f.Rule, f.Message)
+}
+
+// HasErrors reports whether any finding is an error
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Linter checks requests
+type Linter struct {
+	// MinThinkingHeadroom is the number of tokens max_tokens should leave for
+	// the answer after the thinking budget, defaults to
+	// DefaultMinThinkingHeadroom
+	MinThinkingHeadroom int
+
+	// MinToolDescriptionWords is the word count below which a tool
+	// description is reported, defaults to DefaultMinToolDescriptionWords
+	MinToolDescriptionWords int
+
+	// Disabled lists rule IDs that are not checked
+	Disabled []string
+}
+
+// Lint checks a request with the default settings
+func Lint(req models.MessageRequest) []Finding {
+	return (&Linter{}).Lint(req)
+}
+
+// Lint checks a request and returns its findings
+func (l *Linter) Lint(req models.MessageRequest) []Finding {
+	var findings []Finding
+	add := func(rule string, severity Severity, path, message string) {
+		for _, disabled := range l.Disabled {
+			if disabled == rule {
+				return
+			}
+		}
+		findings = append(findings, Finding{Rule: rule, Severity: severity, Path: path, Message: message})
+	}
+
+	l.checkSystem(req, add)
+	l.checkThinking(req, add)
+	l.checkTools(req, add)
+	return findings
+}
+
+// systemMarkers start user turns that hold a system prompt
+var systemMarkers = []string{"system:", "<system>", "[system]", "### system", "# system", "you are a ", "you are an "}
+
+// checkSystem reports instructions sent as the first user turn instead of the
+// system prompt
+func (l *Linter) checkSystem(req models.MessageRequest, add func(rule string, severity Severity, path, message string)) {
+	for i, message := range req.Messages {
+		if message.Role != models.UserRole {
+			continue
+		}
+		for j, block := range message.Content {
+			if block.TextContent == nil {
+				continue
+			}
+			text := strings.ToLower(strings.TrimSpace(block.TextContent.Text))
+			for _, marker := range systemMarkers {
+				if strings.HasPrefix(text, marker) {
+					add(RuleSystemInUserTurn, Warning, fmt.Sprintf("messages[%d].content[%d]", i, j),
+						"user turn starts like a system prompt, move role and standing instructions to the system field")
+					return
+				}
+			}
+		}
+		// Only the opening user turn is checked, later turns quoting such
+		// text are usually intentional
+		return
+	}
+}
+
+// checkThinking reports parameters that conflict with extended thinking
+func (l *Linter) checkThinking(req models.MessageRequest, add func(rule string, severity Severity, path, message string)) {
+	if req.Thinking == nil || req.Thinking.Type != "enabled" {
+		return
+	}
+	budget := req.Thinking.BudgetTokens
+
+	if budget < minThinkingBudget {
+		add(RuleThinkingBudget, Error, "thinking.budget_tokens",
+			fmt.Sprintf("thinking budget %d is below the minimum of %d", budget, minThinkingBudget))
+	}
+
+	headroom := positive(l.MinThinkingHeadroom, DefaultMinThinkingHeadroom)
+	switch {
+	case req.MaxTokens <= budget:
+		add(RuleThinkingHeadroom, Error, "max_tokens",
+			fmt.Sprintf("max_tokens %d must be greater than the thinking budget %d", req.MaxTokens, budget))
+	case req.MaxTokens-budget < headroom:
+		add(RuleThinkingHeadroom, Warning, "max_tokens",
+			fmt.Sprintf("max_tokens %d leaves only %d tokens for the answer after the thinking budget, at least %d are recommended", req.MaxTokens, req.MaxTokens-budget, headroom))
+	}
+
+	if req.Temperature != nil && *req.Temperature != 1 {
+		add(RuleTemperatureWithThinking, Error, "temperature",
+			"temperature cannot be changed while thinking is enabled, remove it or set it to 1")
+	}
+	if req.TopK != nil {
+		add(RuleTopKWithThinking, Error, "top_k", "top_k cannot be set while thinking is enabled")
+	}
+}
+
+// checkTools reports tools whose descriptions are too short to explain when
+// and how to use them
+func (l *Linter) checkTools(req models.MessageRequest, add func(rule string, severity Severity, path, message string)) {
+	minWords := positive(l.MinToolDescriptionWords, DefaultMinToolDescriptionWords)
+	for i, tool := range req.Tools {
+		if _, ok := tool.ExtraFields["type"]; ok {
+			// Tools defined by Anthropic carry no description
+			continue
+		}
+		if words := len(strings.Fields(tool.Description)); words < minWords {
+			add(RuleShortToolDescription, Warning, fmt.Sprintf("tools[%d].description", i),
+				fmt.Sprintf("description of tool %q has %d words, describe what it does, when to use it and what it returns in at least %d", tool.Name, words, minWords))
+		}
+	}
+}
+
+// Middleware returns middleware linting every request and passing the
+// findings to report. Requests with errors are rejected when reject is set. A
+// nil linter uses the default settings
+func Middleware(l *Linter, reject bool, report func(ctx context.Context, findings []Finding)) anthropic.Middleware {
+	if l == nil {
+		l = &Linter{}
+	}
+	return func(ctx context.Context, req *models.MessageRequest) error {
+		findings := l.Lint(*req)
+		if len(findings) == 0 {
+			return nil
+		}
+		if report != nil {
+			report(ctx, findings)
+		}
+		if reject && HasErrors(findings) {
+			return &FindingsError{Findings: findings}
+		}
+		return nil
+	}
+}
+
+// FindingsError is returned by Middleware for rejected requests
+type FindingsError struct {
+	Findings []Finding
+}
+
+// Error implements the error interface
+func (e *FindingsError) Error() string {
+	var messages []string
+	for _, finding := range e.Findings {
+		if finding.Severity == Error {
+			messages = append(messages, finding.Message)
+		}
+	}
+	return "request failed lint: " + strings.Join(messages, "; ")
+}
+
+// positive returns value, or fallback when value is not positive
+func positive(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
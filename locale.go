@@ -0,0 +1,112 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// DefaultLocaleTemplate is the template used by LocaleMiddleware
+const DefaultLocaleTemplate = "The current date and time is {{.DateTime}} ({{.TimeZone}}, UTC{{.UTCOffset}}).{{if .Locale}} The user's locale is {{.Locale}}.{{end}}"
+
+// LocaleContext describes the user's locale and time zone
+type LocaleContext struct {
+	Locale   string
+	TimeZone *time.Location
+}
+
+// LocaleOptions configures LocaleMiddleware
+type LocaleOptions struct {
+	// Default is used when the request context carries no locale
+	Default LocaleContext
+
+	// Template renders the injected context, defaults to DefaultLocaleTemplate.
+	// It receives Date, Time, DateTime, Weekday, TimeZone, UTCOffset and Locale
+	Template string
+
+	// Now returns the current time, defaults to time.Now
+	Now func() time.Time
+}
+
+// localeContextKey is the context key for the user's locale
+type localeContextKey struct{}
+
+// ContextWithLocale returns a context carrying the user's locale and time zone
+func ContextWithLocale(ctx context.Context, locale LocaleContext) context.Context {
+	return context.WithValue(ctx, localeContextKey{}, locale)
+}
+
+// LocaleFromContext returns the locale stored in the context
+func LocaleFromContext(ctx context.Context) (LocaleContext, bool) {
+	locale, ok := ctx.Value(localeContextKey{}).(LocaleContext)
+	return locale, ok
+}
+
+// LocaleMiddleware returns middleware appending the current date, locale and
+// time zone to the system prompt of every request. The locale is taken from
+// the request context, falling back to the configured default
+func LocaleMiddleware(options LocaleOptions) (Middleware, error) {
+	text := options.Template
+	if text == "" {
+		text = DefaultLocaleTemplate
+	}
+	tmpl, err := template.New("locale").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing locale template: %w", err)
+	}
+
+	now := options.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	return func(ctx context.Context, req *models.MessageRequest) error {
+		locale, ok := LocaleFromContext(ctx)
+		if !ok {
+			locale = options.Default
+		}
+
+		location := locale.TimeZone
+		if location == nil {
+			location = time.Local
+		}
+		current := now().In(location)
+
+		var b strings.Builder
+		err := tmpl.Execute(&b, map[string]string{
+			"Date":      current.Format("2006-01-02"),
+			"Time":      current.Format("15:04"),
+			"DateTime":  current.Format("Monday, January 2, 2006 15:04"),
+			"Weekday":   current.Weekday().String(),
+			"TimeZone":  location.String(),
+			"UTCOffset": current.Format("-07:00"),
+			"Locale":    locale.Locale,
+		})
+		if err != nil {
+			return fmt.Errorf("error rendering locale context: %w", err)
+		}
+
+		appendSystem(req, b.String())
+		return nil
+	}, nil
+}
+
+// appendSystem appends text to the system prompt of a request. System blocks
+// are copied first, since the caller's slice may have spare capacity
+func appendSystem(req *models.MessageRequest, text string) {
+	if n := len(req.SystemBlocks); n > 0 {
+		blocks := make([]models.TextBlock, n, n+1)
+		copy(blocks, req.SystemBlocks)
+		req.SystemBlocks = append(blocks, models.TextBlock{Type: models.TextContentType, Text: text})
+		return
+	}
+
+	if req.System != "" {
+		req.System += "\n\n"
+	}
+	req.System += text
+}
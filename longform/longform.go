@@ -0,0 +1,299 @@
+// Package longform writes documents longer than a single response allows. The
+// model first plans an outline, then every section is written in a separate
+// request that sees the outline and the end of the previous section, and
+// sections cut off by max_tokens are continued before moving on
+package longform
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultSectionMaxTokens is the default response limit of a section
+	DefaultSectionMaxTokens = 4096
+
+	// DefaultOutlineMaxTokens is the default response limit of the outline
+	DefaultOutlineMaxTokens = 2048
+
+	// DefaultContextChars is the default number of characters of the previous
+	// section sent as running context
+	DefaultContextChars = 4000
+
+	// DefaultMaxContinuations is the default number of times a section cut off
+	// by max_tokens is continued
+	DefaultMaxContinuations = 2
+
+	// outlineTool is the tool the outline is answered with
+	outlineTool = "outline"
+)
+
+// Section is a planned section of the document
+type Section struct {
+	Title string `json:"title" description:"Section heading"`
+	Brief string `json:"brief" description:"What the section covers, in one or two sentences"`
+}
+
+// Outline is the plan of the document
+type Outline struct {
+	Title    string    `json:"title" description:"Document title"`
+	Sections []Section `json:"sections" description:"Sections in reading order"`
+}
+
+// Writer writes long documents section by section
+type Writer struct {
+	Client anthropic.ChatProvider
+	Model  string
+
+	// System is sent with every request, for example to set the tone
+	System string
+
+	// Sections is the number of sections to plan, the model decides when zero
+	Sections int
+
+	// OutlineMaxTokens and SectionMaxTokens limit the responses, defaulting to
+	// DefaultOutlineMaxTokens and DefaultSectionMaxTokens
+	OutlineMaxTokens int
+	SectionMaxTokens int
+
+	// ContextChars is the number of characters of the previous section sent
+	// with each request, defaults to DefaultContextChars
+	ContextChars int
+
+	// MaxContinuations is the number of times a section cut off by max_tokens
+	// is continued, defaults to DefaultMaxContinuations
+	MaxContinuations int
+
+	// Check optionally verifies that a section continues the document
+	// consistently. A section failing the check is rewritten once with the
+	// error as feedback
+	Check func(ctx context.Context, previous, section string) error
+}
+
+// Result is a written document
+type Result struct {
+	Outline  *Outline
+	Sections []string
+
+	// Requests is the number of requests made and Continuations the number of
+	// them that continued a section cut off by max_tokens
+	Requests      int
+	Continuations int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// Text returns the document as markdown, with the title and section headings
+func (r *Result) Text() string {
+	var b strings.Builder
+	if r.Outline.Title != "" {
+		fmt.Fprintf(&b, "# %s\n\n", r.Outline.Title)
+	}
+	for i, section := range r.Sections {
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", r.Outline.Sections[i].Title, section)
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+// New creates a writer with the default settings
+func New(client anthropic.ChatProvider, model string) *Writer {
+	return &Writer{Client: client, Model: model}
+}
+
+// Write plans an outline for the prompt and writes every section
+func (w *Writer) Write(ctx context.Context, prompt string) (*Result, error) {
+	result := &Result{}
+	outline, err := w.plan(ctx, prompt, result)
+	if err != nil {
+		return nil, err
+	}
+	return w.write(ctx, prompt, outline, result)
+}
+
+// Plan asks the model for an outline of the document
+func (w *Writer) Plan(ctx context.Context, prompt string) (*Outline, error) {
+	return w.plan(ctx, prompt, &Result{})
+}
+
+// WriteOutline writes every section of an existing outline
+func (w *Writer) WriteOutline(ctx context.Context, prompt string, outline *Outline) (*Result, error) {
+	return w.write(ctx, prompt, outline, &Result{})
+}
+
+// plan asks for the outline through a forced tool call
+func (w *Writer) plan(ctx context.Context, prompt string, result *Result) (*Outline, error) {
+	schema, err := models.SchemaFor(reflect.TypeOf(Outline{}))
+	if err != nil {
+		return nil, err
+	}
+
+	instruction := "Plan the document requested below as an outline by calling the outline tool."
+	if w.Sections > 0 {
+		instruction += fmt.Sprintf(" Use exactly %d sections.", w.Sections)
+	}
+
+	choice := models.SpecificToolChoice(outlineTool, true)
+	resp, err := w.Client.CreateMessage(ctx, models.MessageRequest{
+		Model:      w.Model,
+		System:     w.System,
+		MaxTokens:  positive(w.OutlineMaxTokens, DefaultOutlineMaxTokens),
+		Tools:      []models.Tool{models.NewTool(outlineTool, "Records the outline of the document", schema)},
+		ToolChoice: &choice,
+		Messages:   []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(instruction + "\n\n" + prompt))},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error planning outline: %w", err)
+	}
+	result.Requests++
+	result.Usage = result.Usage.Add(resp.Usage)
+
+	for _, block := range resp.Content {
+		if block.ToolUseContent == nil || block.ToolUseContent.Name != outlineTool {
+			continue
+		}
+		var outline Outline
+		if err := block.ToolUseContent.DecodeInput(&outline); err != nil {
+			return nil, fmt.Errorf("error decoding outline: %w", err)
+		}
+		if len(outline.Sections) == 0 {
+			return nil, fmt.Errorf("error planning outline: no sections")
+		}
+		return &outline, nil
+	}
+	return nil, fmt.Errorf("error planning outline: response contains no outline")
+}
+
+// write writes the sections of the outline in order
+func (w *Writer) write(ctx context.Context, prompt string, outline *Outline, result *Result) (*Result, error) {
+	result.Outline = outline
+	previous := ""
+	for i := range outline.Sections {
+		section, err := w.section(ctx, prompt, outline, i, previous, "", result)
+		if err != nil {
+			return nil, err
+		}
+
+		if w.Check != nil {
+			if checkErr := w.Check(ctx, previous, section); checkErr != nil {
+				section, err = w.section(ctx, prompt, outline, i, previous, checkErr.Error(), result)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		result.Sections = append(result.Sections, section)
+		previous = section
+	}
+	return result, nil
+}
+
+// section writes a single section, continuing it while it is cut off by
+// max_tokens
+func (w *Writer) section(ctx context.Context, prompt string, outline *Outline, index int, previous, feedback string, result *Result) (string, error) {
+	current := outline.Sections[index]
+	messages := []models.MessageParam{
+		models.NewUserMessage(models.CreateTextBlock(w.sectionPrompt(prompt, outline, index, previous, feedback))),
+	}
+
+	var text strings.Builder
+	for continuation := 0; ; continuation++ {
+		resp, err := w.Client.CreateMessage(ctx, models.MessageRequest{
+			Model:     w.Model,
+			System:    w.System,
+			MaxTokens: positive(w.SectionMaxTokens, DefaultSectionMaxTokens),
+			Messages:  messages,
+		})
+		if err != nil {
+			return "", fmt.Errorf("error writing section %q: %w", current.Title, err)
+		}
+		result.Requests++
+		result.Usage = result.Usage.Add(resp.Usage)
+		text.WriteString(resp.Text())
+
+		if resp.StopReason != models.MaxTokens || continuation >= positive(w.MaxContinuations, DefaultMaxContinuations) {
+			break
+		}
+
+		// Prefill the text so far so the model continues where it stopped.
+		// Prefills must not end with whitespace
+		result.Continuations++
+		partial := strings.TrimRight(text.String(), " \t\n")
+		text.Reset()
+		text.WriteString(partial)
+		messages = []models.MessageParam{
+			messages[0],
+			models.NewAssistantMessage(models.CreateTextBlock(partial)),
+		}
+	}
+
+	return stripHeading(strings.TrimSpace(text.String()), current.Title), nil
+}
+
+// sectionPrompt builds the prompt of a section with the outline and the end of
+// the previous section as running context
+func (w *Writer) sectionPrompt(prompt string, outline *Outline, index int, previous, feedback string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "You are writing a long document section by section.\n\n<request>\n%s\n</request>\n\n<outline>\n", prompt)
+	if outline.Title != "" {
+		fmt.Fprintf(&b, "Title: %s\n", outline.Title)
+	}
+	for i, section := range outline.Sections {
+		marker := " "
+		if i == index {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s %d. %s: %s\n", marker, i+1, section.Title, section.Brief)
+	}
+	b.WriteString("</outline>\n\n")
+
+	if previous != "" {
+		fmt.Fprintf(&b, "<previous_section_end>\n%s\n</previous_section_end>\n\n", tail(previous, positive(w.ContextChars, DefaultContextChars)))
+	}
+	if feedback != "" {
+		fmt.Fprintf(&b, "A previous attempt at this section was rejected: %s\n\n", feedback)
+	}
+
+	current := outline.Sections[index]
+	fmt.Fprintf(&b, "Write section %d, %q, covering: %s\n", index+1, current.Title, current.Brief)
+	b.WriteString("Continue naturally from the previous section without repeating it, do not write other sections and do not include the section heading.")
+	return b.String()
+}
+
+// stripHeading removes a leading heading repeating the section title
+func stripHeading(text, title string) string {
+	first, rest, _ := strings.Cut(text, "\n")
+	heading := strings.TrimSpace(strings.TrimLeft(first, "#"))
+	if strings.HasPrefix(first, "#") && strings.EqualFold(strings.Trim(heading, "*"), title) {
+		return strings.TrimSpace(rest)
+	}
+	return text
+}
+
+// tail returns the last n characters of text, starting at a line break when
+// possible
+func tail(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[len(runes)-n:])
+	if i := strings.IndexByte(cut, '\n'); i >= 0 && i < len(cut)/2 {
+		cut = cut[i+1:]
+	}
+	return cut
+}
+
+// positive returns value, or fallback when value is not positive
+func positive(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -3,9 +3,8 @@ package anthropic
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"strconv"
+	"runtime/trace"
 
 	"github.com/joakimcarlsson/anthropic-sdk/models"
 	"github.com/joakimcarlsson/anthropic-sdk/streaming"
@@ -15,86 +14,165 @@ import (
 const messagesPath = "v1/messages"
 
 // CreateMessage creates a new message
-func (c *Client) CreateMessage(ctx context.Context, req models.MessageRequest) (*models.Message, error) {
+func (c *Client) CreateMessage(ctx context.Context, req models.MessageRequest, options ...RequestOption) (*models.Message, error) {
+	if err := c.prepareRequest(ctx, &req); err != nil {
+		return nil, err
+	}
+	options, err := c.applyOutputLimit(ctx, &req, options)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.createMessage(ctx, req, options)
+	if err != nil || c.Guardrails == nil {
+		return resp, err
+	}
+
+	var checked *models.Message
+	err = c.guard().Call("guardrails", func() error {
+		var err error
+		checked, err = c.Guardrails.enforce(ctx, req, resp, func(ctx context.Context, req models.MessageRequest) (*models.Message, error) {
+			return c.createMessage(ctx, req, options)
+		})
+		return err
+	})
+	return checked, err
+}
+
+// createMessage sends a prepared message request
+func (c *Client) createMessage(ctx context.Context, req models.MessageRequest, options []RequestOption) (*models.Message, error) {
 	var resp models.Message
-	err := c.post(ctx, messagesPath, req, &resp)
+	err := c.post(ctx, messagesPath, req, &resp, options...)
 	if err != nil {
 		return nil, err
 	}
+
+	if c.StrictDecoding {
+		if err := resp.ValidateContentTypes(); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+	}
+
+	if c.CacheMetrics != nil {
+		c.CacheMetrics.Record(resp.Usage)
+	}
+
 	return &resp, nil
 }
 
 // CreateMessageStream creates a new message with streaming
-func (c *Client) CreateMessageStream(ctx context.Context, req models.MessageRequest) (*streaming.MessageStream, error) {
+func (c *Client) CreateMessageStream(ctx context.Context, req models.MessageRequest, options ...RequestOption) (*streaming.MessageStream, error) {
+	if err := c.prepareRequest(ctx, &req); err != nil {
+		return nil, err
+	}
 	// Ensure streaming is enabled
 	req.Stream = true
 
-	// Create custom request for streaming
-	url := c.BaseURL + "/" + messagesPath
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	options, err := c.applyOutputLimit(ctx, &req, options)
 	if err != nil {
 		return nil, err
 	}
 
-	// Add headers
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("X-Api-Key", c.APIKey)
-	httpReq.Header.Set("anthropic-version", c.Version)
-	httpReq.Header.Set("Accept", "text/event-stream")
-
-	// Add body
-	err = setJSONBody(httpReq, req)
+	body, err := c.marshal(req)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	// The trace task and profiler labels carry over to reading the stream
+	var task *trace.Task
+	if c.RuntimeTrace {
+		ctx, task = c.startTask(ctx, messagesPath, req.Model)
 	}
+	instrumentation := c.streamInstrumentation(ctx, req.Model, task)
+
+	var stream *streaming.MessageStream
+	err = c.withLabels(ctx, messagesPath, req.Model, func(ctx context.Context) error {
+		var err error
+		stream, err = c.openStream(ctx, body, options, instrumentation)
+		return err
+	})
+	if err != nil && task != nil {
+		task.End()
+	}
+	return stream, err
+}
 
-	// Make request
-	resp, err := c.HTTPClient.Do(httpReq)
+// openStream sends a prepared streaming message request
+func (c *Client) openStream(ctx context.Context, body []byte, options []RequestOption, streamOptions []streaming.StreamOption) (*streaming.MessageStream, error) {
+	cfg := newRequestConfig(ctx, options)
+	release, err := c.acquire(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("error making streaming request: %w", err)
+		return nil, err
 	}
 
-	// Check for error
-	if resp.StatusCode >= 400 {
-		defer resp.Body.Close()
-		respData, err := io.ReadAll(resp.Body)
+	exchange := newExchange(http.MethodPost, messagesPath, body)
+	exchange.Streaming = true
+	span := cfg.timeline.Begin(http.MethodPost+" /"+messagesPath, "http", nil)
+	endRegion := c.region(ctx, "send")
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		httpReq, err := c.newRequest(ctx, http.MethodPost, messagesPath, body, cfg)
 		if err != nil {
-			return nil, fmt.Errorf("error reading error response: %w (status code: %d)", err, resp.StatusCode)
-		}
-
-		apiErr := ParseAPIError(resp.StatusCode, respData)
-
-		// Extract request ID if present
-		if requestID := resp.Header.Get("x-request-id"); requestID != "" {
-			apiErr.RequestID = requestID
+			return nil, err
 		}
-
-		// Handle rate limit headers if present
-		if apiErr.IsRateLimitError() {
-			apiErr.RateLimitInfo = &RateLimitInfo{}
-			if retryAfter := resp.Header.Get("retry-after"); retryAfter != "" {
-				if seconds, err := strconv.Atoi(retryAfter); err == nil {
-					apiErr.RateLimitInfo.ResetAfter = seconds
-				}
-			}
-			apiErr.RateLimitInfo.LimitType = resp.Header.Get("x-ratelimit-limit-type")
-		}
-
-		return nil, apiErr
+		httpReq.Header.Set("Accept", "text/event-stream")
+		return httpReq, nil
+	})
+	endRegion()
+	if err != nil {
+		release()
+		exchange.Err = err
+		endRequestSpan(span, &exchange)
+		c.observe(exchange)
+		return nil, err
 	}
+	exchange.setResponse(resp)
+	endRequestSpan(span, &exchange)
+
+	// The request slot is held until the stream ends or is closed, and the
+	// exchange is observed with the digest of the streamed body then
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	resp.Body = c.observeStream(resp.Body, exchange)
 
 	// Create stream
-	return streaming.NewMessageStream(resp.Body), nil
+	if c.StrictDecoding {
+		streamOptions = append(streamOptions, streaming.WithStrictDecoding())
+	}
+	if c.PropagatePanics {
+		streamOptions = append(streamOptions, streaming.WithPropagatePanics())
+	}
+	if cfg.timeline != nil {
+		streamOptions = append(streamOptions, streaming.WithTimeline(cfg.timeline))
+	}
+	if c.MaxStreamMessageSize > 0 {
+		streamOptions = append(streamOptions, streaming.WithMaxMessageSize(c.MaxStreamMessageSize))
+	}
+	if c.MaxStreamEventSize > 0 {
+		streamOptions = append(streamOptions, streaming.WithMaxEventSize(c.MaxStreamEventSize))
+	}
+	if c.Guardrails != nil {
+		streamOptions = append(streamOptions, streaming.WithCompletionValidator(c.Guardrails.validateMessage))
+	}
+	if c.CacheMetrics != nil {
+		streamOptions = append(streamOptions, streaming.WithCompletionValidator(func(message *models.Message) error {
+			c.CacheMetrics.Record(message.Usage)
+			return nil
+		}))
+	}
+	return streaming.NewMessageStream(resp.Body, streamOptions...), nil
 }
 
 // CountTokens counts the tokens in a message
-func (c *Client) CountTokens(ctx context.Context, req models.MessageRequest) (int, error) {
+func (c *Client) CountTokens(ctx context.Context, req models.MessageRequest, options ...RequestOption) (int, error) {
 	type tokenCountResponse struct {
 		InputTokens int `json:"input_tokens"`
 	}
 
+	if err := c.prepareRequest(ctx, &req); err != nil {
+		return 0, err
+	}
+
 	var resp tokenCountResponse
-	err := c.post(ctx, "v1/messages/count_tokens", req, &resp)
+	err := c.post(ctx, "v1/messages/count_tokens", req, &resp, options...)
 	if err != nil {
 		return 0, err
 	}
@@ -0,0 +1,36 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Middleware inspects or modifies a message request before it is sent. An
+// error aborts the request
+type Middleware func(ctx context.Context, req *models.MessageRequest) error
+
+// WithMiddleware adds middleware applied to every message request, in order
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.Middleware = append(c.Middleware, middleware...)
+	}
+}
+
+// prepareRequest applies the client defaults and middleware to a request
+func (c *Client) prepareRequest(ctx context.Context, req *models.MessageRequest) error {
+	if req.Model == "" {
+		req.Model = c.DefaultModel
+	}
+
+	for _, middleware := range c.Middleware {
+		err := c.guard().Call("middleware", func() error {
+			return middleware(ctx, req)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/base64"
+)
+
+// DocumentSourceType defines the type of document source
+type DocumentSourceType string
+
+const (
+	// Base64DocumentSource is a base64-encoded PDF
+	Base64DocumentSource DocumentSourceType = "base64"
+
+	// TextDocumentSource is a plain text document
+	TextDocumentSource DocumentSourceType = "text"
+
+	// URLDocumentSource is a PDF fetched from a URL
+	URLDocumentSource DocumentSourceType = "url"
+)
+
+const (
+	// PDFMediaType represents PDF documents
+	PDFMediaType MediaType = "application/pdf"
+
+	// PlainTextMediaType represents plain text documents
+	PlainTextMediaType MediaType = "text/plain"
+)
+
+// DocumentSource represents the source of a document
+type DocumentSource struct {
+	Type      DocumentSourceType `json:"type"`
+	MediaType MediaType          `json:"media_type,omitempty"`
+	Data      string             `json:"data,omitempty"`
+	URL       string             `json:"url,omitempty"`
+}
+
+// DocumentBlock represents a document content block, such as a PDF
+type DocumentBlock struct {
+	Type         ContentType      `json:"type"`
+	Source       DocumentSource   `json:"source"`
+	Title        string           `json:"title,omitempty"`
+	Context      string           `json:"context,omitempty"`
+	Citations    *CitationsConfig `json:"citations,omitempty"`
+	CacheControl *CacheControl    `json:"cache_control,omitempty"`
+}
+
+// NewPDFSource creates a base64-encoded PDF document source
+func NewPDFSource(data []byte) DocumentSource {
+	return DocumentSource{
+		Type:      Base64DocumentSource,
+		MediaType: PDFMediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+// NewTextSource creates a plain text document source
+func NewTextSource(text string) DocumentSource {
+	return DocumentSource{
+		Type:      TextDocumentSource,
+		MediaType: PlainTextMediaType,
+		Data:      text,
+	}
+}
+
+// NewURLDocumentSource creates a source for a PDF fetched from a URL
+func NewURLDocumentSource(url string) DocumentSource {
+	return DocumentSource{
+		Type: URLDocumentSource,
+		URL:  url,
+	}
+}
+
+// CreateDocumentBlock creates a new document content block with an optional
+// title
+func CreateDocumentBlock(source DocumentSource, title string) ContentBlock {
+	return ContentBlock{
+		DocumentContent: &DocumentBlock{
+			Type:   DocumentContentType,
+			Source: source,
+			Title:  title,
+		},
+	}
+}
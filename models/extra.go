@@ -0,0 +1,70 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// mergeExtraFields merges the extra fields into a marshaled JSON object
+func mergeExtraFields(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error merging extra fields: %w", err)
+	}
+	if fields == nil {
+		fields = make(map[string]json.RawMessage, len(extra))
+	}
+
+	for key, value := range extra {
+		fields[key] = value
+	}
+
+	return json.Marshal(fields)
+}
+
+// collectExtraFields returns the fields of a JSON object that do not map to a
+// field of the given struct type
+func collectExtraFields(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, name := range jsonFieldNames(reflect.TypeOf(v)) {
+		delete(fields, name)
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// jsonFieldNames returns the JSON field names of a struct type
+func jsonFieldNames(t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		names = append(names, name)
+	}
+	return names
+}
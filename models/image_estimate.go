@@ -0,0 +1,118 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+)
+
+const (
+	// MaxImageDimension is the largest width or height accepted by the API
+	MaxImageDimension = 8000
+
+	// MaxImageDimensionManyImages is the largest width or height accepted when
+	// a request contains more than 20 images
+	MaxImageDimensionManyImages = 2000
+
+	// MaxImageBytes is the largest encoded image size accepted by the API
+	MaxImageBytes = 5 * 1024 * 1024
+
+	// MaxImageLongEdge is the longest edge an image can have before the API
+	// scales it down
+	MaxImageLongEdge = 1568
+
+	// MaxImagePixels is the number of pixels an image can have before the API
+	// scales it down
+	MaxImagePixels = 1_150_000
+
+	// MinImageEdge is the edge length below which image understanding degrades
+	MinImageEdge = 200
+
+	// imagePixelsPerToken is the number of pixels that cost one token
+	imagePixelsPerToken = 750
+)
+
+// ImageEstimate describes the approximate cost of sending an image
+type ImageEstimate struct {
+	Width        int
+	Height       int
+	ScaledWidth  int
+	ScaledHeight int
+	Tokens       int
+	Warnings     []string
+}
+
+// EstimateImageTokens returns the approximate number of input tokens an image
+// of the given dimensions costs, after the API scales it down if needed
+func EstimateImageTokens(width, height int) int {
+	return EstimateImage(width, height, 0).Tokens
+}
+
+// EstimateImage estimates the token cost of an image and reports warnings when
+// it exceeds the API limits. sizeBytes is the encoded size, or zero if unknown
+func EstimateImage(width, height, sizeBytes int) ImageEstimate {
+	estimate := ImageEstimate{
+		Width:        width,
+		Height:       height,
+		ScaledWidth:  width,
+		ScaledHeight: height,
+	}
+	if width <= 0 || height <= 0 {
+		estimate.Warnings = append(estimate.Warnings, "image has no dimensions")
+		return estimate
+	}
+
+	scale := 1.0
+	if longEdge := math.Max(float64(width), float64(height)); longEdge > MaxImageLongEdge {
+		scale = MaxImageLongEdge / longEdge
+	}
+	if pixels := float64(width) * float64(height); pixels > MaxImagePixels {
+		scale = math.Min(scale, math.Sqrt(MaxImagePixels/pixels))
+	}
+	if scale < 1 {
+		estimate.ScaledWidth = int(float64(width) * scale)
+		estimate.ScaledHeight = int(float64(height) * scale)
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"image will be scaled down from %dx%d to about %dx%d, resize it before upload to reduce latency",
+			width, height, estimate.ScaledWidth, estimate.ScaledHeight))
+	}
+
+	estimate.Tokens = int(math.Ceil(float64(estimate.ScaledWidth*estimate.ScaledHeight) / imagePixelsPerToken))
+
+	if width > MaxImageDimension || height > MaxImageDimension {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"image dimensions %dx%d exceed the maximum of %dx%d and will be rejected",
+			width, height, MaxImageDimension, MaxImageDimension))
+	} else if width > MaxImageDimensionManyImages || height > MaxImageDimensionManyImages {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"image dimensions %dx%d exceed %dx%d and will be rejected in requests with more than 20 images",
+			width, height, MaxImageDimensionManyImages, MaxImageDimensionManyImages))
+	}
+
+	if width < MinImageEdge || height < MinImageEdge {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"image edges smaller than %d pixels may degrade image understanding", MinImageEdge))
+	}
+
+	if sizeBytes > MaxImageBytes {
+		estimate.Warnings = append(estimate.Warnings, fmt.Sprintf(
+			"image size of %d bytes exceeds the maximum of %d bytes and will be rejected",
+			sizeBytes, MaxImageBytes))
+	}
+
+	return estimate
+}
+
+// EstimateImageData decodes the dimensions of an encoded JPEG, PNG or GIF image
+// and estimates its token cost
+func EstimateImageData(data []byte) (ImageEstimate, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ImageEstimate{}, fmt.Errorf("error decoding image: %w", err)
+	}
+	return EstimateImage(config.Width, config.Height, len(data)), nil
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/base64"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"strings"
 )
 
 // ImageSourceType defines the type of image source
@@ -71,15 +75,22 @@ func CreateImageBlock(source ImageSource) ContentBlock {
 	}
 }
 
-// Base64EncodeImage encodes an image file as base64
+// Base64EncodeImage encodes an image file as base64. Forward slashes and
+// backslashes are both accepted as separators on every platform
 func Base64EncodeImage(filePath string) (string, MediaType, error) {
-	file, err := os.Open(filePath)
+	file, err := os.Open(filepath.Clean(filepath.FromSlash(strings.ReplaceAll(filePath, "\\", "/"))))
 	if err != nil {
 		return "", "", fmt.Errorf("error opening file: %w", err)
 	}
 	defer file.Close()
 
-	data, err := io.ReadAll(file)
+	return Base64EncodeImageReader(file)
+}
+
+// Base64EncodeImageReader encodes an image read from r as base64, detecting
+// its media type from the content
+func Base64EncodeImageReader(r io.Reader) (string, MediaType, error) {
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return "", "", fmt.Errorf("error reading file: %w", err)
 	}
@@ -95,3 +106,74 @@ func Base64EncodeImage(filePath string) (string, MediaType, error) {
 
 	return encoded, MediaType(mediaType), nil
 }
+
+// Base64EncodeImageFS encodes an image of a file system, such as an embed.FS
+// of images bundled with the binary, as base64. The name is normalized to the
+// slash separated form fs.FS expects, so backslashes and leading slashes are
+// accepted
+func Base64EncodeImageFS(fsys fs.FS, name string) (string, MediaType, error) {
+	file, err := fsys.Open(FSPath(name))
+	if err != nil {
+		return "", "", fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	return Base64EncodeImageReader(file)
+}
+
+// NewImageSourceFromReader creates a base64 image source from an image read
+// from r
+func NewImageSourceFromReader(r io.Reader) (ImageSource, error) {
+	data, mediaType, err := Base64EncodeImageReader(r)
+	if err != nil {
+		return ImageSource{}, err
+	}
+	return NewBase64ImageSource(mediaType, data), nil
+}
+
+// NewImageSourceFromFS creates a base64 image source from an image of a file
+// system
+func NewImageSourceFromFS(fsys fs.FS, name string) (ImageSource, error) {
+	data, mediaType, err := Base64EncodeImageFS(fsys, name)
+	if err != nil {
+		return ImageSource{}, err
+	}
+	return NewBase64ImageSource(mediaType, data), nil
+}
+
+// FSPath normalizes a path for use with fs.FS: backslashes become slashes,
+// the path is cleaned and leading slashes and "./" are removed
+func FSPath(name string) string {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// ImageWithCaption is an image with an optional caption
+type ImageWithCaption struct {
+	Source  ImageSource
+	Caption string
+}
+
+// NewUserImageSet creates a user message for comparing multiple images. Each
+// image is introduced with a numbered label, followed by its caption, and the
+// prompt is placed after all images as recommended for multi-image prompts
+func NewUserImageSet(images []ImageWithCaption, prompt string) MessageParam {
+	content := make([]ContentBlock, 0, len(images)*3+1)
+	for i, img := range images {
+		content = append(content, CreateTextBlock(fmt.Sprintf("Image %d:", i+1)))
+		content = append(content, CreateImageBlock(img.Source))
+		if img.Caption != "" {
+			content = append(content, CreateTextBlock(img.Caption))
+		}
+	}
+
+	if prompt != "" {
+		content = append(content, CreateTextBlock(prompt))
+	}
+
+	return NewUserMessage(content...)
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// OutputLimit is the largest max_tokens a model accepts
+type OutputLimit struct {
+	// MaxTokens is the standard limit
+	MaxTokens int
+
+	// ExtendedMaxTokens is the limit with the ExtendedBeta beta enabled, zero
+	// if the model has no extended output
+	ExtendedMaxTokens int
+	ExtendedBeta      string
+}
+
+// Limit returns the limit with or without the extended output beta
+func (l OutputLimit) Limit(extended bool) int {
+	if extended && l.ExtendedMaxTokens > 0 {
+		return l.ExtendedMaxTokens
+	}
+	return l.MaxTokens
+}
+
+// outputLimits holds the limits of model families, most specific prefix first
+var outputLimits = []struct {
+	prefix string
+	limit  OutputLimit
+}{
+	{"claude-3-7-sonnet", OutputLimit{MaxTokens: 64000, ExtendedMaxTokens: 128000, ExtendedBeta: "output-128k-2025-02-19"}},
+	{Claude35SonnetV1, OutputLimit{MaxTokens: 4096, ExtendedMaxTokens: 8192, ExtendedBeta: "max-tokens-3-5-sonnet-2024-07-15"}},
+	{"claude-3-5-sonnet", OutputLimit{MaxTokens: 8192}},
+	{"claude-3-5-haiku", OutputLimit{MaxTokens: 8192}},
+	{"claude-3-opus", OutputLimit{MaxTokens: 4096}},
+	{"claude-3-sonnet", OutputLimit{MaxTokens: 4096}},
+	{"claude-3-haiku", OutputLimit{MaxTokens: 4096}},
+}
+
+// OutputLimitFor returns the output limit of a model, reporting false for
+// unknown models
+func OutputLimitFor(model string) (OutputLimit, bool) {
+	for _, entry := range outputLimits {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.limit, true
+		}
+	}
+	return OutputLimit{}, false
+}
+
+// MaxOutputTokens returns the standard largest max_tokens of a model, or zero
+// for unknown models
+func MaxOutputTokens(model string) int {
+	limit, _ := OutputLimitFor(model)
+	return limit.MaxTokens
+}
+
+// MaxNonStreamingTokens is the largest max_tokens validated for requests that
+// are not streamed. Generating more tokens can take longer than the ten
+// minutes a non-streaming request may stay open
+const MaxNonStreamingTokens = 21333
+
+// ErrStreamingRequired is returned by ValidateMaxTokens for non-streaming
+// requests above MaxNonStreamingTokens
+var ErrStreamingRequired = errors.New("requests with large max_tokens must be streamed")
+
+// MaxTokensError is returned for requests whose max_tokens exceeds the limit
+// of the model
+type MaxTokensError struct {
+	Model     string
+	MaxTokens int
+	Limit     int
+
+	// Beta is the beta that would raise the limit enough, if any
+	Beta string
+}
+
+// Error implements the error interface
+func (e *MaxTokensError) Error() string {
+	message := fmt.Sprintf("max_tokens %d exceeds the limit of %d for model %s", e.MaxTokens, e.Limit, e.Model)
+	if e.Beta != "" {
+		message += fmt.Sprintf(", enable the %s beta to raise it", e.Beta)
+	}
+	return message
+}
+
+// ValidateMaxTokens returns a *MaxTokensError if the request's max_tokens
+// exceeds the limit of a known model, given whether the model's extended
+// output beta is enabled, and ErrStreamingRequired if a request that is not
+// streamed asks for more than MaxNonStreamingTokens
+func ValidateMaxTokens(req *MessageRequest, extended bool) error {
+	if !req.Stream && req.MaxTokens > MaxNonStreamingTokens {
+		return fmt.Errorf("max_tokens %d: %w", req.MaxTokens, ErrStreamingRequired)
+	}
+
+	limit, ok := OutputLimitFor(req.Model)
+	if !ok || req.MaxTokens <= limit.Limit(extended) {
+		return nil
+	}
+
+	err := &MaxTokensError{Model: req.Model, MaxTokens: req.MaxTokens, Limit: limit.Limit(extended)}
+	if !extended && req.MaxTokens <= limit.ExtendedMaxTokens {
+		err.Beta = limit.ExtendedBeta
+	}
+	return err
+}
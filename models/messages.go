@@ -1,18 +1,43 @@
 package models
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 // Message represents a message in a conversation
 type Message struct {
 	ID           string         `json:"id"`
+	Type         string         `json:"type,omitempty"`
 	Role         Role           `json:"role"`
 	Content      []ContentBlock `json:"content"`
 	Model        string         `json:"model"`
 	StopReason   StopReason     `json:"stop_reason"`
 	StopSequence string         `json:"stop_sequence"`
 	Usage        Usage          `json:"usage"`
+
+	// RawExtra holds top-level response fields the SDK does not recognize
+	RawExtra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+
+	extra, err := collectExtraFields(data, msg)
+	if err != nil {
+		return err
+	}
+
+	*m = Message(msg)
+	m.RawExtra = extra
+	return nil
 }
 
 // MessageParam represents an input message
@@ -23,22 +48,199 @@ type MessageParam struct {
 
 // TextBlock represents a text content block
 type TextBlock struct {
-	Type ContentType `json:"type"`
-	Text string      `json:"text"`
+	Type         ContentType   `json:"type"`
+	Text         string        `json:"text"`
+	Citations    []Citation    `json:"citations,omitempty"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks a prompt caching breakpoint
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+// EphemeralCache creates an ephemeral cache control breakpoint
+func EphemeralCache() *CacheControl {
+	return &CacheControl{Type: "ephemeral"}
+}
+
+// MaxCacheBreakpoints is the number of prompt caching breakpoints allowed in a
+// request, counting those of tools, system blocks and messages
+const MaxCacheBreakpoints = 4
+
+// CacheBreakpoints returns the number of prompt caching breakpoints of a
+// request
+func CacheBreakpoints(req MessageRequest) int {
+	count := 0
+	for _, tool := range req.Tools {
+		if tool.CacheControl != nil {
+			count++
+		}
+	}
+	for _, block := range req.SystemBlocks {
+		if block.CacheControl != nil {
+			count++
+		}
+	}
+	for _, message := range req.Messages {
+		count += blockBreakpoints(message.Content)
+	}
+	return count
+}
+
+// blockBreakpoints returns the number of prompt caching breakpoints of content
+// blocks, including those nested in tool results
+func blockBreakpoints(blocks []ContentBlock) int {
+	count := 0
+	for _, block := range blocks {
+		var cacheControl *CacheControl
+		switch {
+		case block.TextContent != nil:
+			cacheControl = block.TextContent.CacheControl
+		case block.ImageContent != nil:
+			cacheControl = block.ImageContent.CacheControl
+		case block.ToolUseContent != nil:
+			cacheControl = block.ToolUseContent.CacheControl
+		case block.ToolResultContent != nil:
+			cacheControl = block.ToolResultContent.CacheControl
+			count += blockBreakpoints(block.ToolResultContent.Blocks)
+		case block.DocumentContent != nil:
+			cacheControl = block.DocumentContent.CacheControl
+		}
+		if cacheControl != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// CacheMessage returns a copy of the message with a prompt caching breakpoint
+// after its last block that accepts one, caching the conversation up to and
+// including the message. It reports false when no block accepts a breakpoint
+func CacheMessage(message MessageParam) (MessageParam, bool) {
+	for i := len(message.Content) - 1; i >= 0; i-- {
+		block, ok := cacheBlock(message.Content[i])
+		if !ok {
+			continue
+		}
+		content := append([]ContentBlock(nil), message.Content...)
+		content[i] = block
+		return MessageParam{Role: message.Role, Content: content}, true
+	}
+	return message, false
+}
+
+// cacheBlock returns a copy of the block with a prompt caching breakpoint.
+// Thinking and unknown blocks do not accept one
+func cacheBlock(block ContentBlock) (ContentBlock, bool) {
+	switch {
+	case block.TextContent != nil:
+		text := *block.TextContent
+		text.CacheControl = EphemeralCache()
+		block.TextContent = &text
+	case block.ImageContent != nil:
+		image := *block.ImageContent
+		image.CacheControl = EphemeralCache()
+		block.ImageContent = &image
+	case block.ToolUseContent != nil:
+		toolUse := *block.ToolUseContent
+		toolUse.CacheControl = EphemeralCache()
+		block.ToolUseContent = &toolUse
+	case block.ToolResultContent != nil:
+		result := *block.ToolResultContent
+		result.CacheControl = EphemeralCache()
+		block.ToolResultContent = &result
+	case block.DocumentContent != nil:
+		document := *block.DocumentContent
+		document.CacheControl = EphemeralCache()
+		block.DocumentContent = &document
+	default:
+		return block, false
+	}
+	return block, true
+}
+
+// Citation represents a citation supporting a text block in a response
+type Citation struct {
+	Type              string `json:"type"`
+	CitedText         string `json:"cited_text,omitempty"`
+	Source            string `json:"source,omitempty"`
+	Title             string `json:"title,omitempty"`
+	SearchResultIndex int    `json:"search_result_index,omitempty"`
+	StartBlockIndex   int    `json:"start_block_index,omitempty"`
+	EndBlockIndex     int    `json:"end_block_index,omitempty"`
+
+	// received holds the fields of a decoded citation, so fields the SDK does
+	// not know, such as those of other citation types, are sent back
+	received map[string]json.RawMessage
+}
+
+// MarshalJSON implements the json.Marshaler interface. Fields of a decoded
+// citation that the SDK does not know are merged in, and known fields that
+// were received keep their zero values instead of being omitted, since the
+// API requires them when the citation is sent back
+func (c Citation) MarshalJSON() ([]byte, error) {
+	type citation Citation
+	data, err := json.Marshal(citation(c))
+	if err != nil || len(c.received) == 0 {
+		return data, err
+	}
+
+	known := map[string]interface{}{
+		"type":                c.Type,
+		"cited_text":          c.CitedText,
+		"source":              c.Source,
+		"title":               c.Title,
+		"search_result_index": c.SearchResultIndex,
+		"start_block_index":   c.StartBlockIndex,
+		"end_block_index":     c.EndBlockIndex,
+	}
+	extra := make(map[string]json.RawMessage, len(c.received))
+	for key, value := range c.received {
+		field, ok := known[key]
+		if !ok {
+			extra[key] = value
+			continue
+		}
+		if extra[key], err = json.Marshal(field); err != nil {
+			return nil, err
+		}
+	}
+	return mergeExtraFields(data, extra)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (c *Citation) UnmarshalJSON(data []byte) error {
+	type citation Citation
+	var parsed citation
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	var received map[string]json.RawMessage
+	if err := json.Unmarshal(data, &received); err != nil {
+		return err
+	}
+
+	*c = Citation(parsed)
+	c.received = received
+	return nil
 }
 
 // ImageBlock represents an image content block
 type ImageBlock struct {
-	Type   ContentType `json:"type"`
-	Source ImageSource `json:"source"`
+	Type         ContentType   `json:"type"`
+	Source       ImageSource   `json:"source"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 // ToolUseBlock represents a tool use content block
 type ToolUseBlock struct {
-	Type  ContentType `json:"type"`
-	ID    string      `json:"id"`
-	Name  string      `json:"name"`
-	Input interface{} `json:"input"`
+	Type         ContentType   `json:"type"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Input        interface{}   `json:"input"`
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
 // ToolResultBlock represents a tool result content block
@@ -47,6 +249,59 @@ type ToolResultBlock struct {
 	ToolUseID string      `json:"tool_use_id"`
 	Content   string      `json:"content"`
 	IsError   bool        `json:"is_error,omitempty"`
+
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+
+	// Blocks is sent as the content instead of Content when set, for results
+	// made of text, image or search result blocks
+	Blocks []ContentBlock `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (b ToolResultBlock) MarshalJSON() ([]byte, error) {
+	type toolResultBlock ToolResultBlock
+	if len(b.Blocks) == 0 {
+		return json.Marshal(toolResultBlock(b))
+	}
+
+	return json.Marshal(struct {
+		toolResultBlock
+		Content []ContentBlock `json:"content"`
+	}{toolResultBlock(b), b.Blocks})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Array content is
+// decoded into Blocks, with the text of its text blocks joined into Content
+func (b *ToolResultBlock) UnmarshalJSON(data []byte) error {
+	type toolResultBlock ToolResultBlock
+	var block struct {
+		toolResultBlock
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &block); err != nil {
+		return err
+	}
+
+	*b = ToolResultBlock(block.toolResultBlock)
+	content := bytes.TrimSpace(block.Content)
+	if len(content) == 0 || content[0] != '[' {
+		if len(content) > 0 && string(content) != "null" {
+			return json.Unmarshal(content, &b.Content)
+		}
+		return nil
+	}
+
+	if err := json.Unmarshal(content, &b.Blocks); err != nil {
+		return err
+	}
+	var texts []string
+	for _, c := range b.Blocks {
+		if c.TextContent != nil {
+			texts = append(texts, c.TextContent.Text)
+		}
+	}
+	b.Content = strings.Join(texts, "\n")
+	return nil
 }
 
 // ThinkingBlock represents a thinking content block
@@ -62,6 +317,27 @@ type RedactedThinkingBlock struct {
 	Data string      `json:"data"`
 }
 
+// SearchResultBlock represents a search result content block, used to provide
+// results from custom retrieval pipelines that Claude can cite
+type SearchResultBlock struct {
+	Type      ContentType      `json:"type"`
+	Source    string           `json:"source"`
+	Title     string           `json:"title"`
+	Content   []TextBlock      `json:"content"`
+	Citations *CitationsConfig `json:"citations,omitempty"`
+}
+
+// CitationsConfig enables or disables citations for a content block
+type CitationsConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// UnknownBlock holds a content block of a type the SDK does not recognize
+type UnknownBlock struct {
+	Type ContentType     `json:"-"`
+	Raw  json.RawMessage `json:"-"`
+}
+
 // ContentBlock represents a block of content in a message
 type ContentBlock struct {
 	TextContent             *TextBlock             `json:"-"`
@@ -70,29 +346,46 @@ type ContentBlock struct {
 	ToolResultContent       *ToolResultBlock       `json:"-"`
 	ThinkingContent         *ThinkingBlock         `json:"-"`
 	RedactedThinkingContent *RedactedThinkingBlock `json:"-"`
+	SearchResultContent     *SearchResultBlock     `json:"-"`
+	DocumentContent         *DocumentBlock         `json:"-"`
+	UnknownContent          *UnknownBlock          `json:"-"`
+
+	// ExtraFields are merged into the marshaled block, allowing fields the
+	// SDK does not support yet to be sent to the API
+	ExtraFields map[string]json.RawMessage `json:"-"`
 }
 
 // MarshalJSON implements the json.Marshaler interface
 func (c ContentBlock) MarshalJSON() ([]byte, error) {
-	if c.TextContent != nil {
-		return json.Marshal(c.TextContent)
-	}
-	if c.ImageContent != nil {
-		return json.Marshal(c.ImageContent)
+	var block interface{}
+	switch {
+	case c.TextContent != nil:
+		block = c.TextContent
+	case c.ImageContent != nil:
+		block = c.ImageContent
+	case c.ToolUseContent != nil:
+		block = c.ToolUseContent
+	case c.ToolResultContent != nil:
+		block = c.ToolResultContent
+	case c.ThinkingContent != nil:
+		block = c.ThinkingContent
+	case c.RedactedThinkingContent != nil:
+		block = c.RedactedThinkingContent
+	case c.SearchResultContent != nil:
+		block = c.SearchResultContent
+	case c.DocumentContent != nil:
+		block = c.DocumentContent
+	case c.UnknownContent != nil:
+		block = c.UnknownContent.Raw
+	default:
+		return []byte("null"), nil
 	}
-	if c.ToolUseContent != nil {
-		return json.Marshal(c.ToolUseContent)
-	}
-	if c.ToolResultContent != nil {
-		return json.Marshal(c.ToolResultContent)
-	}
-	if c.ThinkingContent != nil {
-		return json.Marshal(c.ThinkingContent)
-	}
-	if c.RedactedThinkingContent != nil {
-		return json.Marshal(c.RedactedThinkingContent)
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return nil, err
 	}
-	return []byte("null"), nil
+	return mergeExtraFields(data, c.ExtraFields)
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface
@@ -104,6 +397,7 @@ func (c *ContentBlock) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	*c = ContentBlock{}
 	switch typeCheck.Type {
 	case TextContentType:
 		var textBlock TextBlock
@@ -141,11 +435,51 @@ func (c *ContentBlock) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		c.RedactedThinkingContent = &redactedThinkingBlock
+	case SearchResultContentType:
+		var searchResultBlock SearchResultBlock
+		if err := json.Unmarshal(data, &searchResultBlock); err != nil {
+			return err
+		}
+		c.SearchResultContent = &searchResultBlock
+	case DocumentContentType:
+		var documentBlock DocumentBlock
+		if err := json.Unmarshal(data, &documentBlock); err != nil {
+			return err
+		}
+		c.DocumentContent = &documentBlock
+	default:
+		c.UnknownContent = &UnknownBlock{
+			Type: typeCheck.Type,
+			Raw:  append(json.RawMessage(nil), data...),
+		}
 	}
 
 	return nil
 }
 
+// ValidateContentTypes returns an error if the message contains content blocks
+// of a type the SDK does not recognize
+func (m *Message) ValidateContentTypes() error {
+	for _, block := range m.Content {
+		if block.UnknownContent != nil {
+			return &UnknownTypeError{Kind: "content block", Type: string(block.UnknownContent.Type)}
+		}
+	}
+	return nil
+}
+
+// UnknownTypeError is returned in strict decoding mode when a response contains
+// a type the SDK does not recognize
+type UnknownTypeError struct {
+	Kind string
+	Type string
+}
+
+// Error implements the error interface
+func (e *UnknownTypeError) Error() string {
+	return fmt.Sprintf("unknown %s type: %q", e.Kind, e.Type)
+}
+
 // CreateTextBlock creates a new text content block
 func CreateTextBlock(text string) ContentBlock {
 	return ContentBlock{
@@ -156,6 +490,38 @@ func CreateTextBlock(text string) ContentBlock {
 	}
 }
 
+// CreateSearchResultBlock creates a new search result content block with one
+// text block per content passage
+func CreateSearchResultBlock(source, title string, content []string, citations bool) ContentBlock {
+	blocks := make([]TextBlock, 0, len(content))
+	for _, text := range content {
+		blocks = append(blocks, TextBlock{Type: TextContentType, Text: text})
+	}
+
+	return ContentBlock{
+		SearchResultContent: &SearchResultBlock{
+			Type:      SearchResultContentType,
+			Source:    source,
+			Title:     title,
+			Content:   blocks,
+			Citations: &CitationsConfig{Enabled: citations},
+		},
+	}
+}
+
+// CreateToolResultBlocks creates a tool result content block whose content is
+// made of blocks, such as search results
+func CreateToolResultBlocks(toolUseID string, blocks []ContentBlock, isError bool) ContentBlock {
+	return ContentBlock{
+		ToolResultContent: &ToolResultBlock{
+			Type:      ToolResultContentType,
+			ToolUseID: toolUseID,
+			IsError:   isError,
+			Blocks:    blocks,
+		},
+	}
+}
+
 // CreateToolResultBlock creates a new tool result content block
 func CreateToolResultBlock(toolUseID string, content string, isError bool) ContentBlock {
 	return ContentBlock{
@@ -173,6 +539,7 @@ type MessageRequest struct {
 	Model         string          `json:"model"`
 	Messages      []MessageParam  `json:"messages"`
 	System        string          `json:"system,omitempty"`
+	SystemBlocks  []TextBlock     `json:"-"`
 	MaxTokens     int             `json:"max_tokens"`
 	Temperature   *float64        `json:"temperature,omitempty"`
 	TopP          *float64        `json:"top_p,omitempty"`
@@ -182,6 +549,73 @@ type MessageRequest struct {
 	Tools         []Tool          `json:"tools,omitempty"`
 	ToolChoice    *ToolChoice     `json:"tool_choice,omitempty"`
 	Thinking      *ThinkingConfig `json:"thinking,omitempty"`
+
+	// ExtraFields are merged into the marshaled request, allowing new API
+	// parameters to be used before the SDK adds typed support for them
+	ExtraFields map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. SystemBlocks take
+// precedence over System and are sent as the system field
+func (r MessageRequest) MarshalJSON() ([]byte, error) {
+	type messageRequest MessageRequest
+	data, err := json.Marshal(messageRequest(r))
+	if err != nil {
+		return nil, err
+	}
+
+	extra := r.ExtraFields
+	if len(r.SystemBlocks) > 0 {
+		system, err := json.Marshal(r.SystemBlocks)
+		if err != nil {
+			return nil, err
+		}
+
+		extra = make(map[string]json.RawMessage, len(r.ExtraFields)+1)
+		extra["system"] = system
+		for key, value := range r.ExtraFields {
+			extra[key] = value
+		}
+	}
+
+	return mergeExtraFields(data, extra)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The system field is
+// decoded into System when it is a string and into SystemBlocks when it is an
+// array of blocks, so marshaled requests can be read back, for example from
+// saved request suites
+func (r *MessageRequest) UnmarshalJSON(data []byte) error {
+	type messageRequest MessageRequest
+	var parsed struct {
+		messageRequest
+		System json.RawMessage `json:"system,omitempty"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	extra, err := collectExtraFields(data, messageRequest{})
+	if err != nil {
+		return err
+	}
+
+	*r = MessageRequest(parsed.messageRequest)
+	r.System = ""
+	r.ExtraFields = extra
+
+	switch system := bytes.TrimSpace(parsed.System); {
+	case len(system) == 0 || bytes.Equal(system, []byte("null")):
+	case system[0] == '[':
+		if err := json.Unmarshal(system, &r.SystemBlocks); err != nil {
+			return fmt.Errorf("error decoding system blocks: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(system, &r.System); err != nil {
+			return fmt.Errorf("error decoding system prompt: %w", err)
+		}
+	}
+	return nil
 }
 
 // ThinkingConfig represents the configuration for extended thinking
@@ -202,6 +636,59 @@ func EnableThinking(budgetTokens int) *ThinkingConfig {
 type Usage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+
+	// CacheCreationInputTokens and CacheReadInputTokens are the input tokens
+	// written to and read from the prompt cache, in addition to InputTokens
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+
+	// RawExtra holds usage fields the SDK does not recognize
+	RawExtra map[string]json.RawMessage `json:"-"`
+}
+
+// Add returns the sum of two usages, for example to report the combined usage
+// of several requests
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:              u.InputTokens + other.InputTokens,
+		OutputTokens:             u.OutputTokens + other.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens + other.CacheReadInputTokens,
+	}
+}
+
+// TotalInputTokens returns the input tokens including those written to and
+// read from the prompt cache
+func (u Usage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// CacheHitRate returns the share of the input tokens read from the prompt
+// cache, between 0 and 1
+func (u Usage) CacheHitRate() float64 {
+	total := u.TotalInputTokens()
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheReadInputTokens) / float64(total)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (u *Usage) UnmarshalJSON(data []byte) error {
+	type usage Usage
+	var parsed usage
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	extra, err := collectExtraFields(data, parsed)
+	if err != nil {
+		return err
+	}
+
+	*u = Usage(parsed)
+	u.RawExtra = extra
+	return nil
 }
 
 // NewUserMessage creates a new user message
@@ -219,3 +706,23 @@ func NewAssistantMessage(content ...ContentBlock) MessageParam {
 		Content: content,
 	}
 }
+
+// ToParam converts a response message to an input message so it can be added
+// to the conversation history
+func (m *Message) ToParam() MessageParam {
+	return MessageParam{
+		Role:    m.Role,
+		Content: m.Content,
+	}
+}
+
+// Text returns the concatenated text of the message's text blocks
+func (m *Message) Text() string {
+	var b strings.Builder
+	for _, block := range m.Content {
+		if block.TextContent != nil {
+			b.WriteString(block.TextContent.Text)
+		}
+	}
+	return b.String()
+}
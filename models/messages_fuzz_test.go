@@ -0,0 +1,86 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func FuzzContentBlockUnmarshalJSON(f *testing.F) {
+	seeds := []string{
+		`{"type":"text","text":"Hello"}`,
+		`{"type":"text","text":"cited","citations":[{"type":"search_result_location","cited_text":"x","source":"s","search_result_index":0,"start_block_index":0,"end_block_index":1}]}`,
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}`,
+		`{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}`,
+		`{"type":"tool_result","tool_use_id":"toolu_1","content":"sunny"}`,
+		`{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"sunny"}],"is_error":true}`,
+		`{"type":"thinking","thinking":"hmm","signature":"sig"}`,
+		`{"type":"redacted_thinking","data":"abc"}`,
+		`{"type":"search_result","source":"s","title":"t","content":[{"type":"text","text":"x"}]}`,
+		`{"type":"document","source":{"type":"text","media_type":"text/plain","data":"doc"}}`,
+		`{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}`,
+
+		// Huge and negative indexes
+		`{"type":"text","text":"x","citations":[{"type":"search_result_location","search_result_index":-1,"start_block_index":9223372036854775807,"end_block_index":-9223372036854775808}]}`,
+		`{"type":"text","text":"x","citations":[{"type":"search_result_location","search_result_index":1e400}]}`,
+
+		// An error payload where a block is expected
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+
+		// Truncated blocks, as left by an unterminated final line
+		`{"type":"text","text":"Hel`,
+		`{"type":"tool_use","id":"toolu_1","input":`,
+
+		// An oversized block
+		`{"type":"text","text":"` + strings.Repeat("a", 1<<16) + `"}`,
+
+		// Mismatched field types
+		`{"type":"text","text":1}`,
+		`{"type":"tool_result","content":{}}`,
+		`{"type":1}`,
+		`{}`,
+		`null`,
+		`[]`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var block ContentBlock
+		if err := json.Unmarshal(data, &block); err != nil {
+			return
+		}
+
+		encoded, err := json.Marshal(block)
+		if err != nil {
+			t.Fatalf("error marshaling decoded block: %v", err)
+		}
+
+		// Decoding into a used block must not keep its previous content
+		reused := CreateTextBlock("previous")
+		if err := json.Unmarshal(data, &reused); err != nil {
+			t.Fatalf("error decoding into a used block: %v", err)
+		}
+		reencoded, err := json.Marshal(reused)
+		if err != nil {
+			t.Fatalf("error marshaling block decoded into a used block: %v", err)
+		}
+		if !bytes.Equal(encoded, reencoded) {
+			t.Fatalf("decoding into a used block gave %s, want %s", reencoded, encoded)
+		}
+
+		var roundTripped ContentBlock
+		if err := json.Unmarshal(encoded, &roundTripped); err != nil {
+			t.Fatalf("error decoding marshaled block %s: %v", encoded, err)
+		}
+		again, err := json.Marshal(roundTripped)
+		if err != nil {
+			t.Fatalf("error marshaling round-tripped block: %v", err)
+		}
+		if !bytes.Equal(encoded, again) {
+			t.Fatalf("block does not round-trip:\nfirst:  %s\nsecond: %s", encoded, again)
+		}
+	})
+}
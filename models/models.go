@@ -25,6 +25,8 @@ const (
 	ToolResultContentType       ContentType = "tool_result"
 	ThinkingContentType         ContentType = "thinking"
 	RedactedThinkingContentType ContentType = "redacted_thinking"
+	SearchResultContentType     ContentType = "search_result"
+	DocumentContentType         ContentType = "document"
 )
 
 // Role defines the role of a message participant
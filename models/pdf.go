@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// MaxImagesPerRequest is the largest number of images accepted in one request
+const MaxImagesPerRequest = 100
+
+// PageImage is a rendered page of a PDF document
+type PageImage struct {
+	Page      int
+	MediaType MediaType
+	Data      []byte
+}
+
+// PageRenderer renders the pages of a PDF document to images
+type PageRenderer interface {
+	RenderPages(ctx context.Context, pdf io.Reader) ([]PageImage, error)
+}
+
+// PageRendererFunc adapts a function to the PageRenderer interface
+type PageRendererFunc func(ctx context.Context, pdf io.Reader) ([]PageImage, error)
+
+// RenderPages implements the PageRenderer interface
+func (f PageRendererFunc) RenderPages(ctx context.Context, pdf io.Reader) ([]PageImage, error) {
+	return f(ctx, pdf)
+}
+
+// NewPDFPageImageSet renders a PDF with the given renderer and creates a user
+// message containing one captioned image per page followed by the prompt. It
+// is a fallback for documents too large to send as a document block
+func NewPDFPageImageSet(ctx context.Context, renderer PageRenderer, pdf io.Reader, prompt string) (MessageParam, error) {
+	pages, err := renderer.RenderPages(ctx, pdf)
+	if err != nil {
+		return MessageParam{}, fmt.Errorf("error rendering pdf pages: %w", err)
+	}
+	if len(pages) == 0 {
+		return MessageParam{}, fmt.Errorf("pdf has no pages")
+	}
+	if len(pages) > MaxImagesPerRequest {
+		return MessageParam{}, fmt.Errorf("pdf has %d pages, more than the %d images allowed per request", len(pages), MaxImagesPerRequest)
+	}
+
+	images := make([]ImageWithCaption, 0, len(pages))
+	for _, page := range pages {
+		images = append(images, ImageWithCaption{
+			Source:  NewBase64ImageSource(page.MediaType, base64.StdEncoding.EncodeToString(page.Data)),
+			Caption: fmt.Sprintf("Page %d", page.Page),
+		})
+	}
+
+	return NewUserImageSet(images, prompt), nil
+}
+
+// PDFToPPMRenderer renders PDF pages to PNG images with the pdftoppm command
+// from poppler-utils, which must be installed separately
+type PDFToPPMRenderer struct {
+	// Path is the path of the pdftoppm binary, defaults to looking it up in PATH
+	Path string
+
+	// DPI is the rendering resolution, defaults to 100
+	DPI int
+}
+
+// RenderPages implements the PageRenderer interface
+func (r PDFToPPMRenderer) RenderPages(ctx context.Context, pdf io.Reader) ([]PageImage, error) {
+	path := r.Path
+	if path == "" {
+		path = "pdftoppm"
+	}
+	dpi := r.DPI
+	if dpi <= 0 {
+		dpi = 100
+	}
+
+	dir, err := os.MkdirTemp("", "anthropic-pdf-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, path, "-png", "-r", strconv.Itoa(dpi), "-", filepath.Join(dir, "page"))
+	cmd.Stdin = pdf
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error running pdftoppm: %w: %s", err, output)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	pages := make([]PageImage, 0, len(files))
+	for i, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading rendered page: %w", err)
+		}
+		pages = append(pages, PageImage{Page: i + 1, MediaType: PNGMediaType, Data: data})
+	}
+
+	return pages, nil
+}
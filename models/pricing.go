@@ -0,0 +1,44 @@
+package models
+
+import "strings"
+
+// Pricing is the price of a model in US dollars per million tokens
+type Pricing struct {
+	Input      float64
+	Output     float64
+	CacheWrite float64
+	CacheRead  float64
+}
+
+// Cost returns the price in US dollars of the given usage
+func (p Pricing) Cost(usage Usage) float64 {
+	cost := float64(usage.InputTokens)*p.Input +
+		float64(usage.OutputTokens)*p.Output +
+		float64(usage.CacheCreationInputTokens)*p.CacheWrite +
+		float64(usage.CacheReadInputTokens)*p.CacheRead
+	return cost / 1_000_000
+}
+
+// pricing holds the list prices of model families, most specific prefix first
+var pricing = []struct {
+	prefix  string
+	pricing Pricing
+}{
+	{"claude-3-7-sonnet", Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}},
+	{"claude-3-5-sonnet", Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}},
+	{"claude-3-5-haiku", Pricing{Input: 0.8, Output: 4, CacheWrite: 1, CacheRead: 0.08}},
+	{"claude-3-opus", Pricing{Input: 15, Output: 75, CacheWrite: 18.75, CacheRead: 1.5}},
+	{"claude-3-sonnet", Pricing{Input: 3, Output: 15, CacheWrite: 3.75, CacheRead: 0.3}},
+	{"claude-3-haiku", Pricing{Input: 0.25, Output: 1.25, CacheWrite: 0.3, CacheRead: 0.03}},
+}
+
+// PricingFor returns the list price of a model, reporting false for unknown
+// models
+func PricingFor(model string) (Pricing, bool) {
+	for _, entry := range pricing {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.pricing, true
+		}
+	}
+	return Pricing{}, false
+}
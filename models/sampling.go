@@ -0,0 +1,73 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SamplingProfile is a preset of sampling parameters
+type SamplingProfile struct {
+	Name        string
+	Temperature *float64
+	TopP        *float64
+	TopK        *int
+}
+
+var (
+	// Deterministic always picks the most likely token, for extraction,
+	// classification and other tasks with one right answer
+	Deterministic = SamplingProfile{Name: "deterministic", Temperature: Float64(0)}
+
+	// Balanced suits most conversational and analytical tasks
+	Balanced = SamplingProfile{Name: "balanced", Temperature: Float64(0.7)}
+
+	// Creative gives the most varied output, for brainstorming and fiction
+	Creative = SamplingProfile{Name: "creative", Temperature: Float64(1)}
+)
+
+// ErrTemperatureAndTopP is returned when a request sets both temperature and
+// top_p. The API documentation advises altering only one of them, and newer
+// models reject requests that set both
+var ErrTemperatureAndTopP = errors.New("temperature and top_p should not both be set")
+
+// Float64 returns a pointer to v, for optional request parameters
+func Float64(v float64) *float64 {
+	return &v
+}
+
+// Apply sets the profile's sampling parameters on a request that does not set
+// any sampling parameter itself
+func (p SamplingProfile) Apply(req *MessageRequest) {
+	if req.Temperature != nil || req.TopP != nil || req.TopK != nil {
+		return
+	}
+	// Copy the values so requests do not share the profile's pointers
+	if p.Temperature != nil {
+		req.Temperature = Float64(*p.Temperature)
+	}
+	if p.TopP != nil {
+		req.TopP = Float64(*p.TopP)
+	}
+	if p.TopK != nil {
+		topK := *p.TopK
+		req.TopK = &topK
+	}
+}
+
+// ValidateSampling checks the sampling parameters of a request are within
+// range and that temperature and top_p are not both set
+func ValidateSampling(req *MessageRequest) error {
+	if req.Temperature != nil && (*req.Temperature < 0 || *req.Temperature > 1) {
+		return fmt.Errorf("temperature %g out of range [0, 1]", *req.Temperature)
+	}
+	if req.TopP != nil && (*req.TopP <= 0 || *req.TopP > 1) {
+		return fmt.Errorf("top_p %g out of range (0, 1]", *req.TopP)
+	}
+	if req.TopK != nil && *req.TopK <= 0 {
+		return fmt.Errorf("top_k %d must be positive", *req.TopK)
+	}
+	if req.Temperature != nil && req.TopP != nil {
+		return ErrTemperatureAndTopP
+	}
+	return nil
+}
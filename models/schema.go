@@ -0,0 +1,150 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaFor derives an input schema from a struct type. Fields are named after
+// their json tags, the description tag is used as the property description and
+// fields without omitempty that are not pointers are required
+func SchemaFor(t reflect.Type) (InputSchema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return InputSchema{}, fmt.Errorf("error deriving schema: %s is not a struct", t)
+	}
+
+	property, err := propertyFor(t, nil)
+	if err != nil {
+		return InputSchema{}, err
+	}
+	return SimpleJSONSchema(property.Properties, property.Required), nil
+}
+
+// timeType is the type of time.Time, which is encoded as a string
+var timeType = reflect.TypeOf(time.Time{})
+
+// propertyFor derives the schema property of a type. seen guards against
+// recursive types
+func propertyFor(t reflect.Type, seen []reflect.Type) (Property, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return Property{Type: "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return Property{Type: "string"}, nil
+	case reflect.Bool:
+		return Property{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Property{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return Property{Type: "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := propertyFor(t.Elem(), seen)
+		if err != nil {
+			return Property{}, err
+		}
+		return Property{Type: "array", Items: &items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return Property{}, fmt.Errorf("error deriving schema: map key of %s is not a string", t)
+		}
+		return Property{Type: "object"}, nil
+	case reflect.Interface:
+		return Property{}, fmt.Errorf("error deriving schema: interface type %s is not supported", t)
+	case reflect.Struct:
+		return structProperty(t, seen)
+	}
+
+	return Property{}, fmt.Errorf("error deriving schema: type %s is not supported", t)
+}
+
+// structProperty derives an object property from a struct type
+func structProperty(t reflect.Type, seen []reflect.Type) (Property, error) {
+	for _, s := range seen {
+		if s == t {
+			return Property{}, fmt.Errorf("error deriving schema: recursive type %s is not supported", t)
+		}
+	}
+	seen = append(seen, t)
+
+	property := Property{Type: "object", Properties: make(map[string]Property)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" && options == "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		fieldProperty, err := propertyFor(field.Type, seen)
+		if err != nil {
+			return Property{}, fmt.Errorf("error deriving schema for field %s: %w", field.Name, err)
+		}
+		fieldProperty.Description = field.Tag.Get("description")
+		property.Properties[name] = fieldProperty
+
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			property.Required = append(property.Required, name)
+		}
+	}
+	return property, nil
+}
+
+// MissingFields returns the paths of required fields absent from a decoded
+// JSON value, such as a tool input, according to the schema
+func (s InputSchema) MissingFields(value interface{}) []string {
+	missing := missingFields(Property{Type: s.Type, Properties: s.Properties, Required: s.Required}, value, "")
+	sort.Strings(missing)
+	return missing
+}
+
+// missingFields walks a decoded JSON value collecting missing required fields
+func missingFields(property Property, value interface{}, path string) []string {
+	var missing []string
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range property.Required {
+			if field, ok := v[name]; !ok || field == nil {
+				missing = append(missing, joinPath(path, name))
+			}
+		}
+		for name, fieldProperty := range property.Properties {
+			if field, ok := v[name]; ok {
+				missing = append(missing, missingFields(fieldProperty, field, joinPath(path, name))...)
+			}
+		}
+	case []interface{}:
+		if property.Items != nil {
+			for i, item := range v {
+				missing = append(missing, missingFields(*property.Items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return missing
+}
+
+// joinPath appends a field name to a dotted path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxStopSequences is the maximum number of stop sequences accepted by
+// ValidateStopSequences
+const MaxStopSequences = 16
+
+// StopSequenceError describes an invalid stop sequence
+type StopSequenceError struct {
+	Index    int
+	Sequence string
+	Reason   string
+}
+
+// Error implements the error interface
+func (e *StopSequenceError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("invalid stop sequences: %s", e.Reason)
+	}
+	return fmt.Sprintf("invalid stop sequence %d (%q): %s", e.Index, e.Sequence, e.Reason)
+}
+
+// ValidateStopSequences checks stop sequences for common mistakes: too many
+// sequences, empty or whitespace-only sequences, duplicates, and leading or
+// trailing spaces. Since the model usually emits a space before a word, a
+// sequence like " END" or "END " rarely matches where intended
+func ValidateStopSequences(sequences []string) error {
+	if len(sequences) > MaxStopSequences {
+		return &StopSequenceError{Index: -1, Reason: fmt.Sprintf("%d sequences exceed the maximum of %d", len(sequences), MaxStopSequences)}
+	}
+
+	seen := make(map[string]bool, len(sequences))
+	for i, sequence := range sequences {
+		switch {
+		case sequence == "":
+			return &StopSequenceError{Index: i, Sequence: sequence, Reason: "empty"}
+		case strings.TrimSpace(sequence) == "":
+			return &StopSequenceError{Index: i, Sequence: sequence, Reason: "whitespace only"}
+		case strings.TrimLeft(sequence, " \t") != sequence:
+			return &StopSequenceError{Index: i, Sequence: sequence, Reason: "leading space"}
+		case strings.TrimRight(sequence, " \t") != sequence:
+			return &StopSequenceError{Index: i, Sequence: sequence, Reason: "trailing space"}
+		case seen[sequence]:
+			return &StopSequenceError{Index: i, Sequence: sequence, Reason: "duplicate"}
+		}
+		seen[sequence] = true
+	}
+	return nil
+}
+
+// NewStopSequences returns the stop sequences after validating them
+func NewStopSequences(sequences ...string) ([]string, error) {
+	if err := ValidateStopSequences(sequences); err != nil {
+		return nil, err
+	}
+	return sequences, nil
+}
+
+// TextWithoutStopSequence returns the text of the message with the stop
+// sequence that ended it removed, in case it is included in the text
+func (m *Message) TextWithoutStopSequence() string {
+	text := m.Text()
+	if m.StopReason == StopSequence && m.StopSequence != "" {
+		text = strings.TrimSuffix(text, m.StopSequence)
+	}
+	return text
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ContextInjector returns dynamic context appended to a system prompt, such as
+// the current date. An empty string adds nothing
+type ContextInjector func() string
+
+// SystemPrompt composes a system prompt from a base persona, policy addenda and
+// dynamic context injectors. The persona and addenda form a stable prefix that
+// is marked for prompt caching, while injected context follows the breakpoint
+// so it can change without invalidating the cache
+type SystemPrompt struct {
+	Persona   string
+	Addenda   []string
+	Injectors []ContextInjector
+
+	// DisableCache omits the cache control breakpoint on the stable prefix
+	DisableCache bool
+}
+
+// NewSystemPrompt creates a system prompt with the given persona
+func NewSystemPrompt(persona string) *SystemPrompt {
+	return &SystemPrompt{Persona: persona}
+}
+
+// WithAddendum returns the prompt with a policy addendum appended
+func (p *SystemPrompt) WithAddendum(addendum string) *SystemPrompt {
+	p.Addenda = append(p.Addenda, addendum)
+	return p
+}
+
+// WithInjector returns the prompt with a context injector appended
+func (p *SystemPrompt) WithInjector(injector ContextInjector) *SystemPrompt {
+	p.Injectors = append(p.Injectors, injector)
+	return p
+}
+
+// Stable returns the stable part of the prompt
+func (p *SystemPrompt) Stable() string {
+	parts := make([]string, 0, len(p.Addenda)+1)
+	if p.Persona != "" {
+		parts = append(parts, p.Persona)
+	}
+	for _, addendum := range p.Addenda {
+		if addendum != "" {
+			parts = append(parts, addendum)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Dynamic returns the injected context
+func (p *SystemPrompt) Dynamic() string {
+	parts := make([]string, 0, len(p.Injectors))
+	for _, injector := range p.Injectors {
+		if text := injector(); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// Blocks renders the prompt as system blocks, with a cache control breakpoint
+// after the stable prefix
+func (p *SystemPrompt) Blocks() []TextBlock {
+	var blocks []TextBlock
+
+	if stable := p.Stable(); stable != "" {
+		block := TextBlock{Type: TextContentType, Text: stable}
+		if !p.DisableCache {
+			block.CacheControl = EphemeralCache()
+		}
+		blocks = append(blocks, block)
+	}
+
+	if dynamic := p.Dynamic(); dynamic != "" {
+		blocks = append(blocks, TextBlock{Type: TextContentType, Text: dynamic})
+	}
+
+	return blocks
+}
+
+// String renders the prompt as plain text
+func (p *SystemPrompt) String() string {
+	parts := make([]string, 0, 2)
+	if stable := p.Stable(); stable != "" {
+		parts = append(parts, stable)
+	}
+	if dynamic := p.Dynamic(); dynamic != "" {
+		parts = append(parts, dynamic)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// Apply sets the rendered prompt as the system blocks of a request
+func (p *SystemPrompt) Apply(req *MessageRequest) {
+	req.System = ""
+	req.SystemBlocks = p.Blocks()
+}
+
+// DateInjector returns an injector stating the current date in the given
+// location, or the local time zone if nil. The date only changes daily, which
+// keeps the injected context stable within a day
+func DateInjector(location *time.Location) ContextInjector {
+	return func() string {
+		now := time.Now()
+		if location != nil {
+			now = now.In(location)
+		}
+		return fmt.Sprintf("Today's date is %s.", now.Format("Monday, January 2, 2006"))
+	}
+}
+
+// StaticInjector returns an injector that always adds the given text
+func StaticInjector(text string) ContextInjector {
+	return func() string {
+		return text
+	}
+}
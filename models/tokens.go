@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"unicode/utf8"
+)
+
+// charsPerToken is the average number of characters per token for English
+// text, used by EstimateTokens
+const charsPerToken = 3.5
+
+// EstimateTokens returns a rough estimate of the number of tokens in text. Use
+// CountTokens on the client for exact counts
+func EstimateTokens(text string) int {
+	return int(math.Ceil(float64(utf8.RuneCountInString(text)) / charsPerToken))
+}
+
+// EstimateRequestTokens returns a rough estimate of the input tokens of a
+// request: its system prompt, tools and messages. Base64 images are estimated
+// from their dimensions, other blocks from their encoded size. Use CountTokens
+// on the client for exact counts
+func EstimateRequestTokens(req *MessageRequest) int {
+	tokens := EstimateTokens(req.System) + EstimateToolTokens(req.Tools)
+	for _, block := range req.SystemBlocks {
+		tokens += EstimateTokens(block.Text)
+	}
+	for _, message := range req.Messages {
+		for _, block := range message.Content {
+			tokens += estimateBlockTokens(block)
+		}
+	}
+	return tokens
+}
+
+// estimateBlockTokens returns a rough estimate of the tokens of a content block
+func estimateBlockTokens(block ContentBlock) int {
+	switch {
+	case block.TextContent != nil:
+		return EstimateTokens(block.TextContent.Text)
+	case block.ToolResultContent != nil && len(block.ToolResultContent.Blocks) == 0:
+		return EstimateTokens(block.ToolResultContent.Content)
+	case block.ToolResultContent != nil:
+		tokens := 0
+		for _, nested := range block.ToolResultContent.Blocks {
+			tokens += estimateBlockTokens(nested)
+		}
+		return tokens
+	case block.ImageContent != nil && block.ImageContent.Source.Type == Base64ImageSource:
+		data, err := base64.StdEncoding.DecodeString(block.ImageContent.Source.Data)
+		if err != nil {
+			break
+		}
+		if estimate, err := EstimateImageData(data); err == nil {
+			return estimate.Tokens
+		}
+	}
+
+	data, err := json.Marshal(block)
+	if err != nil {
+		return 0
+	}
+	return EstimateTokens(string(data))
+}
@@ -1,10 +1,30 @@
 package models
 
+import (
+	"encoding/json"
+)
+
 // Tool represents a tool that can be used by Claude
 type Tool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description,omitempty"`
 	InputSchema InputSchema `json:"input_schema"`
+
+	// CacheControl marks a prompt caching breakpoint after this tool
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+
+	// ExtraFields are merged into the marshaled tool definition
+	ExtraFields map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface
+func (t Tool) MarshalJSON() ([]byte, error) {
+	type tool Tool
+	data, err := json.Marshal(tool(t))
+	if err != nil {
+		return nil, err
+	}
+	return mergeExtraFields(data, t.ExtraFields)
 }
 
 // InputSchema represents the schema for a tool's input
@@ -19,6 +39,35 @@ type Property struct {
 	Type        string   `json:"type"`
 	Description string   `json:"description,omitempty"`
 	Enum        []string `json:"enum,omitempty"`
+
+	// Items describes the elements of array properties
+	Items *Property `json:"items,omitempty"`
+
+	// Properties and Required describe the fields of object properties
+	Properties map[string]Property `json:"properties,omitempty"`
+	Required   []string            `json:"required,omitempty"`
+}
+
+// CacheTools returns a copy of the tools with a prompt caching breakpoint after
+// the last one, so the tool definitions are cached together with the system
+// prompt preceding them
+func CacheTools(tools []Tool) []Tool {
+	if len(tools) == 0 {
+		return tools
+	}
+	cached := append([]Tool(nil), tools...)
+	cached[len(cached)-1].CacheControl = EphemeralCache()
+	return cached
+}
+
+// EstimateToolTokens returns a rough estimate of the number of tokens the tool
+// definitions add to a request
+func EstimateToolTokens(tools []Tool) int {
+	data, err := json.Marshal(tools)
+	if err != nil {
+		return 0
+	}
+	return EstimateTokens(string(data))
 }
 
 // ToolChoice represents how tools should be used by Claude
@@ -97,3 +146,12 @@ func CreateToolUseBlock(id string, name string, input interface{}) ContentBlock
 		},
 	}
 }
+
+// DecodeInput decodes the tool input into v
+func (b *ToolUseBlock) DecodeInput(v interface{}) error {
+	data, err := json.Marshal(b.Input)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
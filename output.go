@@ -0,0 +1,78 @@
+package anthropic
+
+import (
+	"context"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// WithModelMaxTokens sets max_tokens of requests that leave it unset to the
+// largest value the model accepts, capped at models.MaxNonStreamingTokens for
+// requests that are not streamed. Requests for unknown models are unchanged
+func WithModelMaxTokens() ClientOption {
+	return func(c *Client) {
+		c.ModelMaxTokens = true
+	}
+}
+
+// WithMaxTokensValidation rejects requests whose max_tokens exceeds the limit
+// of a known model with a *models.MaxTokensError before they are sent
+func WithMaxTokensValidation() ClientOption {
+	return func(c *Client) {
+		c.ValidateMaxTokens = true
+	}
+}
+
+// WithExtendedOutput enables the extended output beta of a model whenever a
+// request needs it, and makes WithModelMaxTokens use the extended limit
+func WithExtendedOutput() ClientOption {
+	return func(c *Client) {
+		c.ExtendedOutput = true
+	}
+}
+
+// applyOutputLimit fills in and validates max_tokens according to the client
+// settings, returning the request options with the extended output beta added
+// when it is needed
+func (c *Client) applyOutputLimit(ctx context.Context, req *models.MessageRequest, options []RequestOption) ([]RequestOption, error) {
+	if !c.ModelMaxTokens && !c.ValidateMaxTokens && !c.ExtendedOutput {
+		return options, nil
+	}
+	limit, ok := models.OutputLimitFor(req.Model)
+	if !ok {
+		return options, nil
+	}
+
+	extended := limit.ExtendedBeta != "" && c.betaEnabled(ctx, limit.ExtendedBeta, options)
+	if c.ModelMaxTokens && req.MaxTokens == 0 {
+		req.MaxTokens = limit.Limit(extended || c.ExtendedOutput)
+		if !req.Stream {
+			req.MaxTokens = min(req.MaxTokens, models.MaxNonStreamingTokens)
+		}
+	}
+
+	if !extended && c.ExtendedOutput && limit.ExtendedBeta != "" && req.MaxTokens > limit.MaxTokens {
+		options = append(append([]RequestOption(nil), options...), WithRequestBeta(limit.ExtendedBeta))
+		extended = true
+	}
+
+	if c.ValidateMaxTokens {
+		if err := models.ValidateMaxTokens(req, extended); err != nil {
+			return nil, err
+		}
+	}
+	return options, nil
+}
+
+// betaEnabled reports whether a beta is enabled on the client or by the
+// request options
+func (c *Client) betaEnabled(ctx context.Context, beta string, options []RequestOption) bool {
+	values := append(c.Headers.Values(BetaHeader), newRequestConfig(ctx, options).headers.Values(BetaHeader)...)
+	for _, feature := range strings.Split(joinBetas(values), ",") {
+		if feature == beta {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package anthropic
+
+import "github.com/joakimcarlsson/anthropic-sdk/internal/safe"
+
+// PanicError is returned when a callback, such as middleware, a signer, an
+// observer or a tool handler, panics. It carries the panic value and the stack
+// trace of the panicking goroutine
+type PanicError = safe.PanicError
+
+// WithPropagatePanics lets panics of callbacks propagate instead of returning
+// them as a *PanicError, which is useful while debugging
+func WithPropagatePanics() ClientOption {
+	return func(c *Client) {
+		c.PropagatePanics = true
+	}
+}
+
+// guard returns the guard running the client's callbacks
+func (c *Client) guard() safe.Guard {
+	return safe.Guard{Propagate: c.PropagatePanics}
+}
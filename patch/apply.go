@@ -0,0 +1,180 @@
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ConflictError is returned when a hunk does not match the file
+type ConflictError struct {
+	Path string
+
+	// Hunk is the 1-based index of the hunk within its file diff
+	Hunk int
+
+	Reason string
+}
+
+// Error implements the error interface
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict in %s hunk %d: %s", e.Path, e.Hunk, e.Reason)
+}
+
+// Apply applies the diff of a single file to its content. Each hunk is placed
+// where its context and removed lines appear in the file, nearest to its
+// stated line number, ignoring trailing whitespace when there is no exact
+// match. A *ConflictError is returned when a hunk cannot be placed. Whether
+// the content ends with a newline is preserved
+func Apply(content string, diff FileDiff) (string, error) {
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	lines := splitLines(content)
+
+	// delta is the number of lines added so far, shifting the stated line
+	// numbers of later hunks, and min keeps hunks from overlapping
+	delta, min := 0, 0
+	for i, hunk := range diff.Hunks {
+		old, replacement := hunk.Old(), hunk.New()
+
+		expected := min
+		if hunk.OldStart > 0 {
+			expected = hunk.OldStart - 1 + delta
+		}
+
+		pos := find(lines, old, min, expected, equal)
+		if pos < 0 {
+			pos = find(lines, old, min, expected, equalTrimmed)
+		}
+		if pos < 0 {
+			return "", &ConflictError{Path: diff.Path(), Hunk: i + 1, Reason: fmt.Sprintf("expected lines not found: %q", firstLine(old))}
+		}
+
+		lines = append(lines[:pos], append(replacement, lines[pos+len(old):]...)...)
+		delta += len(replacement) - len(old)
+		min = pos + len(replacement)
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// ApplyAll applies file diffs to a set of files keyed by path and returns the
+// updated set, leaving files unchanged. Created files are added and deleted
+// files removed. All conflicts are reported together, joined into one error
+func ApplyAll(files map[string]string, diffs []FileDiff) (map[string]string, error) {
+	result := make(map[string]string, len(files))
+	for path, content := range files {
+		result[path] = content
+	}
+
+	var errs []error
+	for _, diff := range diffs {
+		path := diff.Path()
+		content, exists := result[path]
+		switch {
+		case diff.IsNew() && exists:
+			errs = append(errs, &ConflictError{Path: path, Hunk: 1, Reason: "file already exists"})
+			continue
+		case !diff.IsNew() && !exists:
+			errs = append(errs, &ConflictError{Path: path, Hunk: 1, Reason: "file does not exist"})
+			continue
+		}
+
+		updated, err := Apply(content, diff)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		if diff.IsDeleted() {
+			delete(result, path)
+			continue
+		}
+		if diff.OldPath != diff.NewPath && !diff.IsNew() {
+			delete(result, diff.OldPath)
+		}
+		result[path] = updated
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// find returns the position from min on where want appears in lines, nearest
+// to expected, or -1 when it does not appear
+func find(lines, want []string, min, expected int, eq func(a, b string) bool) int {
+	if len(want) == 0 {
+		if expected < min {
+			return min
+		}
+		if expected > len(lines) {
+			return len(lines)
+		}
+		return expected
+	}
+
+	best := -1
+	for pos := min; pos+len(want) <= len(lines); pos++ {
+		if !matches(lines[pos:pos+len(want)], want, eq) {
+			continue
+		}
+		if best < 0 || abs(pos-expected) < abs(best-expected) {
+			best = pos
+		}
+	}
+	return best
+}
+
+// matches reports whether the lines equal want
+func matches(lines, want []string, eq func(a, b string) bool) bool {
+	for i := range want {
+		if !eq(lines[i], want[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equal compares lines exactly
+func equal(a, b string) bool {
+	return a == b
+}
+
+// equalTrimmed compares lines ignoring trailing whitespace
+func equalTrimmed(a, b string) bool {
+	return strings.TrimRight(a, " \t\r") == strings.TrimRight(b, " \t\r")
+}
+
+// splitLines splits content into lines without the final newline
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// firstLine returns the first non-empty line for conflict messages
+func firstLine(lines []string) string {
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,179 @@
+package patch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/codegen"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultMaxTokens is the default response limit
+	DefaultMaxTokens = 4096
+
+	// DefaultMaxRetries is the default number of times the model is asked for
+	// a new diff when its diff does not parse or apply
+	DefaultMaxRetries = 1
+)
+
+// Editor asks the model for unified diffs against files and applies them
+type Editor struct {
+	Client anthropic.ChatProvider
+	Model  string
+
+	// System is sent with every request
+	System string
+
+	// MaxTokens limits every response, defaults to DefaultMaxTokens
+	MaxTokens int
+
+	// MaxRetries is the number of times the model is asked for a new diff when
+	// its diff does not parse or apply, defaults to DefaultMaxRetries
+	MaxRetries int
+}
+
+// EditResult is the outcome of an edit
+type EditResult struct {
+	// Files is the full set of files after the edit
+	Files map[string]string
+
+	// Changed lists the paths created, modified or deleted, sorted
+	Changed []string
+
+	Diffs []FileDiff
+
+	// Message is the final response
+	Message *models.Message
+
+	// Attempts is the number of requests made
+	Attempts int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// New creates an editor
+func New(client anthropic.ChatProvider, model string) *Editor {
+	return &Editor{Client: client, Model: model}
+}
+
+// Edit asks the model to change the files, keyed by path, as instructed and
+// applies the unified diff it answers with. A diff that does not parse or
+// conflicts with the files is answered with the problem and a new diff
+// requested. When the retries are used up the last error is returned, wrapping
+// the *ConflictError values. The files passed in are not modified
+func (e *Editor) Edit(ctx context.Context, instruction string, files map[string]string) (*EditResult, error) {
+	messages := []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(editPrompt(instruction, files)))}
+
+	result := &EditResult{}
+	for {
+		resp, err := e.Client.CreateMessage(ctx, models.MessageRequest{
+			Model:     e.Model,
+			System:    e.System,
+			MaxTokens: e.maxTokens(),
+			Messages:  messages,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error requesting diff: %w", err)
+		}
+		result.Attempts++
+		result.Usage = result.Usage.Add(resp.Usage)
+		result.Message = resp
+
+		diffs, updated, applyErr := apply(resp.Text(), files)
+		if applyErr == nil {
+			result.Diffs = diffs
+			result.Files = updated
+			result.Changed = changed(diffs)
+			return result, nil
+		}
+		if result.Attempts > e.maxRetries() {
+			return nil, fmt.Errorf("error applying diff: %w", applyErr)
+		}
+
+		feedback := fmt.Sprintf("Your diff could not be applied:\n\n<error>\n%s\n</error>\n\nAnswer with a corrected unified diff against the original files, copying context lines exactly.", applyErr)
+		messages = append(messages, resp.ToParam(), models.NewUserMessage(models.CreateTextBlock(feedback)))
+	}
+}
+
+// apply extracts the diff of a response and applies it
+func apply(text string, files map[string]string) ([]FileDiff, map[string]string, error) {
+	source := text
+	if blocks := codegen.BlocksFor(text, "diff"); len(blocks) > 0 {
+		code := make([]string, len(blocks))
+		for i, block := range blocks {
+			code[i] = block.Code
+		}
+		source = strings.Join(code, "\n")
+	}
+
+	diffs, err := Parse(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	updated, err := ApplyAll(files, diffs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return diffs, updated, nil
+}
+
+// editPrompt lists the files and asks for a unified diff
+func editPrompt(instruction string, files map[string]string) string {
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&b, "<file path=%q>\n%s\n</file>\n\n", path, strings.TrimSuffix(files[path], "\n"))
+	}
+	fmt.Fprintf(&b, "<instruction>\n%s\n</instruction>\n\n", instruction)
+	b.WriteString("Answer with a unified diff in a fenced diff code block. Start every file with --- a/path and +++ b/path headers, using /dev/null for created and deleted files, and give every hunk an @@ header with three lines of unchanged context copied exactly from the file.")
+	return b.String()
+}
+
+// changed returns the sorted paths touched by the diffs
+func changed(diffs []FileDiff) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, diff := range diffs {
+		for _, path := range []string{diff.OldPath, diff.NewPath} {
+			if path != DevNull && !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// maxTokens returns the response limit
+func (e *Editor) maxTokens() int {
+	if e.MaxTokens > 0 {
+		return e.MaxTokens
+	}
+	return DefaultMaxTokens
+}
+
+// maxRetries returns the number of retries allowed
+func (e *Editor) maxRetries() int {
+	if e.MaxRetries > 0 {
+		return e.MaxRetries
+	}
+	return DefaultMaxRetries
+}
+
+// IsConflict reports whether err contains a *ConflictError
+func IsConflict(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict)
+}
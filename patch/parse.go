@@ -0,0 +1,185 @@
+// Package patch edits files through unified diffs written by the model. Diffs
+// are parsed leniently, since models often get hunk line numbers and counts
+// wrong, and applied by locating each hunk's context in the file, reporting a
+// conflict when it cannot be found
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DevNull is the path of the missing side of created and deleted files
+const DevNull = "/dev/null"
+
+// ErrNoDiff is returned when text contains no file diff
+var ErrNoDiff = errors.New("no diff found")
+
+// FileDiff is the diff of a single file
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Path returns the path of the file, which is the new path unless the file is
+// deleted
+func (d FileDiff) Path() string {
+	if d.NewPath == DevNull {
+		return d.OldPath
+	}
+	return d.NewPath
+}
+
+// IsNew reports whether the diff creates the file
+func (d FileDiff) IsNew() bool {
+	return d.OldPath == DevNull
+}
+
+// IsDeleted reports whether the diff deletes the file
+func (d FileDiff) IsDeleted() bool {
+	return d.NewPath == DevNull
+}
+
+// Hunk is a changed region of a file
+type Hunk struct {
+	// OldStart is the 1-based line the hunk starts at in the original file,
+	// zero when the header has no line numbers
+	OldStart int
+	NewStart int
+
+	Lines []Line
+}
+
+// Line is a line of a hunk
+type Line struct {
+	// Op is ' ' for context, '-' for removed and '+' for added lines
+	Op   byte
+	Text string
+}
+
+// Old returns the lines the hunk expects in the original file
+func (h Hunk) Old() []string {
+	return h.side('-')
+}
+
+// New returns the lines replacing Old
+func (h Hunk) New() []string {
+	return h.side('+')
+}
+
+// side returns the context lines together with the lines of op
+func (h Hunk) side(op byte) []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line.Op == ' ' || line.Op == op {
+			lines = append(lines, line.Text)
+		}
+	}
+	return lines
+}
+
+// hunkHeader matches hunk headers, whose line numbers are optional
+var hunkHeader = regexp.MustCompile(`^@@(?: -(\d+)(?:,\d+)? \+(\d+)(?:,\d+)?)? @@`)
+
+// Parse parses the file diffs of a unified diff. Text before the first file
+// header, such as git's diff and index lines, is ignored, and hunks end at the
+// next header rather than after their line counts
+func Parse(text string) ([]FileDiff, error) {
+	var diffs []FileDiff
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			diffs = append(diffs, FileDiff{
+				OldPath: headerPath(strings.TrimPrefix(line, "--- ")),
+				NewPath: headerPath(strings.TrimPrefix(lines[i+1], "+++ ")),
+			})
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if len(diffs) == 0 {
+				return nil, fmt.Errorf("error parsing diff: hunk before file header on line %d", i+1)
+			}
+			match := hunkHeader.FindStringSubmatch(line)
+			if match == nil {
+				return nil, fmt.Errorf("error parsing diff: invalid hunk header on line %d: %q", i+1, line)
+			}
+			hunk := Hunk{}
+			hunk.OldStart, _ = strconv.Atoi(match[1])
+			hunk.NewStart, _ = strconv.Atoi(match[2])
+
+			for i+1 < len(lines) && !isFileHeader(lines, i+1) && !endsHunk(lines[i+1]) {
+				i++
+				if hunkLine, ok := parseLine(lines[i]); ok {
+					hunk.Lines = append(hunk.Lines, hunkLine)
+				}
+			}
+			hunk.Lines = trimTrailingContext(hunk.Lines)
+
+			current := &diffs[len(diffs)-1]
+			current.Hunks = append(current.Hunks, hunk)
+		}
+	}
+
+	if len(diffs) == 0 {
+		return nil, ErrNoDiff
+	}
+	return diffs, nil
+}
+
+// isFileHeader reports whether a file header starts at line i
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+}
+
+// endsHunk reports whether the line ends the current hunk
+func endsHunk(line string) bool {
+	return strings.HasPrefix(line, "@@") || strings.HasPrefix(line, "diff ")
+}
+
+// parseLine parses a hunk line. Empty lines are taken as empty context lines,
+// as trailing whitespace is often stripped, and "\ No newline at end of file"
+// markers are skipped
+func parseLine(line string) (Line, bool) {
+	if line == "" {
+		return Line{Op: ' '}, true
+	}
+	switch line[0] {
+	case ' ', '-', '+':
+		return Line{Op: line[0], Text: line[1:]}, true
+	}
+	return Line{}, false
+}
+
+// trimTrailingContext removes empty context lines at the end of a hunk, which
+// come from blank lines separating the diff from the text following it
+func trimTrailingContext(lines []Line) []Line {
+	for len(lines) > 0 {
+		last := lines[len(lines)-1]
+		if last.Op != ' ' || last.Text != "" {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// headerPath returns the path of a file header, without the timestamp and the
+// a/ or b/ prefix
+func headerPath(header string) string {
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == DevNull {
+		return path
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
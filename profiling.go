@@ -0,0 +1,133 @@
+package anthropic
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+)
+
+// Profiler label keys set by WithProfilerLabels
+const (
+	PathLabel         = "anthropic_path"
+	ModelLabel        = "anthropic_model"
+	ConversationLabel = "anthropic_conversation"
+)
+
+// conversationIDKey is the context key for the conversation ID
+type conversationIDKey struct{}
+
+// WithProfilerLabels tags the goroutines making requests and reading streams
+// with pprof labels holding the path, the model and the conversation ID set
+// with ContextWithConversationID, so CPU and goroutine profiles of services
+// embedding the client can be broken down by them
+func WithProfilerLabels() ClientOption {
+	return func(c *Client) {
+		c.ProfilerLabels = true
+	}
+}
+
+// WithRuntimeTrace runs every request in a runtime/trace task, with regions
+// for sending the request, reading the response and every read of a stream,
+// so requests can be inspected with go tool trace
+func WithRuntimeTrace() ClientOption {
+	return func(c *Client) {
+		c.RuntimeTrace = true
+	}
+}
+
+// ContextWithConversationID returns a context identifying the conversation its
+// requests belong to, used as a profiler label
+func ContextWithConversationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationIDKey{}, id)
+}
+
+// ConversationIDFromContext returns the conversation ID of the context, empty
+// when none is set
+func ConversationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationIDKey{}).(string)
+	return id
+}
+
+// profilerLabels returns the pprof labels of a request
+func profilerLabels(ctx context.Context, path, model string) pprof.LabelSet {
+	labels := []string{PathLabel, path}
+	if model != "" {
+		labels = append(labels, ModelLabel, model)
+	}
+	if id := ConversationIDFromContext(ctx); id != "" {
+		labels = append(labels, ConversationLabel, id)
+	}
+	return pprof.Labels(labels...)
+}
+
+// instrument runs fn in a trace task and with profiler labels as configured
+func (c *Client) instrument(ctx context.Context, path, model string, fn func(ctx context.Context) error) error {
+	if c.RuntimeTrace {
+		var task *trace.Task
+		ctx, task = c.startTask(ctx, path, model)
+		defer task.End()
+	}
+	return c.withLabels(ctx, path, model, fn)
+}
+
+// withLabels runs fn with the goroutine's profiler labels set when enabled
+func (c *Client) withLabels(ctx context.Context, path, model string, fn func(ctx context.Context) error) error {
+	if !c.ProfilerLabels {
+		return fn(ctx)
+	}
+
+	var err error
+	pprof.Do(ctx, profilerLabels(ctx, path, model), func(ctx context.Context) {
+		err = fn(ctx)
+	})
+	return err
+}
+
+// streamInstrumentation returns the stream options continuing the
+// instrumentation of a streaming request while the stream is read
+func (c *Client) streamInstrumentation(ctx context.Context, model string, task *trace.Task) []streaming.StreamOption {
+	var options []streaming.StreamOption
+	if task != nil {
+		options = append(options, streaming.WithTraceTask(ctx, task))
+	}
+	if c.ProfilerLabels {
+		options = append(options, streaming.WithProfilerLabels(ctx, profilerLabels(ctx, messagesPath, model)))
+	}
+	return options
+}
+
+// startTask starts the trace task of a request
+func (c *Client) startTask(ctx context.Context, path, model string) (context.Context, *trace.Task) {
+	ctx, task := trace.NewTask(ctx, "anthropic "+path)
+	trace.Log(ctx, PathLabel, path)
+	if model != "" {
+		trace.Log(ctx, ModelLabel, model)
+	}
+	if id := ConversationIDFromContext(ctx); id != "" {
+		trace.Log(ctx, ConversationLabel, id)
+	}
+	return ctx, task
+}
+
+// region starts a trace region when runtime tracing is enabled, the returned
+// function ends it
+func (c *Client) region(ctx context.Context, name string) func() {
+	if !c.RuntimeTrace {
+		return func() {}
+	}
+	return trace.StartRegion(ctx, name).End
+}
+
+// requestModel returns the model of a request body, empty when it has none
+func requestModel(body interface{}) string {
+	switch req := body.(type) {
+	case models.MessageRequest:
+		return req.Model
+	case *models.MessageRequest:
+		return req.Model
+	}
+	return ""
+}
@@ -0,0 +1,15 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// ChatProvider creates messages. It is implemented by Client, and by fakes so
+// that code built on top of the SDK can be tested without the API
+type ChatProvider interface {
+	CreateMessage(ctx context.Context, req models.MessageRequest, options ...RequestOption) (*models.Message, error)
+}
+
+var _ ChatProvider = (*Client)(nil)
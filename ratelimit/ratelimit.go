@@ -0,0 +1,176 @@
+// Package ratelimit limits the rate of API requests on the client side, to
+// stay below the organization's rate limits instead of running into 429
+// responses. Limiters can be local or shared across replicas through Redis
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/backoff"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Limiter limits the rate of requests
+type Limiter interface {
+	// Wait blocks until n units may be used, or the context is done
+	Wait(ctx context.Context, n int) error
+}
+
+// Cost returns the number of units a request uses
+type Cost func(req *models.MessageRequest) int
+
+// RequestCost counts every request as one unit, for requests per minute limits
+func RequestCost(req *models.MessageRequest) int {
+	return 1
+}
+
+// InputTokenCost estimates the input tokens of a request, for input tokens per
+// minute limits
+func InputTokenCost(req *models.MessageRequest) int {
+	tokens := models.EstimateTokens(req.System)
+	for _, block := range req.SystemBlocks {
+		tokens += models.EstimateTokens(block.Text)
+	}
+	for _, message := range req.Messages {
+		for _, block := range message.Content {
+			if block.TextContent != nil {
+				tokens += models.EstimateTokens(block.TextContent.Text)
+			}
+			if block.ToolResultContent != nil {
+				tokens += models.EstimateTokens(block.ToolResultContent.Content)
+			}
+		}
+	}
+	return max(tokens, 1)
+}
+
+// Middleware returns client middleware that waits for the limiter before every
+// message request. A nil cost counts every request as one unit
+func Middleware(limiter Limiter, cost Cost) anthropic.Middleware {
+	if cost == nil {
+		cost = RequestCost
+	}
+	return func(ctx context.Context, req *models.MessageRequest) error {
+		return limiter.Wait(ctx, cost(req))
+	}
+}
+
+// TokenBucket is an in-process token bucket limiter
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+
+	// resetAt holds back all requests until the API's limit resets, after a
+	// calibration found it exhausted
+	resetAt time.Time
+}
+
+// NewTokenBucket creates a limiter allowing rate units per second with bursts
+// of up to burst units
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// PerMinute creates a limiter allowing limit units per minute
+func PerMinute(limit int) *TokenBucket {
+	return NewTokenBucket(float64(limit)/60, limit)
+}
+
+// Wait blocks until n units are available
+func (b *TokenBucket) Wait(ctx context.Context, n int) error {
+	if float64(n) > b.burst {
+		return fmt.Errorf("request cost %d exceeds the burst of %g", n, b.burst)
+	}
+
+	for {
+		delay := b.reserve(float64(n))
+		if delay == 0 {
+			return nil
+		}
+		if err := backoff.Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// reserve takes n tokens if available and otherwise returns how long to wait
+func (b *TokenBucket) reserve(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if !b.resetAt.IsZero() {
+		if now.Before(b.resetAt) {
+			return b.resetAt.Sub(now)
+		}
+		// The API's limit is fully replenished at its reset
+		b.tokens, b.last, b.resetAt = b.burst, b.resetAt, time.Time{}
+	}
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if b.tokens >= n {
+		b.tokens -= n
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Calibrate aligns the bucket with the rate limit state reported by the API.
+// The bucket never holds more than remaining units, and when remaining is zero
+// all requests wait until reset, so they are sent right after the limit is
+// replenished instead of retrying blindly
+func (b *TokenBucket) Calibrate(remaining int, reset time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	if remaining <= 0 && reset.After(now) {
+		b.tokens = 0
+		b.resetAt = reset
+		return
+	}
+	b.tokens = math.Min(b.tokens, float64(remaining))
+}
+
+// Resource selects the rate limit a bucket is calibrated against
+type Resource func(limits anthropic.RateLimits) anthropic.RateLimit
+
+// Rate limits that buckets can be calibrated against
+var (
+	Requests     Resource = func(l anthropic.RateLimits) anthropic.RateLimit { return l.Requests }
+	Tokens       Resource = func(l anthropic.RateLimits) anthropic.RateLimit { return l.Tokens }
+	InputTokens  Resource = func(l anthropic.RateLimits) anthropic.RateLimit { return l.InputTokens }
+	OutputTokens Resource = func(l anthropic.RateLimits) anthropic.RateLimit { return l.OutputTokens }
+)
+
+// WithCalibration returns a client option calibrating the bucket with the
+// given rate limit of every response. A rate limited response without
+// remaining counts holds the bucket until its reset
+func WithCalibration(bucket *TokenBucket, resource Resource) anthropic.ClientOption {
+	return anthropic.WithRateLimitObserver(func(limits anthropic.RateLimits) {
+		limit := resource(limits)
+		if limit.Reset.IsZero() {
+			return
+		}
+		remaining := limit.Remaining
+		if limits.StatusCode == http.StatusTooManyRequests && limits.ResetDelay() > 0 {
+			remaining = 0
+			limit.Reset = time.Now().Add(limits.ResetDelay())
+		}
+		bucket.Calibrate(remaining, limit.Reset)
+	})
+}
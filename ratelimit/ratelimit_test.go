@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	tests := []struct {
+		name     string
+		prepare  func(b *TokenBucket)
+		n        float64
+		wantWait bool
+	}{
+		{name: "within the burst", n: 10},
+		{name: "after the burst", prepare: func(b *TokenBucket) { b.reserve(10) }, n: 1, wantWait: true},
+		{name: "refilled", prepare: func(b *TokenBucket) { b.reserve(10); b.last = b.last.Add(-time.Second) }, n: 1},
+		{name: "calibrated below the cost", prepare: func(b *TokenBucket) { b.Calibrate(2, time.Now().Add(time.Minute)) }, n: 3, wantWait: true},
+		{name: "calibrated above the cost", prepare: func(b *TokenBucket) { b.Calibrate(5, time.Now().Add(time.Minute)) }, n: 5},
+		{name: "exhausted until reset", prepare: func(b *TokenBucket) { b.Calibrate(0, time.Now().Add(time.Hour)) }, n: 1, wantWait: true},
+		{name: "replenished at reset", prepare: func(b *TokenBucket) { b.Calibrate(0, time.Now().Add(time.Hour)); b.resetAt = time.Now() }, n: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := NewTokenBucket(10, 10)
+			if tt.prepare != nil {
+				tt.prepare(bucket)
+			}
+			if wait := bucket.reserve(tt.n); (wait > 0) != tt.wantWait {
+				t.Fatalf("reserve(%g) = %v, want waiting %t", tt.n, wait, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestTokenBucketHoldsUntilReset(t *testing.T) {
+	bucket := NewTokenBucket(1000, 10)
+	bucket.Calibrate(0, time.Now().Add(time.Hour))
+
+	if wait := bucket.reserve(1); wait < 59*time.Minute {
+		t.Fatalf("reserve(1) = %v, want a wait until the reset", wait)
+	}
+}
+
+func TestTokenBucketWait(t *testing.T) {
+	bucket := NewTokenBucket(1, 2)
+
+	if err := bucket.Wait(context.Background(), 3); err == nil {
+		t.Fatal("Wait() with a cost above the burst succeeded")
+	}
+	if err := bucket.Wait(context.Background(), 2); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := bucket.Wait(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() on an empty bucket = %v, want the context error", err)
+	}
+}
+
+// scripter is a RedisScripter returning scripted results
+type scripter struct {
+	results []interface{}
+	err     error
+	keys    []string
+	args    []interface{}
+	calls   int
+}
+
+func (s *scripter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	s.keys, s.args = keys, args
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	result := s.results[0]
+	s.results = s.results[1:]
+	return result, nil
+}
+
+func TestRedisTokenBucket(t *testing.T) {
+	tests := []struct {
+		name      string
+		client    *scripter
+		n         int
+		wantCalls int
+		wantErr   bool
+	}{
+		{name: "taken", client: &scripter{results: []interface{}{int64(0)}}, n: 1, wantCalls: 1},
+		{name: "waits and retries", client: &scripter{results: []interface{}{int64(1), int64(2), int64(0)}}, n: 1, wantCalls: 3},
+		{name: "cost above the burst", client: &scripter{}, n: 11, wantErr: true},
+		{name: "script error", client: &scripter{err: errors.New("connection refused")}, n: 1, wantCalls: 1, wantErr: true},
+		{name: "unexpected result", client: &scripter{results: []interface{}{"0"}}, n: 1, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket := NewRedisTokenBucket(tt.client, "limits:org", 5, 10)
+			err := bucket.Wait(context.Background(), tt.n)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Wait() = %v, want error %t", err, tt.wantErr)
+			}
+			if tt.client.calls != tt.wantCalls {
+				t.Fatalf("script ran %d times, want %d", tt.client.calls, tt.wantCalls)
+			}
+			if tt.wantCalls > 0 && (len(tt.client.keys) != 1 || tt.client.keys[0] != "limits:org") {
+				t.Fatalf("script keys = %v", tt.client.keys)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/backoff"
+)
+
+// RedisScripter runs a Lua script on Redis. It is satisfied by a small adapter
+// around any Redis client, for example with go-redis:
+//
+//	type scripter struct{ rdb *redis.Client }
+//
+//	func (s scripter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return s.rdb.Eval(ctx, script, keys, args...).Result()
+//	}
+type RedisScripter interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// tokenBucketScript atomically refills the bucket and takes the requested
+// tokens. It returns 0 when the tokens were taken and otherwise the number of
+// milliseconds to wait. Time is taken from the Redis server so replicas with
+// skewed clocks agree
+const tokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'last')
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + (now - last) * rate / 1000)
+local wait = 0
+if tokens >= n then
+  tokens = tokens - n
+else
+  wait = math.ceil((n - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'last', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return wait
+`
+
+// RedisTokenBucket is a token bucket limiter whose state lives in Redis, so
+// replicas of a service share one limit
+type RedisTokenBucket struct {
+	client RedisScripter
+	key    string
+	rate   float64
+	burst  int
+}
+
+// NewRedisTokenBucket creates a limiter stored under key allowing rate units
+// per second with bursts of up to burst units
+func NewRedisTokenBucket(client RedisScripter, key string, rate float64, burst int) *RedisTokenBucket {
+	return &RedisTokenBucket{client: client, key: key, rate: rate, burst: burst}
+}
+
+// Wait blocks until n units are available
+func (b *RedisTokenBucket) Wait(ctx context.Context, n int) error {
+	if n > b.burst {
+		return fmt.Errorf("request cost %d exceeds the burst of %d", n, b.burst)
+	}
+
+	for {
+		result, err := b.client.Eval(ctx, tokenBucketScript, []string{b.key}, b.rate, b.burst, n)
+		if err != nil {
+			return fmt.Errorf("error running rate limit script: %w", err)
+		}
+
+		wait, ok := result.(int64)
+		if !ok {
+			return fmt.Errorf("unexpected rate limit script result %T", result)
+		}
+		if wait == 0 {
+			return nil
+		}
+		if err := backoff.Sleep(ctx, time.Duration(wait)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package anthropic
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit is the state of one rate limit as reported in response headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+
+	// Reset is when the limit is fully replenished, zero when not reported
+	Reset time.Time
+}
+
+// exhausted reports whether the limit has no capacity left
+func (l RateLimit) exhausted() bool {
+	return !l.Reset.IsZero() && l.Remaining <= 0
+}
+
+// RateLimits holds the rate limits reported by a response
+type RateLimits struct {
+	StatusCode int
+
+	Requests     RateLimit
+	Tokens       RateLimit
+	InputTokens  RateLimit
+	OutputTokens RateLimit
+
+	// RetryAfter is the delay requested by the retry-after header
+	RetryAfter time.Duration
+}
+
+// ParseRateLimits parses the anthropic-ratelimit-* and retry-after headers
+func ParseRateLimits(header http.Header) RateLimits {
+	return RateLimits{
+		Requests:     parseRateLimit(header, "requests"),
+		Tokens:       parseRateLimit(header, "tokens"),
+		InputTokens:  parseRateLimit(header, "input-tokens"),
+		OutputTokens: parseRateLimit(header, "output-tokens"),
+		RetryAfter:   retryAfter(header),
+	}
+}
+
+// parseRateLimit parses the headers of one limit
+func parseRateLimit(header http.Header, name string) RateLimit {
+	prefix := "anthropic-ratelimit-" + name + "-"
+	limit := RateLimit{}
+	limit.Limit, _ = strconv.Atoi(header.Get(prefix + "limit"))
+	limit.Remaining, _ = strconv.Atoi(header.Get(prefix + "remaining"))
+	if reset, err := time.Parse(time.RFC3339, header.Get(prefix+"reset")); err == nil {
+		limit.Reset = reset
+	}
+	return limit
+}
+
+// ResetDelay returns how long to wait until the exhausted limits are
+// replenished: the latest reset among limits without remaining capacity, or
+// the token limit's reset when none is reported as exhausted. It is zero when
+// no reset time is known
+func (l RateLimits) ResetDelay() time.Duration {
+	var reset time.Time
+	for _, limit := range []RateLimit{l.Requests, l.Tokens, l.InputTokens, l.OutputTokens} {
+		if limit.exhausted() && limit.Reset.After(reset) {
+			reset = limit.Reset
+		}
+	}
+	if reset.IsZero() {
+		reset = l.Tokens.Reset
+	}
+	if reset.IsZero() {
+		return 0
+	}
+	return max(time.Until(reset), 0)
+}
+
+// reported reports whether the headers contained any rate limit
+func (l RateLimits) reported() bool {
+	for _, limit := range []RateLimit{l.Requests, l.Tokens, l.InputTokens, l.OutputTokens} {
+		if limit.Limit > 0 || !limit.Reset.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// RateLimitObserver is called with the rate limits reported by a response
+type RateLimitObserver func(RateLimits)
+
+// WithRateLimitObserver adds an observer called with the rate limits reported
+// by every response, including responses of attempts that are retried, for
+// example to calibrate a client-side limiter
+func WithRateLimitObserver(observer RateLimitObserver) ClientOption {
+	return func(c *Client) {
+		c.RateLimitObservers = append(c.RateLimitObservers, observer)
+	}
+}
+
+// observeRateLimits passes the rate limits of a response to the observers,
+// returning the panic of an observer
+func (c *Client) observeRateLimits(resp *http.Response) error {
+	if len(c.RateLimitObservers) == 0 {
+		return nil
+	}
+	limits := ParseRateLimits(resp.Header)
+	if !limits.reported() && limits.RetryAfter == 0 {
+		return nil
+	}
+	limits.StatusCode = resp.StatusCode
+	for _, observer := range c.RateLimitObservers {
+		if err := c.guard().Notify("rate limit observer", func() { observer(limits) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,326 @@
+// Package redact removes personally identifiable information from outbound
+// requests and log output using pluggable detectors
+package redact
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Match is a span of text found by a detector
+type Match struct {
+	Start int
+	End   int
+}
+
+// Detector finds sensitive spans in text
+type Detector interface {
+	// Name identifies the kind of data found, used in replacements
+	Name() string
+
+	// Find returns the spans of sensitive data in text
+	Find(text string) []Match
+}
+
+// RegexDetector detects spans matching a regular expression, optionally
+// filtered by a validation function
+type RegexDetector struct {
+	Label    string
+	Pattern  *regexp.Regexp
+	Validate func(match string) bool
+}
+
+// Name implements the Detector interface
+func (d *RegexDetector) Name() string {
+	return d.Label
+}
+
+// Find implements the Detector interface
+func (d *RegexDetector) Find(text string) []Match {
+	var matches []Match
+	for _, loc := range d.Pattern.FindAllStringIndex(text, -1) {
+		if d.Validate != nil && !d.Validate(text[loc[0]:loc[1]]) {
+			continue
+		}
+		matches = append(matches, Match{Start: loc[0], End: loc[1]})
+	}
+	return matches
+}
+
+// Email detects email addresses
+func Email() Detector {
+	return &RegexDetector{
+		Label:   "EMAIL",
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+	}
+}
+
+// phonePattern matches numbers starting with a country code or an area code in
+// parentheses, or made of at least three groups of digits separated by spaces,
+// dots or dashes
+var phonePattern = regexp.MustCompile(`\+\d{1,3}[\s.\-]?(?:\(\d{1,4}\)[\s.\-]?)?\d{1,4}(?:[\s.\-]?\d{1,4}){1,4}\b` +
+	`|\(\d{1,4}\)[\s.\-]?\d{2,4}(?:[\s.\-]?\d{2,4}){1,3}\b` +
+	`|\b\d{2,4}(?:[\s.\-]\d{2,4}){2,4}\b`)
+
+// datePattern matches text starting with a date such as 2024-10-16 or
+// 16.10.2024, which are grouped like phone numbers
+var datePattern = regexp.MustCompile(`^(?:\d{4}[\-./]\d{2}[\-./]\d{2}|\d{2}[\-./]\d{2}[\-./]\d{4})\b`)
+
+// Phone detects phone numbers with at least seven digits, written with a
+// country code, an area code in parentheses or separated groups of digits.
+// Plain numbers and dates are not matched
+func Phone() Detector {
+	return &RegexDetector{
+		Label:   "PHONE",
+		Pattern: phonePattern,
+		Validate: func(match string) bool {
+			return countDigits(match) >= 7 && !datePattern.MatchString(match)
+		},
+	}
+}
+
+// CreditCard detects payment card numbers that pass the Luhn checksum
+func CreditCard() Detector {
+	return &RegexDetector{
+		Label:   "CREDIT_CARD",
+		Pattern: regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Validate: func(match string) bool {
+			return luhn(match)
+		},
+	}
+}
+
+// Redactor replaces the spans found by its detectors
+type Redactor struct {
+	Detectors []Detector
+
+	// Replacement returns the text replacing a span found by the named
+	// detector, defaults to "[REDACTED_<NAME>]"
+	Replacement func(name string) string
+}
+
+// New creates a redactor with the given detectors, or the built-in email,
+// phone and credit card detectors if none are given
+func New(detectors ...Detector) *Redactor {
+	if len(detectors) == 0 {
+		detectors = []Detector{Email(), CreditCard(), Phone()}
+	}
+	return &Redactor{Detectors: detectors}
+}
+
+// span is a match with the detector that found it
+type span struct {
+	Match
+	name string
+}
+
+// Redact returns text with all detected spans replaced
+func (r *Redactor) Redact(text string) string {
+	var spans []span
+	for _, detector := range r.Detectors {
+		for _, match := range detector.Find(text) {
+			spans = append(spans, span{Match: match, name: detector.Name()})
+		}
+	}
+	if len(spans) == 0 {
+		return text
+	}
+
+	// Earlier detectors win overlapping spans since the sort is stable
+	sort.SliceStable(spans, func(i, j int) bool {
+		return spans[i].Start < spans[j].Start
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		if s.Start < last {
+			continue
+		}
+		b.WriteString(text[last:s.Start])
+		b.WriteString(r.replacement(s.name))
+		last = s.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// RedactRequest redacts the system prompt and all text, tool use input, tool
+// result and text document content of a request. The redacted content is
+// copied, so slices shared with the caller are not modified
+func (r *Redactor) RedactRequest(req *models.MessageRequest) {
+	req.System = r.Redact(req.System)
+	if req.SystemBlocks != nil {
+		system := make([]models.TextBlock, len(req.SystemBlocks))
+		for i, block := range req.SystemBlocks {
+			block.Text = r.Redact(block.Text)
+			system[i] = block
+		}
+		req.SystemBlocks = system
+	}
+
+	messages := make([]models.MessageParam, len(req.Messages))
+	for i, message := range req.Messages {
+		messages[i] = models.MessageParam{Role: message.Role, Content: r.redactBlocks(message.Content)}
+	}
+	req.Messages = messages
+}
+
+// redactBlocks returns redacted copies of content blocks
+func (r *Redactor) redactBlocks(blocks []models.ContentBlock) []models.ContentBlock {
+	if blocks == nil {
+		return nil
+	}
+	redacted := make([]models.ContentBlock, len(blocks))
+	for i, block := range blocks {
+		redacted[i] = r.redactBlock(block)
+	}
+	return redacted
+}
+
+// redactBlock returns a redacted copy of a content block
+func (r *Redactor) redactBlock(block models.ContentBlock) models.ContentBlock {
+	if block.TextContent != nil {
+		text := *block.TextContent
+		text.Text = r.Redact(text.Text)
+		block.TextContent = &text
+	}
+	if block.ToolUseContent != nil {
+		toolUse := *block.ToolUseContent
+		toolUse.Input = r.redactInput(toolUse.Input)
+		block.ToolUseContent = &toolUse
+	}
+	if block.ToolResultContent != nil {
+		result := *block.ToolResultContent
+		result.Content = r.Redact(result.Content)
+		result.Blocks = r.redactBlocks(result.Blocks)
+		block.ToolResultContent = &result
+	}
+	if block.SearchResultContent != nil {
+		result := *block.SearchResultContent
+		result.Content = append([]models.TextBlock(nil), result.Content...)
+		for i := range result.Content {
+			result.Content[i].Text = r.Redact(result.Content[i].Text)
+		}
+		block.SearchResultContent = &result
+	}
+	if block.DocumentContent != nil {
+		document := *block.DocumentContent
+		if document.Source.Type == models.TextDocumentSource {
+			document.Source.Data = r.Redact(document.Source.Data)
+		}
+		document.Title = r.Redact(document.Title)
+		document.Context = r.Redact(document.Context)
+		block.DocumentContent = &document
+	}
+	return block
+}
+
+// redactInput returns a tool use input with all of its string values
+// redacted. Inputs that cannot be encoded are returned unchanged
+func (r *Redactor) redactInput(input interface{}) interface{} {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return input
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return input
+	}
+	return r.redactValue(value)
+}
+
+// redactValue redacts the strings of a decoded JSON value
+func (r *Redactor) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return r.Redact(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = r.redactValue(v[i])
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = r.redactValue(item)
+		}
+	}
+	return value
+}
+
+// Middleware returns client middleware redacting every outbound request
+func (r *Redactor) Middleware() anthropic.Middleware {
+	return func(ctx context.Context, req *models.MessageRequest) error {
+		r.RedactRequest(req)
+		return nil
+	}
+}
+
+// Writer returns a writer that redacts each write before passing it to w. It
+// is intended for line oriented log output, such as log.SetOutput
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &writer{redactor: r, w: w}
+}
+
+// writer redacts data written to an underlying writer
+type writer struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+// Write implements the io.Writer interface
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// replacement returns the replacement text for a detector
+func (r *Redactor) replacement(name string) string {
+	if r.Replacement != nil {
+		return r.Replacement(name)
+	}
+	return "[REDACTED_" + name + "]"
+}
+
+// countDigits returns the number of digits in s
+func countDigits(s string) int {
+	count := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+// luhn reports whether the digits in s pass the Luhn checksum
+func luhn(s string) bool {
+	sum := 0
+	double := false
+	digits := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
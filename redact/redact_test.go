@@ -0,0 +1,89 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+func TestPhone(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "Call +1 555 123 4567 now", want: "Call [REDACTED_PHONE] now"},
+		{text: "Call +46701234567", want: "Call [REDACTED_PHONE]"},
+		{text: "Call (555) 123-4567", want: "Call [REDACTED_PHONE]"},
+		{text: "Call 555-123-4567", want: "Call [REDACTED_PHONE]"},
+		{text: "Call 070 123 45 67", want: "Call [REDACTED_PHONE]"},
+		{text: "Meeting on 2024-10-16", want: "Meeting on 2024-10-16"},
+		{text: "Meeting on 2024-10-16 14:30", want: "Meeting on 2024-10-16 14:30"},
+		{text: "Meeting on 16.10.2024", want: "Meeting on 16.10.2024"},
+		{text: "Order 12345678", want: "Order 12345678"},
+		{text: "Version 1.2.3", want: "Version 1.2.3"},
+	}
+
+	redactor := New(Phone())
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := redactor.Redact(tt.text); got != tt.want {
+				t.Fatalf("Redact(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactRequest(t *testing.T) {
+	const email = "jane@example.com"
+	const redacted = "[REDACTED_EMAIL]"
+
+	system := []models.TextBlock{{Type: models.TextContentType, Text: "Mail " + email}}
+	req := models.MessageRequest{
+		SystemBlocks: system,
+		Messages: []models.MessageParam{
+			models.NewUserMessage(
+				models.CreateTextBlock("I am "+email),
+				models.ContentBlock{DocumentContent: &models.DocumentBlock{
+					Type:   models.DocumentContentType,
+					Source: models.DocumentSource{Type: models.TextDocumentSource, MediaType: models.PlainTextMediaType, Data: "Contact " + email},
+				}},
+			),
+			models.NewAssistantMessage(models.ContentBlock{ToolUseContent: &models.ToolUseBlock{
+				Type:  models.ToolUseContentType,
+				ID:    "toolu_1",
+				Name:  "send",
+				Input: map[string]interface{}{"to": []interface{}{email}},
+			}}),
+			models.NewUserMessage(models.ContentBlock{ToolResultContent: &models.ToolResultBlock{
+				Type:      models.ToolResultContentType,
+				ToolUseID: "toolu_1",
+				Blocks:    []models.ContentBlock{models.CreateTextBlock("Sent to " + email)},
+			}}),
+		},
+	}
+
+	New(Email()).RedactRequest(&req)
+
+	if got := system[0].Text; got != "Mail "+email {
+		t.Fatalf("caller's system block = %q, want it unchanged", got)
+	}
+	if got := req.SystemBlocks[0].Text; got != "Mail "+redacted {
+		t.Fatalf("system block = %q", got)
+	}
+
+	content := req.Messages[0].Content
+	if got := content[0].TextContent.Text; got != "I am "+redacted {
+		t.Fatalf("text = %q", got)
+	}
+	if got := content[1].DocumentContent.Source.Data; got != "Contact "+redacted {
+		t.Fatalf("document = %q", got)
+	}
+	input := req.Messages[1].Content[0].ToolUseContent.Input
+	if want := map[string]interface{}{"to": []interface{}{redacted}}; !reflect.DeepEqual(input, want) {
+		t.Fatalf("tool use input = %v, want %v", input, want)
+	}
+	if got := req.Messages[2].Content[0].ToolResultContent.Blocks[0].TextContent.Text; got != "Sent to "+redacted {
+		t.Fatalf("tool result block = %q", got)
+	}
+}
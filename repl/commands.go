@@ -0,0 +1,205 @@
+package repl
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Command is a slash command
+type Command struct {
+	// Name is typed after the slash, such as "model"
+	Name string
+
+	// Usage describes the arguments and effect for /help
+	Usage string
+
+	// Run executes the command with the text following its name. Returning
+	// ErrExit ends the session
+	Run func(r *REPL, args string) error
+}
+
+// Handle adds a command, replacing a command with the same name
+func (r *REPL) Handle(command Command) {
+	if r.commands == nil {
+		r.commands = make(map[string]Command)
+	}
+	r.commands[command.Name] = command
+}
+
+// command runs a slash command line
+func (r *REPL) command(line string) error {
+	name, args, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	command, ok := r.commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command /%s, type /help for a list", name)
+	}
+	return command.Run(r, strings.TrimSpace(args))
+}
+
+// builtinCommands returns the commands every session has
+func builtinCommands() []Command {
+	return []Command{
+		{Name: "help", Usage: "list the commands", Run: help},
+		{Name: "exit", Usage: "end the session", Run: exit},
+		{Name: "quit", Usage: "end the session", Run: exit},
+		{Name: "model", Usage: "[name] show or switch the model", Run: model},
+		{Name: "temperature", Usage: "[value|off] show or set the temperature", Run: temperature},
+		{Name: "max_tokens", Usage: "[n] show or set max_tokens", Run: maxTokens},
+		{Name: "thinking", Usage: "[budget|off|show|hide] set the thinking budget or toggle rendering", Run: thinking},
+		{Name: "tools", Usage: "[show|hide] list the tools or toggle rendering of tool calls", Run: toolsCommand},
+		{Name: "system", Usage: "[prompt|off] show or set the system prompt", Run: system},
+		{Name: "clear", Usage: "start a new conversation", Run: clearConversation},
+		{Name: "history", Usage: "list the inputs, rerun one with !n or !!", Run: listHistory},
+	}
+}
+
+// help lists the commands
+func help(r *REPL, _ string) error {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(r.Out, "  /%-12s %s\n", name, r.commands[name].Usage)
+	}
+	return nil
+}
+
+// exit ends the session
+func exit(*REPL, string) error {
+	return ErrExit
+}
+
+// model shows or switches the model
+func model(r *REPL, args string) error {
+	if args != "" {
+		r.Request.Model = args
+	}
+	fmt.Fprintf(r.Out, "model: %s\n", r.Request.Model)
+	return nil
+}
+
+// temperature shows or sets the temperature
+func temperature(r *REPL, args string) error {
+	switch args {
+	case "":
+	case "off":
+		r.Request.Temperature = nil
+	default:
+		value, err := strconv.ParseFloat(args, 64)
+		if err != nil || value < 0 || value > 1 {
+			return fmt.Errorf("temperature must be between 0 and 1")
+		}
+		r.Request.Temperature = &value
+	}
+	if r.Request.Temperature == nil {
+		fmt.Fprintln(r.Out, "temperature: default")
+	} else {
+		fmt.Fprintf(r.Out, "temperature: %g\n", *r.Request.Temperature)
+	}
+	return nil
+}
+
+// maxTokens shows or sets max_tokens
+func maxTokens(r *REPL, args string) error {
+	if args != "" {
+		value, err := strconv.Atoi(args)
+		if err != nil || value <= 0 {
+			return fmt.Errorf("max_tokens must be a positive number")
+		}
+		r.Request.MaxTokens = value
+	}
+	fmt.Fprintf(r.Out, "max_tokens: %d\n", r.Request.MaxTokens)
+	return nil
+}
+
+// thinking sets the thinking budget or toggles its rendering
+func thinking(r *REPL, args string) error {
+	switch args {
+	case "":
+	case "off":
+		r.Request.Thinking = nil
+	case "show", "hide":
+		r.renderer().HideThinking = args == "hide"
+	default:
+		budget, err := strconv.Atoi(args)
+		if err != nil || budget <= 0 {
+			return fmt.Errorf("thinking budget must be a positive number")
+		}
+		r.Request.Thinking = models.EnableThinking(budget)
+		if r.Request.MaxTokens <= budget {
+			r.Request.MaxTokens = budget + DefaultMaxTokens
+			fmt.Fprintf(r.Out, "max_tokens raised to %d\n", r.Request.MaxTokens)
+		}
+	}
+	if r.Request.Thinking == nil {
+		fmt.Fprintln(r.Out, "thinking: off")
+	} else {
+		fmt.Fprintf(r.Out, "thinking: %d tokens\n", r.Request.Thinking.BudgetTokens)
+	}
+	return nil
+}
+
+// toolsCommand lists the tools or toggles their rendering
+func toolsCommand(r *REPL, args string) error {
+	switch args {
+	case "show", "hide":
+		r.renderer().HideTools = args == "hide"
+		return nil
+	case "":
+	default:
+		return fmt.Errorf("usage: /tools [show|hide]")
+	}
+
+	definitions := r.Request.Tools
+	if len(definitions) == 0 && r.Registry != nil {
+		definitions = r.Registry.Definitions()
+	}
+	if len(definitions) == 0 {
+		fmt.Fprintln(r.Out, "no tools")
+	}
+	for _, tool := range definitions {
+		fmt.Fprintf(r.Out, "  %s: %s\n", tool.Name, tool.Description)
+	}
+	return nil
+}
+
+// system shows or sets the system prompt
+func system(r *REPL, args string) error {
+	switch args {
+	case "":
+	case "off":
+		r.Request.System = ""
+	default:
+		r.Request.System = args
+	}
+	if r.Request.System == "" {
+		fmt.Fprintln(r.Out, "system: none")
+	} else {
+		fmt.Fprintf(r.Out, "system: %s\n", r.Request.System)
+	}
+	return nil
+}
+
+// clearConversation starts a new conversation
+func clearConversation(r *REPL, _ string) error {
+	r.Messages = nil
+	fmt.Fprintln(r.Out, "conversation cleared")
+	return nil
+}
+
+// listHistory lists the inputs
+func listHistory(r *REPL, _ string) error {
+	if r.History == nil {
+		return fmt.Errorf("history is disabled")
+	}
+	for i, entry := range r.History.Entries() {
+		fmt.Fprintf(r.Out, "%4d  %s\n", i+1, entry)
+	}
+	return nil
+}
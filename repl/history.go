@@ -0,0 +1,108 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultHistorySize is the default number of inputs a history keeps
+const DefaultHistorySize = 1000
+
+// History records inputs, optionally persisting them to a file with one input
+// per line
+type History struct {
+	// Path is the file the history is appended to when set
+	Path string
+
+	// Size is the number of inputs kept, defaults to DefaultHistorySize
+	Size int
+
+	entries []string
+}
+
+// LoadHistory creates a history persisted to path, reading earlier inputs
+// from it when it exists
+func LoadHistory(path string) (*History, error) {
+	h := &History{Path: path}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error opening history: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.push(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return h, nil
+}
+
+// Add records an input, skipping repeats of the previous one
+func (h *History) Add(line string) error {
+	line = strings.ReplaceAll(line, "\n", " ")
+	if n := len(h.entries); n > 0 && h.entries[n-1] == line {
+		return nil
+	}
+	h.push(line)
+
+	if h.Path == "" {
+		return nil
+	}
+	file, err := os.OpenFile(h.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintln(file, line); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Entries returns the recorded inputs, oldest first
+func (h *History) Entries() []string {
+	return h.entries
+}
+
+// Lookup resolves a history reference: "!" for the last input or a number for
+// the input at that 1-based position
+func (h *History) Lookup(ref string) (string, error) {
+	if len(h.entries) == 0 {
+		return "", errors.New("history is empty")
+	}
+	if ref == "!" {
+		return h.entries[len(h.entries)-1], nil
+	}
+	n, err := strconv.Atoi(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid history reference %q", ref)
+	}
+	if n < 1 || n > len(h.entries) {
+		return "", fmt.Errorf("no history entry %d", n)
+	}
+	return h.entries[n-1], nil
+}
+
+// push adds an entry, dropping the oldest beyond the size
+func (h *History) push(line string) {
+	size := h.Size
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > size {
+		h.entries = h.entries[len(h.entries)-size:]
+	}
+}
@@ -0,0 +1,149 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultMaxResultChars is the default length tool results are shortened
+	// to when rendered
+	DefaultMaxResultChars = 500
+
+	// DefaultLabel is the default label printed before the text of a response
+	DefaultLabel = "Claude: "
+)
+
+// Renderer prints streamed responses as they arrive
+type Renderer struct {
+	Out io.Writer
+
+	// HideThinking skips thinking blocks, HideTools skips tool calls and
+	// their results
+	HideThinking bool
+	HideTools    bool
+
+	// ShowUsage prints the token usage after every response
+	ShowUsage bool
+
+	// MaxResultChars shortens rendered tool results, defaults to
+	// DefaultMaxResultChars
+	MaxResultChars int
+
+	// Label is printed before the text of a response, defaults to
+	// DefaultLabel
+	Label string
+
+	// hidden is set while the current block is not rendered and labeled once
+	// the label of the current response is printed
+	hidden  bool
+	labeled bool
+}
+
+// NewRenderer creates a renderer writing to out
+func NewRenderer(out io.Writer) *Renderer {
+	return &Renderer{Out: out}
+}
+
+// Event prints a stream event
+func (r *Renderer) Event(event *streaming.Event) {
+	switch event.Type {
+	case streaming.MessageStartEvent:
+		r.labeled = false
+
+	case streaming.ContentBlockStartEvent:
+		block := event.ContentBlock
+		if block == nil {
+			return
+		}
+		r.hidden = false
+		switch {
+		case block.TextContent != nil && !r.labeled:
+			r.labeled = true
+			r.printf("\n%s", r.label())
+		case block.ThinkingContent != nil:
+			r.hidden = r.HideThinking
+			r.printf("\n[thinking] ")
+		case block.RedactedThinkingContent != nil:
+			r.hidden = r.HideThinking
+			r.printf("\n[redacted thinking]")
+		case block.ToolUseContent != nil:
+			r.hidden = r.HideTools
+			r.printf("\n[tool %s] ", block.ToolUseContent.Name)
+		}
+
+	case streaming.ContentBlockDeltaEvent:
+		if event.Delta == nil {
+			return
+		}
+		switch event.Delta.Type {
+		case "text_delta":
+			r.printf("%s", event.Delta.Text)
+		case "thinking_delta":
+			r.printf("%s", event.Delta.Thinking)
+		case "input_json_delta":
+			r.printf("%s", event.Delta.PartialJSON)
+		}
+
+	case streaming.ContentBlockStopEvent:
+		if !r.hidden {
+			r.printf("\n")
+		}
+		r.hidden = false
+	}
+}
+
+// End prints the end of a response
+func (r *Renderer) End(message *models.Message) {
+	if message.StopReason == models.MaxTokens {
+		fmt.Fprintln(r.Out, "[stopped: max_tokens reached]")
+	}
+	if r.ShowUsage {
+		fmt.Fprintf(r.Out, "[usage: %d input, %d output tokens]\n", message.Usage.TotalInputTokens(), message.Usage.OutputTokens)
+	}
+}
+
+// ToolResult prints the result of a tool call
+func (r *Renderer) ToolResult(call tools.Call, result models.ContentBlock) {
+	if r.HideTools || result.ToolResultContent == nil {
+		return
+	}
+	label := "result"
+	if result.ToolResultContent.IsError {
+		label = "error"
+	}
+	fmt.Fprintf(r.Out, "[%s %s] %s\n", call.Name, label, r.shorten(result.ToolResultContent.Content))
+}
+
+// label returns the label printed before response text
+func (r *Renderer) label() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return DefaultLabel
+}
+
+// printf writes unless the current block is hidden
+func (r *Renderer) printf(format string, args ...interface{}) {
+	if !r.hidden {
+		fmt.Fprintf(r.Out, format, args...)
+	}
+}
+
+// shorten limits a tool result to MaxResultChars
+func (r *Renderer) shorten(text string) string {
+	limit := r.MaxResultChars
+	if limit <= 0 {
+		limit = DefaultMaxResultChars
+	}
+	text = strings.TrimSpace(text)
+	if runes := []rune(text); len(runes) > limit {
+		return string(runes[:limit]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,229 @@
+// Package repl is an interactive chat loop for experimenting with the API from
+// a terminal. It streams responses, renders thinking and tool calls, runs
+// tools from a registry, keeps an input history and supports slash commands
+// for changing the model and sampling settings between turns
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultPrompt is the default input prompt
+	DefaultPrompt = "You: "
+
+	// DefaultMaxTokens is used when the request template sets no max_tokens
+	DefaultMaxTokens = 4096
+)
+
+// ErrExit is returned by commands to end Run
+var ErrExit = errors.New("repl: exit")
+
+// REPL is an interactive chat session
+type REPL struct {
+	Client tools.StreamProvider
+
+	// Request is the template of every request. Model, System, MaxTokens,
+	// Temperature and Thinking are changed by the slash commands
+	Request models.MessageRequest
+
+	// Registry holds the tools the model can call when set
+	Registry *tools.Registry
+
+	// MaxToolIterations limits the requests of a turn, defaults to
+	// tools.DefaultMaxIterations
+	MaxToolIterations int
+
+	In  io.Reader
+	Out io.Writer
+
+	// Prompt is printed before every input, defaults to DefaultPrompt
+	Prompt string
+
+	// History records the inputs when set
+	History *History
+
+	// Renderer prints the streamed responses, defaults to a Renderer writing
+	// to Out
+	Renderer *Renderer
+
+	// Messages is the conversation so far
+	Messages []models.MessageParam
+
+	commands map[string]Command
+}
+
+// New creates a session reading from stdin and writing to stdout with the
+// built-in commands
+func New(client tools.StreamProvider, req models.MessageRequest) *REPL {
+	r := &REPL{Client: client, Request: req, In: os.Stdin, Out: os.Stdout}
+	for _, command := range builtinCommands() {
+		r.Handle(command)
+	}
+	return r
+}
+
+// Run reads inputs until the input ends or a command returns ErrExit. Lines
+// starting with a slash run commands, "!!" repeats the last input and "!n"
+// the nth input of the history. Errors of a turn are printed and the session
+// continues
+func (r *REPL) Run(ctx context.Context) error {
+	scanner := bufio.NewScanner(r.In)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+
+	for {
+		fmt.Fprint(r.Out, r.prompt())
+		if !scanner.Scan() {
+			fmt.Fprintln(r.Out)
+			return scanner.Err()
+		}
+
+		line, err := r.expand(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			fmt.Fprintf(r.Out, "Error: %v\n", err)
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		if r.History != nil {
+			if err := r.History.Add(line); err != nil {
+				fmt.Fprintf(r.Out, "Error saving history: %v\n", err)
+			}
+		}
+
+		if strings.HasPrefix(line, "/") {
+			err = r.command(line)
+		} else {
+			err = r.Send(ctx, line)
+		}
+		if errors.Is(err, ErrExit) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(r.Out, "Error: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+// Send sends a user message and streams the response, running the tools it
+// calls until the model answers without tools
+func (r *REPL) Send(ctx context.Context, text string) error {
+	req := r.Request
+	req.Stream = true
+	if r.Registry != nil && len(req.Tools) == 0 {
+		req.Tools = r.Registry.Definitions()
+	}
+	if req.MaxTokens <= 0 {
+		req.MaxTokens = DefaultMaxTokens
+	}
+
+	messages := append(append([]models.MessageParam(nil), r.Messages...), models.NewUserMessage(models.CreateTextBlock(text)))
+	renderer := r.renderer()
+
+	for i := 0; i < r.maxToolIterations(); i++ {
+		req.Messages = messages
+		stream, err := r.Client.CreateMessageStream(ctx, req)
+		if err != nil {
+			return err
+		}
+		for stream.Next() {
+			renderer.Event(stream.Current())
+		}
+		if err := stream.Err(); err != nil {
+			return err
+		}
+
+		message := stream.Message()
+		renderer.End(message)
+		messages = append(messages, message.ToParam())
+
+		var results []models.ContentBlock
+		for _, block := range message.Content {
+			if block.ToolUseContent == nil || r.Registry == nil {
+				continue
+			}
+			call, err := tools.CallFor(block.ToolUseContent)
+			if err != nil {
+				return err
+			}
+			result := r.Registry.Execute(ctx, call)
+			renderer.ToolResult(call, result)
+			results = append(results, result)
+		}
+
+		if message.StopReason != models.ToolUse || len(results) == 0 {
+			r.Messages = messages
+			return nil
+		}
+		messages = append(messages, models.NewUserMessage(results...))
+	}
+
+	return tools.ErrMaxTurnsExceeded
+}
+
+// expand replaces history references with the inputs they refer to. Other
+// lines starting with "!" are sent as they are
+func (r *REPL) expand(line string) (string, error) {
+	if r.History == nil || !isHistoryRef(line) {
+		return line, nil
+	}
+	entry, err := r.History.Lookup(line[1:])
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(r.Out, entry)
+	return entry, nil
+}
+
+// prompt returns the input prompt
+func (r *REPL) prompt() string {
+	if r.Prompt != "" {
+		return r.Prompt
+	}
+	return DefaultPrompt
+}
+
+// renderer returns the renderer of the session
+func (r *REPL) renderer() *Renderer {
+	if r.Renderer == nil {
+		r.Renderer = NewRenderer(r.Out)
+	}
+	return r.Renderer
+}
+
+// maxToolIterations returns the maximum number of requests of a turn
+func (r *REPL) maxToolIterations() int {
+	if r.MaxToolIterations > 0 {
+		return r.MaxToolIterations
+	}
+	return tools.DefaultMaxIterations
+}
+
+// isHistoryRef reports whether the line is "!!" or "!n"
+func isHistoryRef(line string) bool {
+	if line == "!!" {
+		return true
+	}
+	if len(line) < 2 || line[0] != '!' {
+		return false
+	}
+	for _, c := range line[1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/joakimcarlsson/anthropic-sdk/timeline"
+)
+
+// RequestOption is a function that modifies a single request
+type RequestOption func(*requestConfig)
+
+// requestConfig holds the per-request overrides of the client settings
+type requestConfig struct {
+	version  string
+	headers  http.Header
+	limiters []*ConcurrencyLimiter
+	timeline *timeline.Timeline
+}
+
+// requestOptionsKey is the context key for request options
+type requestOptionsKey struct{}
+
+// WithRequestVersion overrides the anthropic-version header for a request
+func WithRequestVersion(version string) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.version = version
+	}
+}
+
+// WithRequestHeader adds a header to a request
+func WithRequestHeader(key, value string) RequestOption {
+	return func(cfg *requestConfig) {
+		if cfg.headers == nil {
+			cfg.headers = make(http.Header)
+		}
+		cfg.headers.Add(key, value)
+	}
+}
+
+// ContextWithRequestOptions returns a context carrying request options that are
+// applied to every request made with it, before any options passed to the call
+func ContextWithRequestOptions(ctx context.Context, options ...RequestOption) context.Context {
+	existing, _ := ctx.Value(requestOptionsKey{}).([]RequestOption)
+	combined := make([]RequestOption, 0, len(existing)+len(options))
+	combined = append(combined, existing...)
+	combined = append(combined, options...)
+	return context.WithValue(ctx, requestOptionsKey{}, combined)
+}
+
+// newRequestConfig applies the context and call options to a request config
+func newRequestConfig(ctx context.Context, options []RequestOption) *requestConfig {
+	cfg := &requestConfig{}
+
+	if contextOptions, ok := ctx.Value(requestOptionsKey{}).([]RequestOption); ok {
+		for _, option := range contextOptions {
+			option(cfg)
+		}
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+
+	return cfg
+}
@@ -0,0 +1,332 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/backoff"
+)
+
+// RetryPolicy decides whether and when the client retries failed requests. It
+// is consulted for unary requests and for establishing streaming connections.
+// StandardRetryPolicy covers the common cases, and custom strategies can
+// implement the interface
+type RetryPolicy interface {
+	// ShouldRetry is called after every failed attempt, numbered from one. For
+	// error responses resp has its body already read and err is the *APIError
+	// parsed from it, for requests that failed without a response resp is nil.
+	// It returns the delay before the next attempt and whether to make one
+	ShouldRetry(attempt int, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// StandardRetryPolicy is the built-in RetryPolicy, retrying by status code,
+// API error type and network errors with exponential backoff
+type StandardRetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first one
+	MaxAttempts int
+
+	// MaxElapsedTime bounds the total time spent on a request including retry
+	// delays, zero means no limit
+	MaxElapsedTime time.Duration
+
+	// InitialBackoff is the base delay before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+
+	// Backoff computes the delay between attempts, defaults to full jitter
+	// exponential backoff based on InitialBackoff and MaxBackoff
+	Backoff backoff.Strategy
+
+	// HonorRetryAfter uses the retry-after header returned by the API as the
+	// delay before the next attempt when present
+	HonorRetryAfter bool
+
+	// HonorRateLimitReset waits for rate limited requests until the exhausted
+	// limit resets, as reported by the anthropic-ratelimit-*-reset headers,
+	// so the retry lands right after the quota is replenished. It takes
+	// precedence over retry-after, which is rounded to whole seconds
+	HonorRateLimitReset bool
+
+	// RetryNetworkErrors retries requests that failed without a response
+	RetryNetworkErrors bool
+
+	// StatusRules lists the HTTP status codes that are retried
+	StatusRules map[int]RetryRule
+
+	// ErrorTypes lists the API error types that are retried regardless of the
+	// status code they are returned with
+	ErrorTypes map[string]RetryRule
+
+	// OnRetry is called before waiting for each retry, in addition to the
+	// client's OnRetry
+	OnRetry func(RetryEvent)
+}
+
+// RetryRule configures retries for a single HTTP status code
+type RetryRule struct {
+	// MaxAttempts overrides the policy's MaxAttempts for this status when set
+	MaxAttempts int
+}
+
+// RetryEvent describes a retry that is about to happen
+type RetryEvent struct {
+	Attempt    int
+	StatusCode int
+	Delay      time.Duration
+	Err        error
+}
+
+// DefaultRetryPolicy returns a retry policy that retries rate limits, server
+// errors, overloaded errors and network errors up to three attempts,
+// classifying error responses by their status code and API error type
+func DefaultRetryPolicy() *StandardRetryPolicy {
+	return &StandardRetryPolicy{
+		MaxAttempts:         3,
+		InitialBackoff:      500 * time.Millisecond,
+		MaxBackoff:          8 * time.Second,
+		HonorRetryAfter:     true,
+		HonorRateLimitReset: true,
+		RetryNetworkErrors:  true,
+		StatusRules: map[int]RetryRule{
+			http.StatusRequestTimeout:      {},
+			http.StatusConflict:            {},
+			http.StatusTooManyRequests:     {},
+			http.StatusInternalServerError: {},
+			http.StatusBadGateway:          {},
+			http.StatusServiceUnavailable:  {},
+			http.StatusGatewayTimeout:      {},
+			529:                            {},
+		},
+		ErrorTypes: map[string]RetryRule{
+			"rate_limit_error": {},
+			"overloaded_error": {},
+			"api_error":        {},
+			"internal_error":   {},
+		},
+	}
+}
+
+// WithRetryCallback sets a callback called before waiting for each retry,
+// whatever the retry policy of the client
+func WithRetryCallback(fn func(RetryEvent)) ClientOption {
+	return func(c *Client) {
+		c.OnRetry = fn
+	}
+}
+
+// WithMaxRetryElapsedTime bounds the total time spent on a request including
+// retry delays, whatever the retry policy of the client. A retry whose delay
+// would exceed it is not made
+func WithMaxRetryElapsedTime(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.MaxRetryElapsedTime = d
+	}
+}
+
+// WithMaxRetries enables retries of rate limits, server errors, overloaded
+// errors and network errors, retrying a failed request up to n times. It
+// starts from the client's *StandardRetryPolicy, or DefaultRetryPolicy when
+// the client has none or a custom policy, and zero disables retries
+func WithMaxRetries(n int) ClientOption {
+	return func(c *Client) {
+		c.editRetryPolicy().MaxAttempts = max(n, 0) + 1
+	}
+}
+
+// WithRetryBackoff enables retries like WithMaxRetries and sets the base delay
+// before the first retry and the cap of the delay between attempts. Delays
+// grow exponentially with full jitter, unless the API asks for a delay with
+// the retry-after header
+func WithRetryBackoff(initial, max time.Duration) ClientOption {
+	return func(c *Client) {
+		policy := c.editRetryPolicy()
+		policy.InitialBackoff = initial
+		policy.MaxBackoff = max
+	}
+}
+
+// editRetryPolicy replaces the client's retry policy with a copy that can be
+// modified without affecting other clients, starting from DefaultRetryPolicy
+// when the client has no *StandardRetryPolicy
+func (c *Client) editRetryPolicy() *StandardRetryPolicy {
+	policy := DefaultRetryPolicy()
+	if standard, ok := c.RetryPolicy.(*StandardRetryPolicy); ok && standard != nil {
+		copied := *standard
+		policy = &copied
+	}
+	c.RetryPolicy = policy
+	return policy
+}
+
+// ShouldRetry implements the RetryPolicy interface
+func (p *StandardRetryPolicy) ShouldRetry(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	return p.retry(attempt, 0, resp, err)
+}
+
+// retry decides whether to retry a failed attempt, given the delay before the
+// attempt for backoff strategies that depend on it
+func (p *StandardRetryPolicy) retry(attempt int, previous time.Duration, resp *http.Response, err error) (time.Duration, bool) {
+	if resp == nil {
+		if !p.shouldRetryError(attempt) {
+			return 0, false
+		}
+		return p.delay(attempt, previous, 0), true
+	}
+
+	if !p.shouldRetryStatus(attempt, resp.StatusCode) && !p.shouldRetryType(attempt, err) {
+		return 0, false
+	}
+	return p.delay(attempt, previous, p.serverDelay(resp)), true
+}
+
+// shouldRetry asks the policy whether to retry a failed attempt, nil never
+// retries
+func shouldRetry(policy RetryPolicy, attempt int, previous time.Duration, resp *http.Response, err error) (time.Duration, bool) {
+	switch p := policy.(type) {
+	case nil:
+		return 0, false
+	case *StandardRetryPolicy:
+		return p.retry(attempt, previous, resp, err)
+	}
+	return policy.ShouldRetry(attempt, resp, err)
+}
+
+// waitRetry sleeps before the next attempt. It returns an error if the elapsed
+// time budget of the client or of a *StandardRetryPolicy would be exceeded, if
+// a retry callback panics or if the context is done
+func (c *Client) waitRetry(ctx context.Context, policy RetryPolicy, start time.Time, event RetryEvent) error {
+	limit := c.MaxRetryElapsedTime
+	callbacks := []func(RetryEvent){c.OnRetry}
+	if standard, ok := policy.(*StandardRetryPolicy); ok {
+		if standard.MaxElapsedTime > 0 && (limit <= 0 || standard.MaxElapsedTime < limit) {
+			limit = standard.MaxElapsedTime
+		}
+		callbacks = append(callbacks, standard.OnRetry)
+	}
+	if limit > 0 && time.Since(start)+event.Delay > limit {
+		return context.DeadlineExceeded
+	}
+
+	for _, callback := range callbacks {
+		if callback == nil {
+			continue
+		}
+		if err := c.guard().Notify("retry callback", func() { callback(event) }); err != nil {
+			return err
+		}
+	}
+
+	return backoff.Sleep(ctx, event.Delay)
+}
+
+// shouldRetryError reports whether a request that failed without a response
+// should be retried
+func (p *StandardRetryPolicy) shouldRetryError(attempt int) bool {
+	if p == nil || !p.RetryNetworkErrors {
+		return false
+	}
+	return attempt < p.MaxAttempts
+}
+
+// shouldRetryStatus reports whether a response with the given status code
+// should be retried
+func (p *StandardRetryPolicy) shouldRetryStatus(attempt, statusCode int) bool {
+	if p == nil {
+		return false
+	}
+
+	rule, ok := p.StatusRules[statusCode]
+	if !ok {
+		return false
+	}
+
+	maxAttempts := p.MaxAttempts
+	if rule.MaxAttempts > 0 {
+		maxAttempts = rule.MaxAttempts
+	}
+	return attempt < maxAttempts
+}
+
+// shouldRetryType reports whether an error response should be retried based
+// on its API error type
+func (p *StandardRetryPolicy) shouldRetryType(attempt int, err error) bool {
+	if p == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	rule, ok := p.ErrorTypes[apiErr.Type]
+	if !ok {
+		return false
+	}
+
+	maxAttempts := p.MaxAttempts
+	if rule.MaxAttempts > 0 {
+		maxAttempts = rule.MaxAttempts
+	}
+	return attempt < maxAttempts
+}
+
+// serverDelay returns the delay requested by the API for a failed response,
+// zero when it requests none or the policy ignores it
+func (p *StandardRetryPolicy) serverDelay(resp *http.Response) time.Duration {
+	if p.HonorRateLimitReset && resp.StatusCode == http.StatusTooManyRequests {
+		if delay := ParseRateLimits(resp.Header).ResetDelay(); delay > 0 {
+			return delay
+		}
+	}
+	if p.HonorRetryAfter {
+		return retryAfter(resp.Header)
+	}
+	return 0
+}
+
+// delay returns the delay before the next attempt, which is the delay
+// requested by the server when there is one
+func (p *StandardRetryPolicy) delay(attempt int, previous, serverDelay time.Duration) time.Duration {
+	if serverDelay > 0 {
+		return serverDelay
+	}
+
+	strategy := p.Backoff
+	if strategy == nil {
+		strategy = backoff.FullJitter{Base: p.InitialBackoff, Max: p.MaxBackoff}
+	}
+	return strategy.Next(attempt, previous)
+}
+
+// retryAfter parses the retry-after header as seconds or an HTTP date
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("retry-after")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+
+	return 0
+}
+
+// retryError returns the error of a request that is not retried after all:
+// a panic of the retry callback, otherwise the error of the last attempt
+func retryError(err, waitErr error) error {
+	var panicErr *PanicError
+	if errors.As(waitErr, &panicErr) {
+		return waitErr
+	}
+	return err
+}
@@ -0,0 +1,24 @@
+package anthropic
+
+import (
+	"context"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// WithSamplingProfile applies a sampling profile to every message request that
+// does not set its own sampling parameters
+func WithSamplingProfile(profile models.SamplingProfile) ClientOption {
+	return WithMiddleware(func(ctx context.Context, req *models.MessageRequest) error {
+		profile.Apply(req)
+		return nil
+	})
+}
+
+// WithSamplingValidation rejects message requests with invalid sampling
+// parameters, including requests that set both temperature and top_p
+func WithSamplingValidation() ClientOption {
+	return WithMiddleware(func(ctx context.Context, req *models.MessageRequest) error {
+		return models.ValidateSampling(req)
+	})
+}
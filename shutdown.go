@@ -0,0 +1,113 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrClientShutdown is returned for requests made after Shutdown was called
+var ErrClientShutdown = errors.New("client is shut down")
+
+// WorkTracker is implemented by Client. Code running work that spans several
+// requests, such as a tool loop, registers it with BeginWork so that a
+// shutdown waits for it to finish
+type WorkTracker interface {
+	BeginWork(ctx context.Context) (context.Context, func(), error)
+}
+
+var _ WorkTracker = (*Client)(nil)
+
+// lifecycle tracks the work in flight on a client and its clones
+type lifecycle struct {
+	mu      sync.Mutex
+	closed  bool
+	active  int
+	drained chan struct{}
+}
+
+// workKey is the context key marking work registered with a lifecycle
+type workKey struct{}
+
+// begin registers work, failing once the lifecycle is closed unless the
+// context belongs to work registered before
+func (l *lifecycle) begin(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed && ctx.Value(workKey{}) != l {
+		return nil, ErrClientShutdown
+	}
+	l.active++
+
+	var once sync.Once
+	return func() {
+		once.Do(l.end)
+	}, nil
+}
+
+// end finishes work, signalling a waiting shutdown when it was the last
+func (l *lifecycle) end() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.active--
+	if l.active == 0 && l.drained != nil {
+		close(l.drained)
+		l.drained = nil
+	}
+}
+
+// shutdown closes the lifecycle and waits for the work in flight
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	l.closed = true
+	if l.active == 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	if l.drained == nil {
+		l.drained = make(chan struct{})
+	}
+	drained := l.drained
+	l.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error waiting for requests in flight: %w", ctx.Err())
+	}
+}
+
+// BeginWork registers work spanning several requests, returning a context for
+// its requests and a function to call once the work is done. Shutdown waits
+// for the work, and its requests are still accepted after Shutdown was called
+func (c *Client) BeginWork(ctx context.Context) (context.Context, func(), error) {
+	if c.lifecycle == nil {
+		return ctx, func() {}, nil
+	}
+
+	done, err := c.lifecycle.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return context.WithValue(ctx, workKey{}, c.lifecycle), done, nil
+}
+
+// Shutdown stops the client and the clients cloned from it from accepting new
+// requests, waits for the requests, streams and work registered with
+// BeginWork in flight, and closes idle connections. It returns an error when
+// the context ends first. Clients not created with NewClient only close their
+// idle connections
+func (c *Client) Shutdown(ctx context.Context) error {
+	var err error
+	if c.lifecycle != nil {
+		err = c.lifecycle.shutdown(ctx)
+	}
+	if c.HTTPClient != nil {
+		c.HTTPClient.CloseIdleConnections()
+	}
+	return err
+}
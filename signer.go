@@ -0,0 +1,38 @@
+package anthropic
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+)
+
+// RequestSigner signs a request before it is sent, for gateways that verify
+// request signatures. It is called for every attempt with the marshaled body,
+// after all other headers are set
+type RequestSigner func(req *http.Request, body []byte) error
+
+// WithRequestSigner sets the signer called for every request
+func WithRequestSigner(signer RequestSigner) ClientOption {
+	return func(c *Client) {
+		c.Signer = signer
+	}
+}
+
+// HMACSigner returns a signer that sets header to the hex encoded HMAC-SHA256
+// of the request body
+func HMACSigner(header string, key []byte) RequestSigner {
+	return HMACSignerWithHash(header, key, sha256.New)
+}
+
+// HMACSignerWithHash returns a signer that sets header to the hex encoded HMAC
+// of the request body using the given hash
+func HMACSignerWithHash(header string, key []byte, h func() hash.Hash) RequestSigner {
+	return func(req *http.Request, body []byte) error {
+		mac := hmac.New(h, key)
+		mac.Write(body)
+		req.Header.Set(header, hex.EncodeToString(mac.Sum(nil)))
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrorStatusMap translates Anthropic error types to the HTTP status code a
+// service wrapping the SDK should return to its own clients. Errors caused by
+// the upstream API or its credentials are reported as gateway errors, since
+// they are not the fault of the downstream caller
+var ErrorStatusMap = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusBadGateway,
+	"permission_error":      http.StatusBadGateway,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusBadGateway,
+	"internal_error":        http.StatusBadGateway,
+	"overloaded_error":      http.StatusServiceUnavailable,
+}
+
+// HTTPStatusFor returns the HTTP status code a proxy should return for an
+// error produced by the SDK
+func HTTPStatusFor(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if status, ok := ErrorStatusMap[apiErr.Type]; ok {
+			return status
+		}
+		if apiErr.StatusCode >= 400 && apiErr.StatusCode < 500 {
+			return apiErr.StatusCode
+		}
+		return http.StatusBadGateway
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		// Non-standard status used by proxies when the client closed the request
+		return 499
+	}
+
+	return http.StatusBadGateway
+}
+
+// WriteHTTPError writes an SDK error as a JSON HTTP response, translating the
+// status code with HTTPStatusFor. Errors other than *APIError are written with
+// a generic message, since they may carry internal details such as hostnames
+// or file paths. Nothing is written for a nil error
+func WriteHTTPError(w http.ResponseWriter, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = &APIError{
+			Type:    "api_error",
+			Message: "internal error",
+		}
+	}
+
+	translated := *apiErr
+	translated.StatusCode = HTTPStatusFor(err)
+
+	return translated.WriteHTTPResponse(w)
+}
@@ -0,0 +1,172 @@
+package streaming
+
+import (
+	"context"
+	"sync"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Broadcaster lets several subscribers follow one in-flight stream, for
+// example viewers watching the same generation. Every event is kept in a
+// replay buffer, so subscribers attaching late still receive all events from
+// the beginning. The source is consumed by the broadcaster and must not be
+// read by anyone else; it must not use pooling
+type Broadcaster struct {
+	mu      sync.Mutex
+	events  []*Event
+	notify  chan struct{}
+	done    bool
+	err     error
+	message *models.Message
+}
+
+// NewBroadcaster starts consuming source and returns the broadcaster
+func NewBroadcaster(source EventStream) *Broadcaster {
+	b := &Broadcaster{notify: make(chan struct{})}
+	go b.run(source)
+	return b
+}
+
+// run reads the source until it ends
+func (b *Broadcaster) run(source EventStream) {
+	for source.Next() {
+		event := *source.Current()
+		if event.Delta != nil {
+			delta := *event.Delta
+			event.Delta = &delta
+		}
+
+		b.mu.Lock()
+		b.events = append(b.events, &event)
+		b.wake()
+		b.mu.Unlock()
+	}
+
+	b.mu.Lock()
+	b.done = true
+	b.err = source.Err()
+	b.message = source.Message()
+	b.wake()
+	b.mu.Unlock()
+}
+
+// wake notifies waiting subscribers, it must be called with mu held
+func (b *Broadcaster) wake() {
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// Subscribe returns a stream of all events of the source, starting with the
+// first one. Next returns false when ctx is done
+func (b *Broadcaster) Subscribe(ctx context.Context) *Subscription {
+	return b.SubscribeFrom(ctx, 0)
+}
+
+// SubscribeFrom returns a stream of the events of the source starting after
+// the first offset events, for subscribers that already received them. The
+// accumulated message still includes the skipped events
+func (b *Broadcaster) SubscribeFrom(ctx context.Context, offset int) *Subscription {
+	s := &Subscription{
+		broadcaster: b,
+		ctx:         ctx,
+		accumulator: NewMessageStream(nil),
+	}
+
+	b.mu.Lock()
+	skipped := b.events[:min(max(offset, 0), len(b.events))]
+	b.mu.Unlock()
+
+	for _, event := range skipped {
+		s.accumulator.updateMessage(event)
+	}
+	s.next = len(skipped)
+	return s
+}
+
+// Wait blocks until the source ends and returns its error
+func (b *Broadcaster) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		done, err, notify := b.done, b.err, b.notify
+		b.mu.Unlock()
+
+		if done {
+			return err
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Subscription is a subscriber's view of a broadcast stream
+type Subscription struct {
+	broadcaster *Broadcaster
+	ctx         context.Context
+	accumulator *MessageStream
+	next        int
+	current     *Event
+	err         error
+}
+
+var _ EventStream = (*Subscription)(nil)
+
+// Next advances to the next event, waiting for the source if needed
+func (s *Subscription) Next() bool {
+	if s.err != nil {
+		return false
+	}
+
+	for {
+		b := s.broadcaster
+		b.mu.Lock()
+		if s.next < len(b.events) {
+			s.current = b.events[s.next]
+			s.next++
+			b.mu.Unlock()
+
+			s.accumulator.updateMessage(s.current)
+			return true
+		}
+		done, err, notify := b.done, b.err, b.notify
+		b.mu.Unlock()
+
+		if done {
+			s.err = err
+			return false
+		}
+
+		select {
+		case <-notify:
+		case <-s.ctx.Done():
+			s.err = s.ctx.Err()
+			return false
+		}
+	}
+}
+
+// Offset returns the number of events received so far, including skipped
+// ones. A subscriber reconnecting with SubscribeFrom passes it as the offset
+func (s *Subscription) Offset() int {
+	return s.next
+}
+
+// Current returns the current event, which is shared with other subscribers
+// and must not be modified
+func (s *Subscription) Current() *Event {
+	return s.current
+}
+
+// Err returns the error of the source, or the context error if the
+// subscription was canceled
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Message returns the message accumulated from the events received so far
+func (s *Subscription) Message() *models.Message {
+	return s.accumulator.message
+}
@@ -0,0 +1,226 @@
+package streaming
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Boundary defines where streamed text may be split
+type Boundary int
+
+const (
+	// WordBoundary splits text after whitespace
+	WordBoundary Boundary = iota
+
+	// SentenceBoundary splits text after sentence-ending punctuation followed
+	// by whitespace, or after a blank line
+	SentenceBoundary
+)
+
+// TextChunker buffers text and returns it in chunks that end at a boundary
+type TextChunker struct {
+	boundary Boundary
+	buffer   strings.Builder
+}
+
+// NewTextChunker creates a new text chunker
+func NewTextChunker(boundary Boundary) *TextChunker {
+	return &TextChunker{boundary: boundary}
+}
+
+// Write adds text to the buffer and returns the complete chunks
+func (c *TextChunker) Write(text string) []string {
+	c.buffer.WriteString(text)
+	buffered := c.buffer.String()
+
+	var chunks []string
+	for {
+		end := nextBoundary(buffered, c.boundary)
+		if end <= 0 {
+			break
+		}
+		chunks = append(chunks, buffered[:end])
+		buffered = buffered[end:]
+	}
+
+	if len(chunks) > 0 {
+		c.buffer.Reset()
+		c.buffer.WriteString(buffered)
+	}
+	return chunks
+}
+
+// Flush returns and clears any buffered text
+func (c *TextChunker) Flush() string {
+	text := c.buffer.String()
+	c.buffer.Reset()
+	return text
+}
+
+// nextBoundary returns the end of the first complete chunk in text, including
+// the whitespace following the boundary, or -1 if there is none
+func nextBoundary(text string, boundary Boundary) int {
+	for i, r := range text {
+		if !unicode.IsSpace(r) {
+			continue
+		}
+
+		if boundary == WordBoundary {
+			if i == 0 {
+				continue
+			}
+			return skipSpace(text, i)
+		}
+
+		if i > 0 && isSentenceEnd(text[:i]) || r == '\n' && strings.HasSuffix(text[:i], "\n") {
+			end := skipSpace(text, i)
+			if end == len(text) {
+				// Wait for the next delta so the whitespace run stays with this chunk
+				return -1
+			}
+			return end
+		}
+	}
+	return -1
+}
+
+// isSentenceEnd reports whether text ends with sentence-ending punctuation,
+// optionally followed by closing quotes or brackets
+func isSentenceEnd(text string) bool {
+	text = strings.TrimRight(text, "\"')]”’")
+	r, _ := utf8.DecodeLastRuneInString(text)
+	switch r {
+	case '.', '!', '?', '…', '。', '！', '？':
+		return true
+	}
+	return false
+}
+
+// skipSpace returns the index of the first non-whitespace rune at or after i
+func skipSpace(text string, i int) int {
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// ChunkedStream buffers text deltas and emits them only at word or sentence
+// boundaries. Remaining text is emitted before the content block stops
+type ChunkedStream struct {
+	source   EventStream
+	boundary Boundary
+	chunkers map[int]*TextChunker
+	queue    []*Event
+	current  *Event
+}
+
+// NewChunkedStream creates a stream that splits the text deltas of source at
+// the given boundary
+func NewChunkedStream(source EventStream, boundary Boundary) *ChunkedStream {
+	return &ChunkedStream{
+		source:   source,
+		boundary: boundary,
+		chunkers: make(map[int]*TextChunker),
+	}
+}
+
+// Next advances the stream to the next event
+func (s *ChunkedStream) Next() bool {
+	for len(s.queue) == 0 {
+		if !s.source.Next() {
+			s.flushAll()
+			if len(s.queue) == 0 {
+				return false
+			}
+			break
+		}
+		s.process(s.source.Current())
+	}
+
+	s.current, s.queue = s.queue[0], s.queue[1:]
+	return true
+}
+
+// Current returns the current event
+func (s *ChunkedStream) Current() *Event {
+	return s.current
+}
+
+// Err returns any error that occurred during streaming
+func (s *ChunkedStream) Err() error {
+	return s.source.Err()
+}
+
+// Message returns the accumulated message
+func (s *ChunkedStream) Message() *models.Message {
+	return s.source.Message()
+}
+
+// process queues the events produced by a source event
+func (s *ChunkedStream) process(event *Event) {
+	if event.Type == ContentBlockDeltaEvent && event.Index != nil && event.Delta != nil && event.Delta.Type == "text_delta" {
+		chunker, ok := s.chunkers[*event.Index]
+		if !ok {
+			chunker = NewTextChunker(s.boundary)
+			s.chunkers[*event.Index] = chunker
+		}
+		for _, chunk := range chunker.Write(event.Delta.Text) {
+			s.queue = append(s.queue, textDeltaEvent(*event.Index, chunk))
+		}
+		return
+	}
+
+	if event.Type == ContentBlockStopEvent && event.Index != nil {
+		s.flush(*event.Index)
+	} else if event.Type == MessageDeltaEvent || event.Type == MessageStopEvent {
+		s.flushAll()
+	}
+	s.queue = append(s.queue, event)
+}
+
+// flush queues the remaining text of a content block
+func (s *ChunkedStream) flush(index int) {
+	chunker, ok := s.chunkers[index]
+	if !ok {
+		return
+	}
+	delete(s.chunkers, index)
+
+	if text := chunker.Flush(); text != "" {
+		s.queue = append(s.queue, textDeltaEvent(index, text))
+	}
+}
+
+// flushAll queues the remaining text of all content blocks
+func (s *ChunkedStream) flushAll() {
+	for _, index := range sortedKeys(s.chunkers) {
+		s.flush(index)
+	}
+}
+
+// sortedKeys returns the keys of a map indexed by content block in order
+func sortedKeys[T any](m map[int]T) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// textDeltaEvent creates a text delta event
+func textDeltaEvent(index int, text string) *Event {
+	return &Event{
+		Type:  ContentBlockDeltaEvent,
+		Index: &index,
+		Delta: &Delta{Type: "text_delta", Text: text},
+	}
+}
@@ -0,0 +1,140 @@
+package streaming
+
+import (
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// CoalesceOptions configures how deltas are coalesced
+type CoalesceOptions struct {
+	// Interval is the minimum time between two emitted deltas of a block
+	Interval time.Duration
+
+	// MaxBytes flushes the buffered delta once it reaches this size
+	MaxBytes int
+}
+
+// CoalescingStream merges consecutive text and thinking deltas of the same
+// content block, emitting them at most once per interval or when the buffered
+// text reaches MaxBytes. Other events are passed through unchanged, after any
+// buffered delta, so the accumulated message is identical to the source's
+type CoalescingStream struct {
+	source    EventStream
+	options   CoalesceOptions
+	pending   *Event
+	queued    *Event
+	current   *Event
+	lastFlush time.Time
+}
+
+// NewCoalescingStream creates a stream that coalesces the deltas of source
+func NewCoalescingStream(source EventStream, options CoalesceOptions) *CoalescingStream {
+	return &CoalescingStream{
+		source:    source,
+		options:   options,
+		lastFlush: time.Now(),
+	}
+}
+
+// Next advances the stream to the next event
+func (s *CoalescingStream) Next() bool {
+	if s.queued != nil {
+		s.current, s.queued = s.queued, nil
+		return true
+	}
+
+	for s.source.Next() {
+		event := s.source.Current()
+
+		if !isCoalescable(event) {
+			if s.pending != nil {
+				s.queued = event
+				s.flush()
+				return true
+			}
+			s.current = event
+			return true
+		}
+
+		if s.pending != nil && !sameDeltaTarget(s.pending, event) {
+			s.flush()
+			s.pending = copyDeltaEvent(event)
+			return true
+		}
+
+		if s.pending == nil {
+			s.pending = copyDeltaEvent(event)
+		} else {
+			s.pending.Delta.Text += event.Delta.Text
+			s.pending.Delta.Thinking += event.Delta.Thinking
+		}
+
+		if s.shouldFlush() {
+			s.flush()
+			return true
+		}
+	}
+
+	if s.pending != nil {
+		s.flush()
+		return true
+	}
+	return false
+}
+
+// Current returns the current event
+func (s *CoalescingStream) Current() *Event {
+	return s.current
+}
+
+// Err returns any error that occurred during streaming
+func (s *CoalescingStream) Err() error {
+	return s.source.Err()
+}
+
+// Message returns the accumulated message
+func (s *CoalescingStream) Message() *models.Message {
+	return s.source.Message()
+}
+
+// shouldFlush reports whether the buffered delta should be emitted
+func (s *CoalescingStream) shouldFlush() bool {
+	if s.options.Interval <= 0 && s.options.MaxBytes <= 0 {
+		return true
+	}
+	if s.options.MaxBytes > 0 && len(s.pending.Delta.Text)+len(s.pending.Delta.Thinking) >= s.options.MaxBytes {
+		return true
+	}
+	return s.options.Interval > 0 && time.Since(s.lastFlush) >= s.options.Interval
+}
+
+// flush makes the buffered delta the current event
+func (s *CoalescingStream) flush() {
+	s.current, s.pending = s.pending, nil
+	s.lastFlush = time.Now()
+}
+
+// isCoalescable reports whether the event is a text or thinking delta
+func isCoalescable(event *Event) bool {
+	if event.Type != ContentBlockDeltaEvent || event.Delta == nil || event.Index == nil {
+		return false
+	}
+	return event.Delta.Type == "text_delta" || event.Delta.Type == "thinking_delta"
+}
+
+// sameDeltaTarget reports whether two deltas apply to the same block and field
+func sameDeltaTarget(a, b *Event) bool {
+	return *a.Index == *b.Index && a.Delta.Type == b.Delta.Type
+}
+
+// copyDeltaEvent copies a delta event so it can be modified while buffered
+func copyDeltaEvent(event *Event) *Event {
+	index := *event.Index
+	delta := *event.Delta
+	return &Event{
+		Type:  event.Type,
+		Index: &index,
+		Delta: &delta,
+	}
+}
@@ -0,0 +1,192 @@
+package streaming
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MarkdownTracker tracks open markdown constructs (code fences, inline code,
+// bold, italic and strikethrough) across streamed deltas, so renderers can
+// avoid displaying half-finished formatting
+type MarkdownTracker struct {
+	text      strings.Builder
+	scanned   int
+	safe      int
+	lineStart bool
+	fence     string
+	stack     []string
+}
+
+// NewMarkdownTracker creates a new markdown tracker
+func NewMarkdownTracker() *MarkdownTracker {
+	return &MarkdownTracker{lineStart: true}
+}
+
+// Write adds a text delta
+func (m *MarkdownTracker) Write(delta string) {
+	m.text.WriteString(delta)
+	m.scan()
+}
+
+// Text returns all text written so far
+func (m *MarkdownTracker) Text() string {
+	return m.text.String()
+}
+
+// SafePrefix returns the longest prefix of the text in which every markdown
+// construct that was opened has also been closed
+func (m *MarkdownTracker) SafePrefix() string {
+	return m.text.String()[:m.safe]
+}
+
+// Open returns the markers of the constructs that are currently open, from
+// outermost to innermost
+func (m *MarkdownTracker) Open() []string {
+	var open []string
+	if m.fence != "" {
+		open = append(open, m.fence)
+	}
+	return append(open, m.stack...)
+}
+
+// Render returns the text with synthetic closing markers appended for every
+// open construct, so it can be rendered without broken formatting
+func (m *MarkdownTracker) Render() string {
+	var b strings.Builder
+	b.WriteString(m.text.String())
+
+	if m.fence != "" {
+		if !strings.HasSuffix(b.String(), "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(m.fence)
+		return b.String()
+	}
+
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		b.WriteString(m.stack[i])
+	}
+	return b.String()
+}
+
+// scan processes the text that has not been scanned yet. Scanning stops before
+// a marker that could still be extended by the next delta
+func (m *MarkdownTracker) scan() {
+	text := m.text.String()
+
+	for m.scanned < len(text) {
+		i := m.scanned
+		rest := text[i:]
+
+		if m.lineStart {
+			trimmed := strings.TrimLeft(rest, " ")
+			if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") || (m.fence != "" && len(trimmed) < 3 && strings.HasPrefix(m.fence, trimmed)) {
+				end := strings.IndexByte(rest, '\n')
+				if end < 0 {
+					return
+				}
+				marker := strings.TrimSpace(rest[:end])[:3]
+				if m.fence == "" && len(m.stack) == 0 {
+					m.fence = marker
+				} else if m.fence == marker {
+					m.fence = ""
+				}
+				m.advance(end + 1)
+				continue
+			}
+		}
+
+		if m.fence != "" {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				m.scanned = len(text)
+				m.lineStart = false
+				return
+			}
+			m.advance(end + 1)
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(rest)
+		if r != '*' && r != '_' && r != '`' && r != '~' {
+			m.advance(size)
+			continue
+		}
+
+		run := 1
+		for run < len(rest) && rest[run] == rest[0] {
+			run++
+		}
+		if run == len(rest) {
+			// The marker may continue in the next delta
+			return
+		}
+
+		marker := rest[:run]
+		if m.isLiteral(text, i, marker) {
+			m.advance(run)
+			continue
+		}
+
+		m.toggle(marker)
+		m.advance(run)
+	}
+}
+
+// isLiteral reports whether a marker run should be treated as plain text
+func (m *MarkdownTracker) isLiteral(text string, i int, marker string) bool {
+	inCode := len(m.stack) > 0 && strings.HasPrefix(m.stack[len(m.stack)-1], "`")
+	if inCode {
+		return marker != m.stack[len(m.stack)-1]
+	}
+
+	if marker[0] == '~' && len(marker) != 2 {
+		return true
+	}
+	if marker[0] != '`' && len(marker) > 3 {
+		return true
+	}
+
+	before, _ := utf8.DecodeLastRuneInString(text[:i])
+	after, _ := utf8.DecodeRuneInString(text[i+len(marker):])
+
+	// Bullets and arithmetic such as "2 * 3"
+	if (m.lineStart || unicode.IsSpace(before)) && unicode.IsSpace(after) {
+		return true
+	}
+
+	// Intraword underscores such as snake_case
+	if marker[0] == '_' && isWordRune(before) && isWordRune(after) {
+		return true
+	}
+
+	return false
+}
+
+// toggle opens or closes the construct for a marker
+func (m *MarkdownTracker) toggle(marker string) {
+	for i := len(m.stack) - 1; i >= 0; i-- {
+		if m.stack[i] == marker {
+			m.stack = m.stack[:i]
+			return
+		}
+	}
+	m.stack = append(m.stack, marker)
+}
+
+// advance marks n more bytes as scanned
+func (m *MarkdownTracker) advance(n int) {
+	text := m.text.String()
+	m.scanned += n
+	m.lineStart = m.scanned > 0 && text[m.scanned-1] == '\n'
+
+	if m.fence == "" && len(m.stack) == 0 {
+		m.safe = m.scanned
+	}
+}
+
+// isWordRune reports whether r is a letter or digit
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
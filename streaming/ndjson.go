@@ -0,0 +1,116 @@
+package streaming
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// NDJSONRecord is a stream event in the simplified schema written by
+// NDJSONEncoder. Type is one of "start", "text", "thinking", "tool",
+// "tool_input", "usage", "stop" and "error"
+type NDJSONRecord struct {
+	Type       string            `json:"type"`
+	Index      *int              `json:"index,omitempty"`
+	Text       string            `json:"text,omitempty"`
+	Tool       *NDJSONTool       `json:"tool,omitempty"`
+	Usage      *models.Usage     `json:"usage,omitempty"`
+	StopReason models.StopReason `json:"stop_reason,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	ID         string            `json:"id,omitempty"`
+	Model      string            `json:"model,omitempty"`
+}
+
+// NDJSONTool describes a tool call. Input is set once the call is complete
+type NDJSONTool struct {
+	ID    string      `json:"id"`
+	Name  string      `json:"name"`
+	Input interface{} `json:"input,omitempty"`
+}
+
+// NDJSONEncoder writes stream events as newline-delimited JSON in a simplified
+// schema, for consumers outside Go such as other services or shell pipelines
+type NDJSONEncoder struct {
+	encoder *json.Encoder
+}
+
+// NewNDJSONEncoder creates an encoder writing to w
+func NewNDJSONEncoder(w io.Writer) *NDJSONEncoder {
+	return &NDJSONEncoder{encoder: json.NewEncoder(w)}
+}
+
+// Encode writes the record for an event, if it has one. The message must be
+// the accumulated message after the event was applied
+func (e *NDJSONEncoder) Encode(event *Event, message *models.Message) error {
+	record, ok := NDJSONRecordFor(event, message)
+	if !ok {
+		return nil
+	}
+	return e.encoder.Encode(record)
+}
+
+// Transcode writes every event of the stream to w and writes an error record
+// if the stream fails. It returns the stream's error
+func Transcode(w io.Writer, stream EventStream) error {
+	encoder := NewNDJSONEncoder(w)
+	for stream.Next() {
+		if err := encoder.Encode(stream.Current(), stream.Message()); err != nil {
+			return err
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		if encodeErr := encoder.encoder.Encode(NDJSONRecord{Type: "error", Error: err.Error()}); encodeErr != nil {
+			return encodeErr
+		}
+		return err
+	}
+	return nil
+}
+
+// NDJSONRecordFor converts an event to its simplified record. It returns false
+// for events without a record, such as pings
+func NDJSONRecordFor(event *Event, message *models.Message) (NDJSONRecord, bool) {
+	switch event.Type {
+	case MessageStartEvent:
+		return NDJSONRecord{Type: "start", ID: message.ID, Model: message.Model}, true
+
+	case ContentBlockStartEvent:
+		if event.ContentBlock != nil && event.ContentBlock.ToolUseContent != nil {
+			tool := event.ContentBlock.ToolUseContent
+			return NDJSONRecord{Type: "tool", Index: event.Index, Tool: &NDJSONTool{ID: tool.ID, Name: tool.Name}}, true
+		}
+
+	case ContentBlockDeltaEvent:
+		if event.Delta == nil {
+			return NDJSONRecord{}, false
+		}
+		switch event.Delta.Type {
+		case "text_delta":
+			return NDJSONRecord{Type: "text", Index: event.Index, Text: event.Delta.Text}, true
+		case "thinking_delta":
+			return NDJSONRecord{Type: "thinking", Index: event.Index, Text: event.Delta.Thinking}, true
+		case "input_json_delta":
+			return NDJSONRecord{Type: "tool_input", Index: event.Index, Text: event.Delta.PartialJSON}, true
+		}
+
+	case ContentBlockStopEvent:
+		if idx, ok := contentIndex(event, message); ok {
+			if tool := message.Content[idx].ToolUseContent; tool != nil {
+				return NDJSONRecord{Type: "tool", Index: event.Index, Tool: &NDJSONTool{ID: tool.ID, Name: tool.Name, Input: tool.Input}}, true
+			}
+		}
+
+	case MessageDeltaEvent:
+		if delta, ok := event.MessageDelta(); ok {
+			return NDJSONRecord{Type: "usage", Usage: delta.Usage, StopReason: delta.StopReason}, true
+		}
+
+	case MessageStopEvent:
+		usage := message.Usage
+		return NDJSONRecord{Type: "stop", StopReason: message.StopReason, Usage: &usage}, true
+	}
+
+	return NDJSONRecord{}, false
+}
@@ -0,0 +1,178 @@
+package streaming
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// PartialDecoder decodes the input of a streamed tool call, such as a tool
+// forced for structured output, into progressively richer values of T. A
+// field is only included once its value is complete, so strings and numbers
+// never appear cut off
+type PartialDecoder[T any] struct {
+	// ToolName selects the tool call to decode, the first one when empty
+	ToolName string
+
+	index *int
+	input strings.Builder
+	last  []byte
+}
+
+// NewPartialDecoder creates a decoder for the input of the named tool
+func NewPartialDecoder[T any](toolName string) *PartialDecoder[T] {
+	return &PartialDecoder[T]{ToolName: toolName}
+}
+
+// Feed processes an event and returns a new value of T when the event
+// completed another field. The returned values are independent, so earlier
+// ones are not modified by later events
+func (d *PartialDecoder[T]) Feed(event *Event) (T, bool, error) {
+	var value T
+	if event == nil || event.Index == nil {
+		return value, false, nil
+	}
+
+	switch event.Type {
+	case ContentBlockStartEvent:
+		if d.index != nil || event.ContentBlock == nil || event.ContentBlock.ToolUseContent == nil {
+			return value, false, nil
+		}
+		if d.ToolName != "" && event.ContentBlock.ToolUseContent.Name != d.ToolName {
+			return value, false, nil
+		}
+		index := *event.Index
+		d.index = &index
+
+	case ContentBlockDeltaEvent:
+		if d.index == nil || *event.Index != *d.index || event.Delta == nil || event.Delta.Type != "input_json_delta" {
+			return value, false, nil
+		}
+		d.input.WriteString(event.Delta.PartialJSON)
+
+		complete := CompletePartialJSON(d.input.String())
+		if complete == nil || bytes.Equal(complete, d.last) {
+			return value, false, nil
+		}
+		d.last = complete
+		if err := json.Unmarshal(complete, &value); err != nil {
+			return value, false, fmt.Errorf("error decoding partial tool input: %w", err)
+		}
+		return value, true, nil
+	}
+
+	return value, false, nil
+}
+
+// Input returns the input received so far
+func (d *PartialDecoder[T]) Input() string {
+	return d.input.String()
+}
+
+// DecodePartial consumes the stream, calling fn with every richer value of the
+// named tool's input, and returns the final value decoded from the complete
+// input. Empty toolName selects the first tool call
+func DecodePartial[T any](stream EventStream, toolName string, fn func(T)) (T, error) {
+	decoder := NewPartialDecoder[T](toolName)
+	var value T
+	for stream.Next() {
+		partial, ok, err := decoder.Feed(stream.Current())
+		if err != nil {
+			return value, err
+		}
+		if ok && fn != nil {
+			fn(partial)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return value, err
+	}
+	if decoder.index == nil {
+		return value, fmt.Errorf("error decoding tool input: stream contains no call of tool %q", toolName)
+	}
+
+	input := decoder.Input()
+	if input == "" {
+		input = "{}"
+	}
+	if err := json.Unmarshal([]byte(input), &value); err != nil {
+		return value, fmt.Errorf("error decoding tool input: %w", err)
+	}
+	return value, nil
+}
+
+// CompletePartialJSON turns a prefix of a JSON object or array into valid JSON
+// containing only the values completed within the prefix, closing the open
+// containers. It returns nil when no container has been opened yet
+func CompletePartialJSON(prefix string) []byte {
+	var stack []byte
+	safe := -1
+	inString, escaped, isKey := false, false, false
+	expectKey := false
+	scalar := false
+
+	for i := 0; i < len(prefix); i++ {
+		c := prefix[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+				if !isKey {
+					safe = i + 1
+				}
+			}
+			continue
+		}
+
+		if scalar {
+			if strings.IndexByte(",}] \t\r\n", c) < 0 {
+				continue
+			}
+			scalar = false
+			safe = i
+		}
+
+		switch c {
+		case '{', '[':
+			stack = append(stack, c)
+			expectKey = c == '{'
+			safe = i + 1
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			expectKey = false
+			safe = i + 1
+		case ',':
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+		case '"':
+			inString = true
+			isKey = expectKey
+			expectKey = false
+		case ':', ' ', '\t', '\r', '\n':
+		default:
+			scalar = true
+		}
+	}
+
+	if safe < 0 {
+		return nil
+	}
+
+	// Everything structural after the last safe point would have moved it, so
+	// the open containers are those of the stack
+	out := []byte(strings.TrimRight(strings.TrimRight(prefix[:safe], " \t\r\n"), ","))
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			out = append(out, '}')
+		} else {
+			out = append(out, ']')
+		}
+	}
+	return out
+}
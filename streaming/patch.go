@@ -0,0 +1,172 @@
+package streaming
+
+import (
+	"fmt"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// PatchOperation is a JSON Patch (RFC 6902) operation against the JSON
+// document of the accumulated message
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// Patcher converts the events of a stream into JSON Patch operations against
+// a document rendered from the accumulated message. It tracks the content
+// blocks of the document, so blocks are always added in order, including the
+// placeholders of blocks whose events arrive out of order. Use one Patcher per
+// stream
+type Patcher struct {
+	// rendered holds for every content block of the document whether it was
+	// rendered with its type, placeholders of gaps are rendered as null
+	rendered []bool
+}
+
+// Operations returns the JSON Patch operations that update the document from
+// the message before the event to the message after it. The message must be
+// the accumulated message after the event was applied
+func (p *Patcher) Operations(event *Event, message *models.Message) []PatchOperation {
+	if event == nil || message == nil {
+		return nil
+	}
+
+	switch event.Type {
+	case MessageStartEvent:
+		document := *message
+		if document.Content == nil {
+			document.Content = []models.ContentBlock{}
+		}
+		p.rendered = p.rendered[:0]
+		for _, block := range document.Content {
+			p.rendered = append(p.rendered, !isEmptyBlock(block))
+		}
+		return []PatchOperation{{Op: "replace", Path: "", Value: document}}
+
+	case ContentBlockStartEvent, ContentBlockDeltaEvent, ContentBlockStopEvent:
+		idx, ok := contentIndex(event, message)
+		if !ok {
+			return nil
+		}
+
+		// Added blocks are rendered with the content after the event
+		if ops := p.add(idx, message); ops != nil {
+			return ops
+		}
+
+		block := message.Content[idx]
+		if event.Type == ContentBlockStartEvent || !p.rendered[idx] {
+			p.rendered[idx] = !isEmptyBlock(block)
+			return []PatchOperation{{Op: "replace", Path: fmt.Sprintf("/content/%d", idx), Value: block}}
+		}
+		return blockOperations(event, block, idx)
+
+	case MessageDeltaEvent, MessageStopEvent:
+		return []PatchOperation{
+			{Op: "replace", Path: "/stop_reason", Value: message.StopReason},
+			{Op: "replace", Path: "/stop_sequence", Value: message.StopSequence},
+			{Op: "replace", Path: "/usage", Value: message.Usage},
+		}
+	}
+
+	return nil
+}
+
+// add returns the operations adding the blocks up to idx that are not in the
+// document yet, in order
+func (p *Patcher) add(idx int, message *models.Message) []PatchOperation {
+	var ops []PatchOperation
+	for i := len(p.rendered); i <= idx; i++ {
+		ops = append(ops, PatchOperation{Op: "add", Path: fmt.Sprintf("/content/%d", i), Value: message.Content[i]})
+		p.rendered = append(p.rendered, !isEmptyBlock(message.Content[i]))
+	}
+	return ops
+}
+
+// blockOperations returns the operations updating a rendered block for a
+// delta or stop event
+func blockOperations(event *Event, block models.ContentBlock, idx int) []PatchOperation {
+	path := fmt.Sprintf("/content/%d", idx)
+	if event.Type == ContentBlockStopEvent {
+		if tool := block.ToolUseContent; tool != nil {
+			return []PatchOperation{{Op: "replace", Path: path + "/input", Value: tool.Input}}
+		}
+		return nil
+	}
+	if event.Delta == nil {
+		return nil
+	}
+
+	switch event.Delta.Type {
+	case "text_delta":
+		if block.TextContent != nil {
+			return []PatchOperation{{Op: "replace", Path: path + "/text", Value: block.TextContent.Text}}
+		}
+	case "thinking_delta":
+		if block.ThinkingContent != nil {
+			return []PatchOperation{{Op: "replace", Path: path + "/thinking", Value: block.ThinkingContent.Thinking}}
+		}
+	case "signature_delta":
+		if block.ThinkingContent != nil {
+			return []PatchOperation{{Op: "replace", Path: path + "/signature", Value: block.ThinkingContent.Signature}}
+		}
+	case "input_json_delta":
+		if block.ToolUseContent != nil && block.ToolUseContent.Input != nil {
+			return []PatchOperation{{Op: "replace", Path: path + "/input", Value: block.ToolUseContent.Input}}
+		}
+	}
+	return nil
+}
+
+// MergePatch returns a JSON Merge Patch (RFC 7386) that updates a document
+// rendered from the message before the event to the message after it. Since
+// merge patches replace arrays as a whole, content changes include the full
+// content array
+func MergePatch(event *Event, message *models.Message) map[string]interface{} {
+	if event == nil || message == nil {
+		return nil
+	}
+
+	switch event.Type {
+	case MessageStartEvent:
+		return map[string]interface{}{
+			"id":      message.ID,
+			"type":    message.Type,
+			"role":    message.Role,
+			"model":   message.Model,
+			"content": message.Content,
+			"usage":   message.Usage,
+		}
+	case ContentBlockStartEvent, ContentBlockDeltaEvent, ContentBlockStopEvent:
+		return map[string]interface{}{"content": message.Content}
+	case MessageDeltaEvent, MessageStopEvent:
+		return map[string]interface{}{
+			"stop_reason":   message.StopReason,
+			"stop_sequence": message.StopSequence,
+			"usage":         message.Usage,
+		}
+	}
+
+	return nil
+}
+
+// Patches returns the JSON Patch operations for the current event. It must be
+// called for every event, since the operations depend on the earlier ones
+func (s *MessageStream) Patches() []PatchOperation {
+	return s.patcher.Operations(s.currentEvent, s.message)
+}
+
+// contentIndex returns the content index of the event if it refers to an
+// existing block of the message
+func contentIndex(event *Event, message *models.Message) (int, bool) {
+	if event.Index == nil {
+		return 0, false
+	}
+	idx := *event.Index
+	if idx < 0 || idx >= len(message.Content) {
+		return 0, false
+	}
+	return idx, true
+}
@@ -0,0 +1,161 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPatchesBuildMessage(t *testing.T) {
+	start := `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","usage":{"input_tokens":3}}}`
+	stop := []string{
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":9}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	tests := []struct {
+		name   string
+		events []string
+	}{
+		{
+			name: "blocks in order",
+			events: []string{
+				start,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":\"go\"}"}}`,
+				`{"type":"content_block_stop","index":1}`,
+			},
+		},
+		{
+			name: "start past the end",
+			events: []string{
+				start,
+				`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":"third"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"first"}}`,
+			},
+		},
+		{
+			name: "delta before start",
+			events: []string{
+				start,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"world"}}`,
+				`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"Hello "}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"!"}}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := NewMessageStream(strings.NewReader(sse(append(tt.events, stop...)...)))
+
+			var document interface{}
+			for stream.Next() {
+				for _, op := range stream.Patches() {
+					var err error
+					if document, err = applyPatch(document, op); err != nil {
+						t.Fatalf("error applying %s %s after %s: %v", op.Op, op.Path, stream.Current().Type, err)
+					}
+				}
+			}
+			if err := stream.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+
+			data, err := json.Marshal(stream.Message())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want interface{}
+			if err := json.Unmarshal(data, &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(document, want) {
+				got, _ := json.Marshal(document)
+				t.Fatalf("patched document = %s, want %s", got, data)
+			}
+		})
+	}
+}
+
+// applyPatch applies an add or replace operation to a decoded JSON document,
+// failing where RFC 6902 does
+func applyPatch(document interface{}, op PatchOperation) (interface{}, error) {
+	data, err := json.Marshal(op.Value)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	if op.Path == "" {
+		if op.Op != "replace" {
+			return nil, fmt.Errorf("unsupported operation on the root")
+		}
+		return value, nil
+	}
+
+	tokens := strings.Split(strings.TrimPrefix(op.Path, "/"), "/")
+	parent := document
+	for _, token := range tokens[:len(tokens)-1] {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			parent = p[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(p) {
+				return nil, fmt.Errorf("index %q out of range", token)
+			}
+			parent = p[i]
+		default:
+			return nil, fmt.Errorf("path %q does not exist", op.Path)
+		}
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last]; op.Op == "replace" && !ok {
+			return nil, fmt.Errorf("member %q does not exist", last)
+		}
+		p[last] = value
+	case []interface{}:
+		i, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case op.Op == "add" && i == len(p):
+			return setArray(document, tokens[:len(tokens)-1], append(p, value))
+		case op.Op == "replace" && i >= 0 && i < len(p):
+			p[i] = value
+		default:
+			return nil, fmt.Errorf("%s at index %d of an array of %d", op.Op, i, len(p))
+		}
+	default:
+		return nil, fmt.Errorf("path %q does not exist", op.Path)
+	}
+	return document, nil
+}
+
+// setArray replaces the array at the path of a document after it grew
+func setArray(document interface{}, tokens []string, array []interface{}) (interface{}, error) {
+	if len(tokens) != 1 {
+		return nil, fmt.Errorf("unsupported array path %q", tokens)
+	}
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("document is not an object")
+	}
+	object[tokens[0]] = array
+	return document, nil
+}
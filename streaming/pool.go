@@ -0,0 +1,87 @@
+package streaming
+
+import "sync"
+
+// pooledEvent is an event together with the delta it decodes into, so both
+// can be reused with a single pool lookup
+type pooledEvent struct {
+	event Event
+	delta Delta
+}
+
+// eventPool holds events that are reused by streams created WithPooling
+var eventPool = sync.Pool{
+	New: func() interface{} {
+		return &pooledEvent{}
+	},
+}
+
+// lineBufferPool holds line buffers that are reused by streams created
+// WithPooling
+var lineBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 4096)
+		return &buf
+	},
+}
+
+// WithPooling makes the stream reuse events and read buffers across streams,
+// reducing allocations for servers relaying many concurrent streams. The event
+// returned by Current is only valid until the next call to Next and must not
+// be retained, copy it if it is needed later
+func WithPooling() StreamOption {
+	return func(s *MessageStream) {
+		s.pooling = true
+	}
+}
+
+// acquireEvent returns a reset event ready to be decoded into
+func (s *MessageStream) acquireEvent() *Event {
+	if !s.pooling {
+		return &Event{}
+	}
+
+	p := eventPool.Get().(*pooledEvent)
+	p.event = Event{}
+	p.delta = Delta{}
+	p.event.Delta = &p.delta
+	s.pooled = p
+	return &p.event
+}
+
+// finishEvent drops the pooled delta from a decoded event that had none
+func (s *MessageStream) finishEvent(event *Event) {
+	if s.pooling && event.Delta != nil && *event.Delta == (Delta{}) {
+		event.Delta = nil
+	}
+}
+
+// releaseEvent returns the event decoded last to the pool
+func (s *MessageStream) releaseEvent() {
+	if s.pooled == nil {
+		return
+	}
+	eventPool.Put(s.pooled)
+	s.pooled = nil
+	s.currentEvent = nil
+}
+
+// lineBuffer returns the buffer lines are read into
+func (s *MessageStream) lineBuffer() []byte {
+	if !s.pooling {
+		return nil
+	}
+	if s.buffer == nil {
+		s.buffer = lineBufferPool.Get().(*[]byte)
+	}
+	return (*s.buffer)[:0]
+}
+
+// releaseBuffers returns the line buffer to the pool once the stream ends
+func (s *MessageStream) releaseBuffers() {
+	if s.buffer == nil {
+		return
+	}
+	lineBufferPool.Put(s.buffer)
+	s.buffer = nil
+}
@@ -0,0 +1,49 @@
+package streaming
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchmarkStream is the body of a typical text stream
+var benchmarkStream = func() string {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":25,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+	}
+	for i := 0; i < 50; i++ {
+		events = append(events, fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"word %d "}}`, i))
+	}
+	events = append(events,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":100}}`,
+		`{"type":"message_stop"}`,
+	)
+	return sse(events...)
+}()
+
+func BenchmarkMessageStream(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		options []StreamOption
+	}{
+		{name: "without pooling"},
+		{name: "with pooling", options: []StreamOption{WithPooling()}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(benchmarkStream)))
+			for i := 0; i < b.N; i++ {
+				stream := NewMessageStream(strings.NewReader(benchmarkStream), bm.options...)
+				for stream.Next() {
+				}
+				if err := stream.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package streaming
+
+import (
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// WithProfilerLabels applies pprof labels to the goroutine calling Next while
+// it reads and decodes events, restoring the labels of ctx afterwards
+func WithProfilerLabels(ctx context.Context, labels pprof.LabelSet) StreamOption {
+	return func(s *MessageStream) {
+		s.labelCtx = ctx
+		s.labels = &labels
+	}
+}
+
+// WithTraceTask runs every call of Next in a runtime/trace region of the task
+// started with ctx, and ends the task once the stream ends or is closed
+func WithTraceTask(ctx context.Context, task *trace.Task) StreamOption {
+	return func(s *MessageStream) {
+		s.traceCtx = ctx
+		s.task = task
+	}
+}
+
+// instrumentedNext advances the stream with the profiler labels and trace
+// region applied
+func (s *MessageStream) instrumentedNext() bool {
+	next := s.next
+	if s.task != nil {
+		next = func() bool {
+			defer trace.StartRegion(s.traceCtx, "stream.next").End()
+			return s.next()
+		}
+	}
+	if s.labels == nil {
+		return next()
+	}
+
+	var ok bool
+	pprof.Do(s.labelCtx, *s.labels, func(context.Context) {
+		ok = next()
+	})
+	return ok
+}
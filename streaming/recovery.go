@@ -0,0 +1,117 @@
+package streaming
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Recovery describes what a stream delivered before it failed, so that an
+// application can offer to continue from where it stopped
+type Recovery struct {
+	// Err is the error the stream failed with
+	Err error
+
+	// Message is the partial message accumulated before the failure
+	Message *models.Message
+
+	// LastEvent is the last event delivered before the failure, nil when no
+	// event was delivered
+	LastEvent *Event
+
+	// Usage is the usage reported before the failure
+	Usage models.Usage
+}
+
+// Recovery returns what the stream delivered before it failed, or nil when it
+// has not failed
+func (s *MessageStream) Recovery() *Recovery {
+	if s.err == nil {
+		return nil
+	}
+
+	message := *s.message
+	message.Content = append([]models.ContentBlock(nil), s.message.Content...)
+
+	recovery := &Recovery{Err: s.err, Message: &message, Usage: s.message.Usage}
+	if s.delivered {
+		last := s.lastEvent
+		if last.Delta != nil {
+			delta := s.lastDelta
+			last.Delta = &delta
+		}
+		recovery.LastEvent = &last
+	}
+	return recovery
+}
+
+// recordEvent remembers the event being delivered for Recovery without
+// retaining pooled memory
+func (s *MessageStream) recordEvent(event *Event) {
+	s.delivered = true
+	s.lastEvent = *event
+	if event.Delta != nil {
+		s.lastDelta = *event.Delta
+	}
+	if s.timeline != nil {
+		s.timeline.Mark(string(event.Type), "stream", eventAttributes(event))
+	}
+}
+
+// eventAttributes describes an event on the timeline
+func eventAttributes(event *Event) map[string]string {
+	attributes := make(map[string]string)
+	if event.Index != nil {
+		attributes["index"] = strconv.Itoa(*event.Index)
+	}
+	if event.Delta != nil && event.Delta.Type != "" {
+		attributes["delta"] = event.Delta.Type
+	}
+	if event.Delta != nil && event.Delta.StopReason != nil {
+		attributes["stop_reason"] = string(*event.Delta.StopReason)
+	}
+	if block := event.ContentBlock; block != nil {
+		switch {
+		case block.TextContent != nil:
+			attributes["block"] = string(models.TextContentType)
+		case block.ToolUseContent != nil:
+			attributes["block"] = string(models.ToolUseContentType)
+			attributes["name"] = block.ToolUseContent.Name
+		case block.ThinkingContent != nil:
+			attributes["block"] = string(models.ThinkingContentType)
+		case block.RedactedThinkingContent != nil:
+			attributes["block"] = string(models.RedactedThinkingContentType)
+		case block.UnknownContent != nil:
+			attributes["block"] = string(block.UnknownContent.Type)
+		}
+	}
+	return attributes
+}
+
+// Continue returns the messages followed by the partial text of the failed
+// response as an assistant prefill, so that the model continues where it
+// stopped. The text is added to a trailing assistant message, such as the
+// prefill of the failed request. Tool calls and thinking cannot be continued
+// and are dropped, the messages are returned unchanged when no text was
+// received
+func (r *Recovery) Continue(messages []models.MessageParam) []models.MessageParam {
+	var text string
+	if r.Message != nil {
+		// A prefill must not end with whitespace
+		text = strings.TrimRightFunc(r.Message.Text(), unicode.IsSpace)
+	}
+	if text == "" {
+		return messages
+	}
+
+	continued := make([]models.MessageParam, 0, len(messages)+1)
+	continued = append(continued, messages...)
+	if last := len(continued) - 1; last >= 0 && continued[last].Role == models.AssistantRole {
+		content := append([]models.ContentBlock(nil), continued[last].Content...)
+		continued[last] = models.NewAssistantMessage(append(content, models.CreateTextBlock(text))...)
+		return continued
+	}
+	return append(continued, models.NewAssistantMessage(models.CreateTextBlock(text)))
+}
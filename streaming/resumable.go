@@ -0,0 +1,88 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultResumeTTL is how long finished streams can still be resumed
+const DefaultResumeTTL = 5 * time.Minute
+
+var (
+	// ErrStreamNotFound is returned when resuming an unknown or expired stream
+	ErrStreamNotFound = errors.New("stream not found")
+
+	// ErrStreamExists is returned when starting a stream with an ID in use
+	ErrStreamExists = errors.New("stream already exists")
+)
+
+// ResumableStream is a broadcast stream registered under an ID
+type ResumableStream struct {
+	*Broadcaster
+	ID string
+}
+
+// ResumableRegistry keeps in-flight streams by ID, so a frontend that loses
+// its connection can reconnect with the ID and the number of events it
+// received, get the events it missed and continue live, without calling the
+// API again. Finished streams are kept for TTL
+type ResumableRegistry struct {
+	// TTL is how long finished streams are kept, defaults to
+	// DefaultResumeTTL
+	TTL time.Duration
+
+	mu      sync.Mutex
+	streams map[string]*ResumableStream
+}
+
+// NewResumableRegistry creates an empty registry
+func NewResumableRegistry() *ResumableRegistry {
+	return &ResumableRegistry{streams: make(map[string]*ResumableStream)}
+}
+
+// Start starts broadcasting source under id
+func (r *ResumableRegistry) Start(id string, source EventStream) (*ResumableStream, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.streams[id]; ok {
+		return nil, ErrStreamExists
+	}
+
+	stream := &ResumableStream{Broadcaster: NewBroadcaster(source), ID: id}
+	r.streams[id] = stream
+	go r.expire(stream)
+	return stream, nil
+}
+
+// Resume subscribes to the stream with id, skipping the first offset events
+// the subscriber already received
+func (r *ResumableRegistry) Resume(ctx context.Context, id string, offset int) (*Subscription, error) {
+	r.mu.Lock()
+	stream, ok := r.streams[id]
+	r.mu.Unlock()
+
+	if !ok {
+		return nil, ErrStreamNotFound
+	}
+	return stream.SubscribeFrom(ctx, offset), nil
+}
+
+// expire removes a stream once it finished and the TTL passed
+func (r *ResumableRegistry) expire(stream *ResumableStream) {
+	stream.Wait(context.Background())
+
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultResumeTTL
+	}
+	time.Sleep(ttl)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams[stream.ID] == stream {
+		delete(r.streams, stream.ID)
+	}
+}
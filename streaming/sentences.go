@@ -0,0 +1,182 @@
+package streaming
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Sentence is a sentence of streamed text
+type Sentence struct {
+	// ID increases monotonically for each new sentence in the stream
+	ID int
+
+	// Index is the content block the sentence belongs to
+	Index int
+
+	// Text is the text of the sentence
+	Text string
+
+	// Revised is set when the sentence replaces a previously emitted sentence
+	// with the same ID, because later deltas showed it had not ended yet
+	Revised bool
+}
+
+// sentenceState tracks the sentence being built for a content block
+type sentenceState struct {
+	buffer      string
+	id          int
+	provisional string
+}
+
+// SentenceStream splits streamed text into sentences for consumers such as
+// speech synthesis. Sentences ending at the end of a delta are emitted eagerly
+// so audio generation can start early; if later deltas show the sentence did
+// not end there (for example "3." followed by "14"), it is emitted again with
+// the same ID and Revised set
+type SentenceStream struct {
+	source  EventStream
+	states  map[int]*sentenceState
+	nextID  int
+	queue   []Sentence
+	current Sentence
+}
+
+// NewSentenceStream creates a sentence stream reading from source
+func NewSentenceStream(source EventStream) *SentenceStream {
+	return &SentenceStream{
+		source: source,
+		states: make(map[int]*sentenceState),
+		nextID: 1,
+	}
+}
+
+// Next advances the stream to the next sentence
+func (s *SentenceStream) Next() bool {
+	for len(s.queue) == 0 {
+		if !s.source.Next() {
+			s.flushAll()
+			if len(s.queue) == 0 {
+				return false
+			}
+			break
+		}
+
+		event := s.source.Current()
+		switch {
+		case event.Type == ContentBlockDeltaEvent && event.Index != nil && event.Delta != nil && event.Delta.Type == "text_delta":
+			s.write(*event.Index, event.Delta.Text)
+		case event.Type == ContentBlockStopEvent && event.Index != nil:
+			s.flush(*event.Index)
+		case event.Type == MessageStopEvent:
+			s.flushAll()
+		}
+	}
+
+	s.current, s.queue = s.queue[0], s.queue[1:]
+	return true
+}
+
+// Sentence returns the current sentence
+func (s *SentenceStream) Sentence() Sentence {
+	return s.current
+}
+
+// Err returns any error that occurred during streaming
+func (s *SentenceStream) Err() error {
+	return s.source.Err()
+}
+
+// Message returns the accumulated message
+func (s *SentenceStream) Message() *models.Message {
+	return s.source.Message()
+}
+
+// write adds text to the sentence buffer of a content block
+func (s *SentenceStream) write(index int, text string) {
+	state, ok := s.states[index]
+	if !ok {
+		state = &sentenceState{}
+		s.states[index] = state
+	}
+	state.buffer += text
+
+	for {
+		end := nextBoundary(state.buffer, SentenceBoundary)
+		if end <= 0 {
+			break
+		}
+		s.emit(index, state, state.buffer[:end])
+		state.buffer = state.buffer[end:]
+	}
+
+	trimmed := strings.TrimRightFunc(state.buffer, unicode.IsSpace)
+	if trimmed != "" && isSentenceEnd(trimmed) && trimmed != state.provisional {
+		s.emitProvisional(index, state, trimmed)
+	}
+}
+
+// emit queues a completed sentence
+func (s *SentenceStream) emit(index int, state *sentenceState, text string) {
+	trimmed := strings.TrimSpace(text)
+	provisional := state.provisional
+	id := state.id
+	state.provisional = ""
+	state.id = 0
+
+	if trimmed == "" {
+		return
+	}
+	if provisional != "" && trimmed == strings.TrimSpace(provisional) {
+		// Already emitted eagerly with the same text
+		return
+	}
+
+	sentence := Sentence{Index: index, Text: trimmed}
+	if provisional != "" {
+		sentence.ID = id
+		sentence.Revised = true
+	} else {
+		sentence.ID = s.allocateID()
+	}
+	s.queue = append(s.queue, sentence)
+}
+
+// emitProvisional eagerly queues a sentence that may still continue
+func (s *SentenceStream) emitProvisional(index int, state *sentenceState, text string) {
+	sentence := Sentence{Index: index, Text: strings.TrimSpace(text)}
+	if state.provisional != "" {
+		sentence.ID = state.id
+		sentence.Revised = true
+	} else {
+		state.id = s.allocateID()
+		sentence.ID = state.id
+	}
+	state.provisional = text
+	s.queue = append(s.queue, sentence)
+}
+
+// flush queues the remaining text of a content block as a sentence
+func (s *SentenceStream) flush(index int) {
+	state, ok := s.states[index]
+	if !ok {
+		return
+	}
+	delete(s.states, index)
+	s.emit(index, state, state.buffer)
+}
+
+// flushAll queues the remaining text of all content blocks
+func (s *SentenceStream) flushAll() {
+	for _, index := range sortedKeys(s.states) {
+		s.flush(index)
+	}
+}
+
+// allocateID returns the next sentence ID
+func (s *SentenceStream) allocateID() int {
+	id := s.nextID
+	s.nextID++
+	return id
+}
@@ -3,12 +3,19 @@ package streaming
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"runtime/pprof"
+	"runtime/trace"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/joakimcarlsson/anthropic-sdk/internal/safe"
 	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/timeline"
 )
 
 // EventType represents the type of streaming event
@@ -21,8 +28,22 @@ const (
 	ContentBlockStopEvent  EventType = "content_block_stop"
 	MessageDeltaEvent      EventType = "message_delta"
 	MessageStopEvent       EventType = "message_stop"
+	PingEvent              EventType = "ping"
+	ErrorEvent             EventType = "error"
 )
 
+// knownEventTypes contains the event types understood by the stream
+var knownEventTypes = map[EventType]bool{
+	MessageStartEvent:      true,
+	ContentBlockStartEvent: true,
+	ContentBlockDeltaEvent: true,
+	ContentBlockStopEvent:  true,
+	MessageDeltaEvent:      true,
+	MessageStopEvent:       true,
+	PingEvent:              true,
+	ErrorEvent:             true,
+}
+
 // Event represents a streaming event
 type Event struct {
 	Type         EventType            `json:"type"`
@@ -32,15 +53,100 @@ type Event struct {
 	ContentBlock *models.ContentBlock `json:"content_block,omitempty"`
 	Delta        *Delta               `json:"delta,omitempty"`
 	Usage        *models.Usage        `json:"usage,omitempty"`
+	Error        *EventError          `json:"error,omitempty"`
 }
 
-// Delta represents a delta update in a streaming event
+// Delta represents a delta update in a streaming event. Content block deltas
+// use Type and the content fields, message deltas use StopReason and
+// StopSequence
 type Delta struct {
 	Type        string `json:"type,omitempty"`
 	Text        string `json:"text,omitempty"`
 	PartialJSON string `json:"partial_json,omitempty"`
 	Thinking    string `json:"thinking,omitempty"`
 	Signature   string `json:"signature,omitempty"`
+
+	StopReason   *models.StopReason `json:"stop_reason,omitempty"`
+	StopSequence *string            `json:"stop_sequence,omitempty"`
+}
+
+// MessageDelta is the payload of a message_delta event, sent once the stop
+// reason is known and before message_stop
+type MessageDelta struct {
+	StopReason   models.StopReason
+	StopSequence string
+
+	// Usage holds the cumulative usage of the message so far
+	Usage *models.Usage
+}
+
+// MessageDelta returns the payload of a message_delta event
+func (e *Event) MessageDelta() (*MessageDelta, bool) {
+	if e.Type != MessageDeltaEvent {
+		return nil, false
+	}
+
+	delta := &MessageDelta{Usage: e.Usage}
+	if e.Delta != nil {
+		if e.Delta.StopReason != nil {
+			delta.StopReason = *e.Delta.StopReason
+		}
+		if e.Delta.StopSequence != nil {
+			delta.StopSequence = *e.Delta.StopSequence
+		}
+	}
+	return delta, true
+}
+
+// MaxContentBlocks is the highest number of content blocks a streamed message
+// may contain, protecting against malformed events with huge indexes
+const MaxContentBlocks = 4096
+
+// DefaultMaxEventSize is the default limit for the size of a single line of
+// the event stream
+const DefaultMaxEventSize = 10 << 20
+
+// EventTooLargeError is returned when a line of the event stream exceeds the
+// maximum event size
+type EventTooLargeError struct {
+	Limit int
+}
+
+// Error implements the error interface
+func (e *EventTooLargeError) Error() string {
+	return fmt.Sprintf("error reading stream: event exceeds maximum size of %d bytes", e.Limit)
+}
+
+// MessageTooLargeError is returned when the content accumulated by a stream
+// exceeds the maximum message size
+type MessageTooLargeError struct {
+	Limit int
+}
+
+// Error implements the error interface
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("error reading stream: message exceeds maximum size of %d bytes", e.Limit)
+}
+
+// EventError is an error event received in the stream, for example when the
+// API becomes overloaded mid-stream
+type EventError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface
+func (e *EventError) Error() string {
+	return fmt.Sprintf("stream error: %s: %s", e.Type, e.Message)
+}
+
+// EventStream is implemented by MessageStream and the stream transforms that
+// wrap it
+type EventStream interface {
+	Next() bool
+	Current() *Event
+	Err() error
+	Message() *models.Message
 }
 
 // MessageStream handles streaming responses from the Claude API
@@ -50,53 +156,340 @@ type MessageStream struct {
 	err          error
 	message      *models.Message
 	jsonBuffers  map[int]string
+	strict       bool
+	done         bool
+	validators   []func(*models.Message) error
+	maxEventSize int
+	maxSize      int
+	size         int
+	pooling      bool
+	pooled       *pooledEvent
+	buffer       *[]byte
+	onToolUse    []func(name string, id string, input json.RawMessage)
+	guard        safe.Guard
+	closer       io.Closer
+	delivered    bool
+	lastEvent    Event
+	lastDelta    Delta
+	closeOnce    sync.Once
+	closed       atomic.Bool
+	timeline     *timeline.Timeline
+	span         *timeline.Span
+	labelCtx     context.Context
+	labels       *pprof.LabelSet
+	traceCtx     context.Context
+	task         *trace.Task
+	patcher      Patcher
+}
+
+// StreamOption is a function that modifies a MessageStream
+type StreamOption func(*MessageStream)
+
+// WithStrictDecoding makes the stream fail on unknown event or content block
+// types instead of passing them through
+func WithStrictDecoding() StreamOption {
+	return func(s *MessageStream) {
+		s.strict = true
+	}
+}
+
+// WithCompletionValidator adds a validator run on the accumulated message once
+// the message stops. A validation error is returned by Err after the
+// message_stop event has been delivered
+func WithCompletionValidator(validator func(*models.Message) error) StreamOption {
+	return func(s *MessageStream) {
+		s.validators = append(s.validators, validator)
+	}
+}
+
+// WithPropagatePanics lets panics of the stream's callbacks propagate instead
+// of failing the stream with an error carrying the panic and its stack trace
+func WithPropagatePanics() StreamOption {
+	return func(s *MessageStream) {
+		s.guard.Propagate = true
+	}
+}
+
+// WithMaxEventSize sets the maximum size in bytes of a single line of the event
+// stream. Larger events fail the stream with an *EventTooLargeError instead of
+// being buffered, zero or less disables the limit
+func WithMaxEventSize(size int) StreamOption {
+	return func(s *MessageStream) {
+		s.maxEventSize = size
+	}
+}
+
+// WithTimeline records a span covering the stream and every event it delivers
+// on the timeline
+func WithTimeline(t *timeline.Timeline) StreamOption {
+	return func(s *MessageStream) {
+		s.timeline = t
+	}
+}
+
+// WithMaxMessageSize sets the maximum size in bytes of the text, thinking and
+// tool input accumulated from the stream's events. Larger messages fail the
+// stream with a *MessageTooLargeError, zero or less disables the limit
+func WithMaxMessageSize(size int) StreamOption {
+	return func(s *MessageStream) {
+		s.maxSize = size
+	}
 }
 
 // NewMessageStream creates a new message stream from a reader
-func NewMessageStream(reader io.Reader) *MessageStream {
-	return &MessageStream{
-		reader:      bufio.NewReader(reader),
-		message:     &models.Message{},
-		jsonBuffers: make(map[int]string),
+func NewMessageStream(reader io.Reader, options ...StreamOption) *MessageStream {
+	stream := &MessageStream{
+		reader:       bufio.NewReader(reader),
+		message:      &models.Message{},
+		jsonBuffers:  make(map[int]string),
+		maxEventSize: DefaultMaxEventSize,
 	}
+
+	if closer, ok := reader.(io.Closer); ok {
+		stream.closer = closer
+	}
+
+	for _, option := range options {
+		option(stream)
+	}
+	stream.span = stream.timeline.Begin("stream", "stream", nil)
+
+	return stream
+}
+
+// Close stops the stream and closes the underlying reader when it is an
+// io.Closer, such as the response body of a streaming request. It must be
+// called when a stream is abandoned before its end, and may be called from
+// another goroutine to interrupt a blocked Next. The reader is closed
+// automatically once the stream ends
+func (s *MessageStream) Close() error {
+	s.closed.Store(true)
+	return s.closeReader()
+}
+
+// closeReader closes the underlying reader once
+func (s *MessageStream) closeReader() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.closer != nil {
+			err = s.closer.Close()
+		}
+		if s.err != nil {
+			s.span.Set("error", s.err.Error())
+		}
+		s.span.End()
+		if s.task != nil {
+			s.task.End()
+		}
+	})
+	return err
 }
 
 // Next advances the stream to the next event
 func (s *MessageStream) Next() bool {
-	if s.err != nil {
+	if s.labels != nil || s.task != nil {
+		return s.instrumentedNext()
+	}
+	return s.next()
+}
+
+// next advances the stream to the next event
+func (s *MessageStream) next() bool {
+	if s.err != nil || s.done {
 		return false
 	}
+	s.releaseEvent()
+
+	for {
+		if s.closed.Load() {
+			s.done = true
+			s.releaseBuffers()
+			return false
+		}
 
-	line, err := s.reader.ReadBytes('\n')
-	if err != nil {
+		line, err := s.readLine()
+		if err != nil && err != io.EOF {
+			if s.closed.Load() {
+				s.done = true
+			} else {
+				s.err = err
+			}
+			s.releaseBuffers()
+			s.closeReader()
+			return false
+		}
 		if err == io.EOF {
+			s.done = true
+		}
+
+		event := s.parseLine(line)
+		if s.done || s.err != nil {
+			s.releaseBuffers()
+			s.closeReader()
+		}
+		if event != nil {
+			s.recordEvent(event)
+			return true
+		}
+		if s.err != nil {
+			return false
+		}
+		if s.done {
 			return false
 		}
-		s.err = fmt.Errorf("error reading stream: %w", err)
-		return false
 	}
+}
 
-	line = bytes.TrimSpace(line)
-	if len(line) == 0 {
-		return s.Next()
+// readLine reads the next line of the stream, failing once the line grows
+// beyond the maximum event size
+func (s *MessageStream) readLine() ([]byte, error) {
+	line := s.lineBuffer()
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if s.maxEventSize > 0 && len(line)+len(chunk) > s.maxEventSize {
+			return nil, &EventTooLargeError{Limit: s.maxEventSize}
+		}
+		line = append(line, chunk...)
+		if s.buffer != nil {
+			*s.buffer = line
+		}
+
+		switch err {
+		case nil, io.EOF:
+			return line, err
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return nil, fmt.Errorf("error reading stream: %w", err)
+		}
 	}
+}
+
+// parseLine parses an event from a line of the stream. It returns nil for
+// lines that do not carry an event, setting s.err if the line is invalid
+func (s *MessageStream) parseLine(line []byte) *Event {
+	line = bytes.TrimSpace(line)
 
-	prefix := []byte("data: ")
+	prefix := []byte("data:")
 	if !bytes.HasPrefix(line, prefix) {
-		return s.Next()
+		return nil
 	}
 
-	data := line[len(prefix):]
-	var event Event
-	if err := json.Unmarshal(data, &event); err != nil {
+	data := bytes.TrimSpace(line[len(prefix):])
+	event := s.acquireEvent()
+	if err := json.Unmarshal(data, event); err != nil {
 		s.err = fmt.Errorf("error parsing event: %w", err)
-		return false
+		return nil
+	}
+	s.finishEvent(event)
+
+	if s.strict {
+		if err := validateEvent(event); err != nil {
+			s.err = err
+			return nil
+		}
+	}
+
+	if event.Type == ErrorEvent && event.Error != nil {
+		s.err = event.Error
+		return nil
+	}
+
+	if err := s.checkIndex(event); err != nil {
+		s.err = err
+		return nil
+	}
+
+	if err := s.checkSize(event); err != nil {
+		s.err = err
+		return nil
+	}
+
+	s.currentEvent = event
+	s.updateMessage(event)
+
+	if event.Type == MessageStopEvent {
+		for _, validator := range s.validators {
+			err := s.guard.Call("completion validator", func() error {
+				return validator(s.message)
+			})
+			if err != nil {
+				s.err = err
+				break
+			}
+		}
+	}
+
+	return event
+}
+
+// checkIndex returns an error if the event refers to a content block index
+// that is negative or implausibly large
+func (s *MessageStream) checkIndex(event *Event) error {
+	if event.Index == nil {
+		return nil
+	}
+	if idx := *event.Index; idx < 0 || idx >= MaxContentBlocks {
+		return fmt.Errorf("error parsing event: content block index %d out of range", idx)
+	}
+	return nil
+}
+
+// checkSize returns an error if the content of the event grows the message
+// beyond the maximum message size
+func (s *MessageStream) checkSize(event *Event) error {
+	if s.maxSize <= 0 {
+		return nil
 	}
 
-	s.currentEvent = &event
-	s.updateMessage(&event)
+	if delta := event.Delta; delta != nil && event.Type == ContentBlockDeltaEvent {
+		s.size += len(delta.Text) + len(delta.PartialJSON) + len(delta.Thinking)
+	}
+	if block := event.ContentBlock; block != nil {
+		if block.TextContent != nil {
+			s.size += len(block.TextContent.Text)
+		}
+		if block.ThinkingContent != nil {
+			s.size += len(block.ThinkingContent.Thinking)
+		}
+	}
+	if s.size > s.maxSize {
+		return &MessageTooLargeError{Limit: s.maxSize}
+	}
+	return nil
+}
 
-	return true
+// OnToolUse registers a callback fired as soon as each tool use block is
+// complete, so tools can start executing while the model is still emitting the
+// rest of the message. Callbacks run on the goroutine calling Next
+func (s *MessageStream) OnToolUse(fn func(name string, id string, input json.RawMessage)) {
+	s.onToolUse = append(s.onToolUse, fn)
+}
+
+// completeToolUse fires the tool use callbacks for the block at idx
+func (s *MessageStream) completeToolUse(idx int) {
+	if len(s.onToolUse) == 0 {
+		return
+	}
+
+	block := s.message.Content[idx].ToolUseContent
+	input := json.RawMessage(strings.TrimSpace(s.jsonBuffers[idx]))
+	if len(input) == 0 {
+		input = json.RawMessage("{}")
+		if block.Input != nil {
+			if data, err := json.Marshal(block.Input); err == nil {
+				input = data
+			}
+		}
+	}
+
+	for _, fn := range s.onToolUse {
+		err := s.guard.Notify("tool use callback", func() { fn(block.Name, block.ID, input) })
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
 }
 
 // Current returns the current event
@@ -114,6 +507,21 @@ func (s *MessageStream) Message() *models.Message {
 	return s.message
 }
 
+// validateEvent returns an error if the event or its content block is of an
+// unknown type
+func validateEvent(event *Event) error {
+	if !knownEventTypes[event.Type] {
+		return &models.UnknownTypeError{Kind: "event", Type: string(event.Type)}
+	}
+	if event.ContentBlock != nil && event.ContentBlock.UnknownContent != nil {
+		return &models.UnknownTypeError{Kind: "content block", Type: string(event.ContentBlock.UnknownContent.Type)}
+	}
+	if event.Message != nil {
+		return event.Message.ValidateContentTypes()
+	}
+	return nil
+}
+
 // updateMessage updates the accumulated message with the current event
 func (s *MessageStream) updateMessage(event *Event) {
 	switch event.Type {
@@ -122,64 +530,77 @@ func (s *MessageStream) updateMessage(event *Event) {
 			s.message.ID = event.Message.ID
 			s.message.Role = event.Message.Role
 			s.message.Model = event.Message.Model
+			s.message.Type = event.Message.Type
+			s.message.Usage = event.Message.Usage
+			s.message.RawExtra = event.Message.RawExtra
 		}
 	case ContentBlockStartEvent:
 		if event.ContentBlock != nil && event.Index != nil {
 			idx := *event.Index
-			for len(s.message.Content) <= idx {
-				s.message.Content = append(s.message.Content, models.ContentBlock{})
-			}
-			s.message.Content[idx] = *event.ContentBlock
+			s.growContent(idx)
 
-			if event.ContentBlock.TextContent != nil && event.ContentBlock.TextContent.Text == "" {
-			}
-
-			if event.ContentBlock.ToolUseContent != nil {
+			block := copyBlock(*event.ContentBlock)
+			if existing := s.message.Content[idx]; !isEmptyBlock(existing) {
+				// Deltas arrived before the start event, keep what was accumulated
+				block = mergeStartedBlock(block, existing)
+			} else if block.ToolUseContent != nil {
 				s.jsonBuffers[idx] = ""
 			}
+			s.message.Content[idx] = block
 		}
 	case ContentBlockDeltaEvent:
 		if event.Delta != nil && event.Index != nil {
 			idx := *event.Index
-			if idx < len(s.message.Content) {
-				if event.Delta.Type == "text_delta" {
-					if s.message.Content[idx].TextContent != nil {
-						s.message.Content[idx].TextContent.Text += event.Delta.Text
-					}
-				} else if event.Delta.Type == "input_json_delta" {
-					if s.message.Content[idx].ToolUseContent != nil {
-						s.jsonBuffers[idx] += event.Delta.PartialJSON
-
-						jsonStr := s.jsonBuffers[idx]
-						if strings.HasPrefix(jsonStr, "{") && strings.HasSuffix(jsonStr, "}") {
-							var inputObj map[string]interface{}
-							if err := json.Unmarshal([]byte(jsonStr), &inputObj); err == nil {
-								s.message.Content[idx].ToolUseContent.Input = inputObj
-							}
-						}
-					}
-				} else if event.Delta.Type == "thinking_delta" {
-					if s.message.Content[idx].ThinkingContent != nil {
-						s.message.Content[idx].ThinkingContent.Thinking += event.Delta.Thinking
-					}
-				} else if event.Delta.Type == "signature_delta" {
-					if s.message.Content[idx].ThinkingContent != nil {
-						s.message.Content[idx].ThinkingContent.Signature = event.Delta.Signature
-					}
+			block := s.blockForDelta(idx, event.Delta.Type)
+			if block == nil {
+				break
+			}
+
+			switch event.Delta.Type {
+			case "text_delta":
+				if block.TextContent != nil {
+					block.TextContent.Text += event.Delta.Text
+				}
+			case "input_json_delta":
+				if block.ToolUseContent != nil {
+					s.jsonBuffers[idx] += event.Delta.PartialJSON
+					s.parseToolInput(idx)
+				}
+			case "thinking_delta":
+				if block.ThinkingContent != nil {
+					block.ThinkingContent.Thinking += event.Delta.Thinking
+				}
+			case "signature_delta":
+				if block.ThinkingContent != nil {
+					block.ThinkingContent.Signature = event.Delta.Signature
 				}
 			}
 		}
 	case ContentBlockStopEvent:
 		if event.Index != nil {
 			idx := *event.Index
-
 			if idx < len(s.message.Content) && s.message.Content[idx].ToolUseContent != nil {
-				jsonStr := s.jsonBuffers[idx]
-				if strings.HasPrefix(jsonStr, "{") && strings.HasSuffix(jsonStr, "}") {
-					var inputObj map[string]interface{}
-					if err := json.Unmarshal([]byte(jsonStr), &inputObj); err == nil {
-						s.message.Content[idx].ToolUseContent.Input = inputObj
-					}
+				s.parseToolInput(idx)
+				s.completeToolUse(idx)
+			}
+		}
+	case MessageDeltaEvent:
+		if delta, ok := event.MessageDelta(); ok {
+			if delta.StopReason != "" {
+				s.message.StopReason = delta.StopReason
+			}
+			s.message.StopSequence = delta.StopSequence
+			if delta.Usage != nil {
+				// Usage in message deltas is cumulative and may omit input tokens
+				s.message.Usage.OutputTokens = delta.Usage.OutputTokens
+				if delta.Usage.InputTokens > 0 {
+					s.message.Usage.InputTokens = delta.Usage.InputTokens
+				}
+				if delta.Usage.CacheCreationInputTokens > 0 {
+					s.message.Usage.CacheCreationInputTokens = delta.Usage.CacheCreationInputTokens
+				}
+				if delta.Usage.CacheReadInputTokens > 0 {
+					s.message.Usage.CacheReadInputTokens = delta.Usage.CacheReadInputTokens
 				}
 			}
 		}
@@ -192,3 +613,103 @@ func (s *MessageStream) updateMessage(event *Event) {
 		}
 	}
 }
+
+// growContent appends empty placeholder blocks until idx is a valid index
+func (s *MessageStream) growContent(idx int) {
+	for len(s.message.Content) <= idx {
+		s.message.Content = append(s.message.Content, models.ContentBlock{})
+	}
+}
+
+// blockForDelta returns the block a delta applies to. If no block was started
+// at the index, for example because a proxy dropped or reordered frames, a
+// block of the type implied by the delta is created
+func (s *MessageStream) blockForDelta(idx int, deltaType string) *models.ContentBlock {
+	if idx < len(s.message.Content) && !isEmptyBlock(s.message.Content[idx]) {
+		return &s.message.Content[idx]
+	}
+
+	// Deltas of unknown types imply no block, so no placeholder is added
+	var placeholder models.ContentBlock
+	switch deltaType {
+	case "text_delta":
+		placeholder.TextContent = &models.TextBlock{Type: models.TextContentType}
+	case "input_json_delta":
+		placeholder.ToolUseContent = &models.ToolUseBlock{Type: models.ToolUseContentType}
+		s.jsonBuffers[idx] = ""
+	case "thinking_delta", "signature_delta":
+		placeholder.ThinkingContent = &models.ThinkingBlock{Type: models.ThinkingContentType}
+	default:
+		return nil
+	}
+
+	s.growContent(idx)
+	s.message.Content[idx] = placeholder
+	return &s.message.Content[idx]
+}
+
+// parseToolInput sets the input of a tool use block once its buffered JSON is
+// a complete object
+func (s *MessageStream) parseToolInput(idx int) {
+	jsonStr := s.jsonBuffers[idx]
+	if !strings.HasPrefix(jsonStr, "{") || !strings.HasSuffix(jsonStr, "}") {
+		return
+	}
+
+	var inputObj map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &inputObj); err == nil {
+		s.message.Content[idx].ToolUseContent.Input = inputObj
+	}
+}
+
+// copyBlock copies the blocks that deltas modify, so accumulating a message
+// does not modify the events it is built from
+func copyBlock(block models.ContentBlock) models.ContentBlock {
+	if block.TextContent != nil {
+		text := *block.TextContent
+		block.TextContent = &text
+	}
+	if block.ToolUseContent != nil {
+		toolUse := *block.ToolUseContent
+		block.ToolUseContent = &toolUse
+	}
+	if block.ThinkingContent != nil {
+		thinking := *block.ThinkingContent
+		block.ThinkingContent = &thinking
+	}
+	return block
+}
+
+// isEmptyBlock reports whether a block is an unfilled placeholder
+func isEmptyBlock(block models.ContentBlock) bool {
+	return block.TextContent == nil && block.ImageContent == nil && block.ToolUseContent == nil &&
+		block.ToolResultContent == nil && block.ThinkingContent == nil && block.RedactedThinkingContent == nil &&
+		block.SearchResultContent == nil && block.DocumentContent == nil && block.UnknownContent == nil
+}
+
+// mergeStartedBlock combines a late start event with the content accumulated
+// from deltas that arrived before it
+func mergeStartedBlock(started, accumulated models.ContentBlock) models.ContentBlock {
+	switch {
+	case started.TextContent != nil && accumulated.TextContent != nil:
+		text := *started.TextContent
+		text.Text += accumulated.TextContent.Text
+		started.TextContent = &text
+	case started.ToolUseContent != nil && accumulated.ToolUseContent != nil:
+		toolUse := *started.ToolUseContent
+		if accumulated.ToolUseContent.Input != nil {
+			toolUse.Input = accumulated.ToolUseContent.Input
+		}
+		started.ToolUseContent = &toolUse
+	case started.ThinkingContent != nil && accumulated.ThinkingContent != nil:
+		thinking := *started.ThinkingContent
+		thinking.Thinking += accumulated.ThinkingContent.Thinking
+		if accumulated.ThinkingContent.Signature != "" {
+			thinking.Signature = accumulated.ThinkingContent.Signature
+		}
+		started.ThinkingContent = &thinking
+	default:
+		return accumulated
+	}
+	return started
+}
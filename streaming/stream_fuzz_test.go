@@ -0,0 +1,116 @@
+package streaming
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fuzzMaxEventSize is the event size limit of fuzzed streams, small enough for
+// the fuzzer to reach it
+const fuzzMaxEventSize = 1 << 10
+
+// fuzzMaxEvents bounds the events read from a fuzzed stream
+const fuzzMaxEvents = 10000
+
+func FuzzMessageStream(f *testing.F) {
+	seeds := []string{
+		// A complete stream
+		"event: message_start\n" +
+			`data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":3}}}` + "\n\n" +
+			`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}` + "\n\n" +
+			`data: {"type":"content_block_stop","index":0}` + "\n\n" +
+			`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}` + "\n\n" +
+			`data: {"type":"content_block_stop","index":1}` + "\n\n" +
+			`data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":9}}` + "\n\n" +
+			`data: {"type":"message_stop"}` + "\n\n",
+
+		// Huge and negative indexes
+		`data: {"type":"content_block_start","index":9223372036854775807,"content_block":{"type":"text","text":""}}` + "\n\n",
+		`data: {"type":"content_block_delta","index":4096,"delta":{"type":"text_delta","text":"x"}}` + "\n\n",
+		`data: {"type":"content_block_delta","index":-1,"delta":{"type":"text_delta","text":"x"}}` + "\n\n",
+		`data: {"type":"content_block_stop","index":-9223372036854775808}` + "\n\n",
+
+		// Deltas for indexes never started
+		`data: {"type":"content_block_delta","index":3,"delta":{"type":"input_json_delta","partial_json":"{}"}}` + "\n\n" +
+			`data: {"type":"content_block_stop","index":3}` + "\n\n",
+
+		// A delta of an unknown type, which implies no block
+		`data: {"type":"content_block_delta","index":0,"delta":{}}` + "\n\n",
+
+		// An error event mid-stream
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}` + "\n\n" +
+			`data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}` + "\n\n" +
+			`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"late"}}` + "\n\n",
+
+		// An unterminated final line
+		`data: {"type":"message_stop"}`,
+		"data: {\"type\":\"ping\"}\n\ndata: {\"type\":\"content_block_delta\"",
+
+		// An oversized event
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"` + strings.Repeat("a", 2*fuzzMaxEventSize) + `"}}` + "\n\n",
+
+		// Comments, blank lines and malformed data
+		": keep-alive\n\n\n\nevent: ping\ndata:\n\n",
+		"data: not json\n\n",
+		"data: null\n\n",
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		plain := readFuzzStream(t, data)
+		pooled := readFuzzStream(t, data, WithPooling())
+
+		if plain != pooled {
+			t.Fatalf("pooled stream differs from plain stream:\nplain:  %s\npooled: %s", plain, pooled)
+		}
+	})
+}
+
+// readFuzzStream reads a fuzzed stream to its end, checking the invariants of
+// the accumulated message, and returns a summary of the result
+func readFuzzStream(t *testing.T, data []byte, options ...StreamOption) string {
+	t.Helper()
+
+	options = append(options, WithMaxEventSize(fuzzMaxEventSize))
+	stream := NewMessageStream(strings.NewReader(string(data)), options...)
+
+	events := 0
+	for stream.Next() {
+		if stream.Current() == nil {
+			t.Fatal("Next returned true without a current event")
+		}
+		events++
+		if events > fuzzMaxEvents {
+			t.Fatalf("stream delivered more than %d events", fuzzMaxEvents)
+		}
+	}
+	if stream.Next() {
+		t.Fatal("Next returned true after the stream ended")
+	}
+
+	if content := stream.Message().Content; len(content) > MaxContentBlocks {
+		t.Fatalf("message has %d content blocks, more than %d", len(content), MaxContentBlocks)
+	}
+
+	var tooLarge *EventTooLargeError
+	if err := stream.Err(); errors.As(err, &tooLarge) && tooLarge.Limit != fuzzMaxEventSize {
+		t.Fatalf("event size limit = %d, want %d", tooLarge.Limit, fuzzMaxEventSize)
+	}
+
+	message, err := json.Marshal(stream.Message())
+	if err != nil {
+		t.Fatalf("error marshaling accumulated message: %v", err)
+	}
+	errText := ""
+	if err := stream.Err(); err != nil {
+		errText = err.Error()
+	}
+	return string(message) + " " + errText
+}
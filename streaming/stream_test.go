@@ -0,0 +1,215 @@
+package streaming
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// sse joins events into the body of an event stream
+func sse(events ...string) string {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "data: %s\n\n", event)
+	}
+	return b.String()
+}
+
+func TestMessageStreamBlockOrdering(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   []models.ContentBlock
+	}{
+		{
+			name: "delta before start",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+				`{"type":"content_block_stop","index":0}`,
+			},
+			want: []models.ContentBlock{
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "Hello world"}},
+			},
+		},
+		{
+			name: "late text start merges into placeholder",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"world"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"Hello "}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"!"}}`,
+			},
+			want: []models.ContentBlock{
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "Hello world!"}},
+			},
+		},
+		{
+			name: "late tool use start keeps streamed input",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"city\":\"Paris\"}"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+				`{"type":"content_block_stop","index":0}`,
+			},
+			want: []models.ContentBlock{
+				{ToolUseContent: &models.ToolUseBlock{
+					Type:  models.ToolUseContentType,
+					ID:    "toolu_1",
+					Name:  "get_weather",
+					Input: map[string]interface{}{"city": "Paris"},
+				}},
+			},
+		},
+		{
+			name: "late thinking start keeps signature",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			},
+			want: []models.ContentBlock{
+				{ThinkingContent: &models.ThinkingBlock{Type: models.ThinkingContentType, Thinking: "hmm", Signature: "sig"}},
+			},
+		},
+		{
+			name: "gap between indexes",
+			events: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"first"}}`,
+				`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"third"}}`,
+			},
+			want: []models.ContentBlock{
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "first"}},
+				{},
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "third"}},
+			},
+		},
+		{
+			name: "gap filled by a late start",
+			events: []string{
+				`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":"second"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"first"}}`,
+			},
+			want: []models.ContentBlock{
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "first"}},
+				{TextContent: &models.TextBlock{Type: models.TextContentType, Text: "second"}},
+			},
+		},
+		{
+			name: "delta of unknown type",
+			events: []string{
+				`{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta"}}`,
+			},
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := NewMessageStream(strings.NewReader(sse(tt.events...)))
+			for stream.Next() {
+			}
+			if err := stream.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+
+			if content := stream.Message().Content; !reflect.DeepEqual(content, tt.want) {
+				t.Fatalf("content = %s, want %s", describeBlocks(content), describeBlocks(tt.want))
+			}
+		})
+	}
+}
+
+func TestMessageStreamMaxContentBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		wantErr string
+	}{
+		{
+			name:  "last allowed index",
+			event: fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"text","text":"last"}}`, MaxContentBlocks-1),
+		},
+		{
+			name:    "start at MaxContentBlocks",
+			event:   fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"text","text":""}}`, MaxContentBlocks),
+			wantErr: fmt.Sprintf("content block index %d out of range", MaxContentBlocks),
+		},
+		{
+			name:    "delta at MaxContentBlocks",
+			event:   fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"text_delta","text":"x"}}`, MaxContentBlocks),
+			wantErr: fmt.Sprintf("content block index %d out of range", MaxContentBlocks),
+		},
+		{
+			name:    "negative index",
+			event:   `{"type":"content_block_delta","index":-1,"delta":{"type":"text_delta","text":"x"}}`,
+			wantErr: "content block index -1 out of range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := NewMessageStream(strings.NewReader(sse(tt.event)))
+			for stream.Next() {
+			}
+
+			err := stream.Err()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Err() = %v, want error containing %q", err, tt.wantErr)
+				}
+				if n := len(stream.Message().Content); n != 0 {
+					t.Fatalf("got %d content blocks, want none", n)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if n := len(stream.Message().Content); n != MaxContentBlocks {
+				t.Fatalf("got %d content blocks, want %d", n, MaxContentBlocks)
+			}
+		})
+	}
+}
+
+func TestMessageStreamLateStartFiresToolUse(t *testing.T) {
+	stream := NewMessageStream(strings.NewReader(sse(
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+	)))
+
+	var calls []string
+	stream.OnToolUse(func(name, id string, input json.RawMessage) {
+		calls = append(calls, fmt.Sprintf("%s %s %s", name, id, input))
+	})
+	for stream.Next() {
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+
+	want := []string{`search toolu_1 {"q":"go"}`}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("tool use callbacks = %q, want %q", calls, want)
+	}
+}
+
+// describeBlocks renders content blocks for failure messages
+func describeBlocks(blocks []models.ContentBlock) string {
+	parts := make([]string, len(blocks))
+	for i, block := range blocks {
+		data, err := block.MarshalJSON()
+		if err != nil {
+			parts[i] = err.Error()
+			continue
+		}
+		parts[i] = string(data)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
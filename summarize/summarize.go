@@ -0,0 +1,214 @@
+// Package summarize summarizes text of any length with a map-reduce approach:
+// the text is split into chunks that are summarized concurrently, and the
+// chunk summaries are combined into a single summary
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/internal/textchunk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultMapPrompt is the instruction sent with each chunk
+	DefaultMapPrompt = "Summarize the following text. Keep all key facts, names and numbers."
+
+	// DefaultReducePrompt is the instruction sent with the combined chunk
+	// summaries
+	DefaultReducePrompt = "The following are summaries of consecutive parts of a longer text. Combine them into a single coherent summary."
+
+	// DefaultChunkTokens is the default chunk size in estimated tokens
+	DefaultChunkTokens = 8000
+
+	// DefaultConcurrency is the default number of concurrent requests
+	DefaultConcurrency = 4
+
+	// DefaultMaxTokens is the default response limit of each request
+	DefaultMaxTokens = 1024
+)
+
+// Summarizer summarizes long text
+type Summarizer struct {
+	Client anthropic.ChatProvider
+
+	// Model summarizes the chunks
+	Model string
+
+	// ReduceModel combines the chunk summaries, defaults to Model
+	ReduceModel string
+
+	// MapPrompt and ReducePrompt are the instructions for both steps,
+	// defaulting to DefaultMapPrompt and DefaultReducePrompt
+	MapPrompt    string
+	ReducePrompt string
+
+	// ChunkTokens is the maximum size of a chunk in estimated tokens
+	ChunkTokens int
+
+	// Concurrency is the maximum number of concurrent requests
+	Concurrency int
+
+	// MaxTokens limits the length of each summary
+	MaxTokens int
+}
+
+// Result is the outcome of a summarization
+type Result struct {
+	Summary string
+
+	// Chunks is the number of chunks the input was split into
+	Chunks int
+
+	// Requests is the number of requests made
+	Requests int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// New creates a summarizer with the default settings
+func New(client anthropic.ChatProvider, model string) *Summarizer {
+	return &Summarizer{Client: client, Model: model}
+}
+
+// Summarize summarizes text, splitting it into chunks when it is too long for
+// a single request
+func (s *Summarizer) Summarize(ctx context.Context, text string) (*Result, error) {
+	chunks := textchunk.Split(text, s.chunkTokens(), models.EstimateTokens)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("error summarizing: empty text")
+	}
+
+	result := &Result{Chunks: len(chunks)}
+	summaries, err := s.summarizeAll(ctx, chunks, s.Model, s.mapPrompt(), result)
+	if err != nil {
+		return nil, err
+	}
+
+	// Combine until a single summary is left, splitting the combined
+	// summaries again if they are too long for one request
+	for len(summaries) > 1 {
+		combined := textchunk.Split(strings.Join(summaries, "\n\n"), s.chunkTokens(), models.EstimateTokens)
+		if len(combined) >= len(summaries) {
+			// Summaries are as long as the chunk size, combine pairwise so
+			// every round makes progress
+			combined = combined[:0]
+			for i := 0; i < len(summaries); i += 2 {
+				combined = append(combined, strings.Join(summaries[i:min(i+2, len(summaries))], "\n\n"))
+			}
+		}
+
+		summaries, err = s.summarizeAll(ctx, combined, s.reduceModel(), s.reducePrompt(), result)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Summary = summaries[0]
+	return result, nil
+}
+
+// summarizeAll summarizes the texts concurrently, preserving their order
+func (s *Summarizer) summarizeAll(ctx context.Context, texts []string, model, prompt string, result *Result) ([]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	summaries := make([]string, len(texts))
+	usages := make([]models.Usage, len(texts))
+
+	var once sync.Once
+	var firstErr error
+
+	sem := make(chan struct{}, s.concurrency())
+	var wg sync.WaitGroup
+	for i, text := range texts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			resp, err := s.Client.CreateMessage(ctx, s.request(model, prompt, text))
+			if err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("error summarizing chunk %d: %w", i, err)
+					cancel()
+				})
+				return
+			}
+			summaries[i] = resp.Text()
+			usages[i] = resp.Usage
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	for _, usage := range usages {
+		result.Usage = result.Usage.Add(usage)
+	}
+	result.Requests += len(texts)
+	return summaries, nil
+}
+
+// request builds the request summarizing a single text
+func (s *Summarizer) request(model, prompt, text string) models.MessageRequest {
+	maxTokens := s.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	return models.MessageRequest{
+		Model:     model,
+		System:    prompt,
+		MaxTokens: maxTokens,
+		Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(text))},
+	}
+}
+
+// reduceModel returns the model combining summaries
+func (s *Summarizer) reduceModel() string {
+	if s.ReduceModel != "" {
+		return s.ReduceModel
+	}
+	return s.Model
+}
+
+// mapPrompt returns the instruction for summarizing chunks
+func (s *Summarizer) mapPrompt() string {
+	if s.MapPrompt != "" {
+		return s.MapPrompt
+	}
+	return DefaultMapPrompt
+}
+
+// reducePrompt returns the instruction for combining summaries
+func (s *Summarizer) reducePrompt() string {
+	if s.ReducePrompt != "" {
+		return s.ReducePrompt
+	}
+	return DefaultReducePrompt
+}
+
+// chunkTokens returns the chunk size in estimated tokens
+func (s *Summarizer) chunkTokens() int {
+	if s.ChunkTokens > 0 {
+		return s.ChunkTokens
+	}
+	return DefaultChunkTokens
+}
+
+// concurrency returns the maximum number of concurrent requests
+func (s *Summarizer) concurrency() int {
+	if s.Concurrency > 0 {
+		return s.Concurrency
+	}
+	return DefaultConcurrency
+}
@@ -0,0 +1,243 @@
+// Package thinking chooses the extended thinking budget of a request from the
+// complexity of its prompt, so simple prompts are answered quickly and hard
+// ones get room to reason. Complexity is scored with heuristics on the length
+// of the prompt and the presence of math and code, or by a quick
+// classification call to a fast model
+package thinking
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/classify"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// MinBudget is the smallest thinking budget the API accepts
+	MinBudget = 1024
+
+	// DefaultMaxBudget is the largest budget chosen when MaxBudget is not set
+	DefaultMaxBudget = 16000
+
+	// DefaultHeadroom is the number of tokens max_tokens is raised to leave
+	// for the answer after the budget, when it does not leave enough
+	DefaultHeadroom = 1024
+
+	// lengthTokens is the prompt length in estimated tokens at which the
+	// length alone scores the most
+	lengthTokens = 4000
+)
+
+// Weights of the heuristic signals, adding up to more than one so that a
+// long prompt with code or math reaches the maximum budget
+const (
+	lengthWeight    = 0.4
+	mathWeight      = 0.3
+	codeWeight      = 0.3
+	reasoningWeight = 0.2
+)
+
+// Scorer rates the complexity of a request between 0 for trivial prompts and 1
+// for prompts needing the most reasoning
+type Scorer func(ctx context.Context, req *models.MessageRequest) (float64, error)
+
+// Signals of the heuristic scorer
+var (
+	mathPattern      = regexp.MustCompile(`(?i)\b(prove|proof|theorem|lemma|equation|integral|derivative|probability|calculate|compute|solve)\b|\\(frac|sum|int|sqrt)|\d\s*[-+*/^=]\s*\d|[∑∫√≤≥≠π]`)
+	codePattern      = regexp.MustCompile("(?im)```|^\\s*(func|def|class|import|package|#include|public|fn|const|let|var)\\b|\\b(debug|refactor|stack trace|compile|algorithm|time complexity)\\b")
+	reasoningPattern = regexp.MustCompile(`(?i)\b(step by step|trade-?offs?|analy[sz]e|design|plan|compare|optimi[sz]e|evaluate)\b`)
+)
+
+// Heuristic scores the last user message of a request by its length and by
+// whether it involves math, code or multi-step reasoning, without a request
+func Heuristic(ctx context.Context, req *models.MessageRequest) (float64, error) {
+	text := promptText(req)
+
+	score := lengthWeight * min(float64(models.EstimateTokens(text))/lengthTokens, 1)
+	if mathPattern.MatchString(text) {
+		score += mathWeight
+	}
+	if codePattern.MatchString(text) {
+		score += codeWeight
+	}
+	if reasoningPattern.MatchString(text) {
+		score += reasoningWeight
+	}
+	return min(score, 1), nil
+}
+
+// Complexity labels of the classifier scorer and their scores
+var complexityScores = map[string]float64{
+	"simple":   0,
+	"moderate": 0.5,
+	"complex":  1,
+}
+
+// Classify returns a scorer asking a fast model to rate the complexity of the
+// last user message, for prompts the heuristics misjudge. It costs a request
+// but the classification is short
+func Classify(client anthropic.ChatProvider, model string) Scorer {
+	classifier := classify.New(client, model, "simple", "moderate", "complex")
+	classifier.Descriptions = map[string]string{
+		"simple":   "a fact, a short answer or a routine edit, answered without deliberation",
+		"moderate": "a few steps of reasoning, light math or a small piece of code",
+		"complex":  "proofs, hard math, non-trivial code or design decisions with many trade-offs",
+	}
+	classifier.Instructions = "The text is a prompt sent to an assistant. Rate how much step-by-step reasoning answering it well requires."
+
+	return func(ctx context.Context, req *models.MessageRequest) (float64, error) {
+		result, err := classifier.Classify(context.WithValue(ctx, scoringKey{}, true), promptText(req))
+		if err != nil {
+			return 0, fmt.Errorf("error scoring prompt complexity: %w", err)
+		}
+		return complexityScores[result.Label], nil
+	}
+}
+
+// scoringKey marks the context of scoring requests, so the tuner's middleware
+// leaves them alone
+type scoringKey struct{}
+
+// Tuner chooses thinking budgets within a range
+type Tuner struct {
+	// MinBudget is the budget of the simplest prompts, defaults to MinBudget
+	MinBudget int
+
+	// MaxBudget is the budget of the most complex prompts, defaults to
+	// DefaultMaxBudget
+	MaxBudget int
+
+	// Scorer rates the complexity of requests, defaults to Heuristic
+	Scorer Scorer
+
+	// Headroom is the number of tokens max_tokens must leave for the answer
+	// after the budget, defaults to DefaultHeadroom
+	Headroom int
+}
+
+// New creates a tuner choosing budgets between minBudget and maxBudget with the
+// heuristic scorer
+func New(minBudget, maxBudget int) *Tuner {
+	return &Tuner{MinBudget: minBudget, MaxBudget: maxBudget}
+}
+
+// Budget returns the thinking budget for a request, scaling linearly with its
+// complexity score between MinBudget and MaxBudget
+func (t *Tuner) Budget(ctx context.Context, req *models.MessageRequest) (int, error) {
+	scorer := t.Scorer
+	if scorer == nil {
+		scorer = Heuristic
+	}
+	score, err := scorer(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	low, high := t.budgets()
+	score = min(max(score, 0), 1)
+	return low + int(math.Round(score*float64(high-low))), nil
+}
+
+// Apply enables extended thinking on a request with a budget chosen for it.
+// Requests that already configure thinking are left unchanged, as are requests
+// the API rejects thinking for: those setting a temperature other than 1,
+// top_k or a top_p below 0.95, or forcing tool use. max_tokens is raised when
+// it leaves less than Headroom tokens after the budget, up to the output limit
+// of the model, lowering the budget if needed
+func (t *Tuner) Apply(ctx context.Context, req *models.MessageRequest) error {
+	if req.Thinking != nil || !supportsThinking(req) {
+		return nil
+	}
+	if scoring, _ := ctx.Value(scoringKey{}).(bool); scoring {
+		return nil
+	}
+
+	budget, err := t.Budget(ctx, req)
+	if err != nil {
+		return err
+	}
+	maxTokens := budget + t.headroom()
+	if limit := models.MaxOutputTokens(req.Model); limit > 0 && maxTokens > limit {
+		budget = max(limit-t.headroom(), MinBudget)
+		if budget >= limit {
+			return nil
+		}
+		maxTokens = limit
+	}
+
+	req.Thinking = models.EnableThinking(budget)
+	if req.MaxTokens < maxTokens {
+		req.MaxTokens = maxTokens
+	}
+	return nil
+}
+
+// Middleware returns client middleware applying the tuner to every message
+// request
+func (t *Tuner) Middleware() anthropic.Middleware {
+	return t.Apply
+}
+
+// budgets returns the range of budgets, keeping both ends within what the API
+// accepts
+func (t *Tuner) budgets() (int, int) {
+	low, high := t.MinBudget, t.MaxBudget
+	if low < MinBudget {
+		low = MinBudget
+	}
+	if high <= 0 {
+		high = DefaultMaxBudget
+	}
+	return low, max(low, high)
+}
+
+// headroom returns the tokens max_tokens must leave after the budget
+func (t *Tuner) headroom() int {
+	if t.Headroom > 0 {
+		return t.Headroom
+	}
+	return DefaultHeadroom
+}
+
+// minThinkingTopP is the lowest top_p the API accepts with thinking
+const minThinkingTopP = 0.95
+
+// supportsThinking reports whether the API accepts thinking with the sampling
+// parameters and tool choice of a request
+func supportsThinking(req *models.MessageRequest) bool {
+	if req.Temperature != nil && *req.Temperature != 1 {
+		return false
+	}
+	if req.TopK != nil || (req.TopP != nil && *req.TopP < minThinkingTopP) {
+		return false
+	}
+	if choice := req.ToolChoice; choice != nil && (choice.Type == "any" || choice.Type == "tool") {
+		return false
+	}
+	return true
+}
+
+// promptText returns the text of the last user message with text, skipping the
+// turns holding only tool results
+func promptText(req *models.MessageRequest) string {
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if req.Messages[i].Role != models.UserRole {
+			continue
+		}
+		var parts []string
+		for _, block := range req.Messages[i].Content {
+			if block.TextContent != nil {
+				parts = append(parts, block.TextContent.Text)
+			}
+		}
+		if len(parts) > 0 {
+			return strings.Join(parts, "\n")
+		}
+	}
+	return ""
+}
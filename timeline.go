@@ -0,0 +1,30 @@
+package anthropic
+
+import (
+	"strconv"
+
+	"github.com/joakimcarlsson/anthropic-sdk/timeline"
+)
+
+// WithTimeline records the request on the timeline: a span from sending the
+// request until its response arrives and, for streams, a span covering the
+// stream and every event it delivers
+func WithTimeline(t *timeline.Timeline) RequestOption {
+	return func(cfg *requestConfig) {
+		cfg.timeline = t
+	}
+}
+
+// endRequestSpan ends the span of a request with the outcome of its exchange
+func endRequestSpan(span *timeline.Span, exchange *Exchange) {
+	if exchange.StatusCode != 0 {
+		span.Set("status", strconv.Itoa(exchange.StatusCode))
+	}
+	if exchange.RequestID != "" {
+		span.Set("request_id", exchange.RequestID)
+	}
+	if exchange.Err != nil {
+		span.Set("error", exchange.Err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,198 @@
+// Package timeline records timestamped events and spans of requests, streams
+// and tool executions, and exports them as JSON or in the Chrome trace event
+// format, so the latency inside complex agent turns can be inspected in
+// chrome://tracing or Perfetto. A nil *Timeline records nothing, so callers
+// can pass one through without checking whether recording is enabled
+package timeline
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Entry is a recorded event or span
+type Entry struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+
+	// Start is when the event happened or the span began
+	Start time.Time `json:"start"`
+
+	// Duration is the length of a span, zero for events
+	Duration time.Duration `json:"duration,omitempty"`
+
+	// Span reports whether the entry is a span
+	Span bool `json:"span,omitempty"`
+
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// End returns when the entry ended
+func (e Entry) End() time.Time {
+	return e.Start.Add(e.Duration)
+}
+
+// Timeline records entries. It is safe for concurrent use
+type Timeline struct {
+	mu      sync.Mutex
+	start   time.Time
+	entries []Entry
+	now     func() time.Time
+}
+
+// New creates an empty timeline starting now
+func New() *Timeline {
+	return &Timeline{start: time.Now(), now: time.Now}
+}
+
+// Mark records an event happening now
+func (t *Timeline) Mark(name, category string, attributes map[string]string) {
+	if t == nil {
+		return
+	}
+	t.add(Entry{Name: name, Category: category, Start: t.now(), Attributes: attributes})
+}
+
+// Begin starts a span, which is recorded once it ends
+func (t *Timeline) Begin(name, category string, attributes map[string]string) *Span {
+	if t == nil {
+		return nil
+	}
+	attributes = maps.Clone(attributes)
+	return &Span{timeline: t, entry: Entry{Name: name, Category: category, Start: t.now(), Span: true, Attributes: attributes}}
+}
+
+// Entries returns a copy of the recorded entries ordered by their start
+func (t *Timeline) Entries() []Entry {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	entries := append([]Entry(nil), t.entries...)
+	t.mu.Unlock()
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+	return entries
+}
+
+// WriteJSON writes the entries as a JSON array ordered by their start
+func (t *Timeline) WriteJSON(w io.Writer) error {
+	entries := t.Entries()
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return json.NewEncoder(w).Encode(entries)
+}
+
+// traceEvent is an event of the Chrome trace event format
+type traceEvent struct {
+	Name      string            `json:"name"`
+	Category  string            `json:"cat"`
+	Phase     string            `json:"ph"`
+	Timestamp float64           `json:"ts"`
+	Duration  *float64          `json:"dur,omitempty"`
+	Scope     string            `json:"s,omitempty"`
+	Process   int               `json:"pid"`
+	Thread    int               `json:"tid"`
+	Args      map[string]string `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes the entries in the Chrome trace event format, with
+// timestamps relative to the start of the timeline. Overlapping spans, such as
+// concurrent tool calls, are placed on separate tracks
+func (t *Timeline) WriteChromeTrace(w io.Writer) error {
+	entries := t.Entries()
+	var start time.Time
+	if t != nil {
+		start = t.start
+	}
+	micros := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Microsecond)
+	}
+
+	// Events share the first track, spans fill the first free track after it
+	var trackEnds []time.Time
+	events := make([]traceEvent, 0, len(entries))
+	for _, entry := range entries {
+		event := traceEvent{
+			Name:      entry.Name,
+			Category:  entry.Category,
+			Timestamp: micros(entry.Start.Sub(start)),
+			Process:   1,
+			Args:      entry.Attributes,
+		}
+		if !entry.Span {
+			event.Phase, event.Scope = "i", "t"
+			events = append(events, event)
+			continue
+		}
+
+		track := 0
+		for track < len(trackEnds) && trackEnds[track].After(entry.Start) {
+			track++
+		}
+		if track == len(trackEnds) {
+			trackEnds = append(trackEnds, time.Time{})
+		}
+		trackEnds[track] = entry.End()
+
+		duration := micros(entry.Duration)
+		event.Phase, event.Duration, event.Thread = "X", &duration, track+1
+		events = append(events, event)
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents []traceEvent `json:"traceEvents"`
+	}{events})
+}
+
+// add appends an entry
+func (t *Timeline) add(entry Entry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.entries = append(t.entries, entry)
+}
+
+// Span is a span in progress. A nil *Span records nothing
+type Span struct {
+	timeline *Timeline
+	entry    Entry
+	ended    bool
+}
+
+// Set adds an attribute to the span, unless it ended
+func (s *Span) Set(key, value string) {
+	if s == nil {
+		return
+	}
+	s.timeline.mu.Lock()
+	defer s.timeline.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if s.entry.Attributes == nil {
+		s.entry.Attributes = make(map[string]string)
+	}
+	s.entry.Attributes[key] = value
+}
+
+// End records the span, ending now. Later calls are ignored
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.timeline.mu.Lock()
+	defer s.timeline.mu.Unlock()
+	if s.ended {
+		return
+	}
+	s.ended = true
+	s.entry.Duration = s.timeline.now().Sub(s.entry.Start)
+	s.timeline.entries = append(s.timeline.entries, s.entry)
+}
@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// ResultCache holds the results of read-only tools keyed by tool name and
+// input, so repeated identical calls are answered without executing the tool
+// again. Use one cache per conversation. A ResultCache is safe for concurrent
+// use
+type ResultCache struct {
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry is a cached result, available once done is closed
+type cacheEntry struct {
+	done   chan struct{}
+	result *Result
+}
+
+// NewResultCache creates an empty cache
+func NewResultCache() *ResultCache {
+	return &ResultCache{entries: make(map[string]*cacheEntry)}
+}
+
+// Len returns the number of cached results
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Clear removes all cached results
+func (c *ResultCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*cacheEntry)
+}
+
+// do returns the cached result of the call, executing it with fn when there is
+// none. Concurrent identical calls wait for the first one, and failed results
+// are not cached
+func (c *ResultCache) do(ctx context.Context, call Call, fn func() *Result) *Result {
+	key := cacheKey(call)
+
+	c.mu.Lock()
+	if entry, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-entry.done:
+			if entry.result != nil {
+				return entry.result
+			}
+		case <-ctx.Done():
+		}
+		return fn()
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	result := fn()
+	if result.IsError {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	} else {
+		entry.result = result
+	}
+	close(entry.done)
+	return result
+}
+
+// cacheKey identifies a call by tool name and canonical input, so inputs that
+// differ only in key order or whitespace share an entry
+func cacheKey(call Call) string {
+	input := []byte(call.Input)
+	var value interface{}
+	if err := json.Unmarshal(input, &value); err == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			input = canonical
+		}
+	}
+	return call.Name + "\x00" + string(input)
+}
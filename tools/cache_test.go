@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+func TestResultCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		effect    Effect
+		inputs    []string
+		fail      bool
+		wantRuns  int
+		wantCache int
+	}{
+		{name: "identical calls", effect: ReadOnly, inputs: []string{`{"q":"go"}`, `{"q":"go"}`, `{"q":"go"}`}, wantRuns: 1, wantCache: 1},
+		{name: "key order and whitespace", effect: ReadOnly, inputs: []string{`{"a":1,"b":2}`, `{ "b": 2, "a": 1 }`}, wantRuns: 1, wantCache: 1},
+		{name: "different inputs", effect: ReadOnly, inputs: []string{`{"q":"go"}`, `{"q":"rust"}`}, wantRuns: 2, wantCache: 2},
+		{name: "failed calls", effect: ReadOnly, inputs: []string{`{"q":"go"}`, `{"q":"go"}`}, fail: true, wantRuns: 2},
+		{name: "idempotent tool", effect: Idempotent, inputs: []string{`{"q":"go"}`, `{"q":"go"}`}, wantRuns: 2},
+		{name: "side-effecting tool", effect: SideEffecting, inputs: []string{`{"q":"go"}`, `{"q":"go"}`}, wantRuns: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs int
+			tool := New("search", "search", models.InputSchema{Type: "object"}, func(ctx context.Context, input json.RawMessage) (*Result, error) {
+				runs++
+				if tt.fail {
+					return Error("search failed"), nil
+				}
+				return Text("results"), nil
+			}).WithEffect(tt.effect)
+
+			runner := NewRunner(nil, tool)
+			runner.Cache = NewResultCache()
+			// Each call is its own response, so identical calls do not run
+			// concurrently
+			for i, input := range tt.inputs {
+				exec := runner.newExecution(context.Background())
+				results, callErr := exec.results([]Call{{ID: "toolu_" + input, Name: "search", Input: json.RawMessage(input)}})
+				exec.stop()
+				if callErr != nil {
+					t.Fatalf("call %d error = %v", i, callErr.err)
+				}
+				if got := results[0].ToolResultContent.IsError; got != tt.fail {
+					t.Fatalf("call %d is error = %t, want %t", i, got, tt.fail)
+				}
+			}
+
+			if runs != tt.wantRuns {
+				t.Fatalf("tool ran %d times, want %d", runs, tt.wantRuns)
+			}
+			if got := runner.Cache.Len(); got != tt.wantCache {
+				t.Fatalf("Len() = %d, want %d", got, tt.wantCache)
+			}
+		})
+	}
+}
+
+func TestResultCacheConcurrentCalls(t *testing.T) {
+	cache := NewResultCache()
+	call := Call{ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"q":"go"}`)}
+	release := make(chan struct{})
+	var runs atomic.Int32
+
+	var wg sync.WaitGroup
+	results := make([]*Result, 5)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = cache.do(context.Background(), call, func() *Result {
+				runs.Add(1)
+				<-release
+				return Text("results")
+			})
+		}()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := runs.Load(); got != 1 {
+		t.Fatalf("identical concurrent calls ran %d times, want 1", got)
+	}
+	for i, result := range results {
+		if result == nil || result.Content != "results" {
+			t.Fatalf("result %d = %v", i, result)
+		}
+	}
+
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Fatal("Clear() left cached results")
+	}
+}
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"errors"
+
+	"github.com/joakimcarlsson/anthropic-sdk/budget"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+)
+
+var (
+	// ErrToolNotFound is returned when the model calls a tool that is not
+	// registered and the runner fails on unknown tools
+	ErrToolNotFound = errors.New("tool not found")
+
+	// ErrToolTimeout is returned when a tool call runs longer than the
+	// runner's tool timeout
+	ErrToolTimeout = errors.New("tool call timed out")
+
+	// ErrMaxTurnsExceeded is returned when the model keeps calling tools after
+	// the maximum number of iterations
+	ErrMaxTurnsExceeded = errors.New("tool loop exceeded the maximum number of iterations")
+
+	// ErrBudgetExceeded is returned when a run uses up its token budget. It is
+	// the error of budget.Manager, so runs through a budget.Provider fail with
+	// it too
+	ErrBudgetExceeded = budget.ErrExceeded
+)
+
+// RunError is returned when a run fails, carrying the transcript up to the
+// failure so callers can show what happened or continue from there
+type RunError struct {
+	Err error
+
+	// Messages is the request history followed by every turn completed
+	// before the failure
+	Messages []models.MessageParam
+
+	// Iterations is the number of requests made
+	Iterations int
+
+	// Usage is the combined usage of the requests made
+	Usage models.Usage
+
+	// Call is the tool call that failed, for ErrToolNotFound and
+	// ErrToolTimeout
+	Call *Call
+
+	// Recovery holds the partial response of a streamed run whose stream
+	// failed midway. Its Continue method applied to Messages continues the
+	// response where it stopped
+	Recovery *streaming.Recovery
+}
+
+// Error implements the error interface
+func (e *RunError) Error() string {
+	return "error running tools: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *RunError) Unwrap() error {
+	return e.Err
+}
+
+// streamError is the failure of a stream together with what it delivered
+type streamError struct {
+	err      error
+	recovery *streaming.Recovery
+}
+
+// Error implements the error interface
+func (e *streamError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *streamError) Unwrap() error {
+	return e.err
+}
+
+// callError is the failure of a tool call that ends the run
+type callError struct {
+	call Call
+	err  error
+}
@@ -0,0 +1,240 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/internal/safe"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/timeline"
+)
+
+// execution runs the tool calls of one response in the background. Calls of
+// tools that change state run one at a time in the order they are submitted,
+// read-only calls run concurrently between them
+type execution struct {
+	ctx        context.Context
+	cancel     context.CancelFunc
+	registry   *Registry
+	truncation *Truncation
+	cache      *ResultCache
+	approve    func(ctx context.Context, call Call) (bool, error)
+	timeout    time.Duration
+	strict     bool
+	timeline   *timeline.Timeline
+
+	mu      sync.Mutex
+	pending map[string]*pendingCall
+
+	// barrier is the last submitted call that changes state, reads are the
+	// read-only calls submitted after it
+	barrier *pendingCall
+	reads   []*pendingCall
+
+	// held is set once a call that is not read-only was seen while the
+	// response was streaming. Later calls are only submitted once it stops
+	held bool
+}
+
+// pendingCall is a submitted call whose result is available once done is
+// closed
+type pendingCall struct {
+	done   chan struct{}
+	result models.ContentBlock
+	err    error
+}
+
+// newExecution creates an execution for the next response
+func (r *Runner) newExecution(ctx context.Context) *execution {
+	ctx, cancel := context.WithCancel(ctx)
+	return &execution{
+		ctx:        ctx,
+		cancel:     cancel,
+		registry:   r.Registry,
+		truncation: r.Truncation,
+		cache:      r.Cache,
+		approve:    r.Approve,
+		timeout:    r.ToolTimeout,
+		strict:     r.FailOnUnknownTool,
+		timeline:   r.Timeline,
+		pending:    make(map[string]*pendingCall),
+	}
+}
+
+// submit starts executing a call. Read-only calls wait for the previous call
+// that changes state, other calls wait for all previously submitted calls.
+// Calls that were already submitted are ignored
+func (e *execution) submit(call Call) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.pending[call.ID]; ok {
+		return
+	}
+
+	p := &pendingCall{done: make(chan struct{})}
+	e.pending[call.ID] = p
+
+	var wait []*pendingCall
+	if e.barrier != nil {
+		wait = append(wait, e.barrier)
+	}
+	if e.effect(call) == ReadOnly {
+		e.reads = append(e.reads, p)
+	} else {
+		wait = append(wait, e.reads...)
+		e.barrier = p
+		e.reads = nil
+	}
+
+	go func() {
+		defer close(p.done)
+		for _, previous := range wait {
+			<-previous.done
+		}
+		span := e.timeline.Begin(call.Name, "tool", map[string]string{"id": call.ID})
+		p.result, p.err = e.execute(call)
+		if p.err != nil {
+			span.Set("error", p.err.Error())
+		} else if p.result.ToolResultContent != nil && p.result.ToolResultContent.IsError {
+			span.Set("error", p.result.ToolResultContent.Content)
+		}
+		span.End()
+	}()
+}
+
+// prefetch submits a call completed while the response is still streaming if
+// it is read-only. Calls that may change state, and every call after them,
+// wait for the response to stop, since a response ending for another reason
+// or failing midway must not have side effects
+func (e *execution) prefetch(call Call) {
+	e.mu.Lock()
+	if e.effect(call) != ReadOnly {
+		e.held = true
+	}
+	held := e.held
+	e.mu.Unlock()
+
+	if !held {
+		e.submit(call)
+	}
+}
+
+// stop cancels the submitted calls and waits for them to return. It is called
+// once the results of the execution are no longer needed
+func (e *execution) stop() {
+	e.cancel()
+
+	e.mu.Lock()
+	pending := make([]*pendingCall, 0, len(e.pending))
+	for _, p := range e.pending {
+		pending = append(pending, p)
+	}
+	e.mu.Unlock()
+
+	for _, p := range pending {
+		<-p.done
+	}
+}
+
+// effect returns the effect of the called tool, unknown tools are treated as
+// side-effecting
+func (e *execution) effect(call Call) Effect {
+	if e.registry == nil {
+		return SideEffecting
+	}
+	tool, ok := e.registry.Get(call.Name)
+	if !ok {
+		return SideEffecting
+	}
+	return tool.Effect
+}
+
+// execute runs a single call, asking for approval of side-effecting tools and
+// answering read-only tools from the cache when possible, and truncates its
+// result. Side-effecting calls only get here through results, so approval is
+// never asked for a response that is still streaming. Unknown tools in strict executions and timed out calls return an
+// error ending the run
+func (e *execution) execute(call Call) (models.ContentBlock, error) {
+	if e.registry == nil {
+		if e.strict {
+			return models.ContentBlock{}, fmt.Errorf("%w: %q", ErrToolNotFound, call.Name)
+		}
+		return models.CreateToolResultBlock(call.ID, "no tools are registered", true), nil
+	}
+
+	var callErr error
+	run := func() *Result {
+		result, err := e.registry.invoke(e.ctx, call, e.timeout)
+		if err != nil {
+			if e.strict || !errors.Is(err, ErrToolNotFound) {
+				callErr = err
+			}
+			return Error(err.Error())
+		}
+		return result
+	}
+
+	var result *Result
+	_, known := e.registry.Get(call.Name)
+	switch effect := e.effect(call); {
+	case effect == ReadOnly && e.cache != nil:
+		result = e.cache.do(e.ctx, call, run)
+	case effect == SideEffecting && known && e.approve != nil:
+		result = e.approved(call, run)
+	default:
+		result = run()
+	}
+	if callErr != nil {
+		return models.ContentBlock{}, callErr
+	}
+
+	if e.truncation != nil && len(result.Blocks) == 0 {
+		truncated := *result
+		truncated.Content = e.truncation.Apply(e.ctx, result.Content)
+		result = &truncated
+	}
+	return result.block(call.ID), nil
+}
+
+// approved runs the call once it is approved
+func (e *execution) approved(call Call, run func() *Result) *Result {
+	var ok bool
+	err := safe.Guard{Propagate: e.registry.PropagatePanics}.Call("approval callback", func() error {
+		var err error
+		ok, err = e.approve(e.ctx, call)
+		return err
+	})
+	if err != nil {
+		return Error(fmt.Sprintf("error approving tool call: %v", err))
+	}
+	if !ok {
+		return Error("the tool call was not approved")
+	}
+	return run()
+}
+
+// results submits the calls that were not submitted yet and returns the
+// results of all calls in order, or the error of the first failed call
+func (e *execution) results(calls []Call) ([]models.ContentBlock, *callError) {
+	for _, call := range calls {
+		e.submit(call)
+	}
+
+	results := make([]models.ContentBlock, len(calls))
+	for i, call := range calls {
+		e.mu.Lock()
+		p := e.pending[call.ID]
+		e.mu.Unlock()
+
+		<-p.done
+		if p.err != nil {
+			return nil, &callError{call: call, err: p.err}
+		}
+		results[i] = p.result
+	}
+	return results, nil
+}
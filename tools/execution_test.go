@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// trace records when the tools of a test start and end
+type trace struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (tr *trace) add(event string) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, event)
+}
+
+// index returns the position of an event, or -1
+func (tr *trace) index(event string) int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	for i, e := range tr.events {
+		if e == event {
+			return i
+		}
+	}
+	return -1
+}
+
+// tool returns a tool that records its calls by call ID and takes a moment, so
+// calls that are not ordered overlap
+func (tr *trace) tool(name string, effect Effect) Tool {
+	return New(name, name, models.InputSchema{Type: "object"}, func(ctx context.Context, input json.RawMessage) (*Result, error) {
+		var in struct{ ID string }
+		json.Unmarshal(input, &in)
+		tr.add("start " + in.ID)
+		time.Sleep(20 * time.Millisecond)
+		tr.add("end " + in.ID)
+		return Text(in.ID), nil
+	}).WithEffect(effect)
+}
+
+// calls returns the calls of a response, as tool name and call ID pairs
+func calls(pairs ...string) []Call {
+	var list []Call
+	for i := 0; i < len(pairs); i += 2 {
+		list = append(list, Call{ID: pairs[i+1], Name: pairs[i], Input: json.RawMessage(fmt.Sprintf(`{"id":%q}`, pairs[i+1]))})
+	}
+	return list
+}
+
+func TestExecutionOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		calls []Call
+		// before lists pairs of calls where the first ends before the second
+		// starts
+		before [][2]string
+	}{
+		{
+			name:   "writes run one at a time in order",
+			calls:  calls("write", "w1", "write", "w2", "upsert", "w3"),
+			before: [][2]string{{"w1", "w2"}, {"w2", "w3"}},
+		},
+		{
+			name:   "write waits for earlier reads",
+			calls:  calls("read", "r1", "read", "r2", "write", "w1"),
+			before: [][2]string{{"r1", "w1"}, {"r2", "w1"}},
+		},
+		{
+			name:   "reads wait for earlier writes",
+			calls:  calls("write", "w1", "read", "r1", "read", "r2", "write", "w2", "read", "r3"),
+			before: [][2]string{{"w1", "r1"}, {"w1", "r2"}, {"r1", "w2"}, {"r2", "w2"}, {"w2", "r3"}},
+		},
+		{
+			name:   "unknown tools change state",
+			calls:  calls("read", "r1", "missing", "m1", "read", "r2"),
+			before: [][2]string{{"r1", "r2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &trace{}
+			runner := NewRunner(nil, tr.tool("read", ReadOnly), tr.tool("write", SideEffecting), tr.tool("upsert", Idempotent))
+			exec := runner.newExecution(context.Background())
+			defer exec.stop()
+
+			results, callErr := exec.results(tt.calls)
+			if callErr != nil {
+				t.Fatalf("results() error = %v", callErr.err)
+			}
+			for i, call := range tt.calls {
+				if got := results[i].ToolResultContent.ToolUseID; got != call.ID {
+					t.Fatalf("result %d answers %s, want %s", i, got, call.ID)
+				}
+			}
+			for _, pair := range tt.before {
+				if end, start := tr.index("end "+pair[0]), tr.index("start "+pair[1]); end < 0 || start < end {
+					t.Fatalf("%s started before %s ended: %q", pair[1], pair[0], tr.events)
+				}
+			}
+		})
+	}
+}
+
+func TestExecutionRunsReadsConcurrently(t *testing.T) {
+	const reads = 3
+	var started sync.WaitGroup
+	started.Add(reads)
+	read := New("read", "read", models.InputSchema{Type: "object"}, func(ctx context.Context, input json.RawMessage) (*Result, error) {
+		// Every read waits for the others to start, so reads that run one at
+		// a time time out
+		started.Done()
+		done := make(chan struct{})
+		go func() { started.Wait(); close(done) }()
+		select {
+		case <-done:
+			return Text("read"), nil
+		case <-time.After(time.Second):
+			return Error("reads did not run concurrently"), nil
+		}
+	}).WithEffect(ReadOnly)
+
+	exec := NewRunner(nil, read).newExecution(context.Background())
+	defer exec.stop()
+	results, callErr := exec.results(calls("read", "r1", "read", "r2", "read", "r3"))
+	if callErr != nil {
+		t.Fatalf("results() error = %v", callErr.err)
+	}
+	for _, result := range results {
+		if result.ToolResultContent.IsError {
+			t.Fatal(result.ToolResultContent.Content)
+		}
+	}
+}
+
+func TestExecutionPrefetch(t *testing.T) {
+	tests := []struct {
+		name      string
+		prefetch  []Call
+		submitted []string
+	}{
+		{name: "reads", prefetch: calls("read", "r1", "read", "r2"), submitted: []string{"r1", "r2"}},
+		{name: "write", prefetch: calls("write", "w1")},
+		{name: "reads after a write", prefetch: calls("read", "r1", "write", "w1", "read", "r2"), submitted: []string{"r1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &trace{}
+			exec := NewRunner(nil, tr.tool("read", ReadOnly), tr.tool("write", SideEffecting)).newExecution(context.Background())
+			for _, call := range tt.prefetch {
+				exec.prefetch(call)
+			}
+			exec.stop()
+
+			var submitted []string
+			for _, call := range tt.prefetch {
+				if _, ok := exec.pending[call.ID]; ok {
+					submitted = append(submitted, call.ID)
+				}
+			}
+			if !reflect.DeepEqual(submitted, tt.submitted) {
+				t.Fatalf("submitted calls = %q, want %q", submitted, tt.submitted)
+			}
+		})
+	}
+}
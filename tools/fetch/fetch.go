@@ -0,0 +1,323 @@
+// Package fetch provides a fetch_url tool that lets the model retrieve web
+// pages from an allowlist of domains, with a timeout, a size limit and
+// optional conversion of HTML to markdown. Connections to loopback, private,
+// link-local and other non-public addresses are refused after DNS resolution,
+// so the model cannot reach internal services or cloud metadata endpoints
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultName is the default tool name
+	DefaultName = "fetch_url"
+
+	// DefaultTimeout is the default time limit of a fetch
+	DefaultTimeout = 15 * time.Second
+
+	// DefaultMaxBytes is the default number of body bytes read
+	DefaultMaxBytes = 256 << 10
+
+	// maxRedirects is the number of redirects followed
+	maxRedirects = 5
+)
+
+// ErrDomainNotAllowed is returned for URLs outside the allowlist
+var ErrDomainNotAllowed = errors.New("domain is not allowed")
+
+// ErrAddressNotAllowed is returned when a host resolves to a loopback,
+// private, link-local or other non-public address
+var ErrAddressNotAllowed = errors.New("address is not allowed")
+
+// nonPublicPrefixes are the non-public ranges not covered by the netip.Addr
+// predicates
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// Options configures the fetch tool
+type Options struct {
+	// Name is the tool name, defaults to DefaultName
+	Name string
+
+	// AllowedDomains lists the domains that can be fetched. A domain also
+	// allows its subdomains. Nothing can be fetched when it is empty, unless
+	// AllowAllDomains is set
+	AllowedDomains []string
+
+	// AllowAllDomains allows fetching any domain
+	AllowAllDomains bool
+
+	// AllowPrivateNetworks allows connecting to loopback, private, link-local
+	// and other non-public addresses, which are refused by default even for
+	// allowed domains
+	AllowPrivateNetworks bool
+
+	// Timeout limits the duration of a fetch, defaults to DefaultTimeout
+	Timeout time.Duration
+
+	// MaxBytes limits the number of body bytes read, defaults to
+	// DefaultMaxBytes
+	MaxBytes int64
+
+	// Markdown converts HTML bodies to markdown
+	Markdown bool
+
+	// HTTPClient sends the requests, defaults to a client without cookies or
+	// proxies. Its redirect policy is replaced to enforce the allowlist. Unless
+	// AllowPrivateNetworks is set, its transport must be nil or an
+	// *http.Transport, whose dial functions are replaced to refuse non-public
+	// addresses. Through a proxy, the address of the proxy is checked
+	HTTPClient *http.Client
+
+	// UserAgent is sent with every request when set
+	UserAgent string
+}
+
+// input is the input of the fetch tool
+type input struct {
+	URL string `json:"url" description:"The http or https URL to fetch"`
+}
+
+// New creates the fetch tool
+func New(opts Options) (tools.Tool, error) {
+	name := opts.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	description := "Fetches a web page and returns its content"
+	if opts.Markdown {
+		description += ", with HTML converted to markdown"
+	}
+	description += "."
+	if !opts.AllowAllDomains {
+		description += " Only these domains and their subdomains can be fetched: " + strings.Join(opts.AllowedDomains, ", ") + "."
+	}
+
+	tool, err := tools.Func(name, description, func(ctx context.Context, in input) (string, error) {
+		return Fetch(ctx, in.URL, opts)
+	})
+	if err != nil {
+		return tools.Tool{}, err
+	}
+	return tool.WithEffect(tools.ReadOnly), nil
+}
+
+// Fetch retrieves a URL allowed by the options and returns its body as text
+func Fetch(ctx context.Context, rawURL string, opts Options) (string, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("error parsing URL: %w", err)
+	}
+	if err := opts.check(target); err != nil {
+		return "", err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html, text/plain, application/json;q=0.9, */*;q=0.1")
+	if opts.UserAgent != "" {
+		req.Header.Set("User-Agent", opts.UserAgent)
+	}
+
+	client, err := opts.client()
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", target, err)
+	}
+	truncated := int64(len(body)) > maxBytes
+	if truncated {
+		body = body[:maxBytes]
+	}
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("error fetching %s: status %d", target, resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !isText(mediaType) {
+		return "", fmt.Errorf("error fetching %s: unsupported content type %q", target, mediaType)
+	}
+
+	content := string(body)
+	if opts.Markdown && mediaType == "text/html" {
+		content = Markdown(content, resp.Request.URL)
+	}
+	if truncated {
+		content += fmt.Sprintf("\n\n[content truncated after %d bytes]", maxBytes)
+	}
+	return content, nil
+}
+
+// check returns an error unless the URL is http or https and its host is
+// allowed
+func (o Options) check(target *url.URL) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("error fetching %s: unsupported scheme %q", target, target.Scheme)
+	}
+	if target.User != nil {
+		return fmt.Errorf("error fetching %s: URLs with credentials are not allowed", target.Redacted())
+	}
+	if o.AllowAllDomains {
+		return nil
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(target.Hostname()), ".")
+	for _, domain := range o.AllowedDomains {
+		domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("error fetching %s: %w", target, ErrDomainNotAllowed)
+}
+
+// client returns the HTTP client with a redirect policy enforcing the
+// allowlist and, unless private networks are allowed, a transport refusing
+// non-public addresses
+func (o Options) client() (*http.Client, error) {
+	client := &http.Client{}
+	if o.HTTPClient != nil {
+		copied := *o.HTTPClient
+		client = &copied
+	}
+	if !o.AllowPrivateNetworks {
+		transport, err := publicTransport(client.Transport)
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = transport
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		return o.check(req.URL)
+	}
+	return client, nil
+}
+
+// publicTransports caches the transports refusing non-public addresses, by the
+// transport they were derived from, so connections are reused across fetches
+var publicTransports sync.Map
+
+// publicTransport returns a transport like the given one that refuses to
+// connect to non-public addresses. A nil transport is replaced by a copy of
+// http.DefaultTransport without a proxy, since a proxy would resolve the
+// target itself
+func publicTransport(rt http.RoundTripper) (*http.Transport, error) {
+	var base *http.Transport
+	switch t := rt.(type) {
+	case nil:
+	case *http.Transport:
+		base = t
+	default:
+		return nil, fmt.Errorf("error fetching: the HTTP client's transport must be an *http.Transport to refuse non-public addresses, unless AllowPrivateNetworks is set")
+	}
+	if cached, ok := publicTransports.Load(base); ok {
+		return cached.(*http.Transport), nil
+	}
+
+	var transport *http.Transport
+	if base == nil {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+	} else {
+		transport = base.Clone()
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialControl}
+	transport.DialContext = dialer.DialContext
+	transport.DialTLSContext = nil
+	transport.Dial = nil
+	transport.DialTLS = nil
+
+	cached, _ := publicTransports.LoadOrStore(base, transport)
+	return cached.(*http.Transport), nil
+}
+
+// dialControl is the dialer control of the transports refusing non-public
+// addresses, replaced in tests to reach a local server
+var dialControl = checkAddress
+
+// checkAddress is the dialer control refusing connections to non-public
+// addresses. It runs after DNS resolution, so hosts resolving to internal
+// addresses are refused too
+func checkAddress(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, address)
+	}
+	if addr := addrPort.Addr().Unmap(); !isPublic(addr) {
+		return fmt.Errorf("%w: %s", ErrAddressNotAllowed, addr)
+	}
+	return nil
+}
+
+// isPublic reports whether an address is a public unicast address
+func isPublic(addr netip.Addr) bool {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// isText reports whether a media type can be returned as text
+func isText(mediaType string) bool {
+	switch {
+	case mediaType == "", strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/javascript":
+		return true
+	}
+	return false
+}
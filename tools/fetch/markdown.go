@@ -0,0 +1,363 @@
+package fetch
+
+import (
+	"bytes"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// skippedElements are elements whose content is not converted
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true,
+	"template": true, "svg": true, "iframe": true, "object": true,
+}
+
+// blockElements are elements separated from their surroundings by a blank
+// line
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "aside": true, "nav": true, "blockquote": true,
+	"table": true, "figure": true, "form": true, "dl": true, "address": true,
+}
+
+// voidElements are elements without an end tag
+var voidElements = map[string]bool{
+	"br": true, "hr": true, "img": true, "input": true, "meta": true, "link": true,
+	"area": true, "base": true, "col": true, "embed": true, "source": true, "wbr": true,
+}
+
+// Markdown converts an HTML document to markdown, keeping headings, lists,
+// links, emphasis and code and dropping scripts, styles and other markup.
+// Relative links are resolved against base when it is not nil
+func Markdown(document string, base *url.URL) string {
+	c := &converter{base: base}
+	c.convert(document)
+	return c.result()
+}
+
+// converter converts HTML to markdown in a single pass
+type converter struct {
+	out  []byte
+	base *url.URL
+
+	skip  []string
+	pre   int
+	lists []int
+	links []link
+}
+
+// link is an open anchor element
+type link struct {
+	href  string
+	start int
+}
+
+// convert scans the document, converting tags and text as they appear
+func (c *converter) convert(document string) {
+	for len(document) > 0 {
+		i := strings.IndexByte(document, '<')
+		if i < 0 {
+			c.text(document)
+			return
+		}
+		c.text(document[:i])
+		document = document[i:]
+
+		switch {
+		case strings.HasPrefix(document, "<!--"):
+			end := strings.Index(document, "-->")
+			if end < 0 {
+				return
+			}
+			document = document[end+3:]
+		case strings.HasPrefix(document, "<!"), strings.HasPrefix(document, "<?"):
+			end := strings.IndexByte(document, '>')
+			if end < 0 {
+				return
+			}
+			document = document[end+1:]
+		default:
+			end := tagEnd(document)
+			if end < 0 {
+				c.text(document)
+				return
+			}
+			name, attrs, closing := parseTag(document[1:end])
+			if name == "" {
+				// Not a tag, such as a < in text
+				c.text("<")
+				document = document[1:]
+				continue
+			}
+			document = document[end+1:]
+			if closing {
+				c.end(name)
+			} else {
+				c.start(name, attrs)
+				if voidElements[name] {
+					c.end(name)
+				}
+			}
+		}
+	}
+}
+
+// start handles a start tag
+func (c *converter) start(name string, attrs map[string]string) {
+	if len(c.skip) > 0 || skippedElements[name] {
+		if !voidElements[name] {
+			c.skip = append(c.skip, name)
+		}
+		return
+	}
+
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+		level, _ := strconv.Atoi(name[1:])
+		c.write(strings.Repeat("#", level) + " ")
+	case "br":
+		c.write("\n")
+	case "hr":
+		c.block()
+		c.write("---")
+		c.block()
+	case "ul":
+		c.listBreak()
+		c.lists = append(c.lists, 0)
+	case "ol":
+		c.listBreak()
+		c.lists = append(c.lists, 1)
+	case "li":
+		c.newline()
+		c.write(strings.Repeat("  ", max(len(c.lists)-1, 0)))
+		if n := len(c.lists); n > 0 && c.lists[n-1] > 0 {
+			c.write(strconv.Itoa(c.lists[n-1]) + ". ")
+			c.lists[n-1]++
+		} else {
+			c.write("- ")
+		}
+	case "tr", "dt", "dd":
+		c.newline()
+	case "td", "th":
+		c.write(" ")
+	case "pre":
+		c.block()
+		c.write("```\n")
+		c.pre++
+	case "code":
+		if c.pre == 0 {
+			c.write("`")
+		}
+	case "strong", "b":
+		c.write("**")
+	case "em", "i":
+		c.write("_")
+	case "a":
+		c.links = append(c.links, link{href: c.resolve(attrs["href"]), start: len(c.out)})
+	case "img":
+		if alt := strings.TrimSpace(attrs["alt"]); alt != "" {
+			c.write("![" + alt + "](" + c.resolve(attrs["src"]) + ")")
+		}
+	default:
+		if blockElements[name] {
+			c.block()
+		}
+	}
+}
+
+// end handles an end tag
+func (c *converter) end(name string) {
+	if n := len(c.skip); n > 0 {
+		if c.skip[n-1] == name {
+			c.skip = c.skip[:n-1]
+		}
+		return
+	}
+
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+	case "ul", "ol":
+		if n := len(c.lists); n > 0 {
+			c.lists = c.lists[:n-1]
+		}
+		c.listBreak()
+	case "pre":
+		if c.pre > 0 {
+			c.pre--
+			c.newline()
+			c.write("```")
+			c.block()
+		}
+	case "code":
+		if c.pre == 0 {
+			c.write("`")
+		}
+	case "strong", "b":
+		c.write("**")
+	case "em", "i":
+		c.write("_")
+	case "a":
+		n := len(c.links)
+		if n == 0 {
+			return
+		}
+		l := c.links[n-1]
+		c.links = c.links[:n-1]
+		text := strings.TrimSpace(string(c.out[l.start:]))
+		if l.href == "" || text == "" || strings.HasPrefix(l.href, "#") {
+			return
+		}
+		c.out = append(c.out[:l.start], "["+text+"]("+l.href+")"...)
+	default:
+		if blockElements[name] {
+			c.block()
+		}
+	}
+}
+
+// text writes text, collapsing whitespace outside of preformatted blocks
+func (c *converter) text(text string) {
+	if len(c.skip) > 0 || text == "" {
+		return
+	}
+	text = html.UnescapeString(text)
+	if c.pre > 0 {
+		c.write(text)
+		return
+	}
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if !c.atLineStart() {
+			c.write(" ")
+		}
+		return
+	}
+	if isSpace(text[0]) && !c.atLineStart() {
+		c.write(" ")
+	}
+	c.write(strings.Join(fields, " "))
+	if isSpace(text[len(text)-1]) {
+		c.write(" ")
+	}
+}
+
+// write appends s to the output
+func (c *converter) write(s string) {
+	c.out = append(c.out, s...)
+}
+
+// atLineStart reports whether the output ends with a line break or a space
+func (c *converter) atLineStart() bool {
+	return len(c.out) == 0 || c.out[len(c.out)-1] == '\n' || c.out[len(c.out)-1] == ' '
+}
+
+// newline ends the current line
+func (c *converter) newline() {
+	c.out = bytes.TrimRight(c.out, " ")
+	if len(c.out) > 0 && c.out[len(c.out)-1] != '\n' {
+		c.write("\n")
+	}
+}
+
+// block separates the following content with a blank line
+func (c *converter) block() {
+	c.newline()
+	if len(c.out) > 0 && !bytes.HasSuffix(c.out, []byte("\n\n")) {
+		c.write("\n")
+	}
+}
+
+// listBreak separates top level lists with a blank line and nested lists with
+// a line break
+func (c *converter) listBreak() {
+	if len(c.lists) > 0 {
+		c.newline()
+		return
+	}
+	c.block()
+}
+
+// resolve resolves a link against the base URL, dropping script links
+func (c *converter) resolve(href string) string {
+	href = strings.TrimSpace(href)
+	if strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return ""
+	}
+	if c.base == nil || href == "" {
+		return href
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	return c.base.ResolveReference(ref).String()
+}
+
+// blankLines matches runs of blank lines
+var blankLines = regexp.MustCompile(`\n[ \t]*\n(?:[ \t]*\n)+`)
+
+// result returns the converted markdown
+func (c *converter) result() string {
+	return strings.TrimSpace(blankLines.ReplaceAllString(string(c.out), "\n\n"))
+}
+
+// tagEnd returns the index of the > ending the tag at the start of s, skipping
+// quoted attribute values
+func tagEnd(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// attrPattern matches an attribute with an optional quoted or unquoted value
+var attrPattern = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+)))?`)
+
+// parseTag parses the content of a tag between < and >
+func parseTag(tag string) (name string, attrs map[string]string, closing bool) {
+	tag = strings.TrimSuffix(strings.TrimSpace(tag), "/")
+	if strings.HasPrefix(tag, "/") {
+		closing = true
+		tag = tag[1:]
+	}
+
+	end := strings.IndexFunc(tag, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '/'
+	})
+	if end < 0 {
+		end = len(tag)
+	}
+	name = strings.ToLower(tag[:end])
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return "", nil, false
+		}
+	}
+
+	attrs = make(map[string]string)
+	for _, match := range attrPattern.FindAllStringSubmatch(tag[end:], -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+	return name, attrs, closing
+}
+
+// isSpace reports whether b is an ASCII whitespace character
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == '\f'
+}
@@ -0,0 +1,314 @@
+// Package fstools provides tools to list, read, search and write files below a
+// root directory. All access goes through an os.Root, so paths cannot escape
+// the directory through .. components or symbolic links
+package fstools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultMaxReadBytes is the default size of a chunk returned by read_file
+	DefaultMaxReadBytes = 32 << 10
+
+	// DefaultMaxWriteBytes is the default size limit of write_file
+	DefaultMaxWriteBytes = 1 << 20
+
+	// DefaultMaxEntries is the default number of entries returned by
+	// list_files and glob_files
+	DefaultMaxEntries = 500
+)
+
+// Options configures the file tools
+type Options struct {
+	// ReadOnly omits the write_file tool
+	ReadOnly bool
+
+	// WriteEffect is the effect of write_file. It defaults to
+	// tools.SideEffecting, so a runner asks for approval before overwriting
+	// files. tools.Idempotent lets writes run without approval
+	WriteEffect tools.Effect
+
+	// MaxReadBytes is the size of a chunk returned by read_file, larger files
+	// are read in several calls. Defaults to DefaultMaxReadBytes
+	MaxReadBytes int
+
+	// MaxWriteBytes limits the size of written files, defaults to
+	// DefaultMaxWriteBytes
+	MaxWriteBytes int
+
+	// MaxEntries limits the number of entries returned by list_files and
+	// glob_files, defaults to DefaultMaxEntries
+	MaxEntries int
+}
+
+// FS holds the root directory the file tools operate in
+type FS struct {
+	root *os.Root
+	opts Options
+}
+
+// Open opens the directory the file tools operate in
+func Open(dir string, opts Options) (*FS, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening root directory: %w", err)
+	}
+	return &FS{root: root, opts: opts}, nil
+}
+
+// Close closes the root directory
+func (f *FS) Close() error {
+	return f.root.Close()
+}
+
+// listInput is the input of list_files
+type listInput struct {
+	Path      string `json:"path,omitempty" description:"Directory relative to the root, defaults to the root"`
+	Recursive bool   `json:"recursive,omitempty" description:"List the entries of subdirectories too"`
+}
+
+// readInput is the input of read_file
+type readInput struct {
+	Path   string `json:"path" description:"File relative to the root"`
+	Offset int64  `json:"offset,omitempty" description:"Byte offset to start reading at, used to read the next chunk of large files"`
+}
+
+// globInput is the input of glob_files
+type globInput struct {
+	Pattern string `json:"pattern" description:"Glob pattern relative to the root, for example src/*.go"`
+}
+
+// writeInput is the input of write_file
+type writeInput struct {
+	Path    string `json:"path" description:"File relative to the root, parent directories are created"`
+	Content string `json:"content" description:"The complete new content of the file"`
+}
+
+// Tools returns the list_files, read_file, glob_files and, unless read-only,
+// write_file tools
+func (f *FS) Tools() ([]tools.Tool, error) {
+	list, err := tools.Func("list_files", "Lists the files and directories in a directory. Directories end with a slash.", f.list)
+	if err != nil {
+		return nil, err
+	}
+	read, err := tools.Func("read_file", fmt.Sprintf("Reads a text file. Files larger than %d bytes are returned in chunks, with the offset of the next chunk at the end.", f.maxReadBytes()), f.read)
+	if err != nil {
+		return nil, err
+	}
+	glob, err := tools.Func("glob_files", "Finds files whose path matches a glob pattern.", f.glob)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []tools.Tool{list.WithEffect(tools.ReadOnly), read.WithEffect(tools.ReadOnly), glob.WithEffect(tools.ReadOnly)}
+	if !f.opts.ReadOnly {
+		write, err := tools.Func("write_file", "Creates or replaces a file with the given content.", f.write)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, write.WithEffect(f.opts.WriteEffect))
+	}
+	return result, nil
+}
+
+// list lists a directory
+func (f *FS) list(ctx context.Context, in listInput) (string, error) {
+	dir := clean(in.Path)
+	fsys := f.root.FS()
+
+	var entries []string
+	truncated := false
+	err := fs.WalkDir(fsys, dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == dir {
+			return nil
+		}
+		if len(entries) == f.maxEntries() {
+			truncated = true
+			return fs.SkipAll
+		}
+
+		rel := strings.TrimPrefix(name, dir+"/")
+		if dir == "." {
+			rel = name
+		}
+		if entry.IsDir() {
+			entries = append(entries, rel+"/")
+			if !in.Recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		entries = append(entries, rel)
+		return ctx.Err()
+	})
+	if err != nil {
+		return "", fmt.Errorf("error listing %s: %w", dir, err)
+	}
+
+	if len(entries) == 0 {
+		return "The directory is empty.", nil
+	}
+	result := strings.Join(entries, "\n")
+	if truncated {
+		result += fmt.Sprintf("\n[listing truncated after %d entries]", len(entries))
+	}
+	return result, nil
+}
+
+// read reads a chunk of a file
+func (f *FS) read(ctx context.Context, in readInput) (string, error) {
+	name := clean(in.Path)
+	file, err := f.root.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("error opening %s: %w", name, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("error reading %s: is a directory", name)
+	}
+	if in.Offset < 0 || in.Offset > info.Size() {
+		return "", fmt.Errorf("error reading %s: offset %d outside the file of %d bytes", name, in.Offset, info.Size())
+	}
+
+	chunk := make([]byte, f.maxReadBytes())
+	n, err := file.ReadAt(chunk, in.Offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("error reading %s: %w", name, err)
+	}
+	chunk = chunk[:n]
+
+	end := in.Offset + int64(n)
+	if end < info.Size() {
+		// Do not split a multi-byte character between chunks
+		for i := 0; i < utf8.UTFMax && len(chunk) > 0 && !utf8.Valid(chunk); i++ {
+			chunk = chunk[:len(chunk)-1]
+		}
+		end = in.Offset + int64(len(chunk))
+	}
+	if !utf8.Valid(chunk) {
+		return "", fmt.Errorf("error reading %s: not a text file", name)
+	}
+
+	if in.Offset == 0 && end == info.Size() {
+		return string(chunk), nil
+	}
+	result := fmt.Sprintf("[bytes %d-%d of %d]\n%s", in.Offset, end, info.Size(), chunk)
+	if end < info.Size() {
+		result += fmt.Sprintf("\n[call read_file with offset %d to continue]", end)
+	}
+	return result, nil
+}
+
+// glob finds files matching a pattern
+func (f *FS) glob(ctx context.Context, in globInput) (string, error) {
+	pattern := path.Clean(strings.TrimPrefix(in.Pattern, "/"))
+	matches, err := fs.Glob(f.root.FS(), pattern)
+	if err != nil {
+		return "", fmt.Errorf("error matching %s: %w", in.Pattern, err)
+	}
+	if len(matches) == 0 {
+		return "No files match the pattern.", nil
+	}
+
+	sort.Strings(matches)
+	result := matches
+	if len(matches) > f.maxEntries() {
+		result = matches[:f.maxEntries()]
+	}
+	text := strings.Join(result, "\n")
+	if len(result) < len(matches) {
+		text += fmt.Sprintf("\n[%d of %d matches shown]", len(result), len(matches))
+	}
+	return text, nil
+}
+
+// write replaces a file, creating its parent directories
+func (f *FS) write(ctx context.Context, in writeInput) (string, error) {
+	name := clean(in.Path)
+	if name == "." {
+		return "", fmt.Errorf("error writing: missing path")
+	}
+	if len(in.Content) > f.maxWriteBytes() {
+		return "", fmt.Errorf("error writing %s: %d bytes exceeds the limit of %d bytes", name, len(in.Content), f.maxWriteBytes())
+	}
+
+	if err := f.mkdirAll(path.Dir(name)); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", name, err)
+	}
+	file, err := f.root.Create(name)
+	if err != nil {
+		return "", fmt.Errorf("error writing %s: %w", name, err)
+	}
+	if _, err := io.WriteString(file, in.Content); err != nil {
+		file.Close()
+		return "", fmt.Errorf("error writing %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return "", fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return fmt.Sprintf("Wrote %d bytes to %s.", len(in.Content), name), nil
+}
+
+// mkdirAll creates a directory and its parents below the root
+func (f *FS) mkdirAll(dir string) error {
+	if dir == "." {
+		return nil
+	}
+	current := ""
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		if err := f.root.Mkdir(current, 0o755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// clean returns the slash separated path relative to the root
+func clean(name string) string {
+	return path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+}
+
+// maxReadBytes returns the size of a read chunk
+func (f *FS) maxReadBytes() int {
+	if f.opts.MaxReadBytes > 0 {
+		return f.opts.MaxReadBytes
+	}
+	return DefaultMaxReadBytes
+}
+
+// maxWriteBytes returns the size limit of written files
+func (f *FS) maxWriteBytes() int {
+	if f.opts.MaxWriteBytes > 0 {
+		return f.opts.MaxWriteBytes
+	}
+	return DefaultMaxWriteBytes
+}
+
+// maxEntries returns the number of entries returned by listings
+func (f *FS) maxEntries() int {
+	if f.opts.MaxEntries > 0 {
+		return f.opts.MaxEntries
+	}
+	return DefaultMaxEntries
+}
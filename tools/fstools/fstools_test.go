@@ -0,0 +1,158 @@
+package fstools
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const secret = "top secret"
+
+// sandbox creates a root directory next to a directory holding a secret, with
+// symbolic links from the root pointing at it, and opens the file tools on the
+// root
+func sandbox(t *testing.T, opts Options) (map[string]tools.Tool, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	outside := filepath.Join(base, "outside")
+	root := filepath.Join(base, "root")
+	for _, dir := range []string{outside, filepath.Join(root, "docs")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte(secret), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "docs", "readme.txt"), []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "dir-link")); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "file-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := Open(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsys.Close() })
+
+	list, err := fsys.Tools()
+	if err != nil {
+		t.Fatal(err)
+	}
+	byName := make(map[string]tools.Tool, len(list))
+	for _, tool := range list {
+		byName[tool.Definition.Name] = tool
+	}
+	return byName, outside
+}
+
+// call runs a tool and returns its content, or the error of a failed call
+func call(t *testing.T, tool tools.Tool, input string) (string, bool) {
+	t.Helper()
+	result, err := tool.Handler(context.Background(), json.RawMessage(input))
+	if err != nil {
+		return err.Error(), true
+	}
+	return result.Content, result.IsError
+}
+
+func TestPathEscape(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		input string
+	}{
+		{name: "read parent", tool: "read_file", input: `{"path":"../outside/secret.txt"}`},
+		{name: "read absolute", tool: "read_file", input: `{"path":"/../outside/secret.txt"}`},
+		{name: "read nested parent", tool: "read_file", input: `{"path":"docs/../../outside/secret.txt"}`},
+		{name: "read backslashes", tool: "read_file", input: `{"path":"..\\outside\\secret.txt"}`},
+		{name: "read file symlink", tool: "read_file", input: `{"path":"file-link"}`},
+		{name: "read through directory symlink", tool: "read_file", input: `{"path":"dir-link/secret.txt"}`},
+		{name: "list parent", tool: "list_files", input: `{"path":"../outside"}`},
+		{name: "list directory symlink", tool: "list_files", input: `{"path":"dir-link"}`},
+		{name: "glob parent", tool: "glob_files", input: `{"pattern":"../outside/*"}`},
+		{name: "glob directory symlink", tool: "glob_files", input: `{"pattern":"dir-link/*"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toolset, _ := sandbox(t, Options{})
+			content, failed := call(t, toolset[tt.tool], tt.input)
+			// Errors may echo the requested path, results must not name it
+			if strings.Contains(content, secret) || !failed && strings.Contains(content, "secret.txt") {
+				t.Fatalf("%s(%s) = %q, leaked the outside directory", tt.tool, tt.input, content)
+			}
+		})
+	}
+}
+
+func TestWriteEscape(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{name: "parent", path: "../outside/secret.txt"},
+		{name: "new file in parent", path: "../outside/new.txt"},
+		{name: "nested parent", path: "docs/../../outside/new.txt"},
+		{name: "backslashes", path: `..\outside\new.txt`},
+		{name: "file symlink", path: "file-link"},
+		{name: "directory symlink", path: "dir-link/new.txt"},
+		{name: "directory symlink parent", path: "dir-link/sub/new.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toolset, outside := sandbox(t, Options{})
+			input, err := json.Marshal(map[string]string{"path": tt.path, "content": "overwritten"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, failed := call(t, toolset["write_file"], string(input))
+			if !failed {
+				t.Fatalf("write_file(%s) = %q, want an error", tt.path, content)
+			}
+
+			data, err := os.ReadFile(filepath.Join(outside, "secret.txt"))
+			if err != nil || string(data) != secret {
+				t.Fatalf("outside file = %q, %v, want it unchanged", data, err)
+			}
+			entries, err := os.ReadDir(outside)
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("outside directory has %d entries, want only the secret", len(entries))
+			}
+		})
+	}
+}
+
+func TestInsideRoot(t *testing.T) {
+	toolset, _ := sandbox(t, Options{})
+
+	if content, failed := call(t, toolset["write_file"], `{"path":"/notes/today.txt","content":"done"}`); failed {
+		t.Fatalf("write_file = %q", content)
+	}
+	if content, failed := call(t, toolset["read_file"], `{"path":"notes/../notes/today.txt"}`); failed || content != "done" {
+		t.Fatalf("read_file = %q, want the written content", content)
+	}
+	if content, failed := call(t, toolset["glob_files"], `{"pattern":"*/*.txt"}`); failed || content != "docs/readme.txt\nnotes/today.txt" {
+		t.Fatalf("glob_files = %q", content)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	toolset, _ := sandbox(t, Options{ReadOnly: true})
+	if _, ok := toolset["write_file"]; ok {
+		t.Fatal("read-only file tools include write_file")
+	}
+}
@@ -0,0 +1,293 @@
+// Package grpcbridge exposes the unary methods of a gRPC server as tools, with
+// input schemas derived from the protobuf descriptors of their request
+// messages.
+//
+// The module has no dependency on gRPC, so the bridge talks to the server
+// through two small interfaces: a Reflector listing the methods and an Invoker
+// calling a method with JSON input. The grpcreflect module implements both
+// with the server reflection service, dynamicpb and protojson
+package grpcbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+// Kind is the protobuf type of a field, named as in descriptor.proto without
+// the TYPE_ prefix, for example "string", "int64" or "message"
+type Kind string
+
+// Field kinds
+const (
+	KindDouble   Kind = "double"
+	KindFloat    Kind = "float"
+	KindInt32    Kind = "int32"
+	KindInt64    Kind = "int64"
+	KindUint32   Kind = "uint32"
+	KindUint64   Kind = "uint64"
+	KindSint32   Kind = "sint32"
+	KindSint64   Kind = "sint64"
+	KindFixed32  Kind = "fixed32"
+	KindFixed64  Kind = "fixed64"
+	KindSfixed32 Kind = "sfixed32"
+	KindSfixed64 Kind = "sfixed64"
+	KindBool     Kind = "bool"
+	KindString   Kind = "string"
+	KindBytes    Kind = "bytes"
+	KindEnum     Kind = "enum"
+	KindMessage  Kind = "message"
+)
+
+// Field describes a field of a protobuf message
+type Field struct {
+	// Name is the JSON name of the field
+	Name string
+
+	Kind     Kind
+	Repeated bool
+
+	// Description is taken from the field's leading comment when known
+	Description string
+
+	// EnumValues are the value names of enum fields
+	EnumValues []string
+
+	// Message describes message fields
+	Message *Message
+}
+
+// Message describes a protobuf message
+type Message struct {
+	// FullName is the fully qualified name, for example "acme.v1.GetUserRequest"
+	FullName string
+	Fields   []Field
+}
+
+// Method describes an RPC
+type Method struct {
+	// Service is the fully qualified service name, for example "acme.v1.Users"
+	Service string
+	Name    string
+
+	// Description is taken from the method's leading comment when known
+	Description string
+
+	Input  *Message
+	Output *Message
+
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// FullMethod returns the method name used to invoke it, for example
+// "/acme.v1.Users/GetUser"
+func (m Method) FullMethod() string {
+	return "/" + m.Service + "/" + m.Name
+}
+
+// Reflector lists the methods of a server
+type Reflector interface {
+	Methods(ctx context.Context) ([]Method, error)
+}
+
+// Invoker calls a unary method with the request encoded as protobuf JSON and
+// returns the response encoded the same way
+type Invoker interface {
+	Invoke(ctx context.Context, method Method, input json.RawMessage) (json.RawMessage, error)
+}
+
+// InvokerFunc adapts a function to an Invoker
+type InvokerFunc func(ctx context.Context, method Method, input json.RawMessage) (json.RawMessage, error)
+
+// Invoke calls f
+func (f InvokerFunc) Invoke(ctx context.Context, method Method, input json.RawMessage) (json.RawMessage, error) {
+	return f(ctx, method, input)
+}
+
+// Bridge exposes the methods of a server as tools
+type Bridge struct {
+	Reflector Reflector
+	Invoker   Invoker
+
+	// Select picks the methods exposed as tools, all unary methods are exposed
+	// when nil
+	Select func(Method) bool
+
+	// Effect classifies the side effects of a method, all methods are
+	// side-effecting when nil
+	Effect func(Method) tools.Effect
+}
+
+// New creates a bridge exposing the selected methods
+func New(reflector Reflector, invoker Invoker, selectMethod func(Method) bool) *Bridge {
+	return &Bridge{Reflector: reflector, Invoker: invoker, Select: selectMethod}
+}
+
+// Methods returns a selector picking methods by full method or by name
+func Methods(names ...string) func(Method) bool {
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		selected[name] = true
+	}
+	return func(m Method) bool {
+		return selected[m.FullMethod()] || selected[m.Service+"."+m.Name] || selected[m.Name]
+	}
+}
+
+// Tools lists the server's methods and returns a tool for each selected unary
+// method. Streaming methods are skipped
+func (b *Bridge) Tools(ctx context.Context) ([]tools.Tool, error) {
+	methods, err := b.Reflector.Methods(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error listing gRPC methods: %w", err)
+	}
+
+	var result []tools.Tool
+	names := make(map[string]string)
+	for _, method := range methods {
+		if method.ClientStreaming || method.ServerStreaming {
+			continue
+		}
+		if b.Select != nil && !b.Select(method) {
+			continue
+		}
+
+		tool := b.Tool(method)
+		name := tool.Definition.Name
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("error creating gRPC tools: %s and %s both map to tool %q", other, method.FullMethod(), name)
+		}
+		names[name] = method.FullMethod()
+		result = append(result, tool)
+	}
+	return result, nil
+}
+
+// Tool returns the tool calling a method
+func (b *Bridge) Tool(method Method) tools.Tool {
+	effect := tools.SideEffecting
+	if b.Effect != nil {
+		effect = b.Effect(method)
+	}
+
+	description := method.Description
+	if description == "" {
+		description = fmt.Sprintf("Calls the %s gRPC method.", method.FullMethod())
+	}
+
+	return tools.Tool{
+		Definition: models.NewTool(ToolName(method), description, Schema(method.Input)),
+		Handler: func(ctx context.Context, input json.RawMessage) (*tools.Result, error) {
+			output, err := b.Invoker.Invoke(ctx, method, input)
+			if err != nil {
+				return tools.Error(fmt.Sprintf("error calling %s: %v", method.FullMethod(), err)), nil
+			}
+			return tools.Text(string(output)), nil
+		},
+		Effect: effect,
+	}
+}
+
+// invalidNameChars matches the characters not allowed in tool names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// maxNameLength is the longest tool name accepted by the API
+const maxNameLength = 64
+
+// ToolName returns the tool name of a method, the short service name and the
+// method name joined by an underscore
+func ToolName(method Method) string {
+	service := method.Service
+	if i := strings.LastIndex(service, "."); i >= 0 {
+		service = service[i+1:]
+	}
+
+	name := invalidNameChars.ReplaceAllString(service+"_"+method.Name, "_")
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+// Schema derives the input schema of a request message
+func Schema(message *Message) models.InputSchema {
+	if message == nil {
+		return models.SimpleJSONSchema(map[string]models.Property{}, nil)
+	}
+	property := messageProperty(message, nil)
+	return models.SimpleJSONSchema(property.Properties, nil)
+}
+
+// wellKnownStrings are the well-known types encoded as JSON strings
+var wellKnownStrings = map[string]string{
+	"google.protobuf.Timestamp": "RFC 3339 timestamp",
+	"google.protobuf.Duration":  "duration in seconds with an s suffix, for example 1.5s",
+	"google.protobuf.FieldMask": "comma separated field paths",
+}
+
+// messageProperty converts a message to an object property. seen guards
+// against recursive messages
+func messageProperty(message *Message, seen []string) models.Property {
+	property := models.Property{Type: "object", Properties: make(map[string]models.Property, len(message.Fields))}
+	for _, ref := range seen {
+		if ref == message.FullName {
+			// Recursive messages are cut off as free-form objects
+			return models.Property{Type: "object"}
+		}
+	}
+	seen = append(seen, message.FullName)
+
+	for _, field := range message.Fields {
+		fieldProperty := fieldProperty(field, seen)
+		if field.Repeated {
+			items := fieldProperty
+			items.Description = ""
+			fieldProperty = models.Property{Type: "array", Description: fieldProperty.Description, Items: &items}
+		}
+		property.Properties[field.Name] = fieldProperty
+	}
+	return property
+}
+
+// fieldProperty converts a single, non-repeated field to a property
+func fieldProperty(field Field, seen []string) models.Property {
+	property := models.Property{Description: field.Description}
+
+	switch field.Kind {
+	case KindDouble, KindFloat:
+		property.Type = "number"
+	case KindInt32, KindInt64, KindUint32, KindUint64, KindSint32, KindSint64,
+		KindFixed32, KindFixed64, KindSfixed32, KindSfixed64:
+		property.Type = "integer"
+	case KindBool:
+		property.Type = "boolean"
+	case KindBytes:
+		property.Type = "string"
+		property.Description = strings.TrimSpace(property.Description + " (base64 encoded)")
+	case KindEnum:
+		property.Type = "string"
+		property.Enum = field.EnumValues
+	case KindMessage:
+		if field.Message == nil {
+			property.Type = "object"
+			break
+		}
+		if format, ok := wellKnownStrings[field.Message.FullName]; ok {
+			property.Type = "string"
+			property.Description = strings.TrimSpace(property.Description + " (" + format + ")")
+			break
+		}
+		message := messageProperty(field.Message, seen)
+		message.Description = property.Description
+		property = message
+	default:
+		property.Type = "string"
+	}
+	return property
+}
@@ -0,0 +1,332 @@
+// Package grpcreflect implements the Reflector and Invoker of grpcbridge with
+// the gRPC server reflection service, so the unary methods of any server with
+// reflection enabled can be exposed as tools:
+//
+//	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(creds))
+//	client := grpcreflect.New(conn)
+//	bridge := grpcbridge.New(client, client, nil)
+//	tools, err := bridge.Tools(ctx)
+//
+// It is a separate module, so the SDK itself does not depend on gRPC
+package grpcreflect
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools/grpcbridge"
+)
+
+// reflectionService is the name prefix of the reflection services, which are
+// not listed as methods
+const reflectionService = "grpc.reflection."
+
+// Client lists and calls the methods of a server through its reflection
+// service. A Client is safe for concurrent use
+type Client struct {
+	conn grpc.ClientConnInterface
+
+	mu     sync.Mutex
+	protos map[string]*descriptorpb.FileDescriptorProto
+	files  *protoregistry.Files
+}
+
+// New creates a client for the server behind conn
+func New(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn, protos: make(map[string]*descriptorpb.FileDescriptorProto)}
+}
+
+// Methods implements the grpcbridge.Reflector interface, listing the methods
+// of every service except the reflection services
+func (c *Client) Methods(ctx context.Context) ([]grpcbridge.Method, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := rpb.NewServerReflectionClient(c.conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error opening reflection stream: %w", err)
+	}
+	defer stream.CloseSend()
+
+	resp, err := call(stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing services: %w", err)
+	}
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		if !strings.HasPrefix(service.GetName(), reflectionService) {
+			services = append(services, service.GetName())
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, service := range services {
+		if err := c.resolve(stream, service); err != nil {
+			return nil, err
+		}
+	}
+
+	var methods []grpcbridge.Method
+	for _, service := range services {
+		descriptor, err := c.files.FindDescriptorByName(protoreflect.FullName(service))
+		if err != nil {
+			return nil, fmt.Errorf("error finding service %s: %w", service, err)
+		}
+		sd, ok := descriptor.(protoreflect.ServiceDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a service", service)
+		}
+		for i := 0; i < sd.Methods().Len(); i++ {
+			methods = append(methods, method(sd.Methods().Get(i)))
+		}
+	}
+	return methods, nil
+}
+
+// Invoke implements the grpcbridge.Invoker interface, calling a unary method
+// with a request decoded from protobuf JSON
+func (c *Client) Invoke(ctx context.Context, m grpcbridge.Method, input json.RawMessage) (json.RawMessage, error) {
+	md, err := c.methodDescriptor(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	req := dynamicpb.NewMessage(md.Input())
+	if len(input) > 0 {
+		if err := protojson.Unmarshal(input, req); err != nil {
+			return nil, fmt.Errorf("error decoding request: %w", err)
+		}
+	}
+
+	resp := dynamicpb.NewMessage(md.Output())
+	if err := c.conn.Invoke(ctx, m.FullMethod(), req, resp); err != nil {
+		return nil, err
+	}
+
+	output, err := protojson.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding response: %w", err)
+	}
+	return output, nil
+}
+
+// methodDescriptor returns the descriptor of a method, resolving its service
+// through reflection when it was not listed before
+func (c *Client) methodDescriptor(ctx context.Context, m grpcbridge.Method) (protoreflect.MethodDescriptor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.files == nil || !c.has(m.Service) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		stream, err := rpb.NewServerReflectionClient(c.conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error opening reflection stream: %w", err)
+		}
+		defer stream.CloseSend()
+		if err := c.resolve(stream, m.Service); err != nil {
+			return nil, err
+		}
+	}
+
+	descriptor, err := c.files.FindDescriptorByName(protoreflect.FullName(m.Service))
+	if err != nil {
+		return nil, fmt.Errorf("error finding service %s: %w", m.Service, err)
+	}
+	sd, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", m.Service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(m.Name))
+	if md == nil {
+		return nil, fmt.Errorf("method %s not found", m.FullMethod())
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %s is not unary", m.FullMethod())
+	}
+	return md, nil
+}
+
+// has reports whether a symbol is already resolved
+func (c *Client) has(name string) bool {
+	_, err := c.files.FindDescriptorByName(protoreflect.FullName(name))
+	return err == nil
+}
+
+// resolve fetches the file declaring a symbol and its dependencies, and
+// rebuilds the descriptors. The caller holds c.mu
+func (c *Client) resolve(stream rpb.ServerReflection_ServerReflectionInfoClient, symbol string) error {
+	if c.files != nil && c.has(symbol) {
+		return nil
+	}
+
+	resp, err := call(stream, &rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	})
+	if err != nil {
+		return fmt.Errorf("error fetching descriptor of %s: %w", symbol, err)
+	}
+	if err := c.add(resp); err != nil {
+		return err
+	}
+
+	// Servers usually send the dependencies along, missing ones are fetched
+	// by name or taken from the descriptors linked into the binary
+	for missing := c.missing(); len(missing) > 0; missing = c.missing() {
+		for _, name := range missing {
+			resp, err := call(stream, &rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+			})
+			if err == nil {
+				err = c.add(resp)
+			}
+			if c.protos[name] != nil {
+				continue
+			}
+			file, globalErr := protoregistry.GlobalFiles.FindFileByPath(name)
+			if globalErr != nil {
+				if err == nil {
+					err = errors.New("not returned by the server")
+				}
+				return fmt.Errorf("error fetching descriptor file %s: %w", name, err)
+			}
+			c.protos[name] = protodesc.ToFileDescriptorProto(file)
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, file := range c.protos {
+		set.File = append(set.File, file)
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return fmt.Errorf("error building descriptors: %w", err)
+	}
+	c.files = files
+	return nil
+}
+
+// add stores the files of a file descriptor response
+func (c *Client) add(resp *rpb.ServerReflectionResponse) error {
+	for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, file); err != nil {
+			return fmt.Errorf("error decoding descriptor file: %w", err)
+		}
+		c.protos[file.GetName()] = file
+	}
+	return nil
+}
+
+// missing returns the dependencies of the stored files that are not stored
+func (c *Client) missing() []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, file := range c.protos {
+		for _, dependency := range file.GetDependency() {
+			if c.protos[dependency] == nil && !seen[dependency] {
+				seen[dependency] = true
+				names = append(names, dependency)
+			}
+		}
+	}
+	return names
+}
+
+// call sends a request on the reflection stream and returns its response,
+// turning error responses into errors
+func call(stream rpb.ServerReflection_ServerReflectionInfoClient, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		return nil, errors.New(errResp.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// method converts a method descriptor
+func method(md protoreflect.MethodDescriptor) grpcbridge.Method {
+	messages := make(map[protoreflect.FullName]*grpcbridge.Message)
+	return grpcbridge.Method{
+		Service:         string(md.Parent().FullName()),
+		Name:            string(md.Name()),
+		Description:     comment(md),
+		Input:           message(md.Input(), messages),
+		Output:          message(md.Output(), messages),
+		ClientStreaming: md.IsStreamingClient(),
+		ServerStreaming: md.IsStreamingServer(),
+	}
+}
+
+// message converts a message descriptor. converted holds the messages
+// converted so far, so recursive messages refer to themselves
+func message(md protoreflect.MessageDescriptor, converted map[protoreflect.FullName]*grpcbridge.Message) *grpcbridge.Message {
+	if m, ok := converted[md.FullName()]; ok {
+		return m
+	}
+	m := &grpcbridge.Message{FullName: string(md.FullName())}
+	converted[md.FullName()] = m
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		m.Fields = append(m.Fields, field(fields.Get(i), converted))
+	}
+	return m
+}
+
+// field converts a field descriptor. Map fields are described as free-form
+// objects, as they are encoded in protobuf JSON
+func field(fd protoreflect.FieldDescriptor, converted map[protoreflect.FullName]*grpcbridge.Message) grpcbridge.Field {
+	f := grpcbridge.Field{
+		Name:        fd.JSONName(),
+		Kind:        grpcbridge.Kind(fd.Kind().String()),
+		Repeated:    fd.IsList(),
+		Description: comment(fd),
+	}
+
+	switch {
+	case fd.IsMap():
+		f.Kind = grpcbridge.KindMessage
+		f.Description = strings.TrimSpace(f.Description + " (map of " + fd.MapKey().Kind().String() + " keys)")
+	case fd.Kind() == protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			f.EnumValues = append(f.EnumValues, string(values.Get(i).Name()))
+		}
+	case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+		f.Kind = grpcbridge.KindMessage
+		f.Message = message(fd.Message(), converted)
+	}
+	return f
+}
+
+// comment returns the leading comment of a descriptor, when the server sent
+// source information
+func comment(d protoreflect.Descriptor) string {
+	return strings.TrimSpace(d.ParentFile().SourceLocations().ByDescriptor(d).LeadingComments)
+}
@@ -0,0 +1,125 @@
+package grpcreflect
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/joakimcarlsson/anthropic-sdk/tools/grpcbridge"
+)
+
+// dial starts a server with the health and reflection services and returns a
+// connection to it
+func dial(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	reflection.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestMethods(t *testing.T) {
+	client := New(dial(t))
+
+	methods, err := client.Methods(context.Background())
+	if err != nil {
+		t.Fatalf("Methods() error = %v", err)
+	}
+
+	found := make(map[string]grpcbridge.Method)
+	for _, method := range methods {
+		found[method.FullMethod()] = method
+	}
+	if len(found) != len(methods) {
+		t.Fatalf("Methods() = %v, want unique methods", methods)
+	}
+	for name := range found {
+		if name == "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo" {
+			t.Fatalf("Methods() lists the reflection service")
+		}
+	}
+
+	check, ok := found["/grpc.health.v1.Health/Check"]
+	if !ok {
+		t.Fatalf("Methods() = %v, want grpc.health.v1.Health/Check", methods)
+	}
+	if check.ClientStreaming || check.ServerStreaming {
+		t.Fatalf("Check is reported as streaming")
+	}
+	if check.Input == nil || len(check.Input.Fields) != 1 || check.Input.Fields[0].Name != "service" || check.Input.Fields[0].Kind != grpcbridge.KindString {
+		t.Fatalf("Check input = %+v, want a single string field service", check.Input)
+	}
+	if status := check.Output.Fields[0]; status.Kind != grpcbridge.KindEnum || len(status.EnumValues) == 0 {
+		t.Fatalf("Check output field = %+v, want an enum", status)
+	}
+
+	if watch := found["/grpc.health.v1.Health/Watch"]; !watch.ServerStreaming {
+		t.Fatalf("Watch is not reported as server streaming")
+	}
+}
+
+func TestBridgeTools(t *testing.T) {
+	client := New(dial(t))
+	bridge := grpcbridge.New(client, client, grpcbridge.Methods("Check"))
+
+	tools, err := bridge.Tools(context.Background())
+	if err != nil {
+		t.Fatalf("Tools() error = %v", err)
+	}
+	if len(tools) != 1 || tools[0].Definition.Name != "Health_Check" {
+		t.Fatalf("Tools() = %v, want Health_Check", tools)
+	}
+
+	result, err := tools[0].Handler(context.Background(), json.RawMessage(`{"service":""}`))
+	if err != nil {
+		t.Fatalf("Handler() error = %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Handler() = %q", result.Content)
+	}
+	var resp struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(result.Content), &resp); err != nil || resp.Status != "SERVING" {
+		t.Fatalf("Handler() = %q, want status SERVING", result.Content)
+	}
+}
+
+func TestInvokeWithoutMethods(t *testing.T) {
+	client := New(dial(t))
+
+	output, err := client.Invoke(context.Background(), grpcbridge.Method{Service: "grpc.health.v1.Health", Name: "Check"}, json.RawMessage(`{}`))
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+	if string(output) == "" {
+		t.Fatalf("Invoke() returned no output")
+	}
+
+	if _, err := client.Invoke(context.Background(), grpcbridge.Method{Service: "grpc.health.v1.Health", Name: "Watch"}, nil); err == nil {
+		t.Fatalf("Invoke() of a streaming method succeeded")
+	}
+}
@@ -0,0 +1,154 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// GenerateOptions configures the generated Go source
+type GenerateOptions struct {
+	// Package is the package name of the generated file
+	Package string
+
+	// Func is the name of the generated function returning the tools
+	Func string
+
+	// Operations limits the generated tools to the named operations when set
+	Operations []string
+}
+
+// Generate writes Go source declaring a function that returns the tools of the
+// document, so the document does not need to be parsed at runtime. It is used
+// by the openapi-tools command
+func Generate(w io.Writer, spec *Spec, opts GenerateOptions) error {
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool, len(opts.Operations))
+	for _, name := range opts.Operations {
+		allowed[name] = true
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by openapi-tools. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", opts.Package)
+	fmt.Fprintf(&buf, "import (\n")
+	fmt.Fprintf(&buf, "\t%q\n", "github.com/joakimcarlsson/anthropic-sdk/models")
+	fmt.Fprintf(&buf, "\t%q\n", "github.com/joakimcarlsson/anthropic-sdk/tools")
+	fmt.Fprintf(&buf, "\t%q\n", "github.com/joakimcarlsson/anthropic-sdk/tools/openapi")
+	fmt.Fprintf(&buf, ")\n\n")
+
+	fmt.Fprintf(&buf, "// %s returns the tools of the API\n", opts.Func)
+	fmt.Fprintf(&buf, "func %s(opts openapi.Options) []tools.Tool {\n", opts.Func)
+	if len(spec.Servers) > 0 {
+		fmt.Fprintf(&buf, "if opts.BaseURL == \"\" {\nopts.BaseURL = %q\n}\n", spec.Servers[0].URL)
+	}
+	fmt.Fprintf(&buf, "return []tools.Tool{\n")
+	for _, endpoint := range endpoints {
+		if len(allowed) > 0 && !allowed[endpoint.Name] {
+			continue
+		}
+		writeEndpoint(&buf, endpoint)
+	}
+	fmt.Fprintf(&buf, "}\n}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("error formatting generated source: %w", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// writeEndpoint writes an endpoint literal calling Tool
+func writeEndpoint(buf *bytes.Buffer, e Endpoint) {
+	fmt.Fprintf(buf, "openapi.Endpoint{\n")
+	fmt.Fprintf(buf, "Name: %q,\n", e.Name)
+	if e.Description != "" {
+		fmt.Fprintf(buf, "Description: %q,\n", e.Description)
+	}
+	fmt.Fprintf(buf, "Method: %q,\n", e.Method)
+	fmt.Fprintf(buf, "Path: %q,\n", e.Path)
+	writeStrings(buf, "PathParams", e.PathParams)
+	writeStrings(buf, "QueryParams", e.QueryParams)
+	writeStrings(buf, "HeaderParams", e.HeaderParams)
+	if e.HasBody {
+		fmt.Fprintf(buf, "HasBody: true,\n")
+	}
+	fmt.Fprintf(buf, "Schema: models.SimpleJSONSchema(")
+	writeProperties(buf, e.Schema.Properties)
+	fmt.Fprintf(buf, ", %s),\n", stringSlice(e.Schema.Required))
+	fmt.Fprintf(buf, "}.Tool(opts),\n")
+}
+
+// writeStrings writes a string slice field when it is not empty
+func writeStrings(buf *bytes.Buffer, field string, values []string) {
+	if len(values) > 0 {
+		fmt.Fprintf(buf, "%s: %s,\n", field, stringSlice(values))
+	}
+}
+
+// writeProperties writes a property map literal with sorted keys
+func writeProperties(buf *bytes.Buffer, properties map[string]models.Property) {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(buf, "map[string]models.Property{\n")
+	for _, name := range names {
+		fmt.Fprintf(buf, "%q: ", name)
+		writeProperty(buf, properties[name])
+		fmt.Fprintf(buf, ",\n")
+	}
+	fmt.Fprintf(buf, "}")
+}
+
+// writeProperty writes a property literal
+func writeProperty(buf *bytes.Buffer, p models.Property) {
+	fmt.Fprintf(buf, "{Type: %q", p.Type)
+	if p.Description != "" {
+		fmt.Fprintf(buf, ", Description: %q", p.Description)
+	}
+	if len(p.Enum) > 0 {
+		fmt.Fprintf(buf, ", Enum: %s", stringSlice(p.Enum))
+	}
+	if p.Items != nil {
+		fmt.Fprintf(buf, ", Items: &models.Property")
+		writeProperty(buf, *p.Items)
+	}
+	if len(p.Properties) > 0 {
+		fmt.Fprintf(buf, ", Properties: ")
+		writeProperties(buf, p.Properties)
+	}
+	if len(p.Required) > 0 {
+		fmt.Fprintf(buf, ", Required: %s", stringSlice(p.Required))
+	}
+	fmt.Fprintf(buf, "}")
+}
+
+// stringSlice returns a string slice literal
+func stringSlice(values []string) string {
+	if len(values) == 0 {
+		return "nil"
+	}
+	var buf bytes.Buffer
+	buf.WriteString("[]string{")
+	for i, value := range values {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(strconv.Quote(value))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
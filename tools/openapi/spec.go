@@ -0,0 +1,376 @@
+// Package openapi exposes the operations of a REST API described by an OpenAPI
+// 3 document as tools. Every operation becomes a tool whose input holds the
+// path, query and header parameters and the JSON request body, and whose
+// handler sends the corresponding HTTP request
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Spec is the part of an OpenAPI 3 document needed to derive tools
+type Spec struct {
+	OpenAPI    string              `json:"openapi"`
+	Servers    []Server            `json:"servers,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components,omitempty"`
+}
+
+// Server is a server the API is served from
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem holds the operations of a path
+type PathItem struct {
+	Parameters []Parameter `json:"parameters,omitempty"`
+	Get        *Operation  `json:"get,omitempty"`
+	Put        *Operation  `json:"put,omitempty"`
+	Post       *Operation  `json:"post,omitempty"`
+	Delete     *Operation  `json:"delete,omitempty"`
+	Patch      *Operation  `json:"patch,omitempty"`
+	Head       *Operation  `json:"head,omitempty"`
+}
+
+// Operation is a single API operation
+type Operation struct {
+	OperationID string       `json:"operationId,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Parameters  []Parameter  `json:"parameters,omitempty"`
+	RequestBody *RequestBody `json:"requestBody,omitempty"`
+	Deprecated  bool         `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query or header parameter
+type Parameter struct {
+	Ref         string  `json:"$ref,omitempty"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema,omitempty"`
+}
+
+// RequestBody is the body of an operation
+type RequestBody struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body media type
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// Schema is a JSON schema
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	AllOf       []*Schema          `json:"allOf,omitempty"`
+}
+
+// Components holds the reusable parts of the document
+type Components struct {
+	Schemas       map[string]*Schema     `json:"schemas,omitempty"`
+	Parameters    map[string]Parameter   `json:"parameters,omitempty"`
+	RequestBodies map[string]RequestBody `json:"requestBodies,omitempty"`
+}
+
+// Parse parses an OpenAPI 3 document in JSON
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("error parsing OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("error parsing OpenAPI document: unsupported version %q", spec.OpenAPI)
+	}
+	return &spec, nil
+}
+
+// Load reads and parses an OpenAPI 3 document in JSON
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading OpenAPI document: %w", err)
+	}
+	return Parse(data)
+}
+
+// Endpoint is an operation described as a tool
+type Endpoint struct {
+	Name        string
+	Description string
+	Method      string
+	Path        string
+
+	// PathParams, QueryParams and HeaderParams name the inputs sent in each
+	// part of the request
+	PathParams   []string
+	QueryParams  []string
+	HeaderParams []string
+
+	// HasBody reports whether the body input is sent as the JSON request body
+	HasBody bool
+
+	Schema models.InputSchema
+}
+
+// BodyField is the input property holding the request body
+const BodyField = "body"
+
+// maxNameLength is the longest tool name accepted by the API
+const maxNameLength = 64
+
+// invalidNameChars matches the characters not allowed in tool names
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Endpoints returns the endpoints of all operations sorted by name. Operations
+// without an operationId are named after their method and path
+func (s *Spec) Endpoints() ([]Endpoint, error) {
+	var endpoints []Endpoint
+	for path, item := range s.Paths {
+		for method, op := range item.operations() {
+			endpoint, err := s.endpoint(method, path, item, op)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Name < endpoints[j].Name
+	})
+	for i := 1; i < len(endpoints); i++ {
+		if endpoints[i].Name == endpoints[i-1].Name {
+			return nil, fmt.Errorf("error deriving tools: duplicate tool name %q", endpoints[i].Name)
+		}
+	}
+	return endpoints, nil
+}
+
+// operations returns the operations of the path keyed by HTTP method
+func (p PathItem) operations() map[string]*Operation {
+	operations := make(map[string]*Operation)
+	for method, op := range map[string]*Operation{
+		"GET": p.Get, "PUT": p.Put, "POST": p.Post, "DELETE": p.Delete, "PATCH": p.Patch, "HEAD": p.Head,
+	} {
+		if op != nil {
+			operations[method] = op
+		}
+	}
+	return operations
+}
+
+// endpoint derives the endpoint of an operation
+func (s *Spec) endpoint(method, path string, item PathItem, op *Operation) (Endpoint, error) {
+	endpoint := Endpoint{
+		Name:        toolName(method, path, op.OperationID),
+		Description: strings.TrimSpace(strings.Join(nonEmpty(op.Summary, op.Description), "\n\n")),
+		Method:      method,
+		Path:        path,
+		Schema:      models.SimpleJSONSchema(map[string]models.Property{}, nil),
+	}
+
+	for _, param := range s.parameters(item.Parameters, op.Parameters) {
+		property, err := s.property(param.Schema, nil)
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("error deriving tool %s: %w", endpoint.Name, err)
+		}
+		if param.Description != "" {
+			property.Description = param.Description
+		}
+
+		switch param.In {
+		case "path":
+			endpoint.PathParams = append(endpoint.PathParams, param.Name)
+			param.Required = true
+		case "query":
+			endpoint.QueryParams = append(endpoint.QueryParams, param.Name)
+		case "header":
+			endpoint.HeaderParams = append(endpoint.HeaderParams, param.Name)
+		default:
+			continue
+		}
+
+		endpoint.Schema.Properties[param.Name] = property
+		if param.Required {
+			endpoint.Schema.Required = append(endpoint.Schema.Required, param.Name)
+		}
+	}
+
+	if body := s.requestBody(op.RequestBody); body != nil {
+		if media, ok := body.Content["application/json"]; ok {
+			property, err := s.property(media.Schema, nil)
+			if err != nil {
+				return Endpoint{}, fmt.Errorf("error deriving tool %s: %w", endpoint.Name, err)
+			}
+			if body.Description != "" {
+				property.Description = body.Description
+			}
+			endpoint.HasBody = true
+			endpoint.Schema.Properties[BodyField] = property
+			if body.Required {
+				endpoint.Schema.Required = append(endpoint.Schema.Required, BodyField)
+			}
+		}
+	}
+
+	return endpoint, nil
+}
+
+// parameters resolves references and merges path level parameters with the
+// operation's, which take precedence
+func (s *Spec) parameters(pathParams, opParams []Parameter) []Parameter {
+	var params []Parameter
+	index := make(map[string]int)
+	for _, param := range append(append([]Parameter(nil), pathParams...), opParams...) {
+		if param.Ref != "" {
+			resolved, ok := s.Components.Parameters[strings.TrimPrefix(param.Ref, "#/components/parameters/")]
+			if !ok {
+				continue
+			}
+			param = resolved
+		}
+
+		key := param.In + "\x00" + param.Name
+		if i, ok := index[key]; ok {
+			params[i] = param
+			continue
+		}
+		index[key] = len(params)
+		params = append(params, param)
+	}
+	return params
+}
+
+// requestBody resolves a request body reference
+func (s *Spec) requestBody(body *RequestBody) *RequestBody {
+	if body == nil || body.Ref == "" {
+		return body
+	}
+	resolved, ok := s.Components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+	if !ok {
+		return nil
+	}
+	return &resolved
+}
+
+// property converts a schema to a tool input property, resolving references.
+// seen guards against recursive schemas
+func (s *Spec) property(schema *Schema, seen []string) (models.Property, error) {
+	if schema == nil {
+		return models.Property{Type: "string"}, nil
+	}
+
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		for _, ref := range seen {
+			if ref == name {
+				// Recursive schemas are cut off as free-form objects
+				return models.Property{Type: "object"}, nil
+			}
+		}
+		resolved, ok := s.Components.Schemas[name]
+		if !ok {
+			return models.Property{}, fmt.Errorf("unresolved schema reference %q", schema.Ref)
+		}
+		property, err := s.property(resolved, append(seen, name))
+		if err != nil {
+			return models.Property{}, err
+		}
+		if schema.Description != "" {
+			property.Description = schema.Description
+		}
+		return property, nil
+	}
+
+	if len(schema.AllOf) > 0 {
+		merged := models.Property{Type: "object", Description: schema.Description, Properties: map[string]models.Property{}}
+		for _, part := range schema.AllOf {
+			property, err := s.property(part, seen)
+			if err != nil {
+				return models.Property{}, err
+			}
+			for name, field := range property.Properties {
+				merged.Properties[name] = field
+			}
+			merged.Required = append(merged.Required, property.Required...)
+		}
+		return merged, nil
+	}
+
+	property := models.Property{Type: schema.Type, Description: schema.Description}
+	if property.Type == "" {
+		property.Type = "string"
+		if len(schema.Properties) > 0 {
+			property.Type = "object"
+		}
+	}
+	for _, value := range schema.Enum {
+		property.Enum = append(property.Enum, fmt.Sprint(value))
+	}
+
+	if schema.Items != nil {
+		items, err := s.property(schema.Items, seen)
+		if err != nil {
+			return models.Property{}, err
+		}
+		property.Items = &items
+	}
+
+	if len(schema.Properties) > 0 {
+		property.Properties = make(map[string]models.Property, len(schema.Properties))
+		for name, field := range schema.Properties {
+			fieldProperty, err := s.property(field, seen)
+			if err != nil {
+				return models.Property{}, err
+			}
+			property.Properties[name] = fieldProperty
+		}
+		property.Required = schema.Required
+	}
+
+	return property, nil
+}
+
+// toolName derives a valid tool name from the operation ID, or from the method
+// and path when there is none
+func toolName(method, path, operationID string) string {
+	name := operationID
+	if name == "" {
+		name = strings.ToLower(method) + path
+	}
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
+
+// nonEmpty returns the non-empty strings
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+// DefaultMaxResponseBytes is the default limit on the response body returned
+// to the model
+const DefaultMaxResponseBytes = 64 << 10
+
+// Options configures the handlers of the generated tools
+type Options struct {
+	// BaseURL is prepended to the operation paths, defaulting to the first
+	// server of the document
+	BaseURL string
+
+	// HTTPClient sends the requests, defaults to http.DefaultClient
+	HTTPClient *http.Client
+
+	// Headers are added to every request, for example an API key
+	Headers http.Header
+
+	// Authorize is called on every request before it is sent when set, to
+	// inject credentials that change over time
+	Authorize func(req *http.Request) error
+
+	// Operations limits the tools to the named operations when set
+	Operations []string
+
+	// MaxResponseBytes limits the response body returned to the model,
+	// defaults to DefaultMaxResponseBytes
+	MaxResponseBytes int64
+}
+
+// Tools returns a tool for every operation of the document
+func Tools(spec *Spec, opts Options) ([]tools.Tool, error) {
+	endpoints, err := spec.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	if opts.BaseURL == "" && len(spec.Servers) > 0 {
+		opts.BaseURL = spec.Servers[0].URL
+	}
+
+	allowed := make(map[string]bool, len(opts.Operations))
+	for _, name := range opts.Operations {
+		allowed[name] = true
+	}
+
+	var result []tools.Tool
+	for _, endpoint := range endpoints {
+		if len(allowed) > 0 && !allowed[endpoint.Name] {
+			continue
+		}
+		result = append(result, endpoint.Tool(opts))
+	}
+	return result, nil
+}
+
+// Tool returns the tool calling the endpoint. GET and HEAD operations are
+// read-only, PUT and DELETE operations idempotent and all others
+// side-effecting
+func (e Endpoint) Tool(opts Options) tools.Tool {
+	return tools.Tool{
+		Definition: models.NewTool(e.Name, e.Description, e.Schema),
+		Handler: func(ctx context.Context, input json.RawMessage) (*tools.Result, error) {
+			return e.call(ctx, opts, input)
+		},
+		Effect: e.effect(),
+	}
+}
+
+// effect classifies the endpoint by its HTTP method
+func (e Endpoint) effect() tools.Effect {
+	switch e.Method {
+	case http.MethodGet, http.MethodHead:
+		return tools.ReadOnly
+	case http.MethodPut, http.MethodDelete:
+		return tools.Idempotent
+	default:
+		return tools.SideEffecting
+	}
+}
+
+// call sends the request for a tool call and returns the response as the
+// result
+func (e Endpoint) call(ctx context.Context, opts Options, data json.RawMessage) (*tools.Result, error) {
+	var input map[string]json.RawMessage
+	if err := json.Unmarshal(data, &input); err != nil {
+		return tools.Error(fmt.Sprintf("invalid input: %v", err)), nil
+	}
+
+	req, err := e.newRequest(ctx, opts, input)
+	if err != nil {
+		return tools.Error(err.Error()), nil
+	}
+
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s %s: %w", e.Method, e.Path, err)
+	}
+	defer resp.Body.Close()
+
+	limit := opts.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response of %s %s: %w", e.Method, e.Path, err)
+	}
+
+	content := string(body)
+	if int64(len(body)) > limit {
+		content = string(body[:limit]) + "\n[response truncated]"
+	}
+	if resp.StatusCode >= 400 {
+		return tools.Error(fmt.Sprintf("status %d: %s", resp.StatusCode, content)), nil
+	}
+	return tools.Text(content), nil
+}
+
+// newRequest maps the tool input to an HTTP request
+func (e Endpoint) newRequest(ctx context.Context, opts Options, input map[string]json.RawMessage) (*http.Request, error) {
+	path := e.Path
+	for _, name := range e.PathParams {
+		value, ok := input[name]
+		if !ok {
+			return nil, fmt.Errorf("missing path parameter %q", name)
+		}
+		path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(scalar(value)))
+	}
+
+	query := url.Values{}
+	for _, name := range e.QueryParams {
+		if value, ok := input[name]; ok {
+			addQuery(query, name, value)
+		}
+	}
+
+	target := strings.TrimSuffix(opts.BaseURL, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if value, ok := input[BodyField]; ok && e.HasBody {
+		body = bytes.NewReader(value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, e.Method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	for _, name := range e.HeaderParams {
+		if value, ok := input[name]; ok {
+			req.Header.Set(name, scalar(value))
+		}
+	}
+	for key, values := range opts.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	if opts.Authorize != nil {
+		if err := opts.Authorize(req); err != nil {
+			return nil, fmt.Errorf("error authorizing request: %w", err)
+		}
+	}
+	return req, nil
+}
+
+// addQuery adds a query parameter, repeating it for arrays
+func addQuery(query url.Values, name string, value json.RawMessage) {
+	var values []json.RawMessage
+	if err := json.Unmarshal(value, &values); err == nil {
+		for _, v := range values {
+			query.Add(name, scalar(v))
+		}
+		return
+	}
+	query.Add(name, scalar(value))
+}
+
+// scalar returns a JSON value as a plain string, unquoting strings
+func scalar(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return string(value)
+}
@@ -0,0 +1,134 @@
+// Package retrieval connects a knowledge base to the model through a
+// search_knowledge_base tool. Any vector database can be used by implementing
+// Retriever, and the retrieved documents are returned as search result blocks
+// so the model can cite them
+package retrieval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultName is the default tool name
+	DefaultName = "search_knowledge_base"
+
+	// DefaultK is the default number of documents retrieved
+	DefaultK = 5
+
+	// DefaultMaxK is the default maximum number of documents the model can
+	// request
+	DefaultMaxK = 20
+)
+
+// Document is a retrieved document
+type Document struct {
+	// Source identifies the document, for example a URL or a file path
+	Source string
+
+	Title string
+
+	// Chunks are the retrieved passages of the document, each can be cited
+	// separately
+	Chunks []string
+
+	// Score is the relevance reported by the retriever, higher is better
+	Score float64
+}
+
+// Retriever finds the k documents most relevant to a query
+type Retriever interface {
+	Query(ctx context.Context, text string, k int) ([]Document, error)
+}
+
+// RetrieverFunc adapts a function to a Retriever
+type RetrieverFunc func(ctx context.Context, text string, k int) ([]Document, error)
+
+// Query calls f
+func (f RetrieverFunc) Query(ctx context.Context, text string, k int) ([]Document, error) {
+	return f(ctx, text, k)
+}
+
+// Options configures the search tool
+type Options struct {
+	// Name is the tool name, defaults to DefaultName
+	Name string
+
+	// Description describes the knowledge base to the model
+	Description string
+
+	// K is the number of documents retrieved when the model does not ask for
+	// a number, defaults to DefaultK
+	K int
+
+	// MaxK limits the number of documents the model can ask for, defaults to
+	// DefaultMaxK
+	MaxK int
+
+	// DisableCitations turns off citations of the search results
+	DisableCitations bool
+}
+
+// input is the input of the search tool
+type input struct {
+	Query string `json:"query" description:"The search query"`
+	K     int    `json:"k,omitempty" description:"Number of results to return"`
+}
+
+// New creates the search tool for the retriever
+func New(retriever Retriever, opts Options) (tools.Tool, error) {
+	name := opts.Name
+	if name == "" {
+		name = DefaultName
+	}
+	description := opts.Description
+	if description == "" {
+		description = "Searches the knowledge base and returns the most relevant passages. Cite the results when using them in the answer."
+	}
+
+	tool, err := tools.Typed(name, description, func(ctx context.Context, in input) (*tools.Result, error) {
+		docs, err := retriever.Query(ctx, in.Query, opts.k(in.K))
+		if err != nil {
+			return nil, fmt.Errorf("error searching the knowledge base: %w", err)
+		}
+		if len(docs) == 0 {
+			return tools.Text("No results found."), nil
+		}
+		return &tools.Result{Blocks: SearchResults(docs, !opts.DisableCitations)}, nil
+	})
+	if err != nil {
+		return tools.Tool{}, err
+	}
+	return tool.WithEffect(tools.ReadOnly), nil
+}
+
+// k returns the number of documents to retrieve
+func (o Options) k(requested int) int {
+	k := o.K
+	if k <= 0 {
+		k = DefaultK
+	}
+	if requested > 0 {
+		k = requested
+	}
+	maxK := o.MaxK
+	if maxK <= 0 {
+		maxK = DefaultMaxK
+	}
+	return min(k, maxK)
+}
+
+// SearchResults converts documents to search result blocks
+func SearchResults(docs []Document, citations bool) []models.ContentBlock {
+	blocks := make([]models.ContentBlock, 0, len(docs))
+	for _, doc := range docs {
+		if len(doc.Chunks) == 0 {
+			continue
+		}
+		blocks = append(blocks, models.CreateSearchResultBlock(doc.Source, doc.Title, doc.Chunks, citations))
+	}
+	return blocks
+}
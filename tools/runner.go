@@ -0,0 +1,242 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+	"github.com/joakimcarlsson/anthropic-sdk/timeline"
+)
+
+// DefaultMaxIterations is the default number of requests a run may make
+const DefaultMaxIterations = 10
+
+// StreamProvider creates streamed messages. It is implemented by
+// anthropic.Client
+type StreamProvider interface {
+	CreateMessageStream(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*streaming.MessageStream, error)
+}
+
+// Runner runs the tool use loop
+type Runner struct {
+	Client anthropic.ChatProvider
+
+	// Registry holds the tools the model can call
+	Registry *Registry
+
+	// MaxIterations limits the number of requests of a run, defaults to
+	// DefaultMaxIterations
+	MaxIterations int
+
+	// TokenBudget limits the input and output tokens of a run when positive.
+	// The run fails with ErrBudgetExceeded once a response uses it up
+	TokenBudget int
+
+	// ToolTimeout limits the duration of every tool call when positive. The
+	// run fails with ErrToolTimeout when a call takes longer
+	ToolTimeout time.Duration
+
+	// FailOnUnknownTool fails the run with ErrToolNotFound when the model
+	// calls a tool that is not registered, instead of reporting it to the model
+	FailOnUnknownTool bool
+
+	// Truncation shortens oversized text tool results when set
+	Truncation *Truncation
+
+	// Cache answers repeated calls of read-only tools when set
+	Cache *ResultCache
+
+	// Approve is asked before every call of a side-effecting tool when set.
+	// Calls that are not approved are reported to the model as failed. In
+	// RunStream it is only asked once the response stops for tool use, never
+	// while it is still streaming
+	Approve func(ctx context.Context, call Call) (bool, error)
+
+	// Timeline records the run, its turns, requests, stream events and tool
+	// calls when set
+	Timeline *timeline.Timeline
+}
+
+// RunResult is the outcome of a run
+type RunResult struct {
+	// Message is the final response
+	Message *models.Message
+
+	// Messages is the request history followed by every turn of the run
+	Messages []models.MessageParam
+
+	// Iterations is the number of requests made
+	Iterations int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// NewRunner creates a runner for the given tools
+func NewRunner(client anthropic.ChatProvider, tools ...Tool) *Runner {
+	return &Runner{Client: client, Registry: NewRegistry(tools...)}
+}
+
+// Run sends the request and executes the tools the model calls until it stops
+// calling tools. The registry's tools are used when the request has none
+func (r *Runner) Run(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*RunResult, error) {
+	options = r.requestOptions(options)
+	return r.loop(ctx, r.Client, req, func(ctx context.Context, req models.MessageRequest, exec *execution) (*models.Message, error) {
+		return r.Client.CreateMessage(ctx, req, options...)
+	})
+}
+
+// RunStream is like Run but streams every response, executing calls of
+// read-only tools as soon as their block is complete while the model is still
+// generating the rest of the message. Calls of other tools, and approval of
+// side-effecting ones, wait until the message stops with stop_reason
+// tool_use. The tool results are sent once the message stops. Read-only calls
+// of a response that fails or stops for another reason, such as max_tokens,
+// are canceled and their results discarded
+func (r *Runner) RunStream(ctx context.Context, client StreamProvider, req models.MessageRequest, options ...anthropic.RequestOption) (*RunResult, error) {
+	options = r.requestOptions(options)
+	return r.loop(ctx, client, req, func(ctx context.Context, req models.MessageRequest, exec *execution) (*models.Message, error) {
+		stream, err := client.CreateMessageStream(ctx, req, options...)
+		if err != nil {
+			return nil, err
+		}
+		stream.OnToolUse(func(name, id string, input json.RawMessage) {
+			exec.prefetch(Call{ID: id, Name: name, Input: input})
+		})
+
+		stopped := false
+		for stream.Next() {
+			stopped = stream.Current().Type == streaming.MessageStopEvent
+		}
+		if err := stream.Err(); err != nil {
+			return nil, &streamError{err: fmt.Errorf("error streaming message: %w", err), recovery: stream.Recovery()}
+		}
+		if !stopped {
+			return nil, fmt.Errorf("error streaming message: %w", io.ErrUnexpectedEOF)
+		}
+		return stream.Message(), nil
+	})
+}
+
+// send creates the next message of a run, submitting tool calls to the
+// execution early when it can
+type send func(ctx context.Context, req models.MessageRequest, exec *execution) (*models.Message, error)
+
+// loop runs the tool use loop with the given way of sending requests. The run
+// is registered as work of clients implementing anthropic.WorkTracker, so
+// their shutdown waits for it. Failures are returned as a *RunError
+func (r *Runner) loop(ctx context.Context, client interface{}, req models.MessageRequest, send send) (*RunResult, error) {
+	if tracker, ok := client.(anthropic.WorkTracker); ok {
+		workCtx, done, err := tracker.BeginWork(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error running tools: %w", err)
+		}
+		defer done()
+		ctx = workCtx
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if len(req.Tools) == 0 && r.Registry != nil {
+		req.Tools = r.Registry.Definitions()
+	}
+	req.Messages = append([]models.MessageParam(nil), req.Messages...)
+
+	span := r.Timeline.Begin("run", "run", nil)
+	defer span.End()
+
+	result := &RunResult{}
+	fail := func(err error, call *Call) error {
+		span.Set("error", err.Error())
+		runErr := &RunError{
+			Err:        err,
+			Messages:   req.Messages,
+			Iterations: result.Iterations,
+			Usage:      result.Usage,
+			Call:       call,
+		}
+		var streamErr *streamError
+		if errors.As(err, &streamErr) {
+			runErr.Err = streamErr.err
+			runErr.Recovery = streamErr.recovery
+			if streamErr.recovery != nil {
+				runErr.Usage = runErr.Usage.Add(streamErr.recovery.Usage)
+			}
+		}
+		return runErr
+	}
+
+	for result.Iterations < r.maxIterations() {
+		exec := r.newExecution(ctx)
+		turn := r.Timeline.Begin("turn "+strconv.Itoa(result.Iterations+1), "turn", nil)
+		resp, err := send(ctx, req, exec)
+		result.Iterations++
+		if err != nil {
+			exec.stop()
+			turn.End()
+			return nil, fail(err, nil)
+		}
+		turn.Set("stop_reason", string(resp.StopReason))
+		turn.End()
+		result.Usage = result.Usage.Add(resp.Usage)
+		req.Messages = append(req.Messages, resp.ToParam())
+
+		var calls []Call
+		for _, block := range resp.Content {
+			if block.ToolUseContent == nil {
+				continue
+			}
+			call, err := CallFor(block.ToolUseContent)
+			if err != nil {
+				exec.stop()
+				return nil, fail(err, nil)
+			}
+			calls = append(calls, call)
+		}
+
+		if resp.StopReason != models.ToolUse || len(calls) == 0 {
+			exec.stop()
+			result.Message = resp
+			result.Messages = req.Messages
+			return result, nil
+		}
+
+		if used := result.Usage.InputTokens + result.Usage.OutputTokens; r.TokenBudget > 0 && used >= r.TokenBudget {
+			exec.stop()
+			return nil, fail(fmt.Errorf("%w: used %d of %d tokens", ErrBudgetExceeded, used, r.TokenBudget), nil)
+		}
+
+		results, callErr := exec.results(calls)
+		exec.stop()
+		if callErr != nil {
+			return nil, fail(callErr.err, &callErr.call)
+		}
+		req.Messages = append(req.Messages, models.NewUserMessage(results...))
+	}
+
+	return nil, fail(ErrMaxTurnsExceeded, nil)
+}
+
+// requestOptions adds the timeline to the request options of a run
+func (r *Runner) requestOptions(options []anthropic.RequestOption) []anthropic.RequestOption {
+	if r.Timeline == nil {
+		return options
+	}
+	return append(append([]anthropic.RequestOption(nil), options...), anthropic.WithTimeline(r.Timeline))
+}
+
+// maxIterations returns the maximum number of requests of a run
+func (r *Runner) maxIterations() int {
+	if r.MaxIterations > 0 {
+		return r.MaxIterations
+	}
+	return DefaultMaxIterations
+}
@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+)
+
+// fakeStreams answers every streamed request with the next scripted event
+// stream body
+type fakeStreams struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (f *fakeStreams) CreateMessageStream(ctx context.Context, req models.MessageRequest, options ...anthropic.RequestOption) (*streaming.MessageStream, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.bodies) == 0 {
+		return nil, fmt.Errorf("no scripted stream left")
+	}
+	body := f.bodies[0]
+	f.bodies = f.bodies[1:]
+	return streaming.NewMessageStream(strings.NewReader(body)), nil
+}
+
+// sse joins events into the body of an event stream
+func sse(events ...string) string {
+	var b strings.Builder
+	for _, event := range events {
+		fmt.Fprintf(&b, "data: %s\n\n", event)
+	}
+	return b.String()
+}
+
+// toolUseEvents returns the events streaming a complete tool_use block
+func toolUseEvents(index int, id, name string) []string {
+	return []string{
+		fmt.Sprintf(`{"type":"content_block_start","index":%d,"content_block":{"type":"tool_use","id":%q,"name":%q,"input":{}}}`, index, id, name),
+		fmt.Sprintf(`{"type":"content_block_delta","index":%d,"delta":{"type":"input_json_delta","partial_json":"{}"}}`, index),
+		fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, index),
+	}
+}
+
+// callLog records the state-changing tools executed by a test
+type callLog struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (l *callLog) tool(name string, effect Effect) Tool {
+	return New(name, name, models.InputSchema{Type: "object"}, func(ctx context.Context, input json.RawMessage) (*Result, error) {
+		if effect != ReadOnly {
+			l.mu.Lock()
+			l.names = append(l.names, name)
+			l.mu.Unlock()
+		}
+		return Text(name + " done"), nil
+	}).WithEffect(effect)
+}
+
+func (l *callLog) calls() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	names := append([]string(nil), l.names...)
+	sort.Strings(names)
+	return names
+}
+
+func TestRunStreamDefersStateChangingCalls(t *testing.T) {
+	start := `{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[],"usage":{"input_tokens":10,"output_tokens":1}}}`
+	calls := append(append(append([]string{start},
+		toolUseEvents(0, "toolu_1", "read")...),
+		toolUseEvents(1, "toolu_2", "write")...),
+		toolUseEvents(2, "toolu_3", "upsert")...)
+	stop := func(reason string, outputTokens int) []string {
+		return []string{
+			fmt.Sprintf(`{"type":"message_delta","delta":{"stop_reason":%q},"usage":{"output_tokens":%d}}`, reason, outputTokens),
+			`{"type":"message_stop"}`,
+		}
+	}
+	endTurn := sse(append([]string{start,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":"done"}}`,
+		`{"type":"content_block_stop","index":0}`,
+	}, stop("end_turn", 5)...)...)
+
+	tests := []struct {
+		name        string
+		bodies      []string
+		tokenBudget int
+		wantErr     string
+		wantCalls   []string // state-changing tools that ran
+		wantApprove int
+	}{
+		{
+			name: "stream fails midway",
+			bodies: []string{sse(append(calls,
+				`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+			)...)},
+			wantErr: "Overloaded",
+		},
+		{
+			name:    "stream ends before message_stop",
+			bodies:  []string{sse(append(calls, stop("tool_use", 5)[0])...)},
+			wantErr: "unexpected EOF",
+		},
+		{
+			name:   "response stops at max_tokens",
+			bodies: []string{sse(append(calls, stop("max_tokens", 5)...)...)},
+		},
+		{
+			name:        "token budget used up",
+			bodies:      []string{sse(append(calls, stop("tool_use", 50)...)...)},
+			tokenBudget: 20,
+			wantErr:     "budget",
+		},
+		{
+			name:        "response stops for tool use",
+			bodies:      []string{sse(append(calls, stop("tool_use", 5)...)...), endTurn},
+			wantCalls:   []string{"upsert", "write"},
+			wantApprove: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := &callLog{}
+			runner := NewRunner(nil,
+				log.tool("read", ReadOnly),
+				log.tool("write", SideEffecting),
+				log.tool("upsert", Idempotent),
+			)
+			runner.TokenBudget = tt.tokenBudget
+			var approvals int
+			runner.Approve = func(ctx context.Context, call Call) (bool, error) {
+				approvals++
+				return true, nil
+			}
+
+			_, err := runner.RunStream(context.Background(), &fakeStreams{bodies: tt.bodies}, models.MessageRequest{
+				Model:    "claude",
+				Messages: []models.MessageParam{models.NewUserMessage(models.CreateTextBlock("hi"))},
+			})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("RunStream() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("RunStream() error = %v, want error containing %q", err, tt.wantErr)
+			}
+
+			if got := log.calls(); !reflect.DeepEqual(got, tt.wantCalls) {
+				t.Fatalf("executed tools = %q, want %q", got, tt.wantCalls)
+			}
+			if approvals != tt.wantApprove {
+				t.Fatalf("approvals = %d, want %d", approvals, tt.wantApprove)
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+// Package sqltool provides a ready-made tool letting the model query a
+// database. Queries are checked to be read-only, run in a read-only
+// transaction that is always rolled back, and their results are limited in
+// rows, columns and cell length before being formatted for the model
+package sqltool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/tools"
+)
+
+const (
+	// DefaultName is the default tool name
+	DefaultName = "query_database"
+
+	// DefaultMaxRows is the default number of rows returned to the model
+	DefaultMaxRows = 100
+
+	// DefaultMaxColumns is the default number of columns returned to the model
+	DefaultMaxColumns = 20
+
+	// DefaultMaxCellLength is the default number of characters of a cell
+	DefaultMaxCellLength = 200
+
+	// DefaultTimeout is the default time limit of a query
+	DefaultTimeout = 30 * time.Second
+)
+
+// ErrNotReadOnly is returned for queries that may modify the database
+var ErrNotReadOnly = errors.New("only read-only queries are allowed")
+
+// Options configures the query tool
+type Options struct {
+	// Name is the tool name, defaults to DefaultName
+	Name string
+
+	// Dialect names the SQL dialect in the tool description, for example
+	// "PostgreSQL"
+	Dialect string
+
+	// Schema describes the tables the model can query and is added to the
+	// tool description
+	Schema string
+
+	// MaxRows, MaxColumns and MaxCellLength limit the returned results,
+	// defaulting to DefaultMaxRows, DefaultMaxColumns and DefaultMaxCellLength
+	MaxRows       int
+	MaxColumns    int
+	MaxCellLength int
+
+	// Timeout limits the duration of a query, defaults to DefaultTimeout
+	Timeout time.Duration
+}
+
+// input is the input of the query tool
+type input struct {
+	Query string `json:"query"`
+}
+
+// New creates the query tool for the database
+func New(db *sql.DB, opts Options) tools.Tool {
+	name := opts.Name
+	if name == "" {
+		name = DefaultName
+	}
+
+	schema := models.SimpleJSONSchema(map[string]models.Property{
+		"query": models.NewProperty("string", "A single read-only SQL query, such as a SELECT statement"),
+	}, []string{"query"})
+
+	return tools.Tool{
+		Definition: models.NewTool(name, opts.description(), schema),
+		Handler: func(ctx context.Context, data json.RawMessage) (*tools.Result, error) {
+			var in input
+			if err := json.Unmarshal(data, &in); err != nil {
+				return tools.Error(fmt.Sprintf("invalid input: %v", err)), nil
+			}
+			content, err := Query(ctx, db, in.Query, opts)
+			if err != nil {
+				return tools.Error(err.Error()), nil
+			}
+			return tools.Text(content), nil
+		},
+		Effect: tools.ReadOnly,
+	}
+}
+
+// description returns the tool description
+func (o Options) description() string {
+	var b strings.Builder
+	b.WriteString("Runs a read-only SQL query against the database and returns the results as a table.")
+	if o.Dialect != "" {
+		fmt.Fprintf(&b, " The database uses the %s dialect.", o.Dialect)
+	}
+	fmt.Fprintf(&b, " At most %d rows and %d columns are returned, use aggregates, filters and LIMIT to keep results small.",
+		positive(o.MaxRows, DefaultMaxRows), positive(o.MaxColumns, DefaultMaxColumns))
+	if o.Schema != "" {
+		b.WriteString("\n\nSchema:\n")
+		b.WriteString(o.Schema)
+	}
+	return b.String()
+}
+
+// Query checks that the query is read-only, runs it in a read-only transaction
+// and formats the limited results as a markdown table
+func Query(ctx context.Context, db *sql.DB, query string, opts Options) (string, error) {
+	if err := CheckReadOnly(query); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, positiveDuration(opts.Timeout, DefaultTimeout))
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("error running query: %w", err)
+	}
+	defer rows.Close()
+
+	return format(rows, opts)
+}
+
+// format reads the rows and formats them as a markdown table
+func format(rows *sql.Rows, opts Options) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("error reading columns: %w", err)
+	}
+
+	maxRows := positive(opts.MaxRows, DefaultMaxRows)
+	maxColumns := positive(opts.MaxColumns, DefaultMaxColumns)
+	maxCell := positive(opts.MaxCellLength, DefaultMaxCellLength)
+	shown := min(len(columns), maxColumns)
+
+	var b strings.Builder
+	writeRow(&b, columns[:shown], maxCell)
+	b.WriteString("|")
+	for range shown {
+		b.WriteString(" --- |")
+	}
+	b.WriteString("\n")
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	count := 0
+	more := false
+	for rows.Next() {
+		if count == maxRows {
+			more = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", fmt.Errorf("error reading row: %w", err)
+		}
+		cells := make([]string, shown)
+		for i := range cells {
+			cells[i] = cell(values[i])
+		}
+		writeRow(&b, cells, maxCell)
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("error reading rows: %w", err)
+	}
+
+	fmt.Fprintf(&b, "\n%d rows", count)
+	if more {
+		fmt.Fprintf(&b, " shown, more rows were omitted")
+	}
+	if shown < len(columns) {
+		fmt.Fprintf(&b, ", %d of %d columns shown", shown, len(columns))
+	}
+	return b.String(), nil
+}
+
+// writeRow writes a markdown table row
+func writeRow(b *strings.Builder, cells []string, maxCell int) {
+	b.WriteString("|")
+	for _, c := range cells {
+		if runes := []rune(c); len(runes) > maxCell {
+			c = string(runes[:maxCell]) + "…"
+		}
+		c = strings.ReplaceAll(strings.ReplaceAll(c, "\n", " "), "|", "\\|")
+		b.WriteString(" ")
+		b.WriteString(c)
+		b.WriteString(" |")
+	}
+	b.WriteString("\n")
+}
+
+// cell formats a scanned value
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// readOnlyStatements are the statements a query may start with
+var readOnlyStatements = map[string]bool{
+	"select": true, "with": true, "explain": true, "show": true,
+	"describe": true, "desc": true, "values": true, "table": true,
+}
+
+// writeKeywords are keywords starting statements that may modify the database.
+// They are matched where a statement may start, so functions sharing their
+// name, such as REPLACE(), are allowed
+var writeKeywords = map[string]bool{
+	"insert": true, "update": true, "delete": true, "merge": true, "upsert": true,
+	"replace": true, "create": true, "alter": true, "drop": true, "truncate": true,
+	"rename": true, "grant": true, "revoke": true, "attach": true, "detach": true,
+	"copy": true, "call": true, "exec": true, "execute": true, "do": true,
+	"lock": true, "vacuum": true, "analyze": true, "reindex": true,
+	"pragma": true, "set": true, "load": true, "import": true,
+}
+
+// writeClauses are keywords that may modify the database anywhere in a query,
+// such as SELECT ... INTO creating a table
+var writeClauses = map[string]bool{
+	"into": true,
+}
+
+// word is a keyword or identifier of a query
+type word struct {
+	text string
+
+	// start is set for words where a statement may start: the first word, and
+	// words following a parenthesis, as in common table expressions
+	start bool
+}
+
+// CheckReadOnly returns an error wrapping ErrNotReadOnly unless the query is a
+// single statement that cannot modify the database. The check is
+// conservative: syntax that dialects read differently, such as # and
+// executable comments, backslash escapes and dollar quoting, is refused. The
+// read-only transaction is the second line of defense
+func CheckReadOnly(query string) error {
+	words, statements, err := scan(query)
+	if err != nil {
+		return fmt.Errorf("%v: %w", err, ErrNotReadOnly)
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("empty query: %w", ErrNotReadOnly)
+	}
+	if statements > 1 {
+		return fmt.Errorf("multiple statements: %w", ErrNotReadOnly)
+	}
+	if !readOnlyStatements[words[0].text] {
+		return fmt.Errorf("%s statement: %w", strings.ToUpper(words[0].text), ErrNotReadOnly)
+	}
+
+	// EXPLAIN takes options before the statement it explains, which some
+	// databases execute
+	explain := words[0].text == "explain"
+	for _, w := range words[1:] {
+		if writeClauses[w.text] || (w.start || explain) && writeKeywords[w.text] {
+			return fmt.Errorf("%s keyword: %w", strings.ToUpper(w.text), ErrNotReadOnly)
+		}
+	}
+	return nil
+}
+
+// scan returns the lower-cased keywords and identifiers of a query, skipping
+// comments, string literals and quoted identifiers, and the number of
+// non-empty statements. It fails on syntax it cannot read the same way as
+// every database
+func scan(query string) ([]word, int, error) {
+	var words []word
+	statements := 0
+	inStatement := false
+	start := true
+
+	begin := func() {
+		if !inStatement {
+			inStatement = true
+			statements++
+		}
+	}
+
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		next := rune(0)
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+
+		switch {
+		case r == '-' && next == '-':
+			// MySQL only reads -- followed by whitespace as a comment
+			if i+2 < len(runes) && !unicode.IsSpace(runes[i+2]) {
+				return nil, 0, errors.New("-- not followed by whitespace")
+			}
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '#':
+			// # starts a comment in MySQL but is an operator in PostgreSQL
+			return nil, 0, errors.New("# outside a string literal")
+		case r == '/' && next == '*':
+			if i+2 < len(runes) && (runes[i+2] == '!' || runes[i+2] == '+') {
+				return nil, 0, errors.New("executable comment")
+			}
+			i += 2
+			for ; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+				// Comments nest in PostgreSQL but not in other databases
+				if runes[i] == '/' && runes[i+1] == '*' {
+					return nil, 0, errors.New("nested comment")
+				}
+			}
+			if i+1 >= len(runes) {
+				return nil, 0, errors.New("unterminated comment")
+			}
+			i++
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				// Backslashes escape quotes in MySQL but not in standard SQL
+				if runes[i] == '\\' {
+					return nil, 0, errors.New("backslash in a quoted string")
+				}
+			}
+			if i >= len(runes) {
+				return nil, 0, errors.New("unterminated quoted string")
+			}
+			begin()
+			start = false
+		case r == '$' && (next == '$' || unicode.IsLetter(next) || next == '_'):
+			// Dollar quoting in PostgreSQL hides quotes and semicolons
+			return nil, 0, errors.New("dollar-quoted string")
+		case r == ';':
+			inStatement = false
+			start = true
+		case r == '(' || r == ')':
+			begin()
+			start = true
+		case unicode.IsLetter(r) || r == '_':
+			first := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			words = append(words, word{text: strings.ToLower(string(runes[first : i+1])), start: start})
+			begin()
+			start = false
+		case !unicode.IsSpace(r):
+			begin()
+			start = false
+		}
+	}
+	return words, statements, nil
+}
+
+// positive returns value, or fallback when value is not positive
+func positive(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// positiveDuration returns value, or fallback when value is not positive
+func positiveDuration(value, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
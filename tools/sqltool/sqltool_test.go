@@ -0,0 +1,58 @@
+package sqltool
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		readOnly bool
+	}{
+		{name: "select", query: "SELECT id, name FROM users WHERE id = 1", readOnly: true},
+		{name: "trailing semicolon", query: "SELECT 1;", readOnly: true},
+		{name: "common table expression", query: "WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent", readOnly: true},
+		{name: "replace function", query: "SELECT REPLACE(name, 'a', 'b') FROM users", readOnly: true},
+		{name: "keywords in literals", query: "SELECT 'DELETE FROM t; DROP TABLE t' AS q", readOnly: true},
+		{name: "doubled quotes", query: "SELECT 'it''s; DELETE FROM t'", readOnly: true},
+		{name: "line comment", query: "SELECT 1 -- DELETE FROM t\n", readOnly: true},
+		{name: "block comment", query: "SELECT /* ; DELETE FROM t */ 1", readOnly: true},
+		{name: "dollar in identifier", query: "SELECT a$b FROM t", readOnly: true},
+		{name: "explain", query: "EXPLAIN SELECT * FROM t", readOnly: true},
+
+		{name: "empty", query: "  -- nothing\n"},
+		{name: "delete", query: "DELETE FROM t"},
+		{name: "lower case update", query: "update t set a = 1"},
+		{name: "multiple statements", query: "SELECT 1; DELETE FROM t"},
+		{name: "statement after comment", query: "SELECT 1 /* x */; DROP TABLE t"},
+		{name: "data-modifying common table expression", query: "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d"},
+		{name: "write after common table expression", query: "WITH d AS (SELECT 1) DELETE FROM t"},
+		{name: "select into", query: "SELECT * INTO copy FROM t"},
+		{name: "explain analyze", query: "EXPLAIN ANALYZE DELETE FROM t"},
+		{name: "executable comment", query: "SELECT 1 /*! ; DELETE FROM t */"},
+		{name: "optimizer hint", query: "SELECT /*+ ; DELETE FROM t */ 1"},
+		{name: "hash comment", query: "SELECT 1 # '\n; DELETE FROM t; -- '"},
+		{name: "dash comment without space", query: "SELECT 1 --1; DELETE FROM t"},
+		{name: "backslash escape", query: `SELECT 'a\''; DELETE FROM t; -- '`},
+		{name: "backslash in identifier", query: "SELECT \"a\\\"; DELETE FROM t; --\""},
+		{name: "dollar quoting", query: "SELECT $$ ' $$; DELETE FROM t; --'"},
+		{name: "tagged dollar quoting", query: "SELECT $x$ ' $x$; DELETE FROM t; --'"},
+		{name: "nested comment", query: "SELECT 1 /* /* */ ' */ ; DELETE FROM t; --'"},
+		{name: "unterminated comment", query: "SELECT 1 /* ; DELETE FROM t"},
+		{name: "unterminated literal", query: "SELECT '; DELETE FROM t"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckReadOnly(tt.query)
+			if tt.readOnly && err != nil {
+				t.Fatalf("CheckReadOnly(%q) = %v, want nil", tt.query, err)
+			}
+			if !tt.readOnly && !errors.Is(err, ErrNotReadOnly) {
+				t.Fatalf("CheckReadOnly(%q) = %v, want ErrNotReadOnly", tt.query, err)
+			}
+		})
+	}
+}
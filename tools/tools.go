@@ -0,0 +1,280 @@
+// Package tools defines tools backed by Go handlers and runs the tool use loop,
+// executing the tools the model calls and sending their results back until the
+// model produces a final answer
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/joakimcarlsson/anthropic-sdk/internal/safe"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+// Handler executes a tool call with the input sent by the model
+type Handler func(ctx context.Context, input json.RawMessage) (*Result, error)
+
+// Result is the outcome of a tool call
+type Result struct {
+	Content string
+
+	// Blocks is sent instead of Content when set, for results made of text,
+	// image or search result blocks
+	Blocks []models.ContentBlock
+
+	// IsError reports the call as failed to the model
+	IsError bool
+}
+
+// Text creates a successful result with the given content
+func Text(content string) *Result {
+	return &Result{Content: content}
+}
+
+// Error creates a failed result with the given message
+func Error(message string) *Result {
+	return &Result{Content: message, IsError: true}
+}
+
+// block returns the tool result block of the result
+func (r *Result) block(toolUseID string) models.ContentBlock {
+	if len(r.Blocks) > 0 {
+		return models.CreateToolResultBlocks(toolUseID, r.Blocks, r.IsError)
+	}
+	return models.CreateToolResultBlock(toolUseID, r.Content, r.IsError)
+}
+
+// Tool is a tool definition together with the handler executing it
+type Tool struct {
+	Definition models.Tool
+	Handler    Handler
+
+	// Effect declares the side effects of the handler, defaulting to
+	// SideEffecting
+	Effect Effect
+}
+
+// Effect classifies the side effects of a tool
+type Effect int
+
+const (
+	// SideEffecting tools change state and may not be repeated safely. They
+	// run one at a time in call order and require approval when the Runner
+	// has an approver
+	SideEffecting Effect = iota
+
+	// Idempotent tools change state but can safely be repeated. They run one
+	// at a time in call order
+	Idempotent
+
+	// ReadOnly tools have no side effects. They run concurrently with other
+	// read-only calls and their results can be cached
+	ReadOnly
+)
+
+// String returns the name of the effect
+func (e Effect) String() string {
+	switch e {
+	case SideEffecting:
+		return "side_effecting"
+	case Idempotent:
+		return "idempotent"
+	case ReadOnly:
+		return "read_only"
+	default:
+		return fmt.Sprintf("Effect(%d)", int(e))
+	}
+}
+
+// WithEffect returns a copy of the tool with the given effect
+func (t Tool) WithEffect(effect Effect) Tool {
+	t.Effect = effect
+	return t
+}
+
+// New creates a tool
+func New(name, description string, schema models.InputSchema, handler Handler) Tool {
+	return Tool{
+		Definition: models.NewTool(name, description, schema),
+		Handler:    handler,
+	}
+}
+
+// Func creates a tool whose input schema is derived from T with
+// models.SchemaFor. The input is decoded into T before fn is called
+func Func[T any](name, description string, fn func(ctx context.Context, input T) (string, error)) (Tool, error) {
+	return Typed(name, description, func(ctx context.Context, input T) (*Result, error) {
+		content, err := fn(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return Text(content), nil
+	})
+}
+
+// Typed is like Func for handlers returning a full Result
+func Typed[T any](name, description string, fn func(ctx context.Context, input T) (*Result, error)) (Tool, error) {
+	schema, err := models.SchemaFor(reflect.TypeFor[T]())
+	if err != nil {
+		return Tool{}, fmt.Errorf("error creating tool %s: %w", name, err)
+	}
+
+	return New(name, description, schema, func(ctx context.Context, data json.RawMessage) (*Result, error) {
+		var input T
+		if err := json.Unmarshal(data, &input); err != nil {
+			return Error(fmt.Sprintf("invalid input: %v", err)), nil
+		}
+		return fn(ctx, input)
+	}), nil
+}
+
+// Registry holds the tools available to a Runner
+type Registry struct {
+	tools map[string]Tool
+	order []string
+
+	// PropagatePanics lets panics of handlers propagate instead of reporting
+	// them to the model as failed results
+	PropagatePanics bool
+
+	// OnPanic is called with the *anthropic.PanicError of a panicking handler,
+	// which carries its stack trace, when set. A panic in OnPanic is added to
+	// the failed result
+	OnPanic func(call Call, err error)
+}
+
+// NewRegistry creates a registry with the given tools
+func NewRegistry(tools ...Tool) *Registry {
+	registry := &Registry{tools: make(map[string]Tool)}
+	for _, tool := range tools {
+		registry.Register(tool)
+	}
+	return registry
+}
+
+// Register adds a tool, replacing any tool with the same name
+func (r *Registry) Register(tool Tool) {
+	name := tool.Definition.Name
+	if _, ok := r.tools[name]; !ok {
+		r.order = append(r.order, name)
+	}
+	r.tools[name] = tool
+}
+
+// Get returns the tool with the given name
+func (r *Registry) Get(name string) (Tool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Definitions returns the definitions of all tools in registration order
+func (r *Registry) Definitions() []models.Tool {
+	definitions := make([]models.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		definitions = append(definitions, r.tools[name].Definition)
+	}
+	return definitions
+}
+
+// Call is a tool call made by the model
+type Call struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+// CallFor returns the call of a tool use block
+func CallFor(block *models.ToolUseBlock) (Call, error) {
+	call := Call{ID: block.ID, Name: block.Name, Input: json.RawMessage("{}")}
+	switch input := block.Input.(type) {
+	case nil:
+	case json.RawMessage:
+		call.Input = input
+	default:
+		data, err := json.Marshal(input)
+		if err != nil {
+			return Call{}, fmt.Errorf("error encoding input of tool call %s: %w", block.ID, err)
+		}
+		call.Input = data
+	}
+	return call, nil
+}
+
+// Execute runs a tool call and returns its tool result block. Unknown tools
+// and handler errors are reported to the model as failed results
+func (r *Registry) Execute(ctx context.Context, call Call) models.ContentBlock {
+	return r.call(ctx, call).block(call.ID)
+}
+
+// call runs a tool call, converting unknown tools, handler errors and panics
+// to failed results
+func (r *Registry) call(ctx context.Context, call Call) *Result {
+	result, err := r.invoke(ctx, call, 0)
+	if err != nil {
+		return Error(err.Error())
+	}
+	return result
+}
+
+// invoke runs a tool call, converting handler errors and panics to failed
+// results. Unknown tools fail with ErrToolNotFound, and calls running longer
+// than a positive timeout with ErrToolTimeout
+func (r *Registry) invoke(ctx context.Context, call Call, timeout time.Duration) (*Result, error) {
+	tool, ok := r.Get(call.Name)
+	if !ok || tool.Handler == nil {
+		return nil, fmt.Errorf("%w: %q", ErrToolNotFound, call.Name)
+	}
+	if timeout <= 0 {
+		return r.handle(ctx, tool, call), nil
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan *Result, 1)
+	go func() {
+		done <- r.handle(callCtx, tool, call)
+	}()
+
+	select {
+	case result := <-done:
+		if ctx.Err() == nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrToolTimeout, call.Name, timeout)
+		}
+		return result, nil
+	case <-callCtx.Done():
+		if ctx.Err() != nil {
+			return Error(ctx.Err().Error()), nil
+		}
+		return nil, fmt.Errorf("%w: %s after %s", ErrToolTimeout, call.Name, timeout)
+	}
+}
+
+// handle runs the handler of a tool, converting errors and panics to failed
+// results
+func (r *Registry) handle(ctx context.Context, tool Tool, call Call) *Result {
+	var result *Result
+	err := safe.Guard{Propagate: r.PropagatePanics}.Call("tool handler", func() error {
+		var err error
+		result, err = tool.Handler(ctx, call.Input)
+		return err
+	})
+	var panicErr *safe.PanicError
+	if errors.As(err, &panicErr) && r.OnPanic != nil {
+		callbackErr := safe.Guard{Propagate: r.PropagatePanics}.Notify("panic callback", func() {
+			r.OnPanic(call, panicErr)
+		})
+		err = errors.Join(err, callbackErr)
+	}
+	if err != nil {
+		return Error(err.Error())
+	}
+	if result == nil {
+		return Text("")
+	}
+	return result
+}
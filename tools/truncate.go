@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+	"github.com/joakimcarlsson/anthropic-sdk/summarize"
+)
+
+// TruncateStrategy selects how oversized tool results are shortened
+type TruncateStrategy int
+
+const (
+	// TruncateHeadTail keeps the beginning and the end of the result
+	TruncateHeadTail TruncateStrategy = iota
+
+	// TruncateHead keeps the beginning of the result
+	TruncateHead
+
+	// TruncateSummarize replaces the result with a summary, falling back to
+	// TruncateHeadTail when summarizing fails
+	TruncateSummarize
+)
+
+// charsPerToken converts token budgets to characters, matching
+// models.EstimateTokens
+const charsPerToken = 3.5
+
+// Truncation limits the size of tool results before they are added to the
+// history, so a single verbose tool cannot fill the context window
+type Truncation struct {
+	// MaxTokens is the maximum estimated size of a result
+	MaxTokens int
+
+	Strategy TruncateStrategy
+
+	// Summarizer summarizes results with TruncateSummarize
+	Summarizer *summarize.Summarizer
+}
+
+// Apply returns content shortened to the token budget
+func (t *Truncation) Apply(ctx context.Context, content string) string {
+	if t == nil || t.MaxTokens <= 0 {
+		return content
+	}
+	tokens := models.EstimateTokens(content)
+	if tokens <= t.MaxTokens {
+		return content
+	}
+
+	switch t.Strategy {
+	case TruncateHead:
+		return truncateHead(content, t.MaxTokens, tokens)
+	case TruncateSummarize:
+		if summary, err := t.summarize(ctx, content); err == nil {
+			return summary
+		}
+	}
+	return truncateHeadTail(content, t.MaxTokens, tokens)
+}
+
+// summarize summarizes content within the token budget
+func (t *Truncation) summarize(ctx context.Context, content string) (string, error) {
+	if t.Summarizer == nil {
+		return "", fmt.Errorf("error summarizing tool result: no summarizer")
+	}
+
+	summarizer := *t.Summarizer
+	summarizer.MaxTokens = t.MaxTokens
+	result, err := summarizer.Summarize(ctx, content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("[summary of a %d token tool result]\n%s", models.EstimateTokens(content), result.Summary), nil
+}
+
+// truncateHead keeps the beginning of content
+func truncateHead(content string, maxTokens, tokens int) string {
+	runes := []rune(content)
+	keep := min(int(float64(maxTokens)*charsPerToken), len(runes))
+	return fmt.Sprintf("%s\n[... truncated %d of %d tokens]", string(runes[:keep]), tokens-maxTokens, tokens)
+}
+
+// truncateHeadTail keeps the beginning and the end of content
+func truncateHeadTail(content string, maxTokens, tokens int) string {
+	runes := []rune(content)
+	keep := min(int(float64(maxTokens)*charsPerToken), len(runes))
+	head := keep / 2
+	tail := keep - head
+	return fmt.Sprintf("%s\n[... truncated %d of %d tokens ...]\n%s",
+		string(runes[:head]), tokens-maxTokens, tokens, string(runes[len(runes)-tail:]))
+}
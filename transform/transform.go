@@ -0,0 +1,142 @@
+// Package transform rewrites text with Claude, for example to translate it,
+// change its tone or fix its grammar. Long documents are split into chunks
+// that are transformed one by one, and a glossary keeps terminology consistent
+// across chunks
+package transform
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	anthropic "github.com/joakimcarlsson/anthropic-sdk"
+	"github.com/joakimcarlsson/anthropic-sdk/internal/textchunk"
+	"github.com/joakimcarlsson/anthropic-sdk/models"
+)
+
+const (
+	// DefaultChunkTokens is the default chunk size in estimated tokens
+	DefaultChunkTokens = 2000
+
+	// DefaultMaxTokens is the default response limit of each request
+	DefaultMaxTokens = 4096
+)
+
+// Transformer applies an instruction to text
+type Transformer struct {
+	Client anthropic.ChatProvider
+	Model  string
+
+	// Instruction describes the transformation, such as "Translate the text
+	// to German"
+	Instruction string
+
+	// Glossary maps terms to the translation or wording that must be used
+	Glossary map[string]string
+
+	// DoNotTranslate lists terms that must be kept as they are, such as
+	// product names
+	DoNotTranslate []string
+
+	// ChunkTokens is the maximum size of a chunk in estimated tokens
+	ChunkTokens int
+
+	// MaxTokens limits the response of each request
+	MaxTokens int
+}
+
+// Result is the transformed text
+type Result struct {
+	Text string
+
+	// Chunks is the number of chunks the input was split into
+	Chunks int
+
+	// Usage is the combined usage of all requests
+	Usage models.Usage
+}
+
+// New creates a transformer with a custom instruction
+func New(client anthropic.ChatProvider, model, instruction string) *Transformer {
+	return &Transformer{Client: client, Model: model, Instruction: instruction}
+}
+
+// Translate creates a transformer translating text into a language
+func Translate(client anthropic.ChatProvider, model, language string) *Transformer {
+	return New(client, model, fmt.Sprintf("Translate the text into %s.", language))
+}
+
+// Rewrite creates a transformer rewriting text in a tone, such as "formal" or
+// "friendly"
+func Rewrite(client anthropic.ChatProvider, model, tone string) *Transformer {
+	return New(client, model, fmt.Sprintf("Rewrite the text in a %s tone, keeping its meaning.", tone))
+}
+
+// FixGrammar creates a transformer fixing spelling and grammar
+func FixGrammar(client anthropic.ChatProvider, model string) *Transformer {
+	return New(client, model, "Fix the spelling and grammar of the text without changing its meaning or style.")
+}
+
+// Transform transforms the text, chunk by chunk for long texts
+func (t *Transformer) Transform(ctx context.Context, text string) (*Result, error) {
+	chunkTokens := t.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = DefaultChunkTokens
+	}
+	maxTokens := t.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+
+	chunks := textchunk.Split(text, chunkTokens, models.EstimateTokens)
+	result := &Result{Chunks: len(chunks)}
+	system := t.System()
+
+	parts := make([]string, 0, len(chunks))
+	for i, chunk := range chunks {
+		resp, err := t.Client.CreateMessage(ctx, models.MessageRequest{
+			Model:     t.Model,
+			System:    system,
+			MaxTokens: maxTokens,
+			Messages:  []models.MessageParam{models.NewUserMessage(models.CreateTextBlock(chunk))},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error transforming chunk %d: %w", i, err)
+		}
+		result.Usage = result.Usage.Add(resp.Usage)
+		parts = append(parts, strings.TrimSpace(resp.Text()))
+	}
+
+	result.Text = strings.Join(parts, "\n\n")
+	return result, nil
+}
+
+// System returns the system prompt with the instruction and glossary
+func (t *Transformer) System() string {
+	var b strings.Builder
+	b.WriteString(t.Instruction)
+	b.WriteString("\nRespond with the resulting text only, without comments or explanations. The text may be part of a longer document.")
+
+	if len(t.Glossary) > 0 {
+		terms := make([]string, 0, len(t.Glossary))
+		for term := range t.Glossary {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+
+		b.WriteString("\n\nAlways use the following glossary:")
+		for _, term := range terms {
+			fmt.Fprintf(&b, "\n- %s: %s", term, t.Glossary[term])
+		}
+	}
+
+	if len(t.DoNotTranslate) > 0 {
+		b.WriteString("\n\nKeep the following terms exactly as they are:")
+		for _, term := range t.DoNotTranslate {
+			fmt.Fprintf(&b, "\n- %s", term)
+		}
+	}
+
+	return b.String()
+}
@@ -2,19 +2,15 @@ package anthropic
 
 import (
 	"bytes"
-	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 )
 
-// setJSONBody sets the JSON body of a request
-func setJSONBody(req *http.Request, body interface{}) error {
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("error marshaling request body: %w", err)
+// setBody sets the body of a request, allowing it to be replayed on redirects
+func setBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
 	}
-	req.Body = io.NopCloser(bytes.NewBuffer(jsonBody))
-	req.ContentLength = int64(len(jsonBody))
-	return nil
 }
@@ -0,0 +1,162 @@
+package xmltag
+
+import (
+	"strings"
+
+	"github.com/joakimcarlsson/anthropic-sdk/streaming"
+)
+
+// Stream extracts the elements with a name from text arriving in chunks, such
+// as the text deltas of a streamed response. Text that may be the start of a
+// tag is held back until the next chunk decides it
+type Stream struct {
+	Name string
+
+	// OnText receives the content of elements as it arrives when set
+	OnText func(text string)
+
+	// OnElement receives every element once it is closed when set
+	OnElement func(element Element)
+
+	pending  string
+	depth    int
+	current  *Element
+	content  strings.Builder
+	elements []Element
+}
+
+// NewStream creates a stream extracting the elements with the name
+func NewStream(name string) *Stream {
+	return &Stream{Name: name}
+}
+
+// Write processes the next chunk of text
+func (s *Stream) Write(chunk string) {
+	s.pending += chunk
+	for s.pending != "" {
+		open := strings.IndexByte(s.pending, '<')
+		if open < 0 {
+			s.text(s.pending)
+			s.pending = ""
+			return
+		}
+		s.text(s.pending[:open])
+		s.pending = s.pending[open:]
+
+		end := strings.IndexByte(s.pending, '>')
+		if end < 0 && s.partialTag(s.pending) {
+			return
+		}
+		var t tag
+		ok := end >= 0
+		if ok {
+			t, ok = parseTag(s.pending[:end+1], s.Name)
+		}
+		if !ok {
+			s.text(s.pending[:1])
+			s.pending = s.pending[1:]
+			continue
+		}
+
+		raw := s.pending[:end+1]
+		s.pending = s.pending[end+1:]
+		s.tag(t, raw)
+	}
+}
+
+// Close flushes held back text and delivers an unclosed element
+func (s *Stream) Close() {
+	if s.pending != "" {
+		s.text(s.pending)
+		s.pending = ""
+	}
+	if s.current != nil {
+		s.emit(false)
+	}
+}
+
+// Elements returns the elements delivered so far
+func (s *Stream) Elements() []Element {
+	return s.elements
+}
+
+// tag handles a tag with the stream's name
+func (s *Stream) tag(t tag, raw string) {
+	switch {
+	case t.closing:
+		if s.depth == 0 {
+			return
+		}
+		s.depth--
+		if s.depth == 0 {
+			s.emit(true)
+			return
+		}
+	case t.selfClosing:
+		if s.depth == 0 {
+			s.current = &Element{Name: s.Name, Attrs: t.attrs}
+			s.emit(true)
+			return
+		}
+	default:
+		s.depth++
+		if s.depth == 1 {
+			s.current = &Element{Name: s.Name, Attrs: t.attrs}
+			return
+		}
+	}
+	s.text(raw)
+}
+
+// text adds text to the current element
+func (s *Stream) text(text string) {
+	if s.depth == 0 || text == "" {
+		return
+	}
+	s.content.WriteString(text)
+	if s.OnText != nil {
+		s.OnText(text)
+	}
+}
+
+// emit delivers the current element
+func (s *Stream) emit(closed bool) {
+	element := *s.current
+	element.Content = s.content.String()
+	element.Closed = closed
+	s.current = nil
+	s.content.Reset()
+	s.depth = 0
+
+	s.elements = append(s.elements, element)
+	if s.OnElement != nil {
+		s.OnElement(element)
+	}
+}
+
+// partialTag reports whether s, which starts with '<' and has no '>', may
+// still become a tag with the stream's name
+func (s *Stream) partialTag(text string) bool {
+	rest := strings.TrimPrefix(text[1:], "/")
+	if len(rest) <= len(s.Name) {
+		return strings.HasPrefix(s.Name, rest)
+	}
+	if !strings.HasPrefix(rest, s.Name) {
+		return false
+	}
+	next := rest[len(s.Name)]
+	return isSpace(next) || next == '/'
+}
+
+// Consume writes the text deltas of the stream to s and closes it once the
+// stream ends. It returns the stream's error
+func Consume(stream streaming.EventStream, s *Stream) error {
+	for stream.Next() {
+		event := stream.Current()
+		if event.Type == streaming.ContentBlockDeltaEvent && event.Delta != nil && event.Delta.Type == "text_delta" {
+			s.Write(event.Delta.Text)
+		}
+	}
+	s.Close()
+	return stream.Err()
+}
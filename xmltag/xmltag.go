@@ -0,0 +1,204 @@
+// Package xmltag extracts the content of XML-style tags such as <answer> from
+// model responses, as prompts commonly ask for output wrapped in tags. The
+// surrounding text does not need to be XML, and tags of other names inside an
+// element are kept as part of its content
+package xmltag
+
+import (
+	"strings"
+)
+
+// Element is an occurrence of a tag
+type Element struct {
+	Name  string
+	Attrs map[string]string
+
+	// Content is the text between the opening and closing tag, including
+	// nested tags
+	Content string
+
+	// Closed reports whether the closing tag was found. Unclosed elements,
+	// for example of a response cut off by max_tokens, run to the end of the
+	// text
+	Closed bool
+}
+
+// Find returns the content of the first element with the name
+func Find(text, name string) (string, bool) {
+	elements := Elements(text, name)
+	if len(elements) == 0 {
+		return "", false
+	}
+	return elements[0].Content, true
+}
+
+// FindAll returns the contents of all elements with the name. Elements nested
+// in an element of the same name are part of its content rather than returned
+// separately
+func FindAll(text, name string) []string {
+	var contents []string
+	for _, element := range Elements(text, name) {
+		contents = append(contents, element.Content)
+	}
+	return contents
+}
+
+// FindPath returns the contents of the elements reached by descending through
+// the named tags, such as FindPath(text, "results", "item")
+func FindPath(text string, names ...string) []string {
+	contents := []string{text}
+	for _, name := range names {
+		var next []string
+		for _, content := range contents {
+			next = append(next, FindAll(content, name)...)
+		}
+		contents = next
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return contents
+}
+
+// Elements returns all elements with the name in order, with the same nesting
+// rules as FindAll
+func Elements(text, name string) []Element {
+	var elements []Element
+	var current *Element
+	depth, contentStart := 0, 0
+
+	for pos := 0; pos < len(text); {
+		open := strings.IndexByte(text[pos:], '<')
+		if open < 0 {
+			break
+		}
+		open += pos
+		end := strings.IndexByte(text[open:], '>')
+		if end < 0 {
+			break
+		}
+		end += open + 1
+
+		t, ok := parseTag(text[open:end], name)
+		if !ok {
+			// The '>' may belong to a later tag, continue after the '<'
+			pos = open + 1
+			continue
+		}
+		pos = end
+
+		switch {
+		case t.closing:
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				current.Content = text[contentStart:open]
+				current.Closed = true
+				elements = append(elements, *current)
+				current = nil
+			}
+		case t.selfClosing:
+			if depth == 0 {
+				elements = append(elements, Element{Name: name, Attrs: t.attrs, Closed: true})
+			}
+		default:
+			if depth == 0 {
+				current = &Element{Name: name, Attrs: t.attrs}
+				contentStart = end
+			}
+			depth++
+		}
+	}
+
+	if current != nil {
+		current.Content = text[contentStart:]
+		elements = append(elements, *current)
+	}
+	return elements
+}
+
+// tag is a parsed tag
+type tag struct {
+	closing     bool
+	selfClosing bool
+	attrs       map[string]string
+}
+
+// parseTag parses s, which runs from '<' to '>', as a tag with the name
+func parseTag(s, name string) (tag, bool) {
+	inner := s[1 : len(s)-1]
+	t := tag{}
+	if strings.HasPrefix(inner, "/") {
+		t.closing = true
+		inner = inner[1:]
+	}
+	if !strings.HasPrefix(inner, name) {
+		return tag{}, false
+	}
+	rest := inner[len(name):]
+	if rest != "" && !isSpace(rest[0]) && rest != "/" {
+		return tag{}, false
+	}
+	if t.closing {
+		return t, strings.TrimSpace(rest) == ""
+	}
+
+	if strings.HasSuffix(rest, "/") {
+		t.selfClosing = true
+		rest = rest[:len(rest)-1]
+	}
+	t.attrs = parseAttrs(rest)
+	return t, true
+}
+
+// parseAttrs parses attributes with double, single or no quotes
+func parseAttrs(s string) map[string]string {
+	var attrs map[string]string
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return attrs
+		}
+
+		i := strings.IndexAny(s, "= \t\r\n")
+		if i < 0 {
+			i = len(s)
+		}
+		key := s[:i]
+		s = strings.TrimLeft(s[i:], " \t\r\n")
+
+		value := ""
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t\r\n")
+			if s != "" && (s[0] == '"' || s[0] == '\'') {
+				end := strings.IndexByte(s[1:], s[0])
+				if end < 0 {
+					end = len(s) - 1
+				}
+				value = s[1 : end+1]
+				s = s[min(end+2, len(s)):]
+			} else {
+				end := strings.IndexAny(s, " \t\r\n")
+				if end < 0 {
+					end = len(s)
+				}
+				value = s[:end]
+				s = s[end:]
+			}
+		}
+
+		if key != "" {
+			if attrs == nil {
+				attrs = make(map[string]string)
+			}
+			attrs[key] = value
+		}
+	}
+}
+
+// isSpace reports whether c is whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}